@@ -28,6 +28,7 @@ import (
 	"github.com/google/syzkaller/pkg/debugtracer"
 	"github.com/google/syzkaller/pkg/email"
 	"github.com/google/syzkaller/pkg/hash"
+	crash_pkg "github.com/google/syzkaller/pkg/report/crash"
 	"github.com/google/syzkaller/pkg/subsystem"
 	"github.com/google/syzkaller/sys/targets"
 	"google.golang.org/appengine/v2"
@@ -794,7 +795,7 @@ func reportCrash(c context.Context, build *Build, req *dashapi.Crash) (*Bug, err
 		for i, t := range req.AltTitles {
 			req.AltTitles[i] = normalizeCrashTitle(t)
 		}
-		req.AltTitles = mergeStringList([]string{req.Title}, req.AltTitles) // dedup
+		req.AltTitles = mergeStringList(crashLookupTitles(req.Title), req.AltTitles) // dedup
 	}
 	req.Maintainers = email.MergeEmailLists(req.Maintainers)
 
@@ -1057,7 +1058,7 @@ func apiReportFailedRepro(c context.Context, ns string, r *http.Request, payload
 	}
 	req.Title = canonicalizeCrashTitle(req.Title, req.Corrupted, req.Suppressed)
 
-	bug, err := findExistingBugForCrash(c, ns, []string{req.Title})
+	bug, err := findExistingBugForCrash(c, ns, crashLookupTitles(req.Title))
 	if err != nil {
 		return nil, err
 	}
@@ -1135,7 +1136,7 @@ func apiNeedRepro(c context.Context, ns string, r *http.Request, payload []byte)
 	}
 	req.Title = canonicalizeCrashTitle(req.Title, req.Corrupted, req.Suppressed)
 
-	bug, err := findExistingBugForCrash(c, ns, []string{req.Title})
+	bug, err := findExistingBugForCrash(c, ns, crashLookupTitles(req.Title))
 	if err != nil {
 		return nil, err
 	}
@@ -1167,9 +1168,20 @@ func canonicalizeCrashTitle(title string, corrupted, suppressed bool) string {
 		// e.g. if there are some spikes in suppressed reports.
 		return suppressedReportTitle
 	}
+	// Data races can be reported with the racing functions in either order.
+	title, _ = crash_pkg.RaceTitles(title)
 	return normalizeCrashTitle(title)
 }
 
+// crashLookupTitles returns titles under which bugs for the canonical crash title may exist.
+// Data races could be reported with the reverse order of the racing functions before.
+func crashLookupTitles(title string) []string {
+	if _, reverse := crash_pkg.RaceTitles(title); reverse != "" {
+		return []string{title, normalizeCrashTitle(reverse)}
+	}
+	return []string{title}
+}
+
 func normalizeCrashTitle(title string) string {
 	return strings.TrimSpace(limitLength(title, maxTextLen))
 }
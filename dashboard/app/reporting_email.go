@@ -658,6 +658,10 @@ func handleBugCommand(c context.Context, bugInfo *bugInfoResult, msg *email.Emai
 			if command.Command != email.CmdUnknown {
 				log.Errorf(c, "unknown email command %v %q", command.Command, command.Str)
 			}
+			if command.Error != nil {
+				// This includes a suggestion for misspelled commands.
+				return command.Error.Error()
+			}
 			return fmt.Sprintf("unknown command %q", command.Str)
 		}
 	}
@@ -790,6 +790,32 @@ func TestAltTitles7(t *testing.T) {
 	c.expectEQ(rep.Log, crash2.Log)
 }
 
+func TestAltTitlesDataRace(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build := testBuild(1)
+	c.client.UploadBuild(build)
+
+	// Data races are deduplicated by the unordered pair of the racing functions
+	// even if the manager does not canonicalize the title.
+	crash1 := testCrash(build, 1)
+	crash1.Title = "KCSAN: data-race in foo / bar"
+	c.client.ReportCrash(crash1)
+	rep := c.client.pollBug()
+	c.expectEQ(rep.Title, "KCSAN: data-race in bar / foo")
+
+	crash2 := testCrashWithRepro(build, 2)
+	crash2.Title = "KCSAN: data-race in bar / foo"
+	c.client.ReportCrash(crash2)
+	rep = c.client.pollBug()
+	c.expectEQ(rep.Title, "KCSAN: data-race in bar / foo")
+	c.expectEQ(rep.Log, crash2.Log)
+
+	needRepro, _ := c.client.NeedRepro(testCrashID(crash1))
+	c.expectEQ(needRepro, false)
+}
+
 func TestDetachExternalTracker(t *testing.T) {
 	c := NewCtx(t)
 	defer c.Close()
@@ -0,0 +1,679 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package lite
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/log"
+)
+
+type APIHandler func(srv *Server, payload []byte) (interface{}, error)
+
+var apiHandlers = map[string]APIHandler{
+	"log_error":           (*Server).apiLogError,
+	"builder_poll":        (*Server).apiBuilderPoll,
+	"upload_build":        (*Server).apiUploadBuild,
+	"report_build_error":  (*Server).apiReportBuildError,
+	"add_build_assets":    (*Server).apiAddBuildAssets,
+	"needed_assets":       (*Server).apiNeededAssets,
+	"commit_poll":         (*Server).apiCommitPoll,
+	"upload_commits":      (*Server).apiUploadCommits,
+	"report_crash":        (*Server).apiReportCrash,
+	"need_repro":          (*Server).apiNeedRepro,
+	"report_failed_repro": (*Server).apiReportFailedRepro,
+	"log_to_repro":        (*Server).apiLogToRepro,
+	"job_poll":            (*Server).apiJobPoll,
+	"job_done":            (*Server).apiJobDone,
+	"job_reset":           (*Server).apiJobReset,
+	"manager_stats":       (*Server).apiManagerStats,
+	"bug_list":            (*Server).apiBugList,
+	"load_bug":            (*Server).apiLoadBug,
+}
+
+const (
+	maxReproPerBug   = 10
+	reproRetryPeriod = 24 * time.Hour // try 1 repro per day until we have at least syz repro
+	reproStalePeriod = 100 * 24 * time.Hour
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+func (srv *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	reply, err := srv.serveAPI(r)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errUnauthorized) {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reply); err != nil {
+		log.Logf(0, "failed to encode reply: %v", err)
+	}
+}
+
+func (srv *Server) serveAPI(r *http.Request) (interface{}, error) {
+	client := r.PostFormValue("client")
+	method := r.PostFormValue("method")
+	if key, ok := srv.keys[client]; !ok || key != r.PostFormValue("key") {
+		return nil, fmt.Errorf("%w client %q", errUnauthorized, client)
+	}
+	handler := apiHandlers[method]
+	if handler == nil {
+		return nil, fmt.Errorf("unknown api method %q", method)
+	}
+	var payload []byte
+	if str := r.PostFormValue("payload"); str != "" {
+		gr, err := gzip.NewReader(strings.NewReader(str))
+		if err != nil {
+			return nil, fmt.Errorf("failed to ungzip payload: %w", err)
+		}
+		payload, err = io.ReadAll(gr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to ungzip payload: %w", err)
+		}
+		if err := gr.Close(); err != nil {
+			return nil, fmt.Errorf("failed to ungzip payload: %w", err)
+		}
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	reply, err := handler(srv, payload)
+	if err != nil {
+		log.Logf(0, "api %q from %q failed: %v", method, client, err)
+	}
+	return reply, err
+}
+
+func unmarshal(payload []byte, req interface{}) error {
+	if err := json.Unmarshal(payload, req); err != nil {
+		return fmt.Errorf("failed to unmarshal request: %w", err)
+	}
+	return nil
+}
+
+func (srv *Server) apiLogError(payload []byte) (interface{}, error) {
+	req := new(dashapi.LogEntry)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	log.Logf(0, "%v: %v", req.Name, req.Text)
+	return nil, nil
+}
+
+func (srv *Server) apiBuilderPoll(payload []byte) (interface{}, error) {
+	req := new(dashapi.BuilderPollReq)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	resp := &dashapi.BuilderPollResp{
+		ReportEmail: srv.cfg.ReportEmail,
+	}
+	pending := make(map[string]bool)
+	for _, bug := range srv.st.sortedBugs() {
+		if bug.Status != dashapi.BugStatusOpen || stringInList(bug.PatchedOn, req.Manager) {
+			continue
+		}
+		for _, com := range bug.Commits {
+			if !pending[com] {
+				pending[com] = true
+				resp.PendingCommits = append(resp.PendingCommits, com)
+			}
+		}
+	}
+	return resp, nil
+}
+
+func (srv *Server) apiUploadBuild(payload []byte) (interface{}, error) {
+	req := new(dashapi.Build)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	return nil, srv.uploadBuild(req)
+}
+
+func (srv *Server) uploadBuild(req *dashapi.Build) error {
+	if req.ID == "" || req.Manager == "" {
+		return fmt.Errorf("build ID and manager must not be empty")
+	}
+	now := srv.now()
+	if srv.st.Builds[req.ID] == nil {
+		build := &Build{
+			Build: *req,
+			Time:  now,
+		}
+		if err := srv.st.saveBuild(build); err != nil {
+			return err
+		}
+		srv.st.Builds[req.ID] = build
+	}
+	mgr := srv.st.manager(req.Manager)
+	mgr.LastActive = now
+	mgr.LastBuild = req.ID
+	if err := srv.st.saveManager(mgr); err != nil {
+		return err
+	}
+	return srv.addCommitsToBugs(req.Manager, req.Commits, req.FixCommits)
+}
+
+// addCommitsToBugs attaches fixing commits (identified by Reported-by tags) to bugs and marks bugs
+// as fixed once all their fixing commits reach all managers where the bug happened.
+// titles are titles of fixing commits present in the latest build on the manager.
+func (srv *Server) addCommitsToBugs(manager string, titles []string, fixCommits []dashapi.Commit) error {
+	now := srv.now()
+	for _, bug := range srv.st.sortedBugs() {
+		if bug.Status != dashapi.BugStatusOpen {
+			continue
+		}
+		changed := false
+		for _, com := range fixCommits {
+			if stringInList(com.BugIDs, bug.ID) && !stringInList(bug.Commits, com.Title) {
+				bug.Commits = append(bug.Commits, com.Title)
+				// New commits need to reach all managers again.
+				bug.PatchedOn = nil
+				changed = true
+			}
+		}
+		if len(bug.Commits) == 0 {
+			if changed {
+				if err := srv.st.saveBug(bug); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		patched := true
+		for _, com := range bug.Commits {
+			patched = patched && stringInList(titles, com)
+		}
+		if patched && !stringInList(bug.PatchedOn, manager) {
+			bug.PatchedOn = append(bug.PatchedOn, manager)
+			changed = true
+		}
+		fixed := true
+		for _, mgr := range bug.HappenedOn {
+			fixed = fixed && stringInList(bug.PatchedOn, mgr)
+		}
+		if fixed {
+			bug.Status = dashapi.BugStatusFixed
+			bug.FixTime = now
+			changed = true
+		}
+		if changed {
+			if err := srv.st.saveBug(bug); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (srv *Server) apiReportBuildError(payload []byte) (interface{}, error) {
+	req := new(dashapi.BuildErrorReq)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	if err := srv.uploadBuild(&req.Build); err != nil {
+		return nil, err
+	}
+	req.Crash.BuildID = req.Build.ID
+	_, err := srv.reportCrash(&req.Crash)
+	return nil, err
+}
+
+func (srv *Server) apiAddBuildAssets(payload []byte) (interface{}, error) {
+	req := new(dashapi.AddBuildAssetsReq)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	build := srv.st.Builds[req.BuildID]
+	if build == nil {
+		return nil, fmt.Errorf("unknown build %v", req.BuildID)
+	}
+	build.Assets = append(build.Assets, req.Assets...)
+	return nil, srv.st.saveBuild(build)
+}
+
+func (srv *Server) apiNeededAssets(payload []byte) (interface{}, error) {
+	resp := new(dashapi.NeededAssetsResp)
+	for _, build := range srv.st.Builds {
+		for _, asset := range build.Assets {
+			resp.DownloadURLs = append(resp.DownloadURLs, asset.DownloadURL)
+		}
+	}
+	return resp, nil
+}
+
+func (srv *Server) apiCommitPoll(payload []byte) (interface{}, error) {
+	// We don't poll kernel repos for fixing commits, syz-ci reports them with builds.
+	resp := &dashapi.CommitPollResp{
+		ReportEmail: srv.cfg.ReportEmail,
+	}
+	return resp, nil
+}
+
+func (srv *Server) apiUploadCommits(payload []byte) (interface{}, error) {
+	return nil, nil
+}
+
+func (srv *Server) apiReportCrash(payload []byte) (interface{}, error) {
+	req := new(dashapi.Crash)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	bug, err := srv.reportCrash(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &dashapi.ReportCrashResp{
+		NeedRepro: srv.needRepro(bug),
+	}
+	return resp, nil
+}
+
+func (srv *Server) reportCrash(req *dashapi.Crash) (*Bug, error) {
+	build := srv.st.Builds[req.BuildID]
+	if build == nil {
+		return nil, fmt.Errorf("unknown build %v", req.BuildID)
+	}
+	title := canonicalizeCrashTitle(req.Title, req.Corrupted, req.Suppressed)
+	titles := []string{title}
+	if !req.Corrupted && !req.Suppressed {
+		for _, alt := range req.AltTitles {
+			titles = mergeString(titles, strings.TrimSpace(alt))
+		}
+	}
+	now := srv.now()
+	bug := srv.st.findBug(titles)
+	if bug == nil {
+		bug = srv.st.createBug(title, now)
+	}
+	crash := &Crash{
+		Manager:     build.Manager,
+		BuildID:     build.ID,
+		Time:        now,
+		Title:       title,
+		Log:         req.Log,
+		Report:      req.Report,
+		MachineInfo: req.MachineInfo,
+		ReproOpts:   req.ReproOpts,
+		ReproSyz:    req.ReproSyz,
+		ReproC:      req.ReproC,
+	}
+	reproLevel := crash.reproLevel()
+	bug.addCrash(crash)
+	bug.LastTime = now
+	bug.NumCrashes++
+	if reproLevel != dashapi.ReproLevelNone {
+		bug.NumRepro++
+		bug.LastReproTime = now
+	}
+	bug.ReproLevel = max(bug.ReproLevel, reproLevel)
+	bug.HappenedOn = mergeString(bug.HappenedOn, build.Manager)
+	for _, title := range titles {
+		if title != bug.Title {
+			bug.AltTitles = mergeString(bug.AltTitles, title)
+		}
+	}
+	if err := srv.st.saveBug(bug); err != nil {
+		return nil, err
+	}
+	mgr := srv.st.manager(build.Manager)
+	mgr.LastActive = now
+	return bug, srv.st.saveManager(mgr)
+}
+
+func (srv *Server) apiNeedRepro(payload []byte) (interface{}, error) {
+	req := new(dashapi.CrashID)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	resp := new(dashapi.NeedReproResp)
+	if req.Corrupted {
+		return resp, nil
+	}
+	bug := srv.st.findBug([]string{canonicalizeCrashTitle(req.Title, req.Corrupted, req.Suppressed)})
+	if bug == nil {
+		if req.MayBeMissing {
+			// Manager does not send leak reports w/o repro to dashboard, we want to reproduce them.
+			resp.NeedRepro = true
+			return resp, nil
+		}
+		return nil, fmt.Errorf("can't find bug for crash %q", req.Title)
+	}
+	resp.NeedRepro = srv.needRepro(bug)
+	return resp, nil
+}
+
+func (srv *Server) apiReportFailedRepro(payload []byte) (interface{}, error) {
+	req := new(dashapi.CrashID)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	bug := srv.st.findBug([]string{canonicalizeCrashTitle(req.Title, req.Corrupted, req.Suppressed)})
+	if bug == nil {
+		return nil, fmt.Errorf("can't find bug for crash %q", req.Title)
+	}
+	bug.NumRepro++
+	bug.LastReproTime = srv.now()
+	return nil, srv.st.saveBug(bug)
+}
+
+func (srv *Server) apiLogToRepro(payload []byte) (interface{}, error) {
+	// We don't ask managers to reproduce crash logs.
+	return new(dashapi.LogToReproResp), nil
+}
+
+var syzErrorTitleRe = regexp.MustCompile(`^SYZFAIL:|^SYZFATAL:`)
+
+func (srv *Server) needRepro(bug *Bug) bool {
+	if bug.Status != dashapi.BugStatusOpen || len(bug.Commits) > 0 {
+		return false
+	}
+	if bug.Title == corruptedReportTitle || bug.Title == suppressedReportTitle {
+		return false
+	}
+	bestReproLevel := dashapi.ReproLevelC
+	// For some bugs there's anyway no chance to find a C repro.
+	if syzErrorTitleRe.MatchString(bug.Title) {
+		bestReproLevel = dashapi.ReproLevelSyz
+	}
+	sinceRepro := srv.now().Sub(bug.LastReproTime)
+	if bug.ReproLevel < bestReproLevel {
+		// We have not found a best-level repro yet, try until we do.
+		return bug.NumRepro < maxReproPerBug || sinceRepro >= reproRetryPeriod
+	}
+	// When the best repro is already found, still do a repro attempt once in a while.
+	return sinceRepro >= reproStalePeriod
+}
+
+func canonicalizeCrashTitle(title string, corrupted, suppressed bool) string {
+	if corrupted {
+		return corruptedReportTitle
+	}
+	if suppressed {
+		return suppressedReportTitle
+	}
+	return strings.TrimSpace(title)
+}
+
+func (srv *Server) apiJobPoll(payload []byte) (interface{}, error) {
+	req := new(dashapi.JobPollReq)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	now := srv.now()
+	for name, mgr := range req.Managers {
+		if !mgr.Any() {
+			continue
+		}
+		m := srv.st.manager(name)
+		m.LastActive = now
+		if err := srv.st.saveManager(m); err != nil {
+			return nil, err
+		}
+	}
+	job := srv.pendingJob(req.Managers)
+	if job == nil {
+		if err := srv.createBisectJobs(req.Managers); err != nil {
+			return nil, err
+		}
+		job = srv.pendingJob(req.Managers)
+	}
+	if job == nil {
+		return new(dashapi.JobPollResp), nil
+	}
+	resp, err := srv.jobPollResp(job)
+	if err != nil {
+		return nil, err
+	}
+	job.Started = now
+	return resp, srv.st.saveJob(job)
+}
+
+func (srv *Server) pendingJob(managers map[string]dashapi.ManagerJobs) *Job {
+	for _, job := range srv.st.sortedJobs() {
+		if !job.Started.IsZero() {
+			continue
+		}
+		mgr, ok := managers[job.Manager]
+		if !ok {
+			continue
+		}
+		switch job.Type {
+		case dashapi.JobTestPatch:
+			ok = mgr.TestPatches
+		case dashapi.JobBisectCause:
+			ok = mgr.BisectCause
+		case dashapi.JobBisectFix:
+			ok = mgr.BisectFix
+		}
+		if ok {
+			return job
+		}
+	}
+	return nil
+}
+
+// createBisectJobs creates cause bisection jobs for open bugs with reproducers.
+func (srv *Server) createBisectJobs(managers map[string]dashapi.ManagerJobs) error {
+	for _, bug := range srv.st.sortedBugs() {
+		if bug.Status != dashapi.BugStatusOpen || bug.BisectCause != "" ||
+			bug.ReproLevel == dashapi.ReproLevelNone {
+			continue
+		}
+		crash := bug.bestCrash()
+		if !managers[crash.Manager].BisectCause {
+			continue
+		}
+		job, err := srv.createJob(dashapi.JobBisectCause, bug, "", "", nil)
+		if err != nil {
+			return err
+		}
+		bug.BisectCause = job.ID
+		if err := srv.st.saveBug(bug); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreatePatchTestJob creates a job to test the patch on the bug reproducer.
+// If repo and branch are empty, the patch is tested on the kernel where the bug was reproduced.
+func (srv *Server) CreatePatchTestJob(bugID, repo, branch string, patch []byte) (string, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	bug := srv.st.Bugs[bugID]
+	if bug == nil {
+		return "", fmt.Errorf("unknown bug %v", bugID)
+	}
+	if bug.ReproLevel == dashapi.ReproLevelNone {
+		return "", fmt.Errorf("the bug does not have a reproducer")
+	}
+	job, err := srv.createJob(dashapi.JobTestPatch, bug, repo, branch, patch)
+	if err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+func (srv *Server) createJob(typ dashapi.JobType, bug *Bug, repo, branch string, patch []byte) (*Job, error) {
+	crash := bug.bestCrash()
+	build := srv.st.Builds[crash.BuildID]
+	if build == nil {
+		return nil, fmt.Errorf("unknown build %v", crash.BuildID)
+	}
+	if repo == "" {
+		repo, branch = build.KernelRepo, build.KernelBranch
+	}
+	job := &Job{
+		ID:           fmt.Sprintf("%v-%v", len(srv.st.Jobs)+1, bug.ID[:8]),
+		Type:         typ,
+		BugID:        bug.ID,
+		Manager:      crash.Manager,
+		KernelRepo:   repo,
+		KernelBranch: branch,
+		Patch:        patch,
+		Created:      srv.now(),
+	}
+	if err := srv.st.saveJob(job); err != nil {
+		return nil, err
+	}
+	srv.st.Jobs[job.ID] = job
+	return job, nil
+}
+
+func (srv *Server) jobPollResp(job *Job) (*dashapi.JobPollResp, error) {
+	bug := srv.st.Bugs[job.BugID]
+	if bug == nil {
+		return nil, fmt.Errorf("job %v refers to unknown bug %v", job.ID, job.BugID)
+	}
+	crash := bug.bestCrash()
+	build := srv.st.Builds[crash.BuildID]
+	if build == nil {
+		return nil, fmt.Errorf("unknown build %v", crash.BuildID)
+	}
+	resp := &dashapi.JobPollResp{
+		ID:                job.ID,
+		Type:              job.Type,
+		Manager:           job.Manager,
+		KernelRepo:        job.KernelRepo,
+		KernelBranch:      job.KernelBranch,
+		KernelCommit:      build.KernelCommit,
+		KernelCommitTitle: build.KernelCommitTitle,
+		KernelConfig:      build.KernelConfig,
+		SyzkallerCommit:   build.SyzkallerCommit,
+		Patch:             job.Patch,
+		ReproOpts:         crash.ReproOpts,
+		ReproSyz:          crash.ReproSyz,
+		ReproC:            crash.ReproC,
+	}
+	if job.Type == dashapi.JobBisectCause {
+		// Bisection starts from the commit where the bug was reproduced.
+		resp.KernelRepo = build.KernelRepo
+		resp.KernelBranch = build.KernelBranch
+	}
+	return resp, nil
+}
+
+func (srv *Server) apiJobDone(payload []byte) (interface{}, error) {
+	req := new(dashapi.JobDoneReq)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	job := srv.st.Jobs[req.ID]
+	if job == nil {
+		return nil, fmt.Errorf("unknown job %v", req.ID)
+	}
+	if !job.Finished.IsZero() {
+		return nil, fmt.Errorf("job %v is already finished", req.ID)
+	}
+	job.Finished = srv.now()
+	job.Result = req
+	return nil, srv.st.saveJob(job)
+}
+
+func (srv *Server) apiJobReset(payload []byte) (interface{}, error) {
+	req := new(dashapi.JobResetReq)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	for _, job := range srv.st.sortedJobs() {
+		if job.Started.IsZero() || !job.Finished.IsZero() || !stringInList(req.Managers, job.Manager) {
+			continue
+		}
+		job.Started = time.Time{}
+		if err := srv.st.saveJob(job); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (srv *Server) apiManagerStats(payload []byte) (interface{}, error) {
+	req := new(dashapi.ManagerStatsReq)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	mgr := srv.st.manager(req.Name)
+	mgr.LastActive = srv.now()
+	mgr.Stats = *req
+	mgr.FuzzingTime += req.FuzzingTime
+	mgr.Crashes += req.Crashes
+	mgr.Execs += req.Execs
+	return nil, srv.st.saveManager(mgr)
+}
+
+func (srv *Server) apiBugList(payload []byte) (interface{}, error) {
+	resp := new(dashapi.BugListResp)
+	for _, bug := range srv.st.sortedBugs() {
+		resp.List = append(resp.List, bug.ID)
+	}
+	return resp, nil
+}
+
+func (srv *Server) apiLoadBug(payload []byte) (interface{}, error) {
+	req := new(dashapi.LoadBugReq)
+	if err := unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	bug := srv.st.Bugs[req.ID]
+	if bug == nil {
+		return nil, fmt.Errorf("no such bug")
+	}
+	return srv.bugReport(bug), nil
+}
+
+func (srv *Server) bugReport(bug *Bug) *dashapi.BugReport {
+	rep := &dashapi.BugReport{
+		Type:        dashapi.ReportNew,
+		BugStatus:   bug.Status,
+		ID:          bug.ID,
+		Title:       bug.Title,
+		Link:        fmt.Sprintf("%v/bug?id=%v", srv.cfg.URL, bug.ID),
+		CreditEmail: srv.cfg.ReportEmail,
+		NumCrashes:  bug.NumCrashes,
+		HappenedOn:  bug.HappenedOn,
+	}
+	crash := bug.bestCrash()
+	if crash == nil {
+		return rep
+	}
+	rep.CrashTime = crash.Time
+	rep.Manager = crash.Manager
+	rep.Log = crash.Log
+	rep.Report = crash.Report
+	rep.MachineInfo = crash.MachineInfo
+	rep.ReproOpts = crash.ReproOpts
+	rep.ReproSyz = crash.ReproSyz
+	rep.ReproC = crash.ReproC
+	if build := srv.st.Builds[crash.BuildID]; build != nil {
+		rep.OS = build.OS
+		rep.Arch = build.Arch
+		rep.VMArch = build.VMArch
+		rep.BuildID = build.ID
+		rep.BuildTime = build.Time
+		rep.CompilerID = build.CompilerID
+		rep.KernelRepo = build.KernelRepo
+		rep.KernelBranch = build.KernelBranch
+		rep.KernelCommit = build.KernelCommit
+		rep.KernelCommitTitle = build.KernelCommitTitle
+		rep.KernelCommitDate = build.KernelCommitDate
+		rep.KernelConfig = build.KernelConfig
+		rep.SyzkallerCommit = build.SyzkallerCommit
+	}
+	return rep
+}
@@ -0,0 +1,362 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package lite
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/html/pages"
+	"github.com/google/syzkaller/pkg/log"
+)
+
+// maxUIJobs limits the number of recent jobs shown on the main page.
+const maxUIJobs = 20
+
+type UIMainPage struct {
+	Name     string
+	Now      time.Time
+	Open     *UIBugList
+	Fixed    *UIBugList
+	Managers []*Manager
+	Jobs     []*UIJob
+}
+
+type UIBugList struct {
+	Caption string
+	Bugs    []*Bug
+}
+
+type UIBugPage struct {
+	Name    string
+	Bug     *Bug
+	Crashes []*UICrash
+	Jobs    []*UIJob
+}
+
+type UICrash struct {
+	*Crash
+	Index int
+}
+
+type UIJob struct {
+	*Job
+	Title   string
+	Kind    string
+	Status  string
+	Summary string
+}
+
+func (srv *Server) httpMain(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	data := &UIMainPage{
+		Name:  srv.cfg.Name,
+		Now:   srv.now(),
+		Open:  &UIBugList{Caption: "Open bugs"},
+		Fixed: &UIBugList{Caption: "Fixed bugs"},
+	}
+	for _, bug := range srv.st.sortedBugs() {
+		if bug.Status == dashapi.BugStatusOpen {
+			data.Open.Bugs = append(data.Open.Bugs, bug)
+		} else {
+			data.Fixed.Bugs = append(data.Fixed.Bugs, bug)
+		}
+	}
+	for _, mgr := range srv.st.Managers {
+		data.Managers = append(data.Managers, mgr)
+	}
+	sort.Slice(data.Managers, func(i, j int) bool {
+		return data.Managers[i].Name < data.Managers[j].Name
+	})
+	jobs := srv.st.sortedJobs()
+	for i := len(jobs) - 1; i >= 0 && len(data.Jobs) < maxUIJobs; i-- {
+		data.Jobs = append(data.Jobs, srv.makeUIJob(jobs[i]))
+	}
+	executeTemplate(w, mainTemplate, data)
+}
+
+func (srv *Server) httpBug(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	bug := srv.st.Bugs[id]
+	if bug == nil {
+		http.Error(w, "no such bug", http.StatusNotFound)
+		return
+	}
+	data := &UIBugPage{
+		Name: srv.cfg.Name,
+		Bug:  bug,
+	}
+	for i := len(bug.Crashes) - 1; i >= 0; i-- {
+		data.Crashes = append(data.Crashes, &UICrash{bug.Crashes[i], i})
+	}
+	for _, job := range srv.st.sortedJobs() {
+		if job.BugID == bug.ID {
+			data.Jobs = append(data.Jobs, srv.makeUIJob(job))
+		}
+	}
+	executeTemplate(w, bugTemplate, data)
+}
+
+func (srv *Server) makeUIJob(job *Job) *UIJob {
+	ui := &UIJob{
+		Job:    job,
+		Kind:   [...]string{"patch testing", "cause bisection", "fix bisection"}[job.Type],
+		Status: job.status(),
+	}
+	if bug := srv.st.Bugs[job.BugID]; bug != nil {
+		ui.Title = bug.Title
+	}
+	res := job.Result
+	switch {
+	case res == nil || len(res.Error) != 0:
+	case job.Type == dashapi.JobTestPatch && res.CrashTitle != "":
+		ui.Summary = res.CrashTitle
+	case job.Type == dashapi.JobTestPatch:
+		ui.Summary = "OK"
+	case len(res.Commits) == 1:
+		ui.Summary = fmt.Sprintf("%v %v %v", res.Commits[0].Hash, res.Commits[0].Title, res.Flags)
+	default:
+		ui.Summary = fmt.Sprintf("%v commits %v", len(res.Commits), res.Flags)
+	}
+	return ui
+}
+
+// httpText serves crash and job texts:
+// /text?bug=ID&crash=N&tag=log|report|machine_info|repro_syz|repro_c and
+// /text?job=ID&tag=log|error|patch|crash_log|crash_report.
+func (srv *Server) httpText(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	var text []byte
+	tag := r.FormValue("tag")
+	if jobID := r.FormValue("job"); jobID != "" {
+		job := srv.st.Jobs[jobID]
+		if job == nil {
+			http.Error(w, "no such job", http.StatusNotFound)
+			return
+		}
+		res := job.Result
+		if res == nil {
+			res = new(dashapi.JobDoneReq)
+		}
+		text = map[string][]byte{
+			"log":          res.Log,
+			"error":        res.Error,
+			"patch":        job.Patch,
+			"crash_log":    res.CrashLog,
+			"crash_report": res.CrashReport,
+		}[tag]
+	} else {
+		bug := srv.st.Bugs[r.FormValue("bug")]
+		if bug == nil {
+			http.Error(w, "no such bug", http.StatusNotFound)
+			return
+		}
+		idx, err := strconv.Atoi(r.FormValue("crash"))
+		if err != nil || idx < 0 || idx >= len(bug.Crashes) {
+			http.Error(w, "no such crash", http.StatusNotFound)
+			return
+		}
+		crash := bug.Crashes[idx]
+		text = map[string][]byte{
+			"log":          crash.Log,
+			"report":       crash.Report,
+			"machine_info": crash.MachineInfo,
+			"repro_syz":    crash.ReproSyz,
+			"repro_c":      crash.ReproC,
+		}[tag]
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(text)
+}
+
+func executeTemplate(w http.ResponseWriter, templ *template.Template, data interface{}) {
+	buf := new(bytes.Buffer)
+	if err := templ.Execute(buf, data); err != nil {
+		log.Logf(0, "failed to execute template: %v", err)
+		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf.Bytes())
+}
+
+var mainTemplate = pages.Create(`
+<!doctype html>
+<html>
+<head>
+	<title>{{.Name}} syzkaller dashboard</title>
+	{{HEAD}}
+</head>
+<body>
+<b>{{.Name}} syzkaller dashboard</b>
+<br>
+
+{{define "bug_list"}}
+<table class="list_table">
+	<caption>{{.Caption}} ({{len .Bugs}}):</caption>
+	<tr>
+		<th>Title</th>
+		<th>Repro</th>
+		<th>Count</th>
+		<th>First</th>
+		<th>Last</th>
+		<th>Managers</th>
+		<th>Fixing commits</th>
+	</tr>
+	{{range $b := .Bugs}}
+	<tr>
+		<td class="title"><a href="/bug?id={{$b.ID}}">{{$b.Title}}</a></td>
+		<td>{{formatReproLevel $b.ReproLevel}}</td>
+		<td>{{$b.NumCrashes}}</td>
+		<td class="time">{{formatTime $b.FirstTime}}</td>
+		<td class="time">{{formatTime $b.LastTime}}</td>
+		<td>{{formatList $b.HappenedOn}}</td>
+		<td>{{formatList $b.Commits}}</td>
+	</tr>
+	{{end}}
+</table>
+<br>
+{{end}}
+
+{{template "bug_list" .Open}}
+{{template "bug_list" .Fixed}}
+
+<table class="list_table">
+	<caption>Managers:</caption>
+	<tr>
+		<th>Name</th>
+		<th>Last active</th>
+		<th>Build</th>
+		<th>Fuzzing time</th>
+		<th>Corpus</th>
+		<th>Coverage</th>
+		<th>Execs</th>
+		<th>Crashes</th>
+	</tr>
+	{{range $m := .Managers}}
+	<tr>
+		<td>{{$m.Name}}</td>
+		<td>{{formatLateness $.Now $m.LastActive}}</td>
+		<td>{{$m.LastBuild}}</td>
+		<td>{{formatDuration $m.FuzzingTime}}</td>
+		<td>{{$m.Stats.Corpus}}</td>
+		<td>{{$m.Stats.PCs}}</td>
+		<td>{{$m.Execs}}</td>
+		<td>{{$m.Crashes}}</td>
+	</tr>
+	{{end}}
+</table>
+<br>
+
+{{template "job_list" .Jobs}}
+</body></html>
+` + jobListTemplate)
+
+var bugTemplate = pages.Create(`
+<!doctype html>
+<html>
+<head>
+	<title>{{.Bug.Title}}</title>
+	{{HEAD}}
+</head>
+<body>
+<a href="/">{{.Name}} syzkaller dashboard</a>
+<br>
+<b>{{.Bug.Title}}</b><br>
+Crashes: {{.Bug.NumCrashes}}, first: {{formatTime .Bug.FirstTime}}, last: {{formatTime .Bug.LastTime}}<br>
+{{if .Bug.Commits}}Fixing commits: {{formatList .Bug.Commits}}<br>{{end}}
+{{if .Bug.AltTitles}}Alternative titles: {{formatList .Bug.AltTitles}}<br>{{end}}
+<br>
+
+<table class="list_table">
+	<caption>Crashes ({{len .Crashes}}):</caption>
+	<tr>
+		<th>Time</th>
+		<th>Manager</th>
+		<th>Build</th>
+		<th>Title</th>
+		<th>Log</th>
+		<th>Report</th>
+		<th>Syz repro</th>
+		<th>C repro</th>
+	</tr>
+	{{range $c := .Crashes}}
+	<tr>
+		<td class="time">{{formatTime $c.Time}}</td>
+		<td>{{$c.Manager}}</td>
+		<td>{{$c.BuildID}}</td>
+		<td class="title">{{$c.Title}}</td>
+		<td><a href="/text?bug={{$.Bug.ID}}&crash={{$c.Index}}&tag=log">log</a></td>
+		<td>{{if $c.Report}}<a href="/text?bug={{$.Bug.ID}}&crash={{$c.Index}}&tag=report">report</a>{{end}}</td>
+		<td>{{if $c.ReproSyz}}<a href="/text?bug={{$.Bug.ID}}&crash={{$c.Index}}&tag=repro_syz">syz</a>{{end}}</td>
+		<td>{{if $c.ReproC}}<a href="/text?bug={{$.Bug.ID}}&crash={{$c.Index}}&tag=repro_c">C</a>{{end}}</td>
+	</tr>
+	{{end}}
+</table>
+<br>
+
+{{template "job_list" .Jobs}}
+
+{{if .Bug.ReproLevel}}
+<form method="post" action="/bug?id={{.Bug.ID}}">
+	<b>Test a patch:</b><br>
+	Kernel repo: <input type="text" name="repo" size="60">
+	branch: <input type="text" name="branch" size="20">
+	(empty to test on the kernel where the bug was reproduced)<br>
+	<textarea name="patch" rows="20" cols="120"></textarea><br>
+	<input type="submit" value="Test">
+</form>
+{{end}}
+</body></html>
+` + jobListTemplate)
+
+const jobListTemplate = `
+{{define "job_list"}}
+<table class="list_table">
+	<caption>Jobs:</caption>
+	<tr>
+		<th>Created</th>
+		<th>Type</th>
+		<th>Bug</th>
+		<th>Manager</th>
+		<th>Status</th>
+		<th>Result</th>
+		<th>Log</th>
+	</tr>
+	{{range $j := .}}
+	<tr>
+		<td class="time">{{formatTime $j.Created}}</td>
+		<td>{{$j.Kind}}</td>
+		<td class="title"><a href="/bug?id={{$j.BugID}}">{{$j.Title}}</a></td>
+		<td>{{$j.Manager}}</td>
+		<td>{{$j.Status}}</td>
+		<td>
+			{{if $j.Result}}{{if $j.Result.Error}}
+				<a href="/text?job={{$j.ID}}&tag=error">error</a>
+			{{else if $j.Result.CrashTitle}}
+				<a href="/text?job={{$j.ID}}&tag=crash_report">{{$j.Summary}}</a>
+			{{else}}
+				{{$j.Summary}}
+			{{end}}{{end}}
+		</td>
+		<td>{{if $j.Result}}<a href="/text?job={{$j.ID}}&tag=log">log</a>{{end}}</td>
+	</tr>
+	{{end}}
+</table>
+<br>
+{{end}}
+`
@@ -0,0 +1,74 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package lite implements a self-hosted dashboard that serves the dashapi protocol
+// (the same one dashboard/app serves) on top of a local embedded database.
+// It supports the part of the API used by syz-ci and syz-manager (builds, crashes, repros,
+// jobs and manager stats) and a minimal web UI with the bug list, so that the whole
+// pipeline can run on a private network without App Engine.
+// It does not implement bug reporting: bugs are only shown in the UI and can be queried
+// with BugList/LoadBug.
+package lite
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Name is shown in the web UI.
+	Name string
+	// URL under which the dashboard is reachable, used for bug links in LoadBug replies.
+	URL string
+	// Email that appears in Reported-by tags of fixing commits.
+	// It's passed to syz-ci to extract bug IDs from fixing commits.
+	ReportEmail string
+	// Clients that are allowed to use the API.
+	Clients []Client
+}
+
+type Client struct {
+	Name string
+	Key  string
+}
+
+type Server struct {
+	mu   sync.Mutex
+	cfg  *Config
+	st   *State
+	keys map[string]string
+	mux  *http.ServeMux
+	now  func() time.Time
+}
+
+func NewServer(cfg *Config, st *State) (*Server, error) {
+	srv := &Server{
+		cfg:  cfg,
+		st:   st,
+		keys: make(map[string]string),
+		mux:  http.NewServeMux(),
+		now:  time.Now,
+	}
+	for _, client := range cfg.Clients {
+		if client.Name == "" || client.Key == "" {
+			return nil, fmt.Errorf("client name and key must not be empty")
+		}
+		srv.keys[client.Name] = client.Key
+	}
+	srv.mux.HandleFunc("/api", srv.handleAPI)
+	srv.mux.HandleFunc("/", srv.httpMain)
+	srv.mux.HandleFunc("/bug", srv.httpBug)
+	srv.mux.HandleFunc("/text", srv.httpText)
+	return srv, nil
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The UI is read-only and unauthenticated, all updates go through the authenticated /api.
+	if r.URL.Path != "/api" && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	srv.mux.ServeHTTP(w, r)
+}
@@ -0,0 +1,303 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package lite
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEnv struct {
+	t      *testing.T
+	dbFile string
+	srv    *Server
+	http   *httptest.Server
+	dash   *dashapi.Dashboard
+	now    time.Time
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{
+		t:      t,
+		dbFile: filepath.Join(t.TempDir(), "dashboard.db"),
+		now:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	env.start()
+	t.Cleanup(func() { env.http.Close() })
+	return env
+}
+
+func (env *testEnv) start() {
+	st, err := LoadState(env.dbFile)
+	require.NoError(env.t, err)
+	cfg := &Config{
+		Name:        "test",
+		URL:         "http://dashboard.local",
+		ReportEmail: "bot@dashboard.local",
+		Clients:     []Client{{Name: "ci", Key: "secret"}},
+	}
+	env.srv, err = NewServer(cfg, st)
+	require.NoError(env.t, err)
+	env.srv.now = func() time.Time { return env.now }
+	if env.http != nil {
+		env.http.Close()
+	}
+	env.http = httptest.NewServer(env.srv)
+	env.dash, err = dashapi.New("ci", env.http.URL, "secret")
+	require.NoError(env.t, err)
+}
+
+func (env *testEnv) get(path string) string {
+	resp, err := http.Get(env.http.URL + path)
+	require.NoError(env.t, err)
+	defer resp.Body.Close()
+	require.Equal(env.t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(env.t, err)
+	return string(body)
+}
+
+func testBuild(id string) *dashapi.Build {
+	return &dashapi.Build{
+		Manager:           "ci-upstream",
+		ID:                id,
+		OS:                "linux",
+		Arch:              "amd64",
+		VMArch:            "amd64",
+		KernelRepo:        "git://repo",
+		KernelBranch:      "master",
+		KernelCommit:      "1111111111111111111111111111111111111111",
+		KernelCommitTitle: "kernel commit title",
+		KernelConfig:      []byte("CONFIG_KASAN=y"),
+		SyzkallerCommit:   "2222222222222222222222222222222222222222",
+	}
+}
+
+func TestAuth(t *testing.T) {
+	env := newTestEnv(t)
+	dash, err := dashapi.New("ci", env.http.URL, "wrong")
+	require.NoError(t, err)
+	_, err = dash.BuilderPoll("ci-upstream")
+	assert.ErrorContains(t, err, "401")
+	_, err = env.dash.BuilderPoll("ci-upstream")
+	assert.NoError(t, err)
+	// The UI doesn't accept any updates.
+	resp, err := http.PostForm(env.http.URL+"/bug", url.Values{"id": {"bug"}, "patch": {"patch"}})
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestCrashes(t *testing.T) {
+	env := newTestEnv(t)
+	require.NoError(t, env.dash.UploadBuild(testBuild("build1")))
+
+	crash := &dashapi.Crash{
+		BuildID: "build1",
+		Title:   "WARNING in foo",
+		Log:     []byte("log1"),
+		Report:  []byte("report1"),
+	}
+	resp, err := env.dash.ReportCrash(crash)
+	require.NoError(t, err)
+	assert.True(t, resp.NeedRepro)
+
+	// The crash is deduplicated into the same bug by the alternative title.
+	crash2 := &dashapi.Crash{
+		BuildID:   "build1",
+		Title:     "WARNING in bar",
+		AltTitles: []string{"WARNING in foo"},
+		Log:       []byte("log2"),
+	}
+	_, err = env.dash.ReportCrash(crash2)
+	require.NoError(t, err)
+	bugs, err := env.dash.BugList()
+	require.NoError(t, err)
+	require.Len(t, bugs.List, 1)
+
+	// Repro attempts are limited.
+	cid := &dashapi.CrashID{BuildID: "build1", Title: "WARNING in foo"}
+	for i := 0; i < maxReproPerBug; i++ {
+		need, err := env.dash.NeedRepro(cid)
+		require.NoError(t, err)
+		require.True(t, need)
+		require.NoError(t, env.dash.ReportFailedRepro(cid))
+	}
+	need, err := env.dash.NeedRepro(cid)
+	require.NoError(t, err)
+	assert.False(t, need)
+	env.now = env.now.Add(reproRetryPeriod)
+	need, err = env.dash.NeedRepro(cid)
+	require.NoError(t, err)
+	assert.True(t, need)
+
+	// A crash with a C repro is the best one.
+	crash.ReproSyz = []byte("getpid()")
+	crash.ReproC = []byte("int main() {}")
+	resp, err = env.dash.ReportCrash(crash)
+	require.NoError(t, err)
+	assert.False(t, resp.NeedRepro)
+
+	rep, err := env.dash.LoadBug(bugs.List[0])
+	require.NoError(t, err)
+	assert.Equal(t, "WARNING in foo", rep.Title)
+	assert.Equal(t, dashapi.BugStatusOpen, rep.BugStatus)
+	assert.Equal(t, int64(3), rep.NumCrashes)
+	assert.Equal(t, "int main() {}", string(rep.ReproC))
+	assert.Equal(t, "1111111111111111111111111111111111111111", rep.KernelCommit)
+	assert.Equal(t, "http://dashboard.local/bug?id="+rep.ID, rep.Link)
+
+	// Everything must survive a restart.
+	env.start()
+	rep2, err := env.dash.LoadBug(bugs.List[0])
+	require.NoError(t, err)
+	assert.Equal(t, rep, rep2)
+
+	_, err = env.dash.NeedRepro(&dashapi.CrashID{BuildID: "build1", Title: "unknown"})
+	assert.Error(t, err)
+	need, err = env.dash.NeedRepro(&dashapi.CrashID{BuildID: "build1", Title: "unknown", MayBeMissing: true})
+	require.NoError(t, err)
+	assert.True(t, need)
+
+	main := env.get("/")
+	assert.Contains(t, main, "WARNING in foo")
+	assert.Contains(t, main, "ci-upstream")
+	bug := env.get("/bug?id=" + rep.ID)
+	assert.Contains(t, bug, "/text?bug="+rep.ID+"&crash=2&tag=repro_c")
+	assert.Equal(t, "log2", env.get("/text?bug="+rep.ID+"&crash=1&tag=log"))
+}
+
+func TestJobs(t *testing.T) {
+	env := newTestEnv(t)
+	require.NoError(t, env.dash.UploadBuild(testBuild("build1")))
+	_, err := env.dash.ReportCrash(&dashapi.Crash{
+		BuildID:  "build1",
+		Title:    "KASAN: use-after-free in foo",
+		ReproSyz: []byte("getpid()"),
+	})
+	require.NoError(t, err)
+	bugs, err := env.dash.BugList()
+	require.NoError(t, err)
+	bugID := bugs.List[0]
+
+	// Managers that don't bisect don't get bisection jobs.
+	poll := &dashapi.JobPollReq{Managers: map[string]dashapi.ManagerJobs{
+		"ci-upstream": {TestPatches: true},
+	}}
+	job, err := env.dash.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Empty(t, job.ID)
+
+	poll.Managers["ci-upstream"] = dashapi.ManagerJobs{TestPatches: true, BisectCause: true}
+	job, err = env.dash.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Equal(t, dashapi.JobBisectCause, job.Type)
+	assert.Equal(t, "ci-upstream", job.Manager)
+	assert.Equal(t, "git://repo", job.KernelRepo)
+	assert.Equal(t, "1111111111111111111111111111111111111111", job.KernelCommit)
+	assert.Equal(t, "getpid()", string(job.ReproSyz))
+	bisectID := job.ID
+
+	// The bisection is created only once.
+	job, err = env.dash.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Empty(t, job.ID)
+
+	_, err = env.srv.CreatePatchTestJob(bugID, "git://other", "fix", []byte("patch"))
+	require.NoError(t, err)
+	job, err = env.dash.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Equal(t, dashapi.JobTestPatch, job.Type)
+	assert.Equal(t, "git://other", job.KernelRepo)
+	assert.Equal(t, "fix", job.KernelBranch)
+	assert.Equal(t, "patch", string(job.Patch))
+	testID := job.ID
+
+	// syz-ci restarted, the unfinished jobs are given out again.
+	require.NoError(t, env.dash.JobDone(&dashapi.JobDoneReq{
+		ID:      bisectID,
+		Commits: []dashapi.Commit{{Hash: "3333333333333333333333333333333333333333", Title: "guilty commit"}},
+	}))
+	require.NoError(t, env.dash.JobReset(&dashapi.JobResetReq{Managers: []string{"ci-upstream"}}))
+	job, err = env.dash.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Equal(t, testID, job.ID)
+	require.NoError(t, env.dash.JobDone(&dashapi.JobDoneReq{ID: testID}))
+	assert.Error(t, env.dash.JobDone(&dashapi.JobDoneReq{ID: testID}))
+
+	main := env.get("/")
+	assert.Contains(t, main, "3333333333333333333333333333333333333333 guilty commit")
+	assert.Contains(t, main, "OK")
+
+	// Manager activity from job polling survives restarts.
+	env.now = env.now.Add(time.Hour)
+	_, err = env.dash.JobPoll(poll)
+	require.NoError(t, err)
+	env.start()
+	assert.Equal(t, env.now, env.srv.st.Managers["ci-upstream"].LastActive)
+}
+
+func TestFixCommits(t *testing.T) {
+	env := newTestEnv(t)
+	require.NoError(t, env.dash.UploadBuild(testBuild("build1")))
+	_, err := env.dash.ReportCrash(&dashapi.Crash{BuildID: "build1", Title: "BUG in foo"})
+	require.NoError(t, err)
+	bugs, err := env.dash.BugList()
+	require.NoError(t, err)
+	bugID := bugs.List[0]
+
+	build2 := testBuild("build2")
+	build2.FixCommits = []dashapi.Commit{{Title: "foo: fix BUG", BugIDs: []string{bugID}}}
+	require.NoError(t, env.dash.UploadBuild(build2))
+	poll, err := env.dash.BuilderPoll("ci-upstream")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo: fix BUG"}, poll.PendingCommits)
+	assert.Equal(t, "bot@dashboard.local", poll.ReportEmail)
+	rep, err := env.dash.LoadBug(bugID)
+	require.NoError(t, err)
+	assert.Equal(t, dashapi.BugStatusOpen, rep.BugStatus)
+
+	build3 := testBuild("build3")
+	build3.Commits = []string{"foo: fix BUG"}
+	require.NoError(t, env.dash.UploadBuild(build3))
+	rep, err = env.dash.LoadBug(bugID)
+	require.NoError(t, err)
+	assert.Equal(t, dashapi.BugStatusFixed, rep.BugStatus)
+	poll, err = env.dash.BuilderPoll("ci-upstream")
+	require.NoError(t, err)
+	assert.Empty(t, poll.PendingCommits)
+
+	// The same crash after the fix is a new bug.
+	_, err = env.dash.ReportCrash(&dashapi.Crash{BuildID: "build3", Title: "BUG in foo"})
+	require.NoError(t, err)
+	bugs, err = env.dash.BugList()
+	require.NoError(t, err)
+	assert.Len(t, bugs.List, 2)
+}
+
+func TestManagerStats(t *testing.T) {
+	env := newTestEnv(t)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, env.dash.UploadManagerStats(&dashapi.ManagerStatsReq{
+			Name:        "ci-upstream",
+			Corpus:      100,
+			FuzzingTime: time.Hour,
+			Execs:       1000,
+		}))
+	}
+	mgr := env.srv.st.Managers["ci-upstream"]
+	assert.Equal(t, 2*time.Hour, mgr.FuzzingTime)
+	assert.Equal(t, uint64(2000), mgr.Execs)
+	assert.True(t, strings.Contains(env.get("/"), "2h00m"))
+}
@@ -0,0 +1,317 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package lite
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/hash"
+)
+
+// State holds all dashboard entities. All entities are kept in memory
+// and are persisted as JSON records in a pkg/db database on every update.
+type State struct {
+	db       *db.DB
+	seq      uint64
+	Builds   map[string]*Build
+	Bugs     map[string]*Bug
+	Jobs     map[string]*Job
+	Managers map[string]*Manager
+}
+
+type Build struct {
+	dashapi.Build
+	Time time.Time
+}
+
+type Bug struct {
+	ID            string
+	Title         string
+	AltTitles     []string
+	Status        dashapi.BugStatus
+	FirstTime     time.Time
+	LastTime      time.Time
+	NumCrashes    int64
+	NumRepro      int64 // number of repro attempts, both successful and not
+	LastReproTime time.Time
+	ReproLevel    dashapi.ReproLevel
+	HappenedOn    []string // managers
+	Commits       []string // titles of fixing commits
+	PatchedOn     []string // managers that have all fixing commits
+	FixTime       time.Time
+	BisectCause   string // ID of the cause bisection job
+	Crashes       []*Crash
+}
+
+type Crash struct {
+	Manager     string
+	BuildID     string
+	Time        time.Time
+	Title       string
+	Log         []byte
+	Report      []byte
+	MachineInfo []byte
+	ReproOpts   []byte
+	ReproSyz    []byte
+	ReproC      []byte
+}
+
+type Job struct {
+	ID           string
+	Type         dashapi.JobType
+	BugID        string
+	Manager      string
+	KernelRepo   string
+	KernelBranch string
+	Patch        []byte
+	Created      time.Time
+	Started      time.Time
+	Finished     time.Time
+	Result       *dashapi.JobDoneReq
+}
+
+type Manager struct {
+	Name        string
+	LastActive  time.Time
+	LastBuild   string
+	Stats       dashapi.ManagerStatsReq // the last received stats
+	FuzzingTime time.Duration
+	Crashes     uint64
+	Execs       uint64
+}
+
+const (
+	corruptedReportTitle  = "corrupted report"
+	suppressedReportTitle = "suppressed report"
+	// We keep maxCrashesPerBug crashes with and without repro (the latest ones).
+	maxCrashesPerBug = 10
+)
+
+// LoadState loads the state from the database file (creates a new database if it does not exist).
+func LoadState(filename string) (*State, error) {
+	database, err := db.Open(filename, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %v: %w", filename, err)
+	}
+	st := &State{
+		db:       database,
+		Builds:   make(map[string]*Build),
+		Bugs:     make(map[string]*Bug),
+		Jobs:     make(map[string]*Job),
+		Managers: make(map[string]*Manager),
+	}
+	for key, rec := range database.Records {
+		st.seq = max(st.seq, rec.Seq)
+		kind, id, _ := strings.Cut(key, "/")
+		var ent interface{}
+		switch kind {
+		case "build":
+			build := new(Build)
+			st.Builds[id] = build
+			ent = build
+		case "bug":
+			bug := new(Bug)
+			st.Bugs[id] = bug
+			ent = bug
+		case "job":
+			job := new(Job)
+			st.Jobs[id] = job
+			ent = job
+		case "manager":
+			mgr := new(Manager)
+			st.Managers[id] = mgr
+			ent = mgr
+		default:
+			return nil, fmt.Errorf("unknown database record %q", key)
+		}
+		if err := json.Unmarshal(rec.Val, ent); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal database record %q: %w", key, err)
+		}
+	}
+	// We have everything decoded in memory, no need to keep another copy.
+	database.DiscardData()
+	return st, nil
+}
+
+func (st *State) save(kind, id string, ent interface{}) error {
+	data, err := json.Marshal(ent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %v %v: %w", kind, id, err)
+	}
+	st.seq++
+	st.db.Save(kind+"/"+id, data, st.seq)
+	return st.db.Flush()
+}
+
+func (st *State) saveBug(bug *Bug) error {
+	return st.save("bug", bug.ID, bug)
+}
+
+func (st *State) saveBuild(build *Build) error {
+	return st.save("build", build.ID, build)
+}
+
+func (st *State) saveJob(job *Job) error {
+	return st.save("job", job.ID, job)
+}
+
+func (st *State) saveManager(mgr *Manager) error {
+	return st.save("manager", mgr.Name, mgr)
+}
+
+func (st *State) manager(name string) *Manager {
+	mgr := st.Managers[name]
+	if mgr == nil {
+		mgr = &Manager{Name: name}
+		st.Managers[name] = mgr
+	}
+	return mgr
+}
+
+// findBug returns an open bug that matches any of the titles.
+func (st *State) findBug(titles []string) *Bug {
+	for _, bug := range st.sortedBugs() {
+		if bug.Status != dashapi.BugStatusOpen {
+			continue
+		}
+		for _, title := range titles {
+			if title == bug.Title || stringInList(bug.AltTitles, title) {
+				return bug
+			}
+		}
+	}
+	return nil
+}
+
+func (st *State) createBug(title string, now time.Time) *Bug {
+	id := ""
+	for seq := 0; ; seq++ {
+		id = hash.String([]byte(fmt.Sprintf("%v-%v", title, seq)))
+		if st.Bugs[id] == nil {
+			break
+		}
+	}
+	bug := &Bug{
+		ID:        id,
+		Title:     title,
+		FirstTime: now,
+	}
+	st.Bugs[id] = bug
+	return bug
+}
+
+// sortedBugs returns all bugs, the most recently crashed first.
+func (st *State) sortedBugs() []*Bug {
+	var bugs []*Bug
+	for _, bug := range st.Bugs {
+		bugs = append(bugs, bug)
+	}
+	sort.Slice(bugs, func(i, j int) bool {
+		if !bugs[i].LastTime.Equal(bugs[j].LastTime) {
+			return bugs[i].LastTime.After(bugs[j].LastTime)
+		}
+		return bugs[i].ID < bugs[j].ID
+	})
+	return bugs
+}
+
+// sortedJobs returns all jobs in the creation order.
+func (st *State) sortedJobs() []*Job {
+	var jobs []*Job
+	for _, job := range st.Jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].Created.Equal(jobs[j].Created) {
+			return jobs[i].Created.Before(jobs[j].Created)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs
+}
+
+// addCrash adds the crash to the bug and drops old crashes if necessary.
+func (bug *Bug) addCrash(crash *Crash) {
+	bug.Crashes = append(bug.Crashes, crash)
+	var repro, norepro int
+	for _, c := range bug.Crashes {
+		if c.reproLevel() != dashapi.ReproLevelNone {
+			repro++
+		} else {
+			norepro++
+		}
+	}
+	// Crashes are ordered by time, so we drop the oldest ones.
+	var kept []*Crash
+	for _, c := range bug.Crashes {
+		if c.reproLevel() != dashapi.ReproLevelNone {
+			if repro > maxCrashesPerBug {
+				repro--
+				continue
+			}
+		} else if norepro > maxCrashesPerBug {
+			norepro--
+			continue
+		}
+		kept = append(kept, c)
+	}
+	bug.Crashes = kept
+}
+
+// bestCrash returns the crash that is the most useful for reporting:
+// the latest one with the best repro.
+func (bug *Bug) bestCrash() *Crash {
+	var best *Crash
+	for _, crash := range bug.Crashes {
+		if best == nil || crash.reproLevel() >= best.reproLevel() {
+			best = crash
+		}
+	}
+	return best
+}
+
+func (crash *Crash) reproLevel() dashapi.ReproLevel {
+	if len(crash.ReproC) != 0 {
+		return dashapi.ReproLevelC
+	}
+	if len(crash.ReproSyz) != 0 {
+		return dashapi.ReproLevelSyz
+	}
+	return dashapi.ReproLevelNone
+}
+
+func (job *Job) status() string {
+	switch {
+	case !job.Finished.IsZero() && job.Result != nil && len(job.Result.Error) != 0:
+		return "error"
+	case !job.Finished.IsZero():
+		return "done"
+	case !job.Started.IsZero():
+		return "running"
+	default:
+		return "pending"
+	}
+}
+
+func stringInList(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeString(list []string, str string) []string {
+	if stringInList(list, str) {
+		return list
+	}
+	return append(list, str)
+}
@@ -7,6 +7,7 @@
 // See Intel Software Developer’s Manual Volume 3: System Programming Guide
 // for details on what happens here.
 
+#include "common_kvm_amd64_syzos.h"
 #include "kvm.h"
 #include "kvm_amd64.S.h"
 
@@ -287,6 +288,13 @@ static volatile long syz_kvm_setup_cpu(volatile long a0, volatile long a1, volat
 	int text_type = text_array_ptr[0].typ;
 	const void* text = text_array_ptr[0].text;
 	uintptr_t text_size = text_array_ptr[0].size;
+	uint8 syzos_text[1000];
+	if (text_type == 0) {
+		// SYZOS commands are assembled into 64-bit code.
+		text_size = syzos_x86_assemble(text, text_size, syzos_text, sizeof(syzos_text));
+		text = syzos_text;
+		text_type = 64;
+	}
 
 	for (uintptr_t i = 0; i < guest_mem_size / page_size; i++) {
 		struct kvm_userspace_memory_region memreg;
@@ -0,0 +1,247 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// This file provides SYZOS for x86-64: guest code is described as a sequence of typed commands
+// (see syzos_api_call_x86 in sys/linux/dev_kvm.txt) that are assembled on the host into long mode code.
+// This way prog mutation, minimization and pretty-printing work on individual guest operations.
+
+#include "kvm.h"
+
+typedef enum {
+	SYZOS_X86_API_UEXIT,
+	SYZOS_X86_API_CODE,
+	SYZOS_X86_API_WRMSR,
+	SYZOS_X86_API_RDMSR,
+	SYZOS_X86_API_OUT,
+	SYZOS_X86_API_IN,
+	SYZOS_X86_API_CPUID,
+	SYZOS_X86_API_WRCR,
+	SYZOS_X86_API_HYPERCALL,
+	SYZOS_X86_API_SET_PTE,
+	SYZOS_X86_API_STOP, // Must be the last one
+} syzos_x86_api_id;
+
+struct api_call_header {
+	uint64 call;
+	uint64 size;
+};
+
+struct api_call_1 {
+	struct api_call_header header;
+	uint64 arg;
+};
+
+struct api_call_2 {
+	struct api_call_header header;
+	uint64 args[2];
+};
+
+struct api_call_3 {
+	struct api_call_header header;
+	uint64 args[3];
+};
+
+struct api_call_code {
+	struct api_call_header header;
+	uint8 insns[];
+};
+
+struct api_call_hypercall {
+	struct api_call_header header;
+	uint64 insn;
+	uint64 nr;
+	uint64 args[4];
+};
+
+struct api_call_pte {
+	struct api_call_header header;
+	uint64 level;
+	uint64 index;
+	uint64 page;
+	uint64 flags;
+};
+
+// Longest sequence of instructions produced for a single command (hypercall).
+#define SYZOS_X86_MAX_INSN 64
+
+#define X86_REG_RAX 0
+#define X86_REG_RCX 1
+#define X86_REG_RDX 2
+#define X86_REG_RBX 3
+#define X86_REG_RSI 6
+#define X86_REG_RDI 7
+
+// movabs reg, imm64
+static int syzos_x86_mov_imm(uint8* insn, int reg, uint64 val)
+{
+	insn[0] = 0x48;
+	insn[1] = 0xb8 + reg;
+	memcpy(&insn[2], &val, sizeof(val));
+	return 10;
+}
+
+static int syzos_x86_port_insn(uint8* insn, uint64 size, uint8 opcode)
+{
+	// opcode is the 8-bit form (in al, dx / out dx, al), +1 is the 16/32-bit form.
+	switch (size) {
+	case 1:
+		insn[0] = opcode;
+		return 1;
+	case 2:
+		insn[0] = 0x66;
+		insn[1] = opcode + 1;
+		return 2;
+	default:
+		insn[0] = opcode + 1;
+		return 1;
+	}
+}
+
+// Assembles a single command into insn, returns size of the generated code.
+static int syzos_x86_assemble_cmd(struct api_call_header* cmd, uint8* insn)
+{
+	int n = 0;
+	switch (cmd->call) {
+	case SYZOS_X86_API_UEXIT: {
+		// Port I/O exits to the host with KVM_EXIT_IO and the exit code as data.
+		struct api_call_1* ccmd = (struct api_call_1*)cmd;
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RDX, X86_SYZOS_UEXIT_PORT);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RAX, ccmd->arg);
+		insn[n++] = 0xef; // out dx, eax
+		break;
+	}
+	case SYZOS_X86_API_WRMSR: {
+		struct api_call_2* ccmd = (struct api_call_2*)cmd;
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RCX, ccmd->args[0]);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RAX, ccmd->args[1] & 0xffffffff);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RDX, ccmd->args[1] >> 32);
+		memcpy(insn + n, "\x0f\x30", 2); // wrmsr
+		n += 2;
+		break;
+	}
+	case SYZOS_X86_API_RDMSR: {
+		struct api_call_1* ccmd = (struct api_call_1*)cmd;
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RCX, ccmd->arg);
+		memcpy(insn + n, "\x0f\x32", 2); // rdmsr
+		n += 2;
+		break;
+	}
+	case SYZOS_X86_API_OUT: {
+		struct api_call_3* ccmd = (struct api_call_3*)cmd;
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RDX, ccmd->args[0] & 0xffff);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RAX, ccmd->args[2]);
+		n += syzos_x86_port_insn(insn + n, ccmd->args[1], 0xee);
+		break;
+	}
+	case SYZOS_X86_API_IN: {
+		struct api_call_2* ccmd = (struct api_call_2*)cmd;
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RDX, ccmd->args[0] & 0xffff);
+		n += syzos_x86_port_insn(insn + n, ccmd->args[1], 0xec);
+		break;
+	}
+	case SYZOS_X86_API_CPUID: {
+		struct api_call_2* ccmd = (struct api_call_2*)cmd;
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RAX, ccmd->args[0]);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RCX, ccmd->args[1]);
+		memcpy(insn + n, "\x0f\xa2", 2); // cpuid
+		n += 2;
+		break;
+	}
+	case SYZOS_X86_API_WRCR: {
+		struct api_call_2* ccmd = (struct api_call_2*)cmd;
+		uint64 cr = ccmd->args[0];
+		if (cr != 0 && cr != 2 && cr != 3 && cr != 4 && cr != 8)
+			break;
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RAX, ccmd->args[1]);
+		if (cr == 8)
+			insn[n++] = 0x44; // REX.R
+		// mov crN, rax
+		insn[n++] = 0x0f;
+		insn[n++] = 0x22;
+		insn[n++] = 0xc0 | ((cr & 7) << 3);
+		break;
+	}
+	case SYZOS_X86_API_HYPERCALL: {
+		struct api_call_hypercall* ccmd = (struct api_call_hypercall*)cmd;
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RAX, ccmd->nr);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RBX, ccmd->args[0]);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RCX, ccmd->args[1]);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RDX, ccmd->args[2]);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RSI, ccmd->args[3]);
+		if (ccmd->insn & 1)
+			memcpy(insn + n, "\x0f\x01\xd9", 3); // vmmcall
+		else
+			memcpy(insn + n, "\x0f\x01\xc1", 3); // vmcall
+		n += 3;
+		break;
+	}
+	case SYZOS_X86_API_SET_PTE: {
+		struct api_call_pte* ccmd = (struct api_call_pte*)cmd;
+		const uint64 tables[] = {ADDR_PML4, ADDR_PDP, ADDR_PD};
+		uint64 entry = tables[ccmd->level % 3] + (ccmd->index % 512) * sizeof(uint64);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RDI, entry);
+		n += syzos_x86_mov_imm(insn + n, X86_REG_RAX, (ccmd->page << 12) | ccmd->flags);
+		// mov [rdi], rax; mov rax, cr3; mov cr3, rax
+		memcpy(insn + n, "\x48\x89\x07\x0f\x20\xd8\x0f\x22\xd8", 9);
+		n += 9;
+		break;
+	}
+	}
+	return n;
+}
+
+// Returns the size of a command with all its arguments.
+static uint64 syzos_x86_cmd_min_size(uint64 call)
+{
+	switch (call) {
+	case SYZOS_X86_API_UEXIT:
+	case SYZOS_X86_API_RDMSR:
+		return sizeof(struct api_call_1);
+	case SYZOS_X86_API_WRMSR:
+	case SYZOS_X86_API_IN:
+	case SYZOS_X86_API_CPUID:
+	case SYZOS_X86_API_WRCR:
+		return sizeof(struct api_call_2);
+	case SYZOS_X86_API_OUT:
+		return sizeof(struct api_call_3);
+	case SYZOS_X86_API_HYPERCALL:
+		return sizeof(struct api_call_hypercall);
+	case SYZOS_X86_API_SET_PTE:
+		return sizeof(struct api_call_pte);
+	}
+	return sizeof(struct api_call_header);
+}
+
+// Converts a sequence of SYZOS commands into x86-64 code, returns size of the generated code.
+// Commands that don't fit into the buffer are dropped.
+static uint64 syzos_x86_assemble(const void* text, uint64 text_size, uint8* buf, uint64 buf_size)
+{
+	uint64 pos = 0;
+	const char* addr = (const char*)text;
+	while (text_size >= sizeof(struct api_call_header)) {
+		struct api_call_header* cmd = (struct api_call_header*)addr;
+		if (cmd->call >= SYZOS_X86_API_STOP)
+			break;
+		if (cmd->size > text_size || cmd->size < sizeof(struct api_call_header))
+			break;
+		if (cmd->call == SYZOS_X86_API_CODE) {
+			uint64 size = cmd->size - sizeof(struct api_call_header);
+			if (pos + size > buf_size)
+				break;
+			memcpy(buf + pos, ((struct api_call_code*)cmd)->insns, size);
+			pos += size;
+		} else {
+			if (cmd->size < syzos_x86_cmd_min_size(cmd->call))
+				break;
+			uint8 insn[SYZOS_X86_MAX_INSN];
+			int n = syzos_x86_assemble_cmd(cmd, insn);
+			if (pos + n > buf_size)
+				break;
+			memcpy(buf + pos, insn, n);
+			pos += n;
+		}
+		addr += cmd->size;
+		text_size -= cmd->size;
+	}
+	return pos;
+}
@@ -73,6 +73,9 @@
 #define MSR_IA32_LSTAR 0xC0000082
 #define MSR_IA32_VMX_PROCBASED_CTLS2 0x48B
 
+// SYZOS uexit command on x86 writes the exit code to this I/O port.
+#define X86_SYZOS_UEXIT_PORT 0xdddd
+
 #define NEXT_INSN $0xbadc0de
 #define PREFIX_SIZE 0xba1d
 
@@ -105,6 +105,17 @@ static void FindIvshmemDevices()
 	output_size = static_cast<uint64>(rpc::Const::MaxOutputSize) - sizeof(rpc::SnapshotHeaderT);
 }
 
+static void SnapshotSetState(rpc::SnapshotState state)
+{
+	debug("changing stapshot state %s -> %s\n",
+	      rpc::EnumNameSnapshotState(ivs.hdr->state), rpc::EnumNameSnapshotState(state));
+	std::atomic_signal_fence(std::memory_order_seq_cst);
+	ivs.hdr->state = state;
+	// The register contains VM index shifted by 16 (the host part is VM index 1)
+	// + interrup vector index (0 in our case).
+	*ivs.doorbell = 1 << 16;
+}
+
 static void SnapshotSetup(char** argv, int argc)
 {
 	flag_snapshot = true;
@@ -117,6 +128,9 @@ static void SnapshotSetup(char** argv, int argc)
 	write_file("/proc/sys/kernel/printk_devkmsg", "on\n");
 #endif
 	FindIvshmemDevices();
+	// Tell the host that we are waiting for the handshake. The host takes a base snapshot at this point
+	// and restores it to set up snapshots for other handshake requests (e.g. with different env flags).
+	SnapshotSetState(rpc::SnapshotState::Waiting);
 	// Wait for the host to write handshake_req into input memory.
 	while (ivs.hdr->state != rpc::SnapshotState::Handshake)
 		sleep_ms(10);
@@ -150,17 +164,6 @@ constexpr size_t kDataPopulate = 8 << 10;
 constexpr size_t kCoveragePopulate = 32 << 10;
 constexpr size_t kThreadsPopulate = 2;
 
-static void SnapshotSetState(rpc::SnapshotState state)
-{
-	debug("changing stapshot state %s -> %s\n",
-	      rpc::EnumNameSnapshotState(ivs.hdr->state), rpc::EnumNameSnapshotState(state));
-	std::atomic_signal_fence(std::memory_order_seq_cst);
-	ivs.hdr->state = state;
-	// The register contains VM index shifted by 16 (the host part is VM index 1)
-	// + interrup vector index (0 in our case).
-	*ivs.doorbell = 1 << 16;
-}
-
 // PopulateMemory prefaults anon memory (we want to avoid minor page faults as well).
 static void PopulateMemory(void* ptr, size_t size)
 {
@@ -0,0 +1,218 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package asset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/debugtracer"
+	"github.com/google/syzkaller/pkg/gcs"
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// localStorageBackend stores assets in a local directory that is served over HTTP
+// (see Config.LocalHandler) under serveURL.
+// Content type and encoding of each asset are kept in a separate metadata file
+// under localMetaDir, so that the files can be served the same way GCS serves them.
+type localStorageBackend struct {
+	dir      string
+	serveURL string
+	tracer   debugtracer.DebugTracer
+}
+
+const localMetaDir = ".meta"
+
+type localMeta struct {
+	ContentType     string
+	ContentEncoding string
+}
+
+func makeLocalStorageBackend(dir, serveURL string, tracer debugtracer.DebugTracer) (*localStorageBackend, error) {
+	tracer.Log("created local backend for dir '%s'", dir)
+	if err := osutil.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	return &localStorageBackend{
+		dir:      dir,
+		serveURL: strings.TrimSuffix(serveURL, "/"),
+		tracer:   tracer,
+	}, nil
+}
+
+// localFileWriter writes the asset to a temp file and moves it to the final destination on Close,
+// so that partially written assets are never visible.
+type localFileWriter struct {
+	*os.File
+	dest string
+}
+
+func (w *localFileWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.Name())
+		return err
+	}
+	return os.Rename(w.Name(), w.dest)
+}
+
+func (lsb *localStorageBackend) upload(req *uploadRequest) (*uploadResponse, error) {
+	dest, err := lsb.filePath(req.savePath)
+	if err != nil {
+		return nil, err
+	}
+	if osutil.IsExist(dest) {
+		return nil, &FileExistsError{req.savePath}
+	}
+	if err := osutil.MkdirAll(filepath.Dir(dest)); err != nil {
+		return nil, err
+	}
+	meta, err := json.Marshal(&localMeta{
+		ContentType:     req.contentType,
+		ContentEncoding: req.contentEncoding,
+	})
+	if err != nil {
+		return nil, err
+	}
+	metaFile := filepath.Join(lsb.dir, localMetaDir, filepath.FromSlash(req.savePath))
+	if err := osutil.MkdirAll(filepath.Dir(metaFile)); err != nil {
+		return nil, err
+	}
+	if err := osutil.WriteFile(metaFile, meta); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(dest), ".tmp-")
+	lsb.tracer.Log("local upload: created a temp file for %s, error %v", dest, err)
+	if err != nil {
+		return nil, err
+	}
+	return &uploadResponse{
+		writer: &localFileWriter{File: f, dest: dest},
+		path:   req.savePath,
+	}, nil
+}
+
+func (lsb *localStorageBackend) downloadURL(path string, publicURL bool) (string, error) {
+	return lsb.serveURL + "/" + path, nil
+}
+
+func (lsb *localStorageBackend) getPath(downloadURL string) (string, error) {
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the URL: %w", err)
+	}
+	serve, err := url.Parse(lsb.serveURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Host != serve.Host {
+		return "", fmt.Errorf("not allowed host: %s", u.Host)
+	}
+	prefix := serve.Path + "/"
+	if !strings.HasPrefix(u.Path, prefix) {
+		return "", ErrUnknownBucket
+	}
+	return u.Path[len(prefix):], nil
+}
+
+func (lsb *localStorageBackend) list() ([]*gcs.Object, error) {
+	var ret []*gcs.Object
+	err := filepath.WalkDir(lsb.dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == localMetaDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(lsb.dir, file)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, &gcs.Object{
+			Path:      filepath.ToSlash(rel),
+			CreatedAt: info.ModTime(),
+		})
+		return nil
+	})
+	return ret, err
+}
+
+func (lsb *localStorageBackend) remove(path string) error {
+	file, err := lsb.filePath(path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrAssetDoesNotExist
+	} else if err != nil {
+		return err
+	}
+	metaFile := filepath.Join(lsb.dir, localMetaDir, filepath.FromSlash(path))
+	os.Remove(metaFile)
+	// Assets are stored in per-upload folders, drop the folders if they became empty.
+	os.Remove(filepath.Dir(file))
+	os.Remove(filepath.Dir(metaFile))
+	return nil
+}
+
+// filePath converts the asset path to the local file path and ensures it stays within the directory.
+func (lsb *localStorageBackend) filePath(assetPath string) (string, error) {
+	clean := path.Clean("/" + assetPath)[1:]
+	if clean == "" || clean != assetPath || strings.HasPrefix(clean, localMetaDir+"/") {
+		return "", fmt.Errorf("invalid asset path %q", assetPath)
+	}
+	return filepath.Join(lsb.dir, filepath.FromSlash(clean)), nil
+}
+
+func (lsb *localStorageBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	assetPath := strings.TrimPrefix(r.URL.Path, "/")
+	file, err := lsb.filePath(assetPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	meta := new(localMeta)
+	data, err := os.ReadFile(filepath.Join(lsb.dir, localMetaDir, filepath.FromSlash(assetPath)))
+	if err == nil {
+		if err := json.Unmarshal(data, meta); err != nil {
+			lsb.tracer.Log("failed to parse metadata for %s: %v", assetPath, err)
+		}
+	}
+	if meta.ContentType != "" {
+		w.Header().Set("Content-Type", meta.ContentType)
+	}
+	if meta.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", meta.ContentEncoding)
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
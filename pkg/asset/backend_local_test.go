@@ -0,0 +1,134 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package asset
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/debugtracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func httpGet(t *testing.T, url string) (*http.Response, []byte) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	cfg := &Config{
+		UploadTo: "file://" + dir,
+		ServeURL: server.URL + "/assets",
+	}
+	require.NoError(t, cfg.Validate())
+	mux.Handle(cfg.LocalHandler())
+	dash := newDashMock()
+	storage, err := StorageFromConfig(cfg, dash)
+	require.NoError(t, err)
+	build := &dashapi.Build{ID: "1234", KernelCommit: "abcdef2134"}
+
+	vmlinux := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+	require.NoError(t, storage.sendBuildAsset(bytes.NewReader(vmlinux), "vmlinux", dashapi.KernelObject, build))
+	html := []byte("<html>coverage</html>")
+	require.NoError(t, storage.sendBuildAsset(bytes.NewReader(html), "cover.html",
+		dashapi.HTMLCoverageReport, build))
+	var vmlinuxURL, htmlURL string
+	for url := range dash.downloadURLs {
+		if filepath.Ext(url) == ".xz" {
+			vmlinuxURL = url
+		} else {
+			htmlURL = url
+		}
+	}
+	assert.Regexp(t, `^`+server.URL+`/assets/[0-9a-f]{12}/vmlinux-abcdef21\.xz$`, vmlinuxURL)
+
+	resp, body := httpGet(t, vmlinuxURL)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	xzReader, err := xz.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	out, err := io.ReadAll(xzReader)
+	require.NoError(t, err)
+	assert.Equal(t, vmlinux, out)
+
+	// The coverage report is stored compressed and must be served with Content-Encoding.
+	resp, body = httpGet(t, htmlURL)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, resp.Uncompressed)
+	assert.Equal(t, "text/html", resp.Header.Get("Content-Type"))
+	assert.Equal(t, html, body)
+
+	resp, _ = httpGet(t, server.URL+"/assets/"+localMetaDir+"/")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Only the coverage report is still needed.
+	objects, err := storage.backend.list()
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	old := time.Now().Add(-2 * deletionEmbargo)
+	for _, obj := range objects {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, filepath.FromSlash(obj.Path)), old, old))
+	}
+	dash.downloadURLs = map[string]bool{htmlURL: true}
+	require.NoError(t, storage.DeprecateAssets())
+	objects, err = storage.backend.list()
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	htmlPath, err := storage.backend.getPath(htmlURL)
+	require.NoError(t, err)
+	assert.Equal(t, htmlPath, objects[0].Path)
+	resp, _ = httpGet(t, vmlinuxURL)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, ErrAssetDoesNotExist, storage.backend.remove("aaaa/vmlinux"))
+}
+
+func TestLocalParsePaths(t *testing.T) {
+	be := &localStorageBackend{
+		dir:      "/dir",
+		serveURL: "http://host:8080/assets",
+		tracer:   &debugtracer.NullTracer{},
+	}
+	path, err := be.getPath("http://host:8080/assets/folder/file.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "folder/file.txt", path)
+	_, err = be.getPath("http://other:8080/assets/folder/file.txt")
+	assert.ErrorContains(t, err, "not allowed host: other:8080")
+	_, err = be.getPath("http://host:8080/other/folder/file.txt")
+	assert.ErrorIs(t, err, ErrUnknownBucket)
+	for _, bad := range []string{"", "../file", "folder/../../file", ".meta/folder/file", "/folder/file"} {
+		_, err := be.filePath(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestLocalConfig(t *testing.T) {
+	cfg := &Config{UploadTo: "file://relative/dir", ServeURL: "http://host/assets"}
+	assert.Error(t, cfg.Validate())
+	cfg = &Config{UploadTo: "file:///dir", ServeURL: "http://host/"}
+	assert.Error(t, cfg.Validate())
+	cfg = &Config{UploadTo: "file:///dir", ServeURL: "http://host/assets/"}
+	assert.NoError(t, cfg.Validate())
+	prefix, handler := cfg.LocalHandler()
+	assert.Equal(t, "/assets/", prefix)
+	assert.NotNil(t, handler)
+	cfg = &Config{UploadTo: "gs://bucket"}
+	_, handler = cfg.LocalHandler()
+	assert.Nil(t, handler)
+}
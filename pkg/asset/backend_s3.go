@@ -0,0 +1,349 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package asset
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/pkg/debugtracer"
+	"github.com/google/syzkaller/pkg/gcs"
+)
+
+// s3StorageBackend stores assets in a bucket of an S3-compatible storage (AWS S3, MinIO, Ceph, etc).
+// Objects are addressed in the path style (endpoint/bucket/object) since this is what
+// the S3 stand-ins support best. Requests are signed with AWS Signature Version 4.
+type s3StorageBackend struct {
+	endpoint string
+	bucket   string
+	creds    s3Credentials
+	client   *http.Client
+	tracer   debugtracer.DebugTracer
+	now      func() time.Time
+}
+
+type s3Credentials struct {
+	region    string
+	accessKey string
+	secretKey string
+}
+
+func makeS3StorageBackend(endpoint, region, bucket string, tracer debugtracer.DebugTracer) (*s3StorageBackend, error) {
+	tracer.Log("created s3 backend for bucket '%s' at %s", bucket, endpoint)
+	creds := s3Credentials{
+		region:    region,
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	}
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set for s3 storage")
+	}
+	if creds.region == "" {
+		creds.region = "us-east-1"
+	}
+	return &s3StorageBackend{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		bucket:   bucket,
+		creds:    creds,
+		client:   http.DefaultClient,
+		tracer:   tracer,
+		now:      time.Now,
+	}, nil
+}
+
+func (sb *s3StorageBackend) objectURL(path string) string {
+	return sb.endpoint + "/" + sb.bucket + "/" + path
+}
+
+// do signs and sends the request and checks that the response status is one of the expected ones.
+func (sb *s3StorageBackend) do(req *http.Request, payloadHash string, okStatus ...int) (*http.Response, error) {
+	sb.creds.sign(req, payloadHash, sb.now())
+	resp, err := sb.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range okStatus {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	resp.Body.Close()
+	return resp, fmt.Errorf("s3 %v %v failed: %v: %s", req.Method, req.URL.Path, resp.Status, body)
+}
+
+// s3FileWriter buffers the object in a temp file since the payload hash
+// and size must be known before sending the request.
+type s3FileWriter struct {
+	sb          *s3StorageBackend
+	file        *os.File
+	hash        hash.Hash
+	path        string
+	contentType string
+	encoding    string
+}
+
+func (w *s3FileWriter) Write(p []byte) (int, error) {
+	w.hash.Write(p)
+	return w.file.Write(p)
+}
+
+func (w *s3FileWriter) Close() error {
+	defer os.Remove(w.file.Name())
+	defer w.file.Close()
+	size, err := w.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, w.sb.objectURL(w.path), w.file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", w.contentType)
+	if w.encoding != "" {
+		req.Header.Set("Content-Encoding", w.encoding)
+	}
+	resp, err := w.sb.do(req, hex.EncodeToString(w.hash.Sum(nil)), http.StatusOK)
+	if err != nil {
+		w.sb.tracer.Log("s3 upload error: %s", err)
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (sb *s3StorageBackend) exists(path string) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, sb.objectURL(path), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := sb.do(req, emptyPayloadHash, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+func (sb *s3StorageBackend) upload(req *uploadRequest) (*uploadResponse, error) {
+	// Best-effort check only, same as for GCS.
+	exists, err := sb.exists(req.savePath)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, &FileExistsError{req.savePath}
+	}
+	f, err := os.CreateTemp("", "syz-s3-upload")
+	sb.tracer.Log("s3 upload: created a temp file for %s, error %v", req.savePath, err)
+	if err != nil {
+		return nil, err
+	}
+	return &uploadResponse{
+		writer: &s3FileWriter{
+			sb:          sb,
+			file:        f,
+			hash:        sha256.New(),
+			path:        req.savePath,
+			contentType: req.contentType,
+			encoding:    req.contentEncoding,
+		},
+		path: req.savePath,
+	}, nil
+}
+
+func (sb *s3StorageBackend) downloadURL(path string, publicURL bool) (string, error) {
+	// Access to the objects is controlled by the bucket policy.
+	return sb.objectURL(path), nil
+}
+
+func (sb *s3StorageBackend) getPath(downloadURL string) (string, error) {
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the URL: %w", err)
+	}
+	endpoint, err := url.Parse(sb.endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Host != endpoint.Host {
+		return "", fmt.Errorf("not allowed host: %s", u.Host)
+	}
+	prefix := endpoint.Path + "/" + sb.bucket + "/"
+	if !strings.HasPrefix(u.Path, prefix) {
+		return "", ErrUnknownBucket
+	}
+	return u.Path[len(prefix):], nil
+}
+
+type s3ListResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		LastModified time.Time
+	}
+}
+
+func (sb *s3StorageBackend) list() ([]*gcs.Object, error) {
+	ret := []*gcs.Object{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := http.NewRequest(http.MethodGet, sb.endpoint+"/"+sb.bucket+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := sb.do(req, emptyPayloadHash, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		res := new(s3ListResult)
+		err = xml.NewDecoder(resp.Body).Decode(res)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse s3 object list: %w", err)
+		}
+		for _, obj := range res.Contents {
+			ret = append(ret, &gcs.Object{
+				Path:      obj.Key,
+				CreatedAt: obj.LastModified,
+			})
+		}
+		if !res.IsTruncated {
+			return ret, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+func (sb *s3StorageBackend) remove(path string) error {
+	// S3 does not tell whether the object existed, so we have to check first.
+	exists, err := sb.exists(path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrAssetDoesNotExist
+	}
+	req, err := http.NewRequest(http.MethodDelete, sb.objectURL(path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := sb.do(req, emptyPayloadHash, http.StatusOK, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SHA256 of an empty payload.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds AWS Signature Version 4 headers to the request.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func (creds *s3Credentials) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "range" || name == "content-type" ||
+			name == "content-encoding" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := new(strings.Builder)
+	for _, name := range names {
+		fmt.Fprintf(canonicalHeaders, "%v:%v\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + creds.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := []byte("AWS4" + creds.secretKey)
+	for _, part := range []string{date, creds.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	var params []string
+	for key, values := range query {
+		for _, val := range values {
+			params = append(params, s3Escape(key)+"="+s3Escape(val))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+func s3EscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = s3Escape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// s3Escape escapes everything except for the unreserved characters as required by SigV4.
+func s3Escape(s string) string {
+	buf := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
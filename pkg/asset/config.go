@@ -5,9 +5,13 @@ package asset
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
 	"strings"
 
 	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/debugtracer"
 )
 
 type Config struct {
@@ -16,9 +20,21 @@ type Config struct {
 	Debug bool `json:"debug"`
 	// Where to upload artifacts.
 	// If "gs://bucket/" is specified, assets will be stored in the corresponding GCS bucket.
+	// If "file:///path/to/dir" is specified, assets will be stored in the local directory
+	// and served over HTTP under ServeURL.
+	// If "s3://bucket" is specified, assets will be stored in the bucket of the S3-compatible
+	// storage at S3Endpoint. Credentials are taken from the AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY environment variables.
 	// If "dummy://" is specified, assets will not be actually stored anywhere. May be helpful
 	// for debugging.
 	UploadTo string `json:"upload_to"`
+	// For "file://" storage: the URL under which the directory is available, e.g. "http://host:8080/assets".
+	// syz-ci and syz-manager serve the directory on their HTTP servers under the path of this URL.
+	ServeURL string `json:"serve_url"`
+	// For "s3://" storage: the storage endpoint, e.g. "http://minio.local:9000", and region
+	// (us-east-1 by default).
+	S3Endpoint string `json:"s3_endpoint"`
+	S3Region   string `json:"s3_region"`
 	// Perform asset deprecation from this instance. If several syz-ci's share a common stoage,
 	// it make sense to enable derprecation only on one of them.
 	DoDeprecation bool `json:"do_deprecation"`
@@ -59,7 +75,7 @@ func (c *Config) Validate() error {
 	if c.UploadTo == "" && len(c.Assets) != 0 {
 		return fmt.Errorf("assets are specified, but upload_to is empty")
 	}
-	allowedFormats := []string{"gs://", "dummy://"}
+	allowedFormats := []string{"gs://", "file://", "s3://", "dummy://"}
 	if c.UploadTo != "" {
 		any := false
 		for _, prefix := range allowedFormats {
@@ -71,5 +87,39 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("the currently supported upload destinations are: %v", allowedFormats)
 		}
 	}
+	if strings.HasPrefix(c.UploadTo, "file://") {
+		if !filepath.IsAbs(strings.TrimPrefix(c.UploadTo, "file://")) {
+			return fmt.Errorf("file:// upload destination must be an absolute path")
+		}
+		if u, err := url.Parse(c.ServeURL); err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return fmt.Errorf("serve_url must be a valid URL with a non-root path for file:// upload destination")
+		}
+	}
+	if strings.HasPrefix(c.UploadTo, "s3://") {
+		if u, err := url.Parse(c.S3Endpoint); err != nil || u.Host == "" {
+			return fmt.Errorf("s3_endpoint must be a valid URL for s3:// upload destination")
+		}
+		if strings.Trim(strings.TrimPrefix(c.UploadTo, "s3://"), "/") == "" {
+			return fmt.Errorf("s3:// upload destination must specify the bucket")
+		}
+	}
 	return nil
 }
+
+// LocalHandler returns the URL path and the handler that serve assets stored in the local directory.
+// The handler is nil if the assets are not stored locally.
+func (c *Config) LocalHandler() (string, http.Handler) {
+	if c.IsEmpty() || !strings.HasPrefix(c.UploadTo, "file://") {
+		return "", nil
+	}
+	u, err := url.Parse(c.ServeURL)
+	if err != nil {
+		return "", nil
+	}
+	prefix := strings.TrimSuffix(u.Path, "/") + "/"
+	backend := &localStorageBackend{
+		dir:    strings.TrimPrefix(c.UploadTo, "file://"),
+		tracer: &debugtracer.NullTracer{},
+	}
+	return prefix, http.StripPrefix(prefix, backend)
+}
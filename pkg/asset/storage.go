@@ -52,6 +52,19 @@ func StorageFromConfig(cfg *Config, dash Dashboard) (*Storage, error) {
 		if err != nil {
 			return nil, fmt.Errorf("the call to MakeCloudStorageBackend failed: %w", err)
 		}
+	} else if strings.HasPrefix(cfg.UploadTo, "file://") {
+		var err error
+		backend, err = makeLocalStorageBackend(strings.TrimPrefix(cfg.UploadTo, "file://"), cfg.ServeURL, tracer)
+		if err != nil {
+			return nil, fmt.Errorf("the call to makeLocalStorageBackend failed: %w", err)
+		}
+	} else if strings.HasPrefix(cfg.UploadTo, "s3://") {
+		var err error
+		backend, err = makeS3StorageBackend(cfg.S3Endpoint, cfg.S3Region,
+			strings.Trim(strings.TrimPrefix(cfg.UploadTo, "s3://"), "/"), tracer)
+		if err != nil {
+			return nil, fmt.Errorf("the call to makeS3StorageBackend failed: %w", err)
+		}
 	} else if strings.HasPrefix(cfg.UploadTo, "dummy://") {
 		backend = makeDummyStorageBackend()
 	} else {
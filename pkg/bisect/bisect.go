@@ -38,6 +38,8 @@ type Config struct {
 	BuildSemaphore  *instance.Semaphore
 	TestSemaphore   *instance.Semaphore
 	BuildCPUs       int
+	// BuildCache is used to reuse kernel builds of already tested commits (optional).
+	BuildCache *build.Cache
 	// CrossTree specifies whether a cross tree bisection is to take place, i.e.
 	// Kernel.Commit is not reachable from Kernel.Branch.
 	// In this case, bisection starts from their merge base.
@@ -627,6 +629,7 @@ func (env *env) build() (*vcs.Commit, string, error) {
 		SysctlFile:   kern.Sysctl,
 		KernelConfig: bisectEnv.KernelConfig,
 		BuildCPUs:    env.cfg.BuildCPUs,
+		BuildCache:   env.cfg.BuildCache,
 	})
 	if imageDetails.CompilerID != "" {
 		env.log("compiler: %v", imageDetails.CompilerID)
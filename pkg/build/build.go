@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/google/syzkaller/pkg/debugtracer"
+	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/pkg/report"
 	"github.com/google/syzkaller/pkg/vcs"
@@ -40,6 +41,9 @@ type Params struct {
 	Tracer       debugtracer.DebugTracer
 	BuildCPUs    int // If 0, all CPUs will be used.
 	Build        json.RawMessage
+	// If Cache is set, build results are looked up in and stored to the cache.
+	// KernelDir must be a git checkout for caching to work.
+	Cache *Cache
 }
 
 // Information that is returned from the Image function.
@@ -91,6 +95,17 @@ func Image(params Params) (details ImageDetails, err error) {
 			return
 		}
 	}
+	cacheKey := ""
+	if params.Cache != nil {
+		cacheKey, err = buildCacheKey(params)
+		if err != nil {
+			log.Logf(0, "build cache: failed to calculate the key: %v", err)
+			err = nil
+		} else if cached, ok := params.Cache.get(cacheKey, params.OutputDir); ok {
+			log.Logf(0, "build cache: using cached build %v", cacheKey)
+			return cached, nil
+		}
+	}
 	details, err = builder.build(params)
 	if details.CompilerID == "" {
 		// Fill in the compiler info even if the build failed.
@@ -109,6 +124,11 @@ func Image(params Params) (details ImageDetails, err error) {
 			return details, fmt.Errorf("failed to chmod 0600 %v: %w", key, err)
 		}
 	}
+	if cacheKey != "" {
+		if err := params.Cache.put(cacheKey, params.OutputDir, details); err != nil {
+			log.Logf(0, "build cache: failed to store build %v: %v", cacheKey, err)
+		}
+	}
 	return
 }
 
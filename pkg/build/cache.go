@@ -0,0 +1,339 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package build
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// Cache is a content-addressed cache of build results (the contents of Params.OutputDir).
+// Entries are keyed by everything that affects the build output: kernel commit,
+// uncommitted changes in the kernel tree (e.g. tested patches and backports),
+// kernel config, compiler identity and the rest of build parameters.
+// When the total size exceeds the limit, least recently used entries are evicted.
+// A single Cache object is meant to be shared by all concurrent builds in the process
+// (e.g. syz-ci managers, patch testing and bisection jobs).
+type Cache struct {
+	dir     string
+	maxSize int64
+	mu      sync.Mutex
+}
+
+type cacheEntry struct {
+	Details ImageDetails
+	Size    int64
+}
+
+const (
+	cacheEntryFile = "entry.json"
+	cacheTmpPrefix = "tmp-"
+)
+
+// NewCache creates a cache in dir that holds at most maxSize bytes of build results.
+func NewCache(dir string, maxSize int64) (*Cache, error) {
+	if err := osutil.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		// Remove leftovers of interrupted stores.
+		if strings.HasPrefix(file.Name(), cacheTmpPrefix) {
+			os.RemoveAll(filepath.Join(dir, file.Name()))
+		}
+	}
+	return &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+	}, nil
+}
+
+// get copies the cached build results to outputDir.
+func (cache *Cache) get(key, outputDir string) (ImageDetails, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	dir := filepath.Join(cache.dir, key)
+	entry, err := readCacheEntry(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Logf(0, "build cache: %v", err)
+		}
+		return ImageDetails{}, false
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		log.Logf(0, "build cache: %v", err)
+		return ImageDetails{}, false
+	}
+	for _, file := range files {
+		if file.Name() == cacheEntryFile {
+			continue
+		}
+		src, dst := filepath.Join(dir, file.Name()), filepath.Join(outputDir, file.Name())
+		if file.IsDir() {
+			err = osutil.CopyDirRecursively(src, dst)
+		} else {
+			err = osutil.CopyFile(src, dst)
+		}
+		if err != nil {
+			log.Logf(0, "build cache: failed to copy %v: %v", file.Name(), err)
+			return ImageDetails{}, false
+		}
+	}
+	now := time.Now()
+	os.Chtimes(filepath.Join(dir, cacheEntryFile), now, now)
+	return entry.Details, true
+}
+
+// put stores the build results from outputDir and evicts old entries if necessary.
+func (cache *Cache) put(key, outputDir string, details ImageDetails) error {
+	tmpDir, err := os.MkdirTemp(cache.dir, cacheTmpPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := osutil.CopyDirRecursively(outputDir, tmpDir); err != nil {
+		return fmt.Errorf("failed to copy build results: %w", err)
+	}
+	size, err := dirSize(tmpDir)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&cacheEntry{
+		Details: details,
+		Size:    size,
+	})
+	if err != nil {
+		return err
+	}
+	if err := osutil.WriteFile(filepath.Join(tmpDir, cacheEntryFile), data); err != nil {
+		return err
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	dir := filepath.Join(cache.dir, key)
+	if osutil.IsExist(dir) {
+		// Somebody has built the same kernel concurrently.
+		return nil
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return err
+	}
+	return cache.evict()
+}
+
+func (cache *Cache) evict() error {
+	files, err := os.ReadDir(cache.dir)
+	if err != nil {
+		return err
+	}
+	type usedEntry struct {
+		dir      string
+		size     int64
+		lastUsed time.Time
+	}
+	var entries []usedEntry
+	total := int64(0)
+	for _, file := range files {
+		if !file.IsDir() || strings.HasPrefix(file.Name(), cacheTmpPrefix) {
+			continue
+		}
+		dir := filepath.Join(cache.dir, file.Name())
+		entry, err := readCacheEntry(dir)
+		if err != nil {
+			// Broken entry, it will never be used.
+			os.RemoveAll(dir)
+			continue
+		}
+		stat, err := os.Stat(filepath.Join(dir, cacheEntryFile))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, usedEntry{dir, entry.Size, stat.ModTime()})
+		total += entry.Size
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+	for _, entry := range entries {
+		if total <= cache.maxSize {
+			break
+		}
+		if err := os.RemoveAll(entry.dir); err != nil {
+			return err
+		}
+		total -= entry.size
+	}
+	return nil
+}
+
+func readCacheEntry(dir string) (*cacheEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, cacheEntryFile))
+	if err != nil {
+		return nil, err
+	}
+	entry := new(cacheEntry)
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", dir, err)
+	}
+	return entry, nil
+}
+
+func dirSize(dir string) (int64, error) {
+	size := int64(0)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// buildCacheKey returns the cache key for the build.
+// The kernel commit and uncommitted changes are taken from the git repository in params.KernelDir.
+func buildCacheKey(params Params) (string, error) {
+	compilerID, err := compilerIdentity(params.Compiler)
+	if err != nil {
+		return "", err
+	}
+	commit, err := osutil.RunCmd(time.Minute, params.KernelDir, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	changes, err := kernelTreeChanges(params.KernelDir)
+	if err != nil {
+		return "", err
+	}
+	readOptional := func(file string) ([]byte, error) {
+		if file == "" {
+			return nil, nil
+		}
+		return os.ReadFile(file)
+	}
+	cmdline, err := readOptional(params.CmdlineFile)
+	if err != nil {
+		return "", err
+	}
+	sysctl, err := readOptional(params.SysctlFile)
+	if err != nil {
+		return "", err
+	}
+	userspace, err := userspaceID(params.UserspaceDir)
+	if err != nil {
+		return "", err
+	}
+	changesHash := sha256.Sum256(changes)
+	data, err := json.Marshal(map[string]interface{}{
+		"os":        params.TargetOS,
+		"arch":      params.TargetArch,
+		"vm":        params.VMType,
+		"commit":    string(bytes.TrimSpace(commit)),
+		"changes":   hex.EncodeToString(changesHash[:]),
+		"config":    params.Config,
+		"compiler":  compilerID,
+		"linker":    params.Linker,
+		"userspace": userspace,
+		"cmdline":   cmdline,
+		"sysctl":    sysctl,
+		"build":     params.Build,
+	})
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// userspaceID identifies the contents of the userspace dir: names, modes and contents of all files.
+// Modification times are not used, since images are often rewritten with preserved times.
+func userspaceID(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	hash := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%q %v\n", filepath.ToSlash(rel), info.Mode())
+		switch {
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(hash, f)
+			return err
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "%q\n", target)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan userspace dir: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// kernelTreeChanges returns all changes in the kernel tree relative to HEAD
+// including new files that are not ignored by git (e.g. added by a patch).
+func kernelTreeChanges(dir string) ([]byte, error) {
+	diff, err := osutil.RunCmd(10*time.Minute, dir, "git", "diff", "HEAD", "--binary")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := osutil.RunCmd(10*time.Minute, dir, "git", "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	changes := diff
+	for _, file := range strings.Split(string(untracked), "\x00") {
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("\nnew file %q %v\n", file, len(data))...)
+		changes = append(changes, data...)
+	}
+	return changes, nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/vcs"
+	"github.com/google/syzkaller/sys/targets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheKey(t *testing.T) {
+	repo := vcs.MakeTestRepo(t, t.TempDir())
+	repo.CommitChange("first")
+	params := Params{
+		TargetOS:   targets.TestOS,
+		TargetArch: targets.TestArch64,
+		KernelDir:  repo.Dir,
+		Config:     []byte("CONFIG_FOO=y"),
+	}
+	key := func() string {
+		key, err := buildCacheKey(params)
+		require.NoError(t, err)
+		return key
+	}
+	keys := map[string]string{}
+	addKey := func(what string) {
+		k := key()
+		assert.Equal(t, k, key(), "key is not stable")
+		if prev, ok := keys[k]; ok {
+			t.Fatalf("%v and %v have the same key", prev, what)
+		}
+		keys[k] = what
+	}
+	addKey("base")
+	params.Config = []byte("CONFIG_FOO=n")
+	addKey("config")
+	require.NoError(t, osutil.WriteFile(filepath.Join(repo.Dir, "file"), []byte("patched")))
+	addKey("modified file")
+	require.NoError(t, osutil.WriteFile(filepath.Join(repo.Dir, "new"), []byte("new")))
+	addKey("new file")
+	repo.Git("reset", "--hard")
+	repo.Git("clean", "-fd")
+	repo.CommitChange("second")
+	addKey("commit")
+	params.Build = []byte(`{"foo": "bar"}`)
+	addKey("build params")
+	params.UserspaceDir = t.TempDir()
+	addKey("userspace")
+	userspaceImage := filepath.Join(params.UserspaceDir, "image")
+	require.NoError(t, osutil.WriteFile(userspaceImage, []byte("image")))
+	addKey("userspace image")
+	require.NoError(t, os.Chtimes(userspaceImage, time.Time{}, time.Unix(1, 0)))
+	require.NoError(t, osutil.WriteFile(userspaceImage, []byte("IMAGE")))
+	require.NoError(t, os.Chtimes(userspaceImage, time.Time{}, time.Unix(1, 0)))
+	addKey("rewritten userspace image with the same size and time")
+	require.NoError(t, os.Symlink("image", filepath.Join(params.UserspaceDir, "link")))
+	addKey("userspace symlink")
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	const entrySize = 100
+	cache, err := NewCache(filepath.Join(dir, "cache"), 2*entrySize)
+	require.NoError(t, err)
+	store := func(key string) {
+		out := filepath.Join(dir, "out-"+key)
+		require.NoError(t, osutil.MkdirAll(filepath.Join(out, "obj")))
+		require.NoError(t, osutil.WriteFile(filepath.Join(out, "image"), make([]byte, entrySize/2)))
+		require.NoError(t, osutil.WriteFile(filepath.Join(out, "obj", "vmlinux"), []byte(key+"\n")))
+		require.NoError(t, os.Truncate(filepath.Join(out, "obj", "vmlinux"), entrySize/2))
+		require.NoError(t, cache.put(key, out, ImageDetails{Signature: "sign-" + key}))
+	}
+	lookup := func(key string) bool {
+		out := filepath.Join(t.TempDir(), "out")
+		require.NoError(t, osutil.MkdirAll(out))
+		details, ok := cache.get(key, out)
+		if !ok {
+			return false
+		}
+		assert.Equal(t, "sign-"+key, details.Signature)
+		vmlinux, err := os.ReadFile(filepath.Join(out, "obj", "vmlinux"))
+		require.NoError(t, err)
+		assert.Equal(t, key+"\n", string(vmlinux[:len(key)+1]))
+		assert.True(t, osutil.IsExist(filepath.Join(out, "image")))
+		assert.False(t, osutil.IsExist(filepath.Join(out, cacheEntryFile)))
+		return true
+	}
+	setLastUsed := func(key string, ago time.Duration) {
+		when := time.Now().Add(-ago)
+		require.NoError(t, os.Chtimes(filepath.Join(cache.dir, key, cacheEntryFile), when, when))
+	}
+	store("a")
+	store("b")
+	assert.True(t, lookup("a"))
+	assert.True(t, lookup("b"))
+	assert.False(t, lookup("c"))
+	// "a" was used more recently, so "b" must be evicted.
+	setLastUsed("a", time.Minute)
+	setLastUsed("b", time.Hour)
+	store("c")
+	assert.True(t, lookup("a"))
+	assert.False(t, lookup("b"))
+	assert.True(t, lookup("c"))
+
+	// Leftovers of interrupted stores are removed on start.
+	tmp := filepath.Join(cache.dir, cacheTmpPrefix+"123")
+	require.NoError(t, osutil.MkdirAll(tmp))
+	_, err = NewCache(cache.dir, 2*entrySize)
+	require.NoError(t, err)
+	assert.False(t, osutil.IsExist(tmp))
+}
+
+func TestImageCache(t *testing.T) {
+	repo := vcs.MakeTestRepo(t, t.TempDir())
+	repo.CommitChange("first")
+	cache, err := NewCache(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	build := func() string {
+		out := t.TempDir()
+		_, err := Image(Params{
+			TargetOS:   targets.TestOS,
+			TargetArch: targets.TestArch64,
+			KernelDir:  repo.Dir,
+			OutputDir:  out,
+			Config:     []byte("CONFIG_FOO=y"),
+			Cache:      cache,
+		})
+		require.NoError(t, err)
+		return out
+	}
+	build()
+	key, err := buildCacheKey(Params{
+		TargetOS:   targets.TestOS,
+		TargetArch: targets.TestArch64,
+		KernelDir:  repo.Dir,
+		Config:     []byte("CONFIG_FOO=y"),
+	})
+	require.NoError(t, err)
+	require.True(t, osutil.IsExist(filepath.Join(cache.dir, key, "kernel.config")))
+	// The test builder does not produce anything, so put an image into the cache
+	// to see that the second build takes the results from the cache.
+	require.NoError(t, osutil.WriteFile(filepath.Join(cache.dir, key, "image"), []byte("image")))
+	out := build()
+	data, err := os.ReadFile(filepath.Join(out, "image"))
+	require.NoError(t, err)
+	assert.Equal(t, "image", string(data))
+}
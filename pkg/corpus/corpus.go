@@ -24,6 +24,8 @@ type Corpus struct {
 	cover   cover.Cover   // total coverage of all items
 	updates chan<- NewItemEvent
 	*ProgramsList
+	focusAreas []*focusArea
+	focusStats map[string]bool // names of focus areas with registered stats
 	StatProgs  *stat.Val
 	StatSignal *stat.Val
 	StatCover  *stat.Val
@@ -37,6 +39,7 @@ func NewMonitoredCorpus(ctx context.Context, updates chan<- NewItemEvent) *Corpu
 	corpus := &Corpus{
 		ctx:          ctx,
 		progs:        make(map[string]*Item),
+		focusStats:   make(map[string]bool),
 		updates:      updates,
 		ProgramsList: &ProgramsList{},
 	}
@@ -121,6 +124,7 @@ func (corpus *Corpus) Save(inp NewInput) {
 			newItem.Updates = append(newItem.Updates, update)
 		}
 		corpus.progs[sig] = newItem
+		corpus.addFocusLocked(old.Prog, old.Call, newSignal, old.Cover, inp.Cover)
 	} else {
 		corpus.progs[sig] = &Item{
 			Sig:     sig,
@@ -132,6 +136,7 @@ func (corpus *Corpus) Save(inp NewInput) {
 			Updates: []ItemUpdate{update},
 		}
 		corpus.saveProgram(inp.Prog, inp.Signal)
+		corpus.addFocusLocked(inp.Prog, inp.Call, inp.Signal, nil, inp.Cover)
 	}
 	corpus.signal.Merge(inp.Signal)
 	newCover := corpus.cover.MergeDiff(inp.Cover)
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/google/syzkaller/pkg/signal"
+	"github.com/google/syzkaller/pkg/stat"
 	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
 	"github.com/stretchr/testify/assert"
@@ -77,6 +78,97 @@ func TestCorpusCoverage(t *testing.T) {
 	assert.Equal(t, corpus.StatCover.Val(), 3)
 }
 
+func TestCorpusFocusAreas(t *testing.T) {
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	corpus := NewCorpus(context.Background())
+	rs := rand.NewSource(0)
+
+	inp1 := generateInput(target, rs, 5, 5)
+	inp1.Cover = []uint64{10, 11}
+	corpus.Save(inp1)
+
+	corpus.SetFocusAreas([]FocusArea{
+		{
+			Name:     "first",
+			CoverPCs: map[uint64]struct{}{11: {}, 20: {}},
+			Weight:   0.5,
+		},
+		{
+			Name:     "second",
+			CoverPCs: map[uint64]struct{}{30: {}},
+			Weight:   0.2,
+		},
+	})
+	// The existing corpus is distributed among the areas.
+	r := rand.New(rs)
+	assert.Equal(t, inp1.Prog, corpus.ChooseFocusProgram(r, 0))
+	assert.Nil(t, corpus.ChooseFocusProgram(r, 1))
+
+	inp2 := generateInput(target, rs, 5, 5)
+	inp2.Cover = []uint64{20, 30}
+	corpus.Save(inp2)
+	assert.Len(t, corpus.focusAreas[0].Programs(), 2)
+	assert.Equal(t, inp2.Prog, corpus.ChooseFocusProgram(r, 1))
+	assert.Len(t, corpus.focusAreas[0].cover, 2)
+	assert.Equal(t, map[*prog.Syscall]bool{inp2.Prog.Calls[inp2.Call].Meta: true}, corpus.FocusCalls(1))
+
+	// A program that is already in the corpus is added to the areas it newly reaches,
+	// but is not added again to the areas it already reached.
+	inp1.Cover = []uint64{11, 30}
+	corpus.Save(inp1)
+	assert.Len(t, corpus.focusAreas[0].Programs(), 2)
+	assert.Len(t, corpus.focusAreas[1].Programs(), 2)
+	assert.True(t, corpus.FocusCalls(1)[inp1.Prog.Calls[inp1.Call].Meta])
+
+	// Callers may still use indices of the replaced areas.
+	corpus.SetFocusAreas(corpus.FocusAreas()[:1])
+	assert.Nil(t, corpus.ChooseFocusProgram(r, 1))
+	assert.Empty(t, corpus.FocusCalls(1))
+}
+
+func TestCorpusFocusAreaStats(t *testing.T) {
+	target := getTarget(t, targets.TestOS, targets.TestArch64)
+	corpus := NewCorpus(context.Background())
+	inp := generateInput(target, rand.NewSource(0), 5, 5)
+	inp.Cover = []uint64{10, 11}
+	corpus.Save(inp)
+	statValue := func(name string) int {
+		for _, v := range stat.Collect(stat.All) {
+			if v.Name == name {
+				return v.V
+			}
+		}
+		t.Fatalf("no stat %q", name)
+		return 0
+	}
+	setArea := func(pcs ...uint64) {
+		area := FocusArea{Name: "stats test", CoverPCs: make(map[uint64]struct{}), Weight: 1}
+		for _, pc := range pcs {
+			area.CoverPCs[pc] = struct{}{}
+		}
+		corpus.SetFocusAreas([]FocusArea{area})
+	}
+	setArea(10)
+	assert.Equal(t, 1, statValue("focus stats test: corpus"))
+	assert.Equal(t, 1, statValue("focus stats test: coverage"))
+	// The stats are registered once and show the values of the new area.
+	setArea(10, 11)
+	assert.Equal(t, 2, statValue("focus stats test: coverage"))
+	setArea(20)
+	assert.Equal(t, 0, statValue("focus stats test: corpus"))
+	corpus.SetFocusAreas(nil)
+	assert.Equal(t, 0, statValue("focus stats test: coverage"))
+	// A new corpus reports its own values rather than values of the first one.
+	setArea(10)
+	corpus2 := NewCorpus(context.Background())
+	inp2 := generateInput(target, rand.NewSource(1), 5, 5)
+	inp2.Cover = []uint64{10}
+	corpus2.Save(inp)
+	corpus2.Save(inp2)
+	corpus2.SetFocusAreas(corpus.FocusAreas())
+	assert.Equal(t, 2, statValue("focus stats test: corpus"))
+}
+
 func TestCorpusSaveConcurrency(t *testing.T) {
 	target := getTarget(t, targets.TestOS, targets.TestArch64)
 	corpus := NewCorpus(context.Background())
@@ -0,0 +1,168 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package corpus
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/google/syzkaller/pkg/cover"
+	"github.com/google/syzkaller/pkg/signal"
+	"github.com/google/syzkaller/pkg/stat"
+	"github.com/google/syzkaller/prog"
+)
+
+// FocusArea is a part of the kernel (e.g. a subsystem) that deserves a fixed share of fuzzing.
+type FocusArea struct {
+	Name string
+	// CoverPCs are coverage PCs (in the form they are reported by the executor) that belong to the area.
+	CoverPCs map[uint64]struct{}
+	// Calls are syscalls that are known to belong to the area regardless of coverage (optional).
+	Calls map[*prog.Syscall]bool
+	// Weight is the share of fuzzing executions to spend on the area, in (0, 1].
+	Weight float64
+}
+
+type focusArea struct {
+	FocusArea
+	*ProgramsList
+	cover cover.Cover
+	calls map[*prog.Syscall]int // number of corpus items that reach the area via the call
+}
+
+// SetFocusAreas replaces the set of focus areas and distributes the existing corpus programs among them.
+func (corpus *Corpus) SetFocusAreas(areas []FocusArea) {
+	corpus.mu.Lock()
+	defer corpus.mu.Unlock()
+	corpus.focusAreas = nil
+	for _, area := range areas {
+		corpus.focusAreas = append(corpus.focusAreas, &focusArea{FocusArea: area})
+		if corpus.focusStats[area.Name] {
+			continue
+		}
+		// Stats can't be unregistered, so they are registered once per area name
+		// and report values of the current area with the name.
+		corpus.focusStats[area.Name] = true
+		name := area.Name
+		stat.New(fmt.Sprintf("focus %v: corpus", name),
+			fmt.Sprintf("Number of corpus programs that reach the %v focus area", name),
+			stat.Graph("focus corpus"), func() int {
+				return corpus.focusAreaStat(name, func(fa *focusArea) int { return len(fa.Programs()) })
+			})
+		stat.New(fmt.Sprintf("focus %v: coverage", name),
+			fmt.Sprintf("Source coverage in the %v focus area", name),
+			stat.Graph("focus coverage"), func() int {
+				return corpus.focusAreaStat(name, func(fa *focusArea) int { return len(fa.cover) })
+			})
+	}
+	corpus.rebuildFocusAreasLocked()
+}
+
+// focusAreaStat returns the stat value for the current focus area with the name (0 if there is no such area).
+func (corpus *Corpus) focusAreaStat(name string, fn func(*focusArea) int) int {
+	corpus.mu.RLock()
+	defer corpus.mu.RUnlock()
+	for _, fa := range corpus.focusAreas {
+		if fa.Name == name {
+			return fn(fa)
+		}
+	}
+	return 0
+}
+
+// FocusAreas returns the current focus areas in the order they were set.
+func (corpus *Corpus) FocusAreas() []FocusArea {
+	corpus.mu.RLock()
+	defer corpus.mu.RUnlock()
+	var ret []FocusArea
+	for _, area := range corpus.focusAreas {
+		ret = append(ret, area.FocusArea)
+	}
+	return ret
+}
+
+// ChooseFocusProgram chooses a corpus program that reaches the area.
+// Returns nil if no such programs are known yet, or if there is no such area
+// (the areas may have been replaced since the caller has seen them).
+func (corpus *Corpus) ChooseFocusProgram(r *rand.Rand, area int) *prog.Prog {
+	corpus.mu.RLock()
+	fa := corpus.focusAreaLocked(area)
+	corpus.mu.RUnlock()
+	if fa == nil {
+		return nil
+	}
+	return fa.ChooseProgram(r)
+}
+
+// FocusCalls returns syscalls that belong to the area: both the static ones
+// and the ones that were observed to reach the area code in the corpus.
+// Returns an empty map if there is no such area.
+func (corpus *Corpus) FocusCalls(area int) map[*prog.Syscall]bool {
+	corpus.mu.RLock()
+	defer corpus.mu.RUnlock()
+	ret := make(map[*prog.Syscall]bool)
+	fa := corpus.focusAreaLocked(area)
+	if fa == nil {
+		return ret
+	}
+	for call := range fa.Calls {
+		ret[call] = true
+	}
+	for call := range fa.calls {
+		ret[call] = true
+	}
+	return ret
+}
+
+func (corpus *Corpus) focusAreaLocked(area int) *focusArea {
+	if area < 0 || area >= len(corpus.focusAreas) {
+		return nil
+	}
+	return corpus.focusAreas[area]
+}
+
+func (corpus *Corpus) rebuildFocusAreasLocked() {
+	for _, fa := range corpus.focusAreas {
+		fa.ProgramsList = &ProgramsList{}
+		fa.cover = nil
+		fa.calls = make(map[*prog.Syscall]int)
+	}
+	for _, item := range corpus.progs {
+		corpus.addFocusLocked(item.Prog, item.Call, item.Signal, nil, item.Cover)
+	}
+}
+
+// addFocusLocked registers the program in all focus areas its coverage cov reaches.
+// oldCov is the coverage the program was registered with before (for programs already in the corpus),
+// the program is added only to the areas that oldCov does not reach.
+func (corpus *Corpus) addFocusLocked(p *prog.Prog, call int, sig signal.Signal, oldCov, cov []uint64) {
+	for _, fa := range corpus.focusAreas {
+		var matched []uint64
+		for _, pc := range cov {
+			if _, ok := fa.CoverPCs[pc]; ok {
+				matched = append(matched, pc)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		fa.cover.Merge(matched)
+		if fa.reaches(oldCov) {
+			continue
+		}
+		fa.saveProgram(p, sig)
+		if call >= 0 && call < len(p.Calls) {
+			fa.calls[p.Calls[call].Meta]++
+		}
+	}
+}
+
+func (fa *focusArea) reaches(cov []uint64) bool {
+	for _, pc := range cov {
+		if _, ok := fa.CoverPCs[pc]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -45,4 +45,5 @@ func (corpus *Corpus) Minimize(cover bool) {
 		programsList.saveProgram(inp.Prog, inp.Signal)
 	}
 	corpus.ProgramsList.replace(programsList)
+	corpus.rebuildFocusAreasLocked()
 }
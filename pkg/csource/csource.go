@@ -39,15 +39,21 @@ import (
 
 // Write generates C source for program p based on the provided options opt.
 func Write(p *prog.Prog, opts Options) ([]byte, error) {
+	return write(p, opts, nil)
+}
+
+// write is Write that also emits comments before the calls, see context.callComments.
+func write(p *prog.Prog, opts Options, callComments map[int][]string) ([]byte, error) {
 	if err := opts.Check(p.Target.OS); err != nil {
 		return nil, fmt.Errorf("csource: invalid opts: %w", err)
 	}
 	ctx := &context{
-		p:         p,
-		opts:      opts,
-		target:    p.Target,
-		sysTarget: targets.Get(p.Target.OS, p.Target.Arch),
-		calls:     make(map[string]uint64),
+		p:            p,
+		opts:         opts,
+		target:       p.Target,
+		sysTarget:    targets.Get(p.Target.OS, p.Target.Arch),
+		calls:        make(map[string]uint64),
+		callComments: callComments,
 	}
 	return ctx.generateSource()
 }
@@ -58,6 +64,11 @@ type context struct {
 	target    *prog.Target
 	sysTarget *targets.Target
 	calls     map[string]uint64 // CallName -> NR
+	// Struct declarations generated in the Pretty mode.
+	prettyStructs map[string]*prettyStruct
+	// Comment lines emitted before the call with the given index,
+	// comments with index len(p.Calls) are emitted after the last call.
+	callComments map[int][]string
 }
 
 func generateSandboxFunctionSignature(sandboxName string, sandboxArg int) string {
@@ -78,6 +89,7 @@ func (ctx *context) generateSource() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	ctx.addCallComments(calls)
 
 	mmapProg := ctx.p.Target.DataMmapProg()
 	mmapCalls, _, err := ctx.generateProgCalls(mmapProg, false)
@@ -108,13 +120,18 @@ func (ctx *context) generateSource() ([]byte, error) {
 		fmt.Fprintf(varsBuf, "};\n")
 	}
 
+	mmapData := strings.Join(mmapCalls, "")
+	if ctx.opts.Pretty {
+		mmapData = ctx.prettyMmapData(mmapData)
+	}
+
 	sandboxFunc := generateSandboxFunctionSignature(ctx.opts.Sandbox, ctx.opts.SandboxArg)
 	replacements := map[string]string{
 		"PROCS":           fmt.Sprint(ctx.opts.Procs),
 		"REPEAT_TIMES":    fmt.Sprint(ctx.opts.RepeatTimes),
 		"NUM_CALLS":       fmt.Sprint(len(ctx.p.Calls)),
-		"MMAP_DATA":       strings.Join(mmapCalls, ""),
-		"SYSCALL_DEFINES": ctx.generateSyscallDefines(),
+		"MMAP_DATA":       mmapData,
+		"SYSCALL_DEFINES": ctx.generateSyscallDefines() + ctx.generatePrettyDecls(),
 		"SANDBOX_FUNC":    sandboxFunc,
 		"RESULTS":         varsBuf.String(),
 		"SYSCALLS":        ctx.generateSyscalls(calls, len(vars) != 0),
@@ -177,10 +194,48 @@ func (ctx *context) filterCalls() {
 			p = ctx.p.Clone()
 		}
 		p.RemoveCall(i)
+		ctx.removeCallComments(i, len(p.Calls))
 	}
 	ctx.p = p
 }
 
+// removeCallComments moves comments of the removed call i to the next call,
+// ncalls is the number of calls after the removal.
+func (ctx *context) removeCallComments(i, ncalls int) {
+	if ctx.callComments == nil {
+		return
+	}
+	comments := make(map[int][]string)
+	for ci := 0; ci <= ncalls+1; ci++ {
+		if len(ctx.callComments[ci]) == 0 {
+			continue
+		}
+		to := ci
+		if ci > i {
+			to--
+		}
+		comments[to] = append(comments[to], ctx.callComments[ci]...)
+	}
+	ctx.callComments = comments
+}
+
+// addCallComments prepends comments to the generated calls.
+func (ctx *context) addCallComments(calls []string) {
+	comment := func(ci int) string {
+		buf := new(bytes.Buffer)
+		for _, line := range ctx.callComments[ci] {
+			fmt.Fprintf(buf, "\t// %v\n", line)
+		}
+		return buf.String()
+	}
+	for ci := range calls {
+		calls[ci] = comment(ci) + calls[ci]
+	}
+	if len(calls) != 0 {
+		calls[len(calls)-1] += comment(len(calls))
+	}
+}
+
 func (ctx *context) generateSyscalls(calls []string, hasVars bool) string {
 	opts := ctx.opts
 	buf := new(bytes.Buffer)
@@ -236,6 +291,13 @@ func (ctx *context) generateSyscallDefines() string {
 	return buf.String()
 }
 
+func (ctx *context) generatePrettyDecls() string {
+	if !ctx.opts.Pretty {
+		return ""
+	}
+	return ctx.prettyDecls()
+}
+
 func (ctx *context) generateProgCalls(p *prog.Prog, trace bool) ([]string, []uint64, error) {
 	exec, err := p.SerializeForExec()
 	if err != nil {
@@ -245,18 +307,26 @@ func (ctx *context) generateProgCalls(p *prog.Prog, trace bool) ([]string, []uin
 	if err != nil {
 		return nil, nil, err
 	}
-	calls, vars := ctx.generateCalls(decoded, trace)
+	var typed [][]prettyCopyin
+	if ctx.opts.Pretty {
+		typed = prettyCopyins(p)
+	}
+	calls, vars := ctx.generateCalls(decoded, typed, trace)
 	return calls, vars, nil
 }
 
-func (ctx *context) generateCalls(p prog.ExecProg, trace bool) ([]string, []uint64) {
+func (ctx *context) generateCalls(p prog.ExecProg, typed [][]prettyCopyin, trace bool) ([]string, []uint64) {
 	var calls []string
 	csumSeq := 0
 	for ci, call := range p.Calls {
 		w := new(bytes.Buffer)
 		// Copyin.
-		for _, copyin := range call.Copyin {
-			ctx.copyin(w, &csumSeq, copyin)
+		if ctx.opts.Pretty && ci < len(typed) {
+			ctx.prettyCopyinAll(w, &csumSeq, call, typed[ci])
+		} else {
+			for _, copyin := range call.Copyin {
+				ctx.copyin(w, &csumSeq, copyin)
+			}
 		}
 
 		if call.Props.FailNth > 0 {
@@ -355,7 +425,16 @@ func (ctx *context) fmtCallBody(call prog.ExecCall) string {
 			}
 			com := ctx.argComment(call.Meta.Args[i], arg)
 			suf := ctx.literalSuffix(arg, native)
-			argsStrs = append(argsStrs, com+handleBigEndian(arg, ctx.constArgToStr(arg, suf)))
+			val := handleBigEndian(arg, ctx.constArgToStr(arg, suf))
+			if ctx.opts.Pretty {
+				switch call.Meta.Args[i].Type.(type) {
+				case *prog.PtrType, *prog.VmaType:
+					if addr, ok := ctx.prettyDataAddr(arg.Value); ok {
+						val = "(intptr_t)" + addr
+					}
+				}
+			}
+			argsStrs = append(argsStrs, com+val)
 		case prog.ExecArgResult:
 			if arg.Format != prog.FormatNative && arg.Format != prog.FormatBigEndian {
 				panic("string format in syscall argument")
@@ -384,8 +463,8 @@ func (ctx *context) generateCsumInet(w *bytes.Buffer, addr uint64, arg prog.Exec
 	for i, chunk := range arg.Chunks {
 		switch chunk.Kind {
 		case prog.ExecArgCsumChunkData:
-			fmt.Fprintf(w, "\tNONFAILING(csum_inet_update(&csum_%d, (const uint8*)0x%x, %d));\n",
-				csumSeq, chunk.Value, chunk.Size)
+			fmt.Fprintf(w, "\tNONFAILING(csum_inet_update(&csum_%d, (const uint8*)%v, %d));\n",
+				csumSeq, ctx.fmtAddr(chunk.Value), chunk.Size)
 		case prog.ExecArgCsumChunkConst:
 			fmt.Fprintf(w, "\tuint%d csum_%d_chunk_%d = 0x%x;\n",
 				chunk.Size*8, csumSeq, i, chunk.Value)
@@ -395,8 +474,8 @@ func (ctx *context) generateCsumInet(w *bytes.Buffer, addr uint64, arg prog.Exec
 			panic(fmt.Sprintf("unknown checksum chunk kind %v", chunk.Kind))
 		}
 	}
-	fmt.Fprintf(w, "\tNONFAILING(*(uint16*)0x%x = csum_inet_digest(&csum_%d));\n",
-		addr, csumSeq)
+	fmt.Fprintf(w, "\tNONFAILING(*(uint16*)%v = csum_inet_digest(&csum_%d));\n",
+		ctx.fmtAddr(addr), csumSeq)
 }
 
 func (ctx *context) copyin(w *bytes.Buffer, csumSeq *int, copyin prog.ExecCopyin) {
@@ -416,19 +495,19 @@ func (ctx *context) copyin(w *bytes.Buffer, csumSeq *int, copyin prog.ExecCopyin
 			if ctx.target.BigEndian {
 				bitfieldOffset = arg.Size*8 - arg.BitfieldOffset - arg.BitfieldLength
 			}
-			fmt.Fprintf(w, "\tNONFAILING(STORE_BY_BITMASK(uint%v, %v, 0x%x, %v, %v, %v));\n",
-				arg.Size*8, htobe, copyin.Addr, ctx.constArgToStr(arg, ""),
+			fmt.Fprintf(w, "\tNONFAILING(STORE_BY_BITMASK(uint%v, %v, %v, %v, %v, %v));\n",
+				arg.Size*8, htobe, ctx.fmtAddr(copyin.Addr), ctx.constArgToStr(arg, ""),
 				bitfieldOffset, arg.BitfieldLength)
 		}
 	case prog.ExecArgResult:
 		ctx.copyinVal(w, copyin.Addr, arg.Size, ctx.resultArgToStr(arg), arg.Format)
 	case prog.ExecArgData:
 		if bytes.Equal(arg.Data, bytes.Repeat(arg.Data[:1], len(arg.Data))) {
-			fmt.Fprintf(w, "\tNONFAILING(memset((void*)0x%x, %v, %v));\n",
-				copyin.Addr, arg.Data[0], len(arg.Data))
+			fmt.Fprintf(w, "\tNONFAILING(memset((void*)%v, %v, %v));\n",
+				ctx.fmtAddr(copyin.Addr), arg.Data[0], len(arg.Data))
 		} else {
-			fmt.Fprintf(w, "\tNONFAILING(memcpy((void*)0x%x, \"%s\", %v));\n",
-				copyin.Addr, toCString(arg.Data, arg.Readable), len(arg.Data))
+			fmt.Fprintf(w, "\tNONFAILING(memcpy((void*)%v, \"%s\", %v));\n",
+				ctx.fmtAddr(copyin.Addr), toCString(arg.Data, arg.Readable), len(arg.Data))
 		}
 	case prog.ExecArgCsum:
 		switch arg.Kind {
@@ -446,22 +525,22 @@ func (ctx *context) copyin(w *bytes.Buffer, csumSeq *int, copyin prog.ExecCopyin
 func (ctx *context) copyinVal(w *bytes.Buffer, addr, size uint64, val string, bf prog.BinaryFormat) {
 	switch bf {
 	case prog.FormatNative, prog.FormatBigEndian:
-		fmt.Fprintf(w, "\tNONFAILING(*(uint%v*)0x%x = %v);\n", size*8, addr, val)
+		fmt.Fprintf(w, "\tNONFAILING(*(uint%v*)%v = %v);\n", size*8, ctx.fmtAddr(addr), val)
 	case prog.FormatStrDec:
 		if size != 20 {
 			panic("bad strdec size")
 		}
-		fmt.Fprintf(w, "\tNONFAILING(sprintf((char*)0x%x, \"%%020llu\", (long long)%v));\n", addr, val)
+		fmt.Fprintf(w, "\tNONFAILING(sprintf((char*)%v, \"%%020llu\", (long long)%v));\n", ctx.fmtAddr(addr), val)
 	case prog.FormatStrHex:
 		if size != 18 {
 			panic("bad strdec size")
 		}
-		fmt.Fprintf(w, "\tNONFAILING(sprintf((char*)0x%x, \"0x%%016llx\", (long long)%v));\n", addr, val)
+		fmt.Fprintf(w, "\tNONFAILING(sprintf((char*)%v, \"0x%%016llx\", (long long)%v));\n", ctx.fmtAddr(addr), val)
 	case prog.FormatStrOct:
 		if size != 23 {
 			panic("bad strdec size")
 		}
-		fmt.Fprintf(w, "\tNONFAILING(sprintf((char*)0x%x, \"%%023llo\", (long long)%v));\n", addr, val)
+		fmt.Fprintf(w, "\tNONFAILING(sprintf((char*)%v, \"%%023llo\", (long long)%v));\n", ctx.fmtAddr(addr), val)
 	default:
 		panic("unknown binary format")
 	}
@@ -489,8 +568,8 @@ func (ctx *context) copyout(w *bytes.Buffer, call prog.ExecCall, resCopyout bool
 		fmt.Fprintf(w, "\t\tr[%v] = res;\n", call.Index)
 	}
 	for _, copyout := range call.Copyout {
-		fmt.Fprintf(w, "\t\tNONFAILING(r[%v] = *(uint%v*)0x%x);\n",
-			copyout.Index, copyout.Size*8, copyout.Addr)
+		fmt.Fprintf(w, "\t\tNONFAILING(r[%v] = *(uint%v*)%v);\n",
+			copyout.Index, copyout.Size*8, ctx.fmtAddr(copyout.Addr))
 	}
 	if copyoutMultiple {
 		fmt.Fprintf(w, "\t}\n")
@@ -519,11 +598,11 @@ func (ctx *context) factorizeAsFlags(value uint64, flags []string, attemptsLeft
 	return subset, remainder
 }
 
-func (ctx *context) prettyPrintValue(field prog.Field, arg prog.ExecArgConst) string {
+func (ctx *context) prettyPrintValue(typ prog.Type, arg prog.ExecArgConst) string {
 	mask := (uint64(1) << (arg.Size * 8)) - 1
 	v := arg.Value & mask
 
-	f := ctx.p.Target.FlagsMap[field.Type.Name()]
+	f := ctx.p.Target.FlagsMap[typ.Name()]
 	if len(f) == 0 {
 		return ""
 	}
@@ -544,7 +623,7 @@ func (ctx *context) argComment(field prog.Field, arg prog.ExecArg) string {
 	val := ""
 	constArg, isConstArg := arg.(prog.ExecArgConst)
 	if isConstArg {
-		val = ctx.prettyPrintValue(field, constArg)
+		val = ctx.prettyPrintValue(field.Type, constArg)
 	}
 
 	return "/*" + field.Name + "=" + val + "*/"
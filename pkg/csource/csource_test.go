@@ -184,6 +184,7 @@ func TestSource(t *testing.T) {
 	type Test struct {
 		input  string
 		output string
+		opts   Options
 	}
 	tests := []Test{
 		{
@@ -240,6 +241,42 @@ syscall(SYS_csource7, /*flag=BIT_1*/2ul);
 syscall(SYS_csource7, /*flag=BIT_0_AND_1*/3ul);
 syscall(SYS_csource7, /*flag=*/4ul);
 syscall(SYS_csource7, /*flag=BIT_0|0x4*/5ul);
+`,
+		},
+		{
+			input: `
+r0 = csource0(0x1)
+csource8(&AUTO={0x1, 0x3, &AUTO=0x2})
+csource9(&AUTO={0x2, r0})
+csource2(&AUTO="12345678")
+`,
+			opts: Options{Pretty: true},
+			output: `
+res = syscall(SYS_csource0, /*num=*/1);
+if (res != -1)
+	r[0] = res;
+NONFAILING(((struct syz_csource_struct0*)(syz_data + 0x40))->num = 1);
+NONFAILING(((struct syz_csource_struct0*)(syz_data + 0x40))->flag = /*BIT_0_AND_1*/3);
+NONFAILING(((struct syz_csource_struct0*)(syz_data + 0x40))->buf = (uint64)(uintptr_t)(syz_data + 0x80));
+NONFAILING(*(uint8*)(syz_data + 0x80) = 2);
+syscall(SYS_csource8, /*arg=*/(intptr_t)(syz_data + 0x40));
+NONFAILING(*(struct syz_csource_struct1*)(syz_data + 0xc0) = (struct syz_csource_struct1){.num = 2, .fd = r[0]});
+syscall(SYS_csource9, /*arg=*/(intptr_t)(syz_data + 0xc0));
+NONFAILING(memcpy((void*)(syz_data + 0x100), "\x12\x34\x56\x78", 4));
+syscall(SYS_csource2, /*buf=*/(intptr_t)(syz_data + 0x100));
+`,
+		},
+		{
+			input: `
+test$text_x86_64(&AUTO="0f300f32f4", 0x5)
+`,
+			opts: Options{Pretty: true},
+			output: `
+// 0000: 0f 30                          WRMSR
+// 0002: 0f 32                          RDMSR
+// 0004: f4                             HLT
+NONFAILING(memcpy((void*)(syz_data + 0x40), "\x0f\x30\x0f\x32\xf4", 5));
+syscall(SYS_test, /*a0=*/(intptr_t)(syz_data + 0x40), /*a1=*/5, 0, 0, 0, 0);
 `,
 		},
 	}
@@ -251,6 +288,7 @@ syscall(SYS_csource7, /*flag=BIT_0|0x4*/5ul);
 			}
 			ctx := &context{
 				p:         p,
+				opts:      test.opts,
 				target:    target,
 				sysTarget: targets.Get(target.OS, target.Arch),
 			}
@@ -50,6 +50,10 @@ type Options struct {
 	HandleSegv bool `json:"segv,omitempty"`
 
 	Trace bool `json:"trace,omitempty"`
+	// Pretty uses type information to make the source more readable for humans
+	// (named struct declarations and initializers, flag names, data addresses relative to
+	// the data area that is allocated at an address chosen by the kernel).
+	Pretty bool `json:"pretty,omitempty"`
 	LegacyOptions
 }
 
@@ -122,40 +126,74 @@ func (opts Options) Check(OS string) error {
 }
 
 func (opts Options) checkLinuxOnly(OS string) error {
+	if issues := opts.unsupportedOptions(OS); len(issues) != 0 {
+		return errors.New(issues[0].Reason)
+	}
+	return nil
+}
+
+// unsupportedOptions returns all enabled options that are not supported on OS.
+func (opts Options) unsupportedOptions(OS string) []PortabilityIssue {
 	if OS == targets.Linux {
 		return nil
 	}
+	var issues []PortabilityIssue
+	unsupported := func(name string) {
+		issues = append(issues, PortabilityIssue{
+			Option: name,
+			Call:   -1,
+			Reason: fmt.Sprintf("option %v is not supported on %v", name, OS),
+		})
+	}
 	if opts.NetInjection && !(OS == targets.OpenBSD || OS == targets.FreeBSD || OS == targets.NetBSD) {
-		return fmt.Errorf("option NetInjection is not supported on %v", OS)
+		unsupported("NetInjection")
+	}
+	// The Pretty mode reserves the data area with mmap and then maps the data area over the reservation.
+	if opts.Pretty && (OS == targets.Windows || OS == targets.Fuchsia || OS == targets.Trusty) {
+		unsupported("Pretty")
 	}
 	if opts.Sandbox == sandboxNamespace ||
 		(opts.Sandbox == sandboxSetuid && !(OS == targets.OpenBSD || OS == targets.FreeBSD || OS == targets.NetBSD)) ||
 		opts.Sandbox == sandboxAndroid {
-		return fmt.Errorf("option Sandbox=%v is not supported on %v", opts.Sandbox, OS)
-	}
-	for name, opt := range map[string]*bool{
-		"NetDevices":    &opts.NetDevices,
-		"NetReset":      &opts.NetReset,
-		"Cgroups":       &opts.Cgroups,
-		"BinfmtMisc":    &opts.BinfmtMisc,
-		"CloseFDs":      &opts.CloseFDs,
-		"KCSAN":         &opts.KCSAN,
-		"DevlinkPCI":    &opts.DevlinkPCI,
-		"NicVF":         &opts.NicVF,
-		"USB":           &opts.USB,
-		"VhciInjection": &opts.VhciInjection,
-		"Wifi":          &opts.Wifi,
-		"ieee802154":    &opts.IEEE802154,
-		"Fault":         &opts.Fault,
-		"Leak":          &opts.Leak,
-		"Sysctl":        &opts.Sysctl,
-		"Swap":          &opts.Swap,
-	} {
-		if *opt {
-			return fmt.Errorf("option %v is not supported on %v", name, OS)
+		issues = append(issues, PortabilityIssue{
+			Option: "Sandbox",
+			Call:   -1,
+			Reason: fmt.Sprintf("option Sandbox=%v is not supported on %v", opts.Sandbox, OS),
+		})
+	}
+	for _, opt := range opts.linuxOnlyOptions() {
+		if *opt.value {
+			unsupported(opt.name)
 		}
 	}
-	return nil
+	return issues
+}
+
+type namedOption struct {
+	name  string
+	value *bool
+}
+
+// linuxOnlyOptions returns pointers to the boolean options that are supported only on Linux.
+func (opts *Options) linuxOnlyOptions() []namedOption {
+	return []namedOption{
+		{"NetDevices", &opts.NetDevices},
+		{"NetReset", &opts.NetReset},
+		{"Cgroups", &opts.Cgroups},
+		{"BinfmtMisc", &opts.BinfmtMisc},
+		{"CloseFDs", &opts.CloseFDs},
+		{"KCSAN", &opts.KCSAN},
+		{"DevlinkPCI", &opts.DevlinkPCI},
+		{"NicVF", &opts.NicVF},
+		{"USB", &opts.USB},
+		{"VhciInjection", &opts.VhciInjection},
+		{"Wifi", &opts.Wifi},
+		{"ieee802154", &opts.IEEE802154},
+		{"Fault", &opts.Fault},
+		{"Leak", &opts.Leak},
+		{"Sysctl", &opts.Sysctl},
+		{"Swap", &opts.Swap},
+	}
 }
 
 func DefaultOpts(cfg *mgrconfig.Config) Options {
@@ -25,6 +25,22 @@ func TestParseOptions(t *testing.T) {
 	}
 }
 
+func TestPrettyOptions(t *testing.T) {
+	// The compile tests must cover the Pretty mode on all OSes that support it.
+	for _, OS := range []string{targets.Linux, targets.FreeBSD, targets.NetBSD, targets.TestOS} {
+		found := false
+		for _, opts := range allOptionsSingle(OS) {
+			found = found || opts.Pretty
+		}
+		if !found {
+			t.Errorf("no Pretty options for %v", OS)
+		}
+	}
+	if err := (Options{Pretty: true}).Check(targets.Windows); err == nil {
+		t.Errorf("Pretty is not supported on %v", targets.Windows)
+	}
+}
+
 func TestParseOptionsCanned(t *testing.T) {
 	// Dashboard stores csource options with syzkaller reproducers,
 	// so we need to be able to parse old formats.
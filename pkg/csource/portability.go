@@ -0,0 +1,242 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// PortableOSes is the list of OSes for which CheckPortability produces reports.
+var PortableOSes = []string{
+	targets.Linux,
+	targets.FreeBSD,
+	targets.NetBSD,
+	targets.OpenBSD,
+	targets.Fuchsia,
+}
+
+// PortabilityIssue describes a single reason why a program can't be reproduced as is on some OS.
+type PortabilityIssue struct {
+	// Option is the name of the unsupported Options field, if the issue is caused by an option.
+	Option string
+	// Call is the index of the unsupported call in the program, or -1.
+	Call int
+	// CallName is the name of the unsupported call, if Call != -1.
+	CallName string
+	Reason   string
+}
+
+// PortabilityReport lists all issues that prevent a program from being reproduced on the OS.
+type PortabilityReport struct {
+	OS     string
+	Arch   string
+	Issues []PortabilityIssue
+}
+
+func (rep *PortabilityReport) Portable() bool {
+	return len(rep.Issues) == 0
+}
+
+func (rep *PortabilityReport) String() string {
+	buf := new(bytes.Buffer)
+	if rep.Portable() {
+		fmt.Fprintf(buf, "%v/%v: portable\n", rep.OS, rep.Arch)
+		return buf.String()
+	}
+	fmt.Fprintf(buf, "%v/%v: %v issues\n", rep.OS, rep.Arch, len(rep.Issues))
+	for _, issue := range rep.Issues {
+		fmt.Fprintf(buf, "\t%v\n", issue.Reason)
+	}
+	return buf.String()
+}
+
+// CheckPortability checks whether program p with options opts can be reproduced on each of PortableOSes.
+// Reports are returned in the PortableOSes order.
+func CheckPortability(p *prog.Prog, opts Options) []*PortabilityReport {
+	var reports []*PortabilityReport
+	for _, OS := range PortableOSes {
+		reports = append(reports, checkPortability(p, opts, OS))
+	}
+	return reports
+}
+
+func checkPortability(p *prog.Prog, opts Options, OS string) *PortabilityReport {
+	rep := &PortabilityReport{
+		OS:     OS,
+		Arch:   portableArch(OS, p.Target.Arch),
+		Issues: opts.unsupportedOptions(OS),
+	}
+	target, err := prog.GetTarget(rep.OS, rep.Arch)
+	if err != nil {
+		rep.Issues = append(rep.Issues, PortabilityIssue{
+			Call:   -1,
+			Reason: fmt.Sprintf("target %v/%v is not available: %v", rep.OS, rep.Arch, err),
+		})
+		return rep
+	}
+	// Calls that are successfully converted to the target. Calls with the same name may still
+	// have different layouts on different OSes, so the conversion is checked strictly.
+	converted := make(map[int]bool)
+	for i, c := range p.Calls {
+		reason := ""
+		meta := target.SyscallMap[c.Meta.Name]
+		switch {
+		case meta == nil:
+			reason = fmt.Sprintf("call %v is not described on %v", c.Meta.Name, OS)
+		case meta.Attrs.Disabled:
+			reason = fmt.Sprintf("call %v is disabled on %v", c.Meta.Name, OS)
+		default:
+			converted[i] = true
+			if _, err := convertCalls(p, target, converted); err != nil {
+				delete(converted, i)
+				reason = fmt.Sprintf("call %v has a different layout on %v: %v",
+					c.Meta.Name, OS, strings.Split(err.Error(), "\n")[0])
+			}
+		}
+		if reason == "" {
+			continue
+		}
+		rep.Issues = append(rep.Issues, PortabilityIssue{
+			Call:     i,
+			CallName: c.Meta.Name,
+			Reason:   reason,
+		})
+	}
+	return rep
+}
+
+// convertCalls converts the calls of p with the given indices to the target.
+func convertCalls(p *prog.Prog, target *prog.Target, calls map[int]bool) (*prog.Prog, error) {
+	p = p.Clone()
+	for i := len(p.Calls) - 1; i >= 0; i-- {
+		if !calls[i] {
+			p.RemoveCall(i)
+		}
+	}
+	if p.Target == target {
+		return p, nil
+	}
+	return target.Deserialize(p.Serialize(), prog.Strict)
+}
+
+// portableArch selects the arch to use for OS, preferring arch if the OS supports it.
+func portableArch(OS, arch string) string {
+	archs := targets.List[OS]
+	if archs[arch] != nil {
+		return arch
+	}
+	if archs[targets.AMD64] != nil {
+		return targets.AMD64
+	}
+	var all []string
+	for a := range archs {
+		all = append(all, a)
+	}
+	sort.Strings(all)
+	if len(all) == 0 {
+		return arch
+	}
+	return all[0]
+}
+
+// WriteBestEffort generates C source for program p for the target OS.
+// Unsupported options are disabled and listed in a comment at the top of the source.
+// Unsupported calls are removed from the program and left as comments in their place.
+func WriteBestEffort(p *prog.Prog, opts Options, OS string) ([]byte, *PortabilityReport, error) {
+	rep := checkPortability(p, opts, OS)
+	target, err := prog.GetTarget(rep.OS, rep.Arch)
+	if err != nil {
+		return nil, rep, err
+	}
+	removed := make(map[int]string)
+	for _, issue := range rep.Issues {
+		if issue.Call != -1 {
+			removed[issue.Call] = issue.Reason
+		}
+	}
+	// Comments with removed calls keyed by the index of the next remaining call.
+	callComments := make(map[int][]string)
+	next := 0
+	for i, call := range p.SerializeCalls() {
+		if reason, ok := removed[i]; ok {
+			callComments[next] = append(callComments[next], reason+":", call)
+		} else {
+			next++
+		}
+	}
+	remaining := make(map[int]bool)
+	for i := range p.Calls {
+		if _, ok := removed[i]; !ok {
+			remaining[i] = true
+		}
+	}
+	// The remaining calls are already checked to convert strictly by checkPortability.
+	portable, err := convertCalls(p, target, remaining)
+	if err != nil {
+		return nil, rep, fmt.Errorf("failed to convert the program to %v/%v: %w",
+			rep.OS, rep.Arch, err)
+	}
+	opts = opts.portable(OS)
+	src, err := write(portable, opts, callComments)
+	if err != nil {
+		return nil, rep, err
+	}
+	if rep.Portable() {
+		return src, rep, nil
+	}
+	comment := new(bytes.Buffer)
+	fmt.Fprintf(comment, "// Best-effort reproducer for %v/%v.\n", rep.OS, rep.Arch)
+	for _, issue := range rep.Issues {
+		if issue.Call == -1 {
+			fmt.Fprintf(comment, "// %v\n", issue.Reason)
+		}
+	}
+	if len(removed) != 0 {
+		fmt.Fprintf(comment, "// Unsupported calls are commented out.\n")
+		if len(portable.Calls) == 0 {
+			// There are no generated calls to attach the comments to.
+			for _, line := range callComments[0] {
+				fmt.Fprintf(comment, "// %v\n", line)
+			}
+		}
+	}
+	comment.WriteString("\n")
+	// Keep the autogenerated header first (if there is one).
+	pos := bytes.Index(src, []byte("\n\n"))
+	if pos == -1 {
+		pos = 0
+	} else {
+		pos += 2
+	}
+	result := append([]byte{}, src[:pos]...)
+	result = append(result, comment.Bytes()...)
+	result = append(result, src[pos:]...)
+	return result, rep, nil
+}
+
+// portable returns a copy of opts with all options that are not supported on OS disabled.
+func (opts Options) portable(OS string) Options {
+	for _, issue := range opts.unsupportedOptions(OS) {
+		switch issue.Option {
+		case "NetInjection":
+			opts.NetInjection = false
+		case "Sandbox":
+			opts.Sandbox = sandboxNone
+		case "Pretty":
+			opts.Pretty = false
+		}
+	}
+	if OS != targets.Linux {
+		for _, opt := range opts.linuxOnlyOptions() {
+			*opt.value = false
+		}
+	}
+	return opts
+}
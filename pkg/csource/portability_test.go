@@ -0,0 +1,161 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPortability(t *testing.T) {
+	target, err := prog.GetTarget(targets.Linux, targets.AMD64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte(`
+r0 = openat(0xffffffffffffff9c, &(0x7f0000000000)='./file0\x00', 0x0, 0x0)
+r1 = epoll_create1(0x0)
+close(r0)
+close(r1)
+`), prog.Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{
+		Threaded:  true,
+		Repeat:    true,
+		Procs:     1,
+		Slowdown:  1,
+		Sandbox:   sandboxNamespace,
+		Cgroups:   true,
+		UseTmpDir: true,
+	}
+	reports := CheckPortability(p, opts)
+	assert.Len(t, reports, len(PortableOSes))
+	for _, rep := range reports {
+		if rep.OS == targets.Linux {
+			assert.True(t, rep.Portable(), rep.String())
+			continue
+		}
+		var options []string
+		var calls []int
+		for _, issue := range rep.Issues {
+			if issue.Option != "" {
+				options = append(options, issue.Option)
+			}
+			if issue.Call != -1 {
+				calls = append(calls, issue.Call)
+				assert.Equal(t, "epoll_create1", issue.CallName)
+			}
+		}
+		assert.Equal(t, []string{"Sandbox", "Cgroups"}, options, rep.OS)
+		assert.Equal(t, []int{1}, calls, rep.OS)
+	}
+}
+
+func TestCheckPortabilityLayout(t *testing.T) {
+	target, err := prog.GetTarget(targets.Linux, targets.AMD64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// poll is described on both linux and freebsd, but struct pollfd is different.
+	p, err := target.Deserialize([]byte(`
+r0 = openat(0xffffffffffffff9c, &(0x7f0000000000)='./file0\x00', 0x0, 0x0)
+poll(&(0x7f0000000040)=[{r0, 0x1, 0x0}], 0x1, 0x0)
+close(r0)
+`), prog.Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{
+		Threaded:  true,
+		Repeat:    true,
+		Procs:     1,
+		Slowdown:  1,
+		Sandbox:   sandboxNone,
+		UseTmpDir: true,
+	}
+	rep := checkPortability(p, opts, targets.FreeBSD)
+	if !assert.Len(t, rep.Issues, 1, rep.String()) {
+		return
+	}
+	assert.Equal(t, 1, rep.Issues[0].Call)
+	assert.Equal(t, "poll", rep.Issues[0].CallName)
+	assert.Contains(t, rep.Issues[0].Reason, "call poll has a different layout on freebsd")
+	assert.True(t, checkPortability(p, opts, targets.Linux).Portable())
+}
+
+func TestWriteBestEffort(t *testing.T) {
+	sysTarget := targets.Get(targets.Linux, targets.AMD64)
+	if runtime.GOOS != sysTarget.BuildOS {
+		t.Skipf("can't generate linux programs on %v", runtime.GOOS)
+	}
+	if err := sysTarget.BrokenCompiler; err != "" {
+		t.Skipf("target compiler is broken: %v", err)
+	}
+	target, err := prog.GetTarget(targets.FreeBSD, targets.AMD64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte(`
+r0 = kqueue()
+r1 = openat(0xffffffffffffff9c, &(0x7f0000000000)='./file0\x00', 0x0, 0x0)
+close(r1)
+kqueue()
+`), prog.Strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{
+		Threaded:  true,
+		Repeat:    true,
+		Procs:     1,
+		Slowdown:  1,
+		Sandbox:   sandboxNone,
+		UseTmpDir: true,
+	}
+	src, rep, err := WriteBestEffort(p, opts, targets.Linux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, rep.Portable())
+	// The removed call is left as a comment in its place.
+	assert.Regexp(t, `(?s)case 0:\n\t\t// call kqueue is not described on linux:\n\t\t// kqueue\(\)\n.*__NR_openat`,
+		string(src))
+	assert.Regexp(t, `__NR_close, .*\);\n\t\t// call kqueue is not described on linux:\n\t\t// kqueue\(\)\n\t\tbreak;`,
+		string(src))
+	assert.True(t, bytes.Contains(src, []byte("// Unsupported calls are commented out.")), string(src))
+}
+
+func TestRemoveCallComments(t *testing.T) {
+	ctx := &context{
+		callComments: map[int][]string{0: {"a"}, 1: {"b"}, 2: {"c"}, 4: {"d"}},
+	}
+	// Comments of the removed call move to the next call.
+	ctx.removeCallComments(1, 3)
+	assert.Equal(t, map[int][]string{0: {"a"}, 1: {"b", "c"}, 3: {"d"}}, ctx.callComments)
+}
+
+func TestPortableOptions(t *testing.T) {
+	opts := Options{
+		Threaded:  true,
+		Repeat:    true,
+		Procs:     1,
+		Slowdown:  1,
+		Sandbox:   sandboxNamespace,
+		UseTmpDir: true,
+		Pretty:    true,
+	}
+	assert.True(t, opts.portable(targets.Linux).Pretty)
+	// Pretty is not supported on fuchsia, so it falls back to the normal mode.
+	fuchsia := opts.portable(targets.Fuchsia)
+	assert.False(t, fuchsia.Pretty)
+	assert.NoError(t, fuchsia.Check(targets.Fuchsia))
+}
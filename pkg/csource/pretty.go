@@ -0,0 +1,423 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// This file implements the Pretty mode of source generation.
+// In this mode we use type information from the program to make the source more readable:
+//   - the data area is allocated at an address chosen by the kernel instead of the fixed address,
+//     and all addresses in it are printed relative to syz_data (the data area start),
+//   - simple structs are declared as C structs and filled with designated initializers
+//     or field-by-field assignments,
+//   - flag values are annotated with flag names,
+//   - machine code buffers are annotated with their disassembly.
+//
+// The resulting program writes exactly the same bytes at the same offsets in the data area
+// as the non-pretty one.
+
+const prettyDataName = "syz_data"
+
+// prettyCopyin is the typed counterpart of prog.ExecCopyin.
+type prettyCopyin struct {
+	arg   prog.Arg
+	group *prog.GroupArg // the struct the arg is a direct field of, if any
+}
+
+// prettyStruct is a C struct declaration generated for a prog struct type.
+type prettyStruct struct {
+	name   string
+	fields []prettyField
+	decl   string
+}
+
+type prettyField struct {
+	name   string
+	size   uint64
+	pad    bool
+	copyin bool // the field is written by a copyin instruction
+}
+
+// prettyCopyins returns typed copyins for each call of p in the same order
+// prog.SerializeForExec emits them. Checksum copyins are not included.
+func prettyCopyins(p *prog.Prog) [][]prettyCopyin {
+	var res [][]prettyCopyin
+	for _, c := range p.Calls {
+		var copyins []prettyCopyin
+		parents := make(map[prog.Arg]*prog.GroupArg)
+		prog.ForeachArg(c, func(arg prog.Arg, ctx *prog.ArgCtx) {
+			if group, ok := arg.(*prog.GroupArg); ok {
+				if _, ok := group.Type().(*prog.StructType); ok {
+					for _, inner := range group.Inner {
+						parents[inner] = group
+					}
+				}
+			}
+			// Keep in sync with execContext.writeCopyin.
+			if ctx.Base == nil {
+				return
+			}
+			switch arg.(type) {
+			case *prog.GroupArg, *prog.UnionArg:
+				return
+			}
+			typ := arg.Type()
+			if arg.Dir() == prog.DirOut || prog.IsPad(typ) || (arg.Size() == 0 && !typ.IsBitfield()) {
+				return
+			}
+			copyins = append(copyins, prettyCopyin{
+				arg:   arg,
+				group: parents[arg],
+			})
+		})
+		res = append(res, copyins)
+	}
+	return res
+}
+
+// prettyCheck verifies that typed copyins match the exec copyins of the call.
+func prettyCheck(call prog.ExecCall, typed []prettyCopyin) bool {
+	if len(typed) > len(call.Copyin) {
+		return false
+	}
+	for i, copyin := range typed {
+		ok := false
+		switch copyin.arg.(type) {
+		case *prog.ConstArg, *prog.PointerArg:
+			_, ok = call.Copyin[i].Arg.(prog.ExecArgConst)
+		case *prog.ResultArg:
+			switch call.Copyin[i].Arg.(type) {
+			case prog.ExecArgConst, prog.ExecArgResult:
+				ok = true
+			}
+		case *prog.DataArg:
+			_, ok = call.Copyin[i].Arg.(prog.ExecArgData)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// prettyCopyinAll emits copyins of the call using the typed information.
+func (ctx *context) prettyCopyinAll(w *bytes.Buffer, csumSeq *int, call prog.ExecCall, typed []prettyCopyin) {
+	if !prettyCheck(call, typed) {
+		typed = nil
+	}
+	for i := 0; i < len(call.Copyin); {
+		if i < len(typed) && typed[i].group != nil {
+			if n := ctx.prettyStructCopyin(w, call.Copyin[i:], typed[i:]); n != 0 {
+				i += n
+				continue
+			}
+		}
+		var arg prog.Arg
+		if i < len(typed) {
+			arg = typed[i].arg
+		}
+		ctx.prettyCopyinOne(w, csumSeq, call.Copyin[i], arg)
+		i++
+	}
+}
+
+func (ctx *context) prettyCopyinOne(w *bytes.Buffer, csumSeq *int, copyin prog.ExecCopyin, arg prog.Arg) {
+	if data, ok := arg.(*prog.DataArg); ok {
+		ctx.prettyTextComment(w, data)
+	}
+	constArg, ok := copyin.Arg.(prog.ExecArgConst)
+	if arg == nil || !ok || constArg.BitfieldOffset != 0 || constArg.BitfieldLength != 0 {
+		ctx.copyin(w, csumSeq, copyin)
+		return
+	}
+	val := ctx.prettyValue(arg.Type(), constArg)
+	ctx.copyinVal(w, copyin.Addr, constArg.Size, val, constArg.Format)
+}
+
+// prettyTextComment emits disassembly of machine code buffers.
+func (ctx *context) prettyTextComment(w *bytes.Buffer, data *prog.DataArg) {
+	typ, ok := data.Type().(*prog.BufferType)
+	if !ok || typ.Kind != prog.BufferText {
+		return
+	}
+	listing := ctx.target.DisassembleText(typ.Text, data.Data())
+	for _, line := range strings.Split(strings.TrimSpace(listing), "\n") {
+		if line != "" {
+			fmt.Fprintf(w, "\t// %v\n", line)
+		}
+	}
+}
+
+// prettyStructCopyin emits copyins for the struct that starts at copyins[0]
+// and returns the number of consumed copyins. If the struct can't be printed
+// as a C struct, returns 0.
+func (ctx *context) prettyStructCopyin(w *bytes.Buffer, copyins []prog.ExecCopyin, typed []prettyCopyin) int {
+	group := typed[0].group
+	st := ctx.prettyStructDecl(group)
+	if st == nil {
+		return 0
+	}
+	var args []prog.Arg
+	for _, f := range group.Inner {
+		if !prog.IsPad(f.Type()) && f.Dir() != prog.DirOut && f.Size() != 0 {
+			args = append(args, f)
+		}
+	}
+	if len(args) > len(typed) || len(args) == 0 {
+		return 0
+	}
+	for i, arg := range args {
+		if typed[i].arg != arg || typed[i].group != group {
+			return 0
+		}
+	}
+	// Struct start is the address of the first copied field minus its offset.
+	start := copyins[0].Addr - ctx.prettyFieldOffset(group, args[0])
+	full := true
+	for _, f := range st.fields {
+		if !f.copyin {
+			full = false
+		}
+	}
+	var vals []string
+	for i := range args {
+		vals = append(vals, ctx.prettyFieldValue(args[i], copyins[i].Arg))
+	}
+	if full {
+		var inits []string
+		for i, f := range st.fields {
+			inits = append(inits, fmt.Sprintf(".%v = %v", f.name, vals[i]))
+		}
+		fmt.Fprintf(w, "\tNONFAILING(*(struct %v*)%v = (struct %v){%v});\n",
+			st.name, ctx.fmtAddr(start), st.name, strings.Join(inits, ", "))
+		return len(args)
+	}
+	vi := 0
+	for _, f := range st.fields {
+		if !f.copyin {
+			continue
+		}
+		fmt.Fprintf(w, "\tNONFAILING(((struct %v*)%v)->%v = %v);\n",
+			st.name, ctx.fmtAddr(start), f.name, vals[vi])
+		vi++
+	}
+	return len(args)
+}
+
+func (ctx *context) prettyFieldOffset(group *prog.GroupArg, arg prog.Arg) uint64 {
+	offset := uint64(0)
+	for _, f := range group.Inner {
+		if f == arg {
+			break
+		}
+		offset += f.Size()
+	}
+	return offset
+}
+
+func (ctx *context) prettyFieldValue(arg prog.Arg, execArg prog.ExecArg) string {
+	switch a := execArg.(type) {
+	case prog.ExecArgConst:
+		return ctx.prettyValue(arg.Type(), a)
+	case prog.ExecArgResult:
+		return ctx.resultArgToStr(a)
+	default:
+		panic(fmt.Sprintf("unexpected struct field arg %+v", execArg))
+	}
+}
+
+// prettyValue formats the const value of type typ, annotating flags and pointers into the data area.
+func (ctx *context) prettyValue(typ prog.Type, arg prog.ExecArgConst) string {
+	switch typ.(type) {
+	case *prog.PtrType, *prog.VmaType:
+		if addr, ok := ctx.prettyDataAddr(arg.Value); ok {
+			return fmt.Sprintf("(uint%v)(uintptr_t)%v", arg.Size*8, addr)
+		}
+	}
+	val := handleBigEndian(arg, ctx.constArgToStr(arg, ""))
+	if flags := ctx.prettyPrintValue(typ, arg); flags != "" {
+		val = "/*" + flags + "*/" + val
+	}
+	return val
+}
+
+// prettyDataAddr returns addr relative to the data area start, if addr is within the data area
+// or the guard pages around it.
+func (ctx *context) prettyDataAddr(addr uint64) (string, bool) {
+	start := ctx.target.DataOffset
+	end := start + ctx.target.NumPages*ctx.target.PageSize
+	if addr < start-ctx.target.PageSize || addr >= end+ctx.target.PageSize {
+		return "", false
+	}
+	if addr < start {
+		return fmt.Sprintf("(%v - 0x%x)", prettyDataName, start-addr), true
+	}
+	return fmt.Sprintf("(%v + 0x%x)", prettyDataName, addr-start), true
+}
+
+// prettyMmapData returns code that allocates the data area in the Pretty mode.
+// The whole range including the guard pages is reserved first at an address chosen by the kernel,
+// then the target-specific calls (mmapCalls) map the data area over it
+// at the same offsets relative to syz_data.
+func (ctx *context) prettyMmapData(mmapCalls string) string {
+	pageSize := ctx.target.PageSize
+	size := ctx.target.NumPages*pageSize + 2*pageSize
+	return fmt.Sprintf("%v = (char*)mmap(0, 0x%xul, PROT_NONE, MAP_ANON | MAP_PRIVATE, -1, 0);\n"+
+		"\tif (%v == MAP_FAILED)\n\t\texit(1);\n\t%v += 0x%x;\n%v",
+		prettyDataName, size, prettyDataName, prettyDataName, pageSize, mmapCalls)
+}
+
+// fmtAddr formats an address used by copyin/copyout instructions.
+func (ctx *context) fmtAddr(addr uint64) string {
+	if ctx.opts.Pretty {
+		if res, ok := ctx.prettyDataAddr(addr); ok {
+			return res
+		}
+	}
+	return fmt.Sprintf("0x%x", addr)
+}
+
+// prettyStructDecl returns C declaration for the struct type of group,
+// or nil if the struct can't be represented as a plain C struct.
+func (ctx *context) prettyStructDecl(group *prog.GroupArg) *prettyStruct {
+	typ, ok := group.Type().(*prog.StructType)
+	if !ok || typ.Varlen() || typ.OverlayField != 0 || ctx.sysTarget.OS == targets.Windows ||
+		len(typ.Fields) != len(group.Inner) {
+		return nil
+	}
+	st := &prettyStruct{}
+	names := make(map[string]bool)
+	decl := new(bytes.Buffer)
+	for i, arg := range group.Inner {
+		ftyp := arg.Type()
+		f := prettyField{
+			size: arg.Size(),
+			pad:  prog.IsPad(ftyp),
+		}
+		if !f.pad {
+			if !ctx.prettySimpleField(arg) {
+				return nil
+			}
+			f.copyin = arg.Dir() != prog.DirOut && arg.Size() != 0
+		}
+		if f.size == 0 {
+			if f.copyin {
+				return nil
+			}
+			continue
+		}
+		name := "pad"
+		if !f.pad {
+			name = typ.Fields[i].Name
+		}
+		f.name = prettyIdent(name, names)
+		if f.pad {
+			fmt.Fprintf(decl, "\tuint8 %v[%v];\n", f.name, f.size)
+		} else {
+			fmt.Fprintf(decl, "\tuint%v %v;\n", f.size*8, f.name)
+		}
+		st.fields = append(st.fields, f)
+	}
+	st.decl = decl.String()
+	if ctx.prettyStructs == nil {
+		ctx.prettyStructs = make(map[string]*prettyStruct)
+	}
+	base := "syz_" + prettyIdent(typ.TemplateName(), nil)
+	for seq := 0; ; seq++ {
+		st.name = base
+		if seq != 0 {
+			st.name = fmt.Sprintf("%v_%v", base, seq)
+		}
+		existing := ctx.prettyStructs[st.name]
+		if existing == nil {
+			ctx.prettyStructs[st.name] = st
+			return st
+		}
+		if existing.decl == st.decl {
+			return existing
+		}
+	}
+}
+
+func (ctx *context) prettySimpleField(arg prog.Arg) bool {
+	typ := arg.Type()
+	if typ.IsBitfield() {
+		return false
+	}
+	switch typ.Format() {
+	case prog.FormatNative, prog.FormatBigEndian:
+	default:
+		return false
+	}
+	switch arg.(type) {
+	case *prog.ConstArg, *prog.ResultArg, *prog.PointerArg:
+	default:
+		return false
+	}
+	switch arg.Size() {
+	case 1, 2, 4, 8:
+		return true
+	}
+	return false
+}
+
+// prettyDecls returns declarations required by the pretty mode.
+func (ctx *context) prettyDecls() string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "#include <sys/mman.h>\n\nstatic char* %v;\n", prettyDataName)
+	var names []string
+	for name := range ctx.prettyStructs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(buf, "\nstruct %v {\n%v} __attribute__((packed));\n", name, ctx.prettyStructs[name].decl)
+	}
+	return buf.String()
+}
+
+var (
+	prettyIdentRe = regexp.MustCompile("[^a-zA-Z0-9_]+")
+	// C keywords and common libc macros that can't be used as field names.
+	prettyReserved = map[string]bool{
+		"auto": true, "break": true, "case": true, "char": true, "const": true, "continue": true,
+		"default": true, "do": true, "double": true, "else": true, "enum": true, "extern": true,
+		"float": true, "for": true, "goto": true, "if": true, "inline": true, "int": true,
+		"long": true, "register": true, "restrict": true, "return": true, "short": true,
+		"signed": true, "sizeof": true, "static": true, "struct": true, "switch": true,
+		"typedef": true, "union": true, "unsigned": true, "void": true, "volatile": true,
+		"while": true, "bool": true, "true": true, "false": true, "errno": true,
+		"major": true, "minor": true, "makedev": true, "stdin": true, "stdout": true,
+		"stderr": true, "unix": true, "linux": true, "i386": true, "NULL": true,
+	}
+)
+
+// prettyIdent converts name to a valid C identifier that is not present in used.
+func prettyIdent(name string, used map[string]bool) string {
+	name = strings.Trim(prettyIdentRe.ReplaceAllString(name, "_"), "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "f_" + name
+	}
+	if prettyReserved[name] || strings.HasPrefix(name, "SYZ_") || strings.HasPrefix(name, "__") {
+		name += "_"
+	}
+	if used == nil {
+		return name
+	}
+	res := name
+	for seq := 1; used[res]; seq++ {
+		res = fmt.Sprintf("%v%v", name, seq)
+	}
+	used[res] = true
+	return res
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package email
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CommandError describes a malformed #syz command.
+type CommandError struct {
+	// Message says what is wrong with the command.
+	Message string
+	// Suggestion is the command that was likely meant (e.g. "fix:" for "fixe:"), if any.
+	Suggestion string
+}
+
+func (err *CommandError) Error() string {
+	if err.Suggestion == "" {
+		return err.Message
+	}
+	return fmt.Sprintf("%v, did you mean %q?", err.Message, commandPrefix+" "+err.Suggestion)
+}
+
+// knownCommands lists command names in the form they are suggested.
+var knownCommands = []string{
+	"upstream", "fix:", "unfix", "dup:", "undup", "test:", "invalid",
+	"uncc", "set:", "unset:", "regenerate",
+}
+
+// commandUsage contains usage examples for commands with arguments.
+var commandUsage = map[Command]string{
+	CmdFix:   "#syz fix: exact-commit-title",
+	CmdDup:   "#syz dup: exact-subject-of-another-report",
+	CmdTest:  "#syz test: git://repo/address.git branch-or-commit-hash",
+	CmdSet:   "#syz set: label-name: value",
+	CmdUnset: "#syz unset: label-name",
+}
+
+// validateCommand checks that the command is known and has the expected arguments.
+func validateCommand(cmd *SingleCommand) *CommandError {
+	fail := func(msg string, args ...interface{}) *CommandError {
+		msg = fmt.Sprintf(msg, args...)
+		if usage := commandUsage[cmd.Command]; usage != "" {
+			msg += ", expected: " + usage
+		}
+		return &CommandError{Message: msg}
+	}
+	switch cmd.Command {
+	case CmdUnknown:
+		// Note: for "#syz" without a command the next line is taken as the command.
+		if cmd.Str == "" || strings.HasPrefix(cmd.Str, commandPrefix) {
+			return fail("missing command")
+		}
+		return &CommandError{
+			Message:    fmt.Sprintf("unknown command %q", cmd.Str),
+			Suggestion: suggestCommand(cmd.Str),
+		}
+	case CmdFix, CmdDup, CmdTest, CmdSet, CmdUnset:
+		// Arguments may be taken from the following lines (see extractCommand),
+		// so a missing argument may result in the next command becoming the argument.
+		if strings.Contains(cmd.Args, commandPrefix) {
+			return fail("arguments run into the next command")
+		}
+	}
+	switch cmd.Command {
+	case CmdFix:
+		if cmd.Args == "" {
+			return fail("no commit title")
+		}
+	case CmdDup:
+		if cmd.Args == "" {
+			return fail("no dup title")
+		}
+	case CmdTest:
+		if args := strings.Fields(cmd.Args); len(args) != 0 && len(args) != 2 {
+			return fail("want 2 args (repo, branch), got %v", len(args))
+		}
+	case CmdSet, CmdUnset:
+		if cmd.Args == "" {
+			return fail("no label")
+		}
+	}
+	return nil
+}
+
+// suggestCommand returns the known command closest to str,
+// or an empty string if no command is close enough.
+func suggestCommand(str string) string {
+	name := strings.ToLower(strings.TrimSuffix(str, ":"))
+	best, bestDist := "", 0
+	for _, cmd := range knownCommands {
+		dist := editDistance(name, strings.TrimSuffix(cmd, ":"))
+		if best == "" || dist < bestDist {
+			best, bestDist = cmd, dist
+		}
+	}
+	// Allow one more typo for every 5 characters.
+	if maxDist := 1 + len(name)/5; bestDist > maxDist {
+		return ""
+	}
+	if !strings.HasSuffix(str, ":") {
+		best = strings.TrimSuffix(best, ":")
+	}
+	return best
+}
+
+// editDistance returns the number of insertions, deletions, substitutions and
+// transpositions of adjacent characters needed to transform a into b.
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package email
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCommandDiagnostics(t *testing.T) {
+	body := `Subject line
+
+#syz fixe: net: fix the foo
+#syz Dup: some title
+#syz tset
+#syz fix:
+#syz upstream
+#syz
+#syz unset
+#syz something-else
+#syz test: git://repo`
+	want := []*SingleCommand{
+		{
+			Command: CmdUnknown,
+			Str:     "fixe:",
+			Line:    2,
+			Error: &CommandError{
+				Message:    `unknown command "fixe:"`,
+				Suggestion: "fix:",
+			},
+		},
+		{
+			Command: CmdUnknown,
+			Str:     "Dup:",
+			Line:    3,
+			Error: &CommandError{
+				Message:    `unknown command "Dup:"`,
+				Suggestion: "dup:",
+			},
+		},
+		{
+			Command: CmdUnknown,
+			Str:     "tset",
+			Line:    4,
+			Error: &CommandError{
+				Message:    `unknown command "tset"`,
+				Suggestion: "test",
+			},
+		},
+		{
+			Command: CmdFix,
+			Str:     "fix:",
+			Args:    "#syz upstream",
+			Line:    5,
+			Error: &CommandError{
+				Message: "arguments run into the next command, expected: #syz fix: exact-commit-title",
+			},
+		},
+		{
+			Command: CmdUpstream,
+			Str:     "upstream",
+			Line:    6,
+		},
+		{
+			Command: CmdUnknown,
+			Str:     "#syz",
+			Line:    7,
+			Error: &CommandError{
+				Message: "missing command",
+			},
+		},
+		{
+			Command: CmdUnknown,
+			Str:     "something-else",
+			Line:    9,
+			Error: &CommandError{
+				Message: `unknown command "something-else"`,
+			},
+		},
+		{
+			Command: CmdTest,
+			Str:     "test:",
+			Args:    "git://repo",
+			Line:    10,
+			Error: &CommandError{
+				Message: "want 2 args (repo, branch), got 1, " +
+					"expected: #syz test: git://repo/address.git branch-or-commit-hash",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, extractCommands(body)); diff != "" {
+		t.Fatal(diff)
+	}
+	err := want[0].Error.Error()
+	if wantErr := `unknown command "fixe:", did you mean "#syz fix:"?`; err != wantErr {
+		t.Fatalf("got %q, want %q", err, wantErr)
+	}
+}
+
+func TestSuggestCommand(t *testing.T) {
+	tests := map[string]string{
+		"fix":          "fix",
+		"FIX:":         "fix:",
+		"fxi:":         "fix:",
+		"fixed:":       "fix:",
+		"unfixe":       "unfix",
+		"upsteam":      "upstream",
+		"regnerate":    "regenerate",
+		"invaild":      "invalid",
+		"un-cc":        "uncc",
+		"foo":          "",
+		"bad-command":  "",
+		"please-fix-x": "",
+	}
+	for str, want := range tests {
+		if got := suggestCommand(str); got != want {
+			t.Errorf("suggestCommand(%q) = %q, want %q", str, got, want)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package lore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/email"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/vcs"
+)
+
+// Index is a local index of messages from lore archives (public-inbox git repositories).
+// It's updated incrementally: only commits added to the archives since the last update are parsed.
+// The index is keyed by Message-ID and allows to quickly find threads and patches related to bugs.
+type Index struct {
+	// Archives maps archive names (directory names) to the last indexed commit.
+	Archives map[string]string
+	Messages map[string]*IndexedMessage
+
+	replies map[string][]*IndexedMessage
+	bugs    map[string][]*IndexedMessage
+	patches map[string][]*IndexedMessage
+}
+
+// IndexedMessage holds the parts of an email that are needed for queries.
+// The full message can be read with Index.Read.
+type IndexedMessage struct {
+	MessageID string
+	InReplyTo string `json:",omitempty"`
+	Subject   string
+	Author    string
+	Date      time.Time
+	OwnEmail  bool     `json:",omitempty"`
+	BugIDs    []string `json:",omitempty"`
+	Type      dashapi.DiscussionType
+	Archive   string
+	Commit    string
+}
+
+func NewIndex() *Index {
+	idx := &Index{
+		Archives: make(map[string]string),
+		Messages: make(map[string]*IndexedMessage),
+	}
+	idx.init()
+	return idx
+}
+
+// LoadIndex loads the index from the file, if the file does not exist, an empty index is returned.
+func LoadIndex(file string) (*Index, error) {
+	idx := NewIndex()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return idx, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("failed to parse index %v: %w", file, err)
+	}
+	idx.init()
+	return idx, nil
+}
+
+func (idx *Index) Save(file string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return osutil.WriteFile(file, data)
+}
+
+func (idx *Index) init() {
+	idx.replies = make(map[string][]*IndexedMessage)
+	idx.bugs = make(map[string][]*IndexedMessage)
+	idx.patches = make(map[string][]*IndexedMessage)
+	for _, msg := range idx.Messages {
+		idx.link(msg)
+	}
+}
+
+func (idx *Index) link(msg *IndexedMessage) {
+	if msg.InReplyTo != "" {
+		idx.replies[msg.InReplyTo] = append(idx.replies[msg.InReplyTo], msg)
+	}
+	for _, id := range msg.BugIDs {
+		idx.bugs[id] = append(idx.bugs[id], msg)
+	}
+	if msg.isPatch() {
+		title := PatchTitle(msg.Subject)
+		idx.patches[title] = append(idx.patches[title], msg)
+	}
+}
+
+// Update indexes new messages from archives located in subdirectories of dir
+// (e.g. 0, 1, 2 for a cloned public-inbox repository).
+// Returns the number of newly indexed messages.
+func (idx *Index) Update(dir string, ownEmails, domains []string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		n, err := idx.updateArchive(filepath.Join(dir, entry.Name()), entry.Name(), ownEmails, domains)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("archive %v: %w", entry.Name(), err)
+		}
+	}
+	return total, nil
+}
+
+func (idx *Index) updateArchive(dir, name string, ownEmails, domains []string) (int, error) {
+	repo := vcs.NewLKMLRepo(dir)
+	head, err := repo.HeadCommit()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query HEAD: %w", err)
+	}
+	last := idx.Archives[name]
+	if last == head.Hash {
+		return 0, nil
+	}
+	commitRange := "HEAD"
+	if last != "" {
+		// If the archive was rewritten, the old commit may be not present anymore.
+		if ok, err := repo.CommitExists(last); err == nil && ok {
+			commitRange = last + "..HEAD"
+		}
+	}
+	commits, err := repo.ListCommitHashes(commitRange)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list commits: %w", err)
+	}
+	commitCh := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	added, failed := 0, 0
+	var readErr error
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for commit := range commitCh {
+				body, err := repo.Object("m", commit)
+				if err != nil {
+					mu.Lock()
+					if readErr == nil {
+						readErr = fmt.Errorf("failed to read message %v: %w", commit, err)
+					}
+					failed++
+					mu.Unlock()
+					continue
+				}
+				// Malformed messages are skipped for good, there is no point in parsing them again.
+				msg, err := email.Parse(bytes.NewReader(body), ownEmails, nil, domains)
+				if err != nil || msg.MessageID == "" {
+					continue
+				}
+				mu.Lock()
+				if idx.Messages[msg.MessageID] == nil {
+					idx.add(msg, name, commit)
+					added++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, commit := range commits {
+		if commit != "" {
+			commitCh <- commit
+		}
+	}
+	close(commitCh)
+	wg.Wait()
+	if readErr != nil {
+		// Don't move the archive past the failed messages, so that they are retried on the next update.
+		// The messages that were read are already indexed and won't be added twice.
+		return added, fmt.Errorf("failed to read %v messages: %w", failed, readErr)
+	}
+	idx.Archives[name] = head.Hash
+	return added, nil
+}
+
+func (idx *Index) add(msg *email.Email, archive, commit string) {
+	indexed := &IndexedMessage{
+		MessageID: msg.MessageID,
+		InReplyTo: msg.InReplyTo,
+		Subject:   msg.Subject,
+		Author:    msg.Author,
+		Date:      msg.Date,
+		OwnEmail:  msg.OwnEmail,
+		BugIDs:    msg.BugIDs,
+		Type:      DiscussionType(msg),
+		Archive:   archive,
+		Commit:    commit,
+	}
+	idx.Messages[msg.MessageID] = indexed
+	idx.link(indexed)
+}
+
+// Read returns the raw message, dir is the directory with archives passed to Update.
+func (idx *Index) Read(dir string, msg *IndexedMessage) ([]byte, error) {
+	return vcs.NewLKMLRepo(filepath.Join(dir, msg.Archive)).Object("m", msg.Commit)
+}
+
+// Message returns the message with the given Message-ID, or nil.
+func (idx *Index) Message(id string) *IndexedMessage {
+	return idx.Messages[id]
+}
+
+// Replies returns direct replies to the message sorted by date.
+func (idx *Index) Replies(id string) []*IndexedMessage {
+	return sortMessages(idx.replies[id])
+}
+
+// BugMessages returns all messages that mention the bug ID sorted by date.
+func (idx *Index) BugMessages(bugID string) []*IndexedMessage {
+	return sortMessages(idx.bugs[bugID])
+}
+
+// BugPatches returns patches that mention the bug ID (e.g. in Reported-by tags) sorted by date.
+func (idx *Index) BugPatches(bugID string) []*IndexedMessage {
+	var ret []*IndexedMessage
+	for _, msg := range idx.bugs[bugID] {
+		if msg.isPatch() {
+			ret = append(ret, msg)
+		}
+	}
+	return sortMessages(ret)
+}
+
+// Patches returns patches with the given title (e.g. a fixing commit title) sorted by date.
+func (idx *Index) Patches(title string) []*IndexedMessage {
+	return sortMessages(idx.patches[PatchTitle(title)])
+}
+
+// Thread returns all messages of the thread the message belongs to sorted by date.
+func (idx *Index) Thread(id string) []*IndexedMessage {
+	return sortMessages(idx.threadMessages(idx.root(id), nil))
+}
+
+// BugThreads returns discussion threads that mention the bug ID.
+func (idx *Index) BugThreads(bugID string) []*Thread {
+	var ret []*Thread
+	for _, thread := range idx.threads(idx.bugs[bugID]) {
+		for _, id := range thread.BugIDs {
+			if id == bugID {
+				ret = append(ret, thread)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Threads returns all discussion threads that mention any bug IDs.
+func (idx *Index) Threads() []*Thread {
+	var msgs []*IndexedMessage
+	for _, list := range idx.bugs {
+		msgs = append(msgs, list...)
+	}
+	var ret []*Thread
+	for _, thread := range idx.threads(msgs) {
+		if len(thread.BugIDs) != 0 {
+			ret = append(ret, thread)
+		}
+	}
+	return ret
+}
+
+// threads splits the threads containing the messages into discussions.
+func (idx *Index) threads(msgs []*IndexedMessage) []*Thread {
+	visited := make(map[string]bool)
+	var emails []*email.Email
+	for _, msg := range msgs {
+		root := idx.root(msg.MessageID)
+		if visited[root] {
+			continue
+		}
+		for _, msg := range idx.threadMessages(root, visited) {
+			emails = append(emails, &email.Email{
+				MessageID: msg.MessageID,
+				InReplyTo: msg.InReplyTo,
+				Subject:   msg.Subject,
+				Author:    msg.Author,
+				Date:      msg.Date,
+				OwnEmail:  msg.OwnEmail,
+				BugIDs:    msg.BugIDs,
+			})
+		}
+	}
+	threads := Threads(emails)
+	for _, thread := range threads {
+		sort.Slice(thread.Messages, func(i, j int) bool {
+			return thread.Messages[i].Date.Before(thread.Messages[j].Date)
+		})
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		return threads[i].MessageID < threads[j].MessageID
+	})
+	return threads
+}
+
+// root returns Message-ID of the first known message of the thread.
+func (idx *Index) root(id string) string {
+	visited := make(map[string]bool)
+	for !visited[id] {
+		visited[id] = true
+		msg := idx.Messages[id]
+		if msg == nil || idx.Messages[msg.InReplyTo] == nil {
+			break
+		}
+		id = msg.InReplyTo
+	}
+	return id
+}
+
+func (idx *Index) threadMessages(root string, visited map[string]bool) []*IndexedMessage {
+	if visited == nil {
+		visited = make(map[string]bool)
+	}
+	var ret []*IndexedMessage
+	queue := []string{root}
+	for len(queue) != 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		if msg := idx.Messages[id]; msg != nil {
+			ret = append(ret, msg)
+		}
+		for _, reply := range idx.replies[id] {
+			queue = append(queue, reply.MessageID)
+		}
+	}
+	return ret
+}
+
+// isPatch returns true for patches, but not for replies to them.
+func (msg *IndexedMessage) isPatch() bool {
+	return msg.Type == dashapi.DiscussionPatch && !replySubjectRe.MatchString(msg.Subject)
+}
+
+func sortMessages(list []*IndexedMessage) []*IndexedMessage {
+	ret := append([]*IndexedMessage{}, list...)
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].Date.Equal(ret[j].Date) {
+			return ret[i].Date.Before(ret[j].Date)
+		}
+		return ret[i].MessageID < ret[j].MessageID
+	})
+	return ret
+}
+
+var replySubjectRe = regexp.MustCompile(`^(?i)\s*(?:re|aw)\s*:`)
+
+var subjectPrefixRe = regexp.MustCompile(`^(?i)(?:\s*(?:re|fwd?|aw)\s*:|\s*\[[^\]]*\])*\s*`)
+
+// PatchTitle strips reply markers and tags like [PATCH v2 1/3] from the subject,
+// which gives the title of the commit the patch will become.
+func PatchTitle(subject string) string {
+	return strings.TrimSpace(subjectPrefixRe.ReplaceAllString(subject, ""))
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package lore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/vcs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	dir := t.TempDir()
+	repo := vcs.MakeTestRepo(t, filepath.Join(dir, "0"))
+	addMessage := func(msg string) {
+		require.NoError(t, osutil.WriteFile(filepath.Join(repo.Dir, "m"), []byte(msg)))
+		repo.Git("add", "m")
+		repo.Git("commit", "-m", "message")
+	}
+	addMessage(`Date: Sun, 7 May 2017 19:57:00 -0700
+Subject: [syzbot] WARNING in foo
+Message-ID: <bug>
+From: syzbot <syzbot+4564456@bar.com>
+Content-Type: text/plain
+
+Bug report`)
+	addMessage(`Date: Sun, 7 May 2017 19:58:00 -0700
+Subject: Re: [syzbot] WARNING in foo
+Message-ID: <reply>
+From: UserA <a@user.com>
+In-Reply-To: <bug>
+Content-Type: text/plain
+
+I'm on it`)
+	addMessage(`Date: Sun, 7 May 2017 19:59:00 -0700
+Subject: Unrelated
+Message-ID: <unrelated>
+From: UserB <b@user.com>
+Content-Type: text/plain
+
+Hello`)
+
+	update := func(file string, want int) *Index {
+		idx, err := LoadIndex(file)
+		require.NoError(t, err)
+		n, err := idx.Update(dir, []string{"syzbot@bar.com"}, []string{"bar.com"})
+		require.NoError(t, err)
+		assert.Equal(t, want, n)
+		require.NoError(t, idx.Save(file))
+		return idx
+	}
+	file := filepath.Join(t.TempDir(), "index.json")
+	idx := update(file, 3)
+	update(file, 0)
+
+	addMessage(`Date: Sun, 7 May 2017 20:00:00 -0700
+Subject: [PATCH v2 2/2] foo: fix the warning
+Message-ID: <patch>
+From: UserA <a@user.com>
+Content-Type: text/plain
+
+Reported-by: syzbot+4564456@bar.com
+---
+diff --git a/foo.c b/foo.c
+`)
+	addMessage(`Date: Sun, 7 May 2017 20:01:00 -0700
+Subject: Re: [PATCH v2 2/2] foo: fix the warning
+Message-ID: <patch-reply>
+From: UserB <b@user.com>
+In-Reply-To: <patch>
+Content-Type: text/plain
+
+Looks good`)
+	idx = update(file, 2)
+
+	assert.Equal(t, "<bug>", idx.Message("<reply>").InReplyTo)
+	assert.Equal(t, []string{"<reply>"}, messageIDs(idx.Replies("<bug>")))
+	assert.Equal(t, []string{"<bug>", "<patch>"}, messageIDs(idx.BugMessages("4564456")))
+	assert.Equal(t, []string{"<patch>"}, messageIDs(idx.BugPatches("4564456")))
+	assert.Equal(t, []string{"<patch>"}, messageIDs(idx.Patches("foo: fix the warning")))
+	assert.Equal(t, []string{"<patch>", "<patch-reply>"}, messageIDs(idx.Thread("<patch-reply>")))
+
+	threads := idx.BugThreads("4564456")
+	require.Len(t, threads, 2)
+	assert.Equal(t, "<bug>", threads[0].MessageID)
+	assert.Equal(t, dashapi.DiscussionReport, threads[0].Type)
+	assert.Len(t, threads[0].Messages, 2)
+	assert.Equal(t, "<patch>", threads[1].MessageID)
+	assert.Equal(t, dashapi.DiscussionPatch, threads[1].Type)
+	assert.Len(t, threads[1].Messages, 2)
+	assert.Len(t, idx.Threads(), 2)
+
+	raw, err := idx.Read(dir, idx.Message("<unrelated>"))
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "Subject: Unrelated")
+}
+
+func TestPatchTitle(t *testing.T) {
+	tests := map[string]string{
+		"foo: fix bar":                        "foo: fix bar",
+		"[PATCH] foo: fix bar":                "foo: fix bar",
+		"Re: [PATCH v2 1/3] foo: fix bar":     "foo: fix bar",
+		"RE: Re: [RFC PATCH net] foo: fix ":   "foo: fix",
+		"[PATCH net-next][RESEND] foo: [bar]": "foo: [bar]",
+	}
+	for subject, want := range tests {
+		assert.Equal(t, want, PatchTitle(subject), subject)
+	}
+}
+
+func messageIDs(list []*IndexedMessage) []string {
+	var ret []string
+	for _, msg := range list {
+		ret = append(ret, msg.MessageID)
+	}
+	return ret
+}
+
+func TestIndexReadError(t *testing.T) {
+	dir := t.TempDir()
+	repo := vcs.MakeTestRepo(t, filepath.Join(dir, "0"))
+	require.NoError(t, osutil.WriteFile(filepath.Join(repo.Dir, "m"), []byte(`Date: Sun, 7 May 2017 19:57:00 -0700
+Subject: Hello
+Message-ID: <hello>
+From: UserA <a@user.com>
+Content-Type: text/plain
+
+Hello`)))
+	repo.Git("add", "m")
+	repo.Git("commit", "-m", "message")
+	// A commit without the message can't be read.
+	repo.Git("rm", "m")
+	repo.Git("commit", "-m", "no message")
+
+	idx, err := LoadIndex(filepath.Join(t.TempDir(), "index.json"))
+	require.NoError(t, err)
+	n, err := idx.Update(dir, []string{"syzbot@bar.com"}, []string{"bar.com"})
+	assert.ErrorContains(t, err, "failed to read 1 messages")
+	assert.Equal(t, 1, n)
+	assert.NotNil(t, idx.Message("<hello>"))
+	// The archive is not marked as indexed, so the failed message is retried next time.
+	assert.Empty(t, idx.Archives["0"])
+	n, err = idx.Update(dir, []string{"syzbot@bar.com"}, []string{"bar.com"})
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -38,6 +38,9 @@ type SingleCommand struct {
 	Command Command
 	Str     string // string representation
 	Args    string // arguments for the command
+	// Line of the command in the email, the subject is line 0 and the body starts at line 1.
+	Line  int
+	Error *CommandError // non-nil if the command is malformed
 }
 
 type Command int
@@ -245,12 +248,17 @@ func CanonicalEmail(email string) string {
 
 func extractCommands(body string) []*SingleCommand {
 	var ret []*SingleCommand
+	line := 0
 	for body != "" {
 		cmd, end := extractCommand(body)
 		if cmd == nil {
 			break
 		}
+		start := strings.LastIndex(body[:end-len(cmd.Str)], commandPrefix)
+		cmd.Line = line + strings.Count(body[:start], "\n")
+		cmd.Error = validateCommand(cmd)
 		ret = append(ret, cmd)
+		line += strings.Count(body[:end], "\n")
 		body = body[end:]
 	}
 	return ret
@@ -458,6 +458,7 @@ For more options, visit https://groups.google.com/d/optout.`,
 					Command: CmdFix,
 					Str:     "fix:",
 					Args:    "arg1 arg2 arg3",
+					Line:    3,
 				},
 			},
 		}},
@@ -510,6 +511,7 @@ last line`,
 					Command: CmdInvalid,
 					Str:     "invalid",
 					Args:    "",
+					Line:    1,
 				},
 			},
 		}},
@@ -540,6 +542,10 @@ last line
 				{
 					Command: CmdUnknown,
 					Str:     "command",
+					Line:    4,
+					Error: &CommandError{
+						Message: `unknown command "command"`,
+					},
 				},
 			},
 		}},
@@ -708,6 +714,7 @@ index 3d85747bd86e..a257b872a53d 100644
 					Command: CmdTest,
 					Str:     "test",
 					Args:    "",
+					Line:    4,
 				},
 			},
 		}},
@@ -762,6 +769,7 @@ d
 				Command: CmdDup,
 				Str:     "dup:",
 				Args:    "BUG: unable to handle kernel NULL pointer dereference in corrupted",
+				Line:    11,
 			},
 		},
 	}},
@@ -783,6 +791,7 @@ BUG: unable to handle kernel NULL pointer dereference in corrupted
 				Command: CmdDup,
 				Str:     "dup:",
 				Args:    "BUG: unable to handle kernel NULL pointer dereference in corrupted",
+				Line:    1,
 			},
 		},
 	}},
@@ -804,6 +813,7 @@ When freeing a lockf struct that already is part of a linked list, make sure to
 				Command: CmdFix,
 				Str:     "fix:",
 				Args:    "When freeing a lockf struct that already is part of a linked list, make sure to",
+				Line:    1,
 			},
 		},
 	}},
@@ -910,6 +920,7 @@ test: https://github.com/torvalds/linux.git 7b5bb460defa107dd2e82f950fddb9ea6bdb
 				Command: CmdTest,
 				Str:     "test:",
 				Args:    "https://github.com/torvalds/linux.git 7b5bb460defa107dd2e82f950fddb9ea6bdb5e39",
+				Line:    1,
 			},
 		},
 	}},
@@ -1059,11 +1070,13 @@ Content-Transfer-Encoding: quoted-printable
 				Command: CmdTest,
 				Str:     "test:",
 				Args:    "aaa bbb",
+				Line:    1,
 			},
 			{
 				Command: CmdTest,
 				Str:     "test:",
 				Args:    "ccc ddd",
+				Line:    2,
 			},
 		},
 	}},
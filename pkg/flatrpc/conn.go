@@ -75,6 +75,8 @@ type Conn struct {
 	data    []byte
 	hasData int
 	lastMsg int
+
+	trace func(sent bool, data []byte)
 }
 
 func NewConn(conn net.Conn) *Conn {
@@ -88,6 +90,13 @@ func (c *Conn) Close() error {
 	return c.conn.Close()
 }
 
+// SetTrace sets a callback that is called with every sent and received serialized message.
+// The data is valid only during the callback. Messages exchanged before the call are not traced.
+// Must not be called concurrently with Send/Recv.
+func (c *Conn) SetTrace(trace func(sent bool, data []byte)) {
+	c.trace = trace
+}
+
 type sendMsg interface {
 	Pack(*flatbuffers.Builder) flatbuffers.UOffsetT
 }
@@ -101,6 +110,9 @@ func Send[T sendMsg](c *Conn, msg T) error {
 	off := msg.Pack(c.builder)
 	c.builder.FinishSizePrefixed(off)
 	data := c.builder.FinishedBytes()
+	if c.trace != nil {
+		c.trace(true, data)
+	}
 	_, err := c.conn.Write(data)
 	c.builder.Reset()
 	statSent.Add(len(data))
@@ -146,6 +158,9 @@ func Recv[Raw RecvType[T], T any](c *Conn) (res *T, err0 error) {
 	if err := c.recv(c.lastMsg); err != nil {
 		return nil, fmt.Errorf("failed to recv %T: %w", (*T)(nil), err)
 	}
+	if c.trace != nil {
+		c.trace(false, c.data[:c.lastMsg])
+	}
 	return Parse[Raw](c.data[sizePrefixSize:c.lastMsg])
 }
 
@@ -429,6 +429,7 @@ const (
 	SnapshotStateExecute     SnapshotState = 4
 	SnapshotStateExecuted    SnapshotState = 5
 	SnapshotStateFailed      SnapshotState = 6
+	SnapshotStateWaiting     SnapshotState = 7
 )
 
 var EnumNamesSnapshotState = map[SnapshotState]string{
@@ -439,6 +440,7 @@ var EnumNamesSnapshotState = map[SnapshotState]string{
 	SnapshotStateExecute:     "Execute",
 	SnapshotStateExecuted:    "Executed",
 	SnapshotStateFailed:      "Failed",
+	SnapshotStateWaiting:     "Waiting",
 }
 
 var EnumValuesSnapshotState = map[string]SnapshotState{
@@ -449,6 +451,7 @@ var EnumValuesSnapshotState = map[string]SnapshotState{
 	"Execute":     SnapshotStateExecute,
 	"Executed":    SnapshotStateExecuted,
 	"Failed":      SnapshotStateFailed,
+	"Waiting":     SnapshotStateWaiting,
 }
 
 func (v SnapshotState) String() string {
@@ -693,11 +693,12 @@ enum class SnapshotState : uint64_t {
   Execute = 4ULL,
   Executed = 5ULL,
   Failed = 6ULL,
+  Waiting = 7ULL,
   MIN = Initial,
-  MAX = Failed
+  MAX = Waiting
 };
 
-inline const SnapshotState (&EnumValuesSnapshotState())[7] {
+inline const SnapshotState (&EnumValuesSnapshotState())[8] {
   static const SnapshotState values[] = {
     SnapshotState::Initial,
     SnapshotState::Handshake,
@@ -705,13 +706,14 @@ inline const SnapshotState (&EnumValuesSnapshotState())[7] {
     SnapshotState::Snapshotted,
     SnapshotState::Execute,
     SnapshotState::Executed,
-    SnapshotState::Failed
+    SnapshotState::Failed,
+    SnapshotState::Waiting
   };
   return values;
 }
 
 inline const char * const *EnumNamesSnapshotState() {
-  static const char * const names[8] = {
+  static const char * const names[9] = {
     "Initial",
     "Handshake",
     "Ready",
@@ -719,13 +721,14 @@ inline const char * const *EnumNamesSnapshotState() {
     "Execute",
     "Executed",
     "Failed",
+    "Waiting",
     nullptr
   };
   return names;
 }
 
 inline const char *EnumNameSnapshotState(SnapshotState e) {
-  if (flatbuffers::IsOutRange(e, SnapshotState::Initial, SnapshotState::Failed)) return "";
+  if (flatbuffers::IsOutRange(e, SnapshotState::Initial, SnapshotState::Waiting)) return "";
   const size_t index = static_cast<size_t>(e);
   return EnumNamesSnapshotState()[index];
 }
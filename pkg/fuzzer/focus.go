@@ -0,0 +1,89 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package fuzzer
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/google/syzkaller/pkg/stat"
+	"github.com/google/syzkaller/prog"
+)
+
+// focusBias is the factor by which the priorities of the focus area syscalls are boosted
+// in the area choice tables.
+const focusBias = 10
+
+// focusState is a snapshot of the corpus focus areas the fuzzer currently uses.
+// It's rebuilt together with the choice table, so the fuzzer picks up focus area changes.
+type focusState struct {
+	weights []float64
+	stats   []*stat.Val
+	cts     []*prog.ChoiceTable
+}
+
+func (fuzzer *Fuzzer) buildFocusState(ct *prog.ChoiceTable) focusState {
+	var ret focusState
+	for i, area := range fuzzer.Config.Corpus.FocusAreas() {
+		ret.weights = append(ret.weights, area.Weight)
+		ret.stats = append(ret.stats, fuzzer.focusStat(area.Name))
+		ret.cts = append(ret.cts, ct.Biased(fuzzer.Config.Corpus.FocusCalls(i), focusBias))
+	}
+	return ret
+}
+
+// focusStat returns the execution counter of the focus area with the name.
+// The counters are kept per fuzzer and are created once per area name.
+func (fuzzer *Fuzzer) focusStat(name string) *stat.Val {
+	fuzzer.focusMu.Lock()
+	defer fuzzer.focusMu.Unlock()
+	if fuzzer.focusStats == nil {
+		fuzzer.focusStats = make(map[string]*stat.Val)
+	}
+	val := fuzzer.focusStats[name]
+	if val == nil {
+		val = stat.New(fmt.Sprintf("focus %v: exec", name),
+			fmt.Sprintf("Executions of programs fuzzed for the %v focus area", name),
+			stat.Rate{}, stat.StackedGraph("focus exec"))
+		fuzzer.focusStats[name] = val
+	}
+	return val
+}
+
+// chooseFocus selects the focus area for the next fuzzed program according to the area weights.
+// Returns -1 and nil stat if the program should not be focused on any area.
+func (fuzzer *Fuzzer) chooseFocus(rnd *rand.Rand) (int, *stat.Val) {
+	fuzzer.ctMu.Lock()
+	defer fuzzer.ctMu.Unlock()
+	val := rnd.Float64()
+	for i, weight := range fuzzer.focus.weights {
+		if val < weight {
+			return i, fuzzer.focus.stats[i]
+		}
+		val -= weight
+	}
+	return -1, nil
+}
+
+// focusChoiceTable returns the choice table biased towards the area syscalls.
+func (fuzzer *Fuzzer) focusChoiceTable(area int) *prog.ChoiceTable {
+	ct := fuzzer.ChoiceTable()
+	fuzzer.ctMu.Lock()
+	defer fuzzer.ctMu.Unlock()
+	if area < 0 || area >= len(fuzzer.focus.cts) {
+		return ct
+	}
+	return fuzzer.focus.cts[area]
+}
+
+// chooseFocusProgram chooses a corpus program that reaches the area,
+// or any corpus program if the area is not reached yet.
+func (fuzzer *Fuzzer) chooseFocusProgram(rnd *rand.Rand, area int) *prog.Prog {
+	if area >= 0 {
+		if p := fuzzer.Config.Corpus.ChooseFocusProgram(rnd, area); p != nil {
+			return p
+		}
+	}
+	return fuzzer.Config.Corpus.ChooseProgram(rnd)
+}
@@ -37,6 +37,9 @@ type Fuzzer struct {
 	ctMu         sync.Mutex // TODO: use RWLock.
 	ctRegenerate chan struct{}
 
+	focus      focusState // protected by ctMu
+	focusMu    sync.Mutex
+	focusStats map[string]*stat.Val // protected by focusMu
 	execQueues
 }
 
@@ -243,11 +246,12 @@ func (fuzzer *Fuzzer) genFuzz() *queue.Request {
 	}
 	var req *queue.Request
 	rnd := fuzzer.rand()
+	area, areaStat := fuzzer.chooseFocus(rnd)
 	if rnd.Float64() < mutateRate {
-		req = mutateProgRequest(fuzzer, rnd)
+		req = mutateProgRequest(fuzzer, rnd, area)
 	}
 	if req == nil {
-		req = genProgRequest(fuzzer, rnd)
+		req = genProgRequest(fuzzer, rnd, area)
 	}
 	if fuzzer.Config.Collide && rnd.Intn(3) == 0 {
 		req = &queue.Request{
@@ -256,6 +260,12 @@ func (fuzzer *Fuzzer) genFuzz() *queue.Request {
 		}
 	}
 	fuzzer.prepare(req, 0, 0)
+	if areaStat != nil {
+		req.OnDone(func(*queue.Request, *queue.Result) bool {
+			areaStat.Add(1)
+			return true
+		})
+	}
 	return req
 }
 
@@ -338,12 +348,14 @@ func (fuzzer *Fuzzer) rand() *rand.Rand {
 
 func (fuzzer *Fuzzer) updateChoiceTable(programs []*prog.Prog) {
 	newCt := fuzzer.target.BuildChoiceTable(programs, fuzzer.Config.EnabledCalls)
+	focus := fuzzer.buildFocusState(newCt)
 
 	fuzzer.ctMu.Lock()
 	defer fuzzer.ctMu.Unlock()
 	if len(programs) >= fuzzer.ctProgs {
 		fuzzer.ctProgs = len(programs)
 		fuzzer.ct = newCt
+		fuzzer.focus = focus
 	}
 }
 
@@ -92,6 +92,55 @@ func TestFuzz(t *testing.T) {
 	}
 }
 
+func TestFocusStats(t *testing.T) {
+	// Each fuzzer counts its own executions.
+	fuzzer1, fuzzer2 := &Fuzzer{}, &Fuzzer{}
+	stat1 := fuzzer1.focusStat("focus stats test")
+	stat2 := fuzzer2.focusStat("focus stats test")
+	assert.Equal(t, stat1, fuzzer1.focusStat("focus stats test"))
+	stat1.Add(1)
+	assert.Equal(t, 1, stat1.Val())
+	assert.Equal(t, 0, stat2.Val())
+}
+
+func TestFocusAreasChange(t *testing.T) {
+	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64Fuzz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := map[*prog.Syscall]bool{}
+	for _, c := range target.Syscalls {
+		calls[c] = true
+	}
+	corpusObj := corpus.NewCorpus(ctx)
+	corpusObj.SetFocusAreas([]corpus.FocusArea{
+		{Name: "focus change test 1", Weight: 0.5},
+		{Name: "focus change test 2", Weight: 0.5},
+	})
+	fuzzer := NewFuzzer(ctx, &Config{
+		Corpus:       corpusObj,
+		Coverage:     true,
+		EnabledCalls: calls,
+	}, rand.New(rand.NewSource(0)), target)
+	run := func() {
+		for i := 0; i < 100; i++ {
+			req := fuzzer.Next()
+			res, _, _ := emulateExec(req)
+			req.Done(res)
+		}
+	}
+	run()
+	// The fuzzer keeps working with the old areas until the choice table is rebuilt,
+	// and switches to the new ones after that.
+	corpusObj.SetFocusAreas([]corpus.FocusArea{{Name: "focus change test 1", Weight: 1}})
+	run()
+	fuzzer.updateChoiceTable(nil)
+	assert.Equal(t, []float64{1}, fuzzer.focus.weights)
+	run()
+}
+
 func BenchmarkFuzzer(b *testing.B) {
 	b.ReportAllocs()
 	target, err := prog.GetTarget(targets.TestOS, targets.TestArch64Fuzz)
@@ -41,10 +41,10 @@ func (ji *JobInfo) ID() string {
 	return fmt.Sprintf("%p", ji)
 }
 
-func genProgRequest(fuzzer *Fuzzer, rnd *rand.Rand) *queue.Request {
+func genProgRequest(fuzzer *Fuzzer, rnd *rand.Rand, area int) *queue.Request {
 	p := fuzzer.target.Generate(rnd,
 		prog.RecommendedCalls,
-		fuzzer.ChoiceTable())
+		fuzzer.focusChoiceTable(area))
 	return &queue.Request{
 		Prog:     p,
 		ExecOpts: setFlags(flatrpc.ExecFlagCollectSignal),
@@ -52,15 +52,15 @@ func genProgRequest(fuzzer *Fuzzer, rnd *rand.Rand) *queue.Request {
 	}
 }
 
-func mutateProgRequest(fuzzer *Fuzzer, rnd *rand.Rand) *queue.Request {
-	p := fuzzer.Config.Corpus.ChooseProgram(rnd)
+func mutateProgRequest(fuzzer *Fuzzer, rnd *rand.Rand, area int) *queue.Request {
+	p := fuzzer.chooseFocusProgram(rnd, area)
 	if p == nil {
 		return nil
 	}
 	newP := p.Clone()
 	newP.Mutate(rnd,
 		prog.RecommendedCalls,
-		fuzzer.ChoiceTable(),
+		fuzzer.focusChoiceTable(area),
 		fuzzer.Config.NoMutateCalls,
 		fuzzer.Config.Corpus.Programs(),
 	)
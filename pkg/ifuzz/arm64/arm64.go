@@ -69,15 +69,20 @@ func (insn *Insn) Encode(cfg *iset.Config, r *rand.Rand) []byte {
 }
 
 func (insnset *InsnSet) Decode(mode iset.Mode, text []byte) (int, error) {
+	_, size, err := insnset.DecodeInsn(mode, text)
+	return size, err
+}
+
+func (insnset *InsnSet) DecodeInsn(mode iset.Mode, text []byte) (iset.Insn, int, error) {
 	if len(text) < 4 {
-		return 0, fmt.Errorf("must be at least 4 bytes")
+		return nil, 0, fmt.Errorf("must be at least 4 bytes")
 	}
 	opcode := binary.LittleEndian.Uint32(text[:4])
-	_, err := ParseInsn(opcode)
+	insn, err := ParseInsn(opcode)
 	if err != nil {
-		return 0, fmt.Errorf("failed to decode %x", opcode)
+		return nil, 0, fmt.Errorf("failed to decode %x", opcode)
 	}
-	return 4, nil
+	return &insn, 4, nil
 }
 
 func (insnset *InsnSet) DecodeExt(mode iset.Mode, text []byte) (int, error) {
@@ -4,7 +4,9 @@
 package ifuzz
 
 import (
+	"fmt"
 	"math/rand"
+	"strings"
 
 	_ "github.com/google/syzkaller/pkg/ifuzz/arm64/generated" // pull in generated instruction descriptions
 	"github.com/google/syzkaller/pkg/ifuzz/iset"
@@ -16,6 +18,7 @@ type (
 	Config    = iset.Config
 	MemRegion = iset.MemRegion
 	Mode      = iset.Mode
+	Insn      = iset.Insn
 )
 
 const (
@@ -54,6 +57,15 @@ func Mutate(cfg *Config, r *rand.Rand, text []byte) []byte {
 			text1 := insn.Encode(cfg, r)
 			i := r.Intn(len(insns))
 			insns[i] = text1
+		case x < 50 && len(insns) != 0:
+			// Re-encode a known instruction with new operands.
+			i := r.Intn(len(insns))
+			insn, size, err := iset.Arches[cfg.Arch].DecodeInsn(cfg.Mode, insns[i])
+			if err != nil || size != len(insns[i]) || !cfg.IsCompatible(insn) {
+				retry = true
+				break
+			}
+			insns[i] = insn.Encode(cfg, r)
 		case x < 70 && len(insns) != 0:
 			// Mutate instruction.
 			i := r.Intn(len(insns))
@@ -101,6 +113,54 @@ func Mutate(cfg *Config, r *rand.Rand, text []byte) []byte {
 	return text
 }
 
+// DecodedInsn is a single instruction recovered from machine code.
+type DecodedInsn struct {
+	Insn Insn // nil if the bytes don't form a known instruction
+	Text []byte
+}
+
+// Decode splits text into instructions of the cfg.Arch/cfg.Mode instruction set.
+// Consecutive bytes that can't be decoded are returned as a single DecodedInsn with nil Insn.
+func Decode(cfg *Config, text []byte) []DecodedInsn {
+	insnset := iset.Arches[cfg.Arch]
+	var insns []DecodedInsn
+	var bad []byte
+	for len(text) != 0 {
+		insn, n, err := insnset.DecodeInsn(cfg.Mode, text)
+		if err != nil || n == 0 {
+			bad = append(bad, text[0])
+			text = text[1:]
+			continue
+		}
+		if bad != nil {
+			insns = append(insns, DecodedInsn{Text: bad})
+			bad = nil
+		}
+		insns = append(insns, DecodedInsn{Insn: insn, Text: text[:n:n]})
+		text = text[n:]
+	}
+	if bad != nil {
+		insns = append(insns, DecodedInsn{Text: bad})
+	}
+	return insns
+}
+
+// Disassemble returns a listing of text with one instruction per line:
+// offset, instruction bytes and instruction name ("???" for bytes that can't be decoded).
+func Disassemble(cfg *Config, text []byte) string {
+	buf := new(strings.Builder)
+	offset := 0
+	for _, insn := range Decode(cfg, text) {
+		name := "???"
+		if insn.Insn != nil {
+			name, _, _, _ = insn.Insn.Info()
+		}
+		fmt.Fprintf(buf, "%04x: %-30v %v\n", offset, fmt.Sprintf("% x", insn.Text), name)
+		offset += len(insn.Text)
+	}
+	return buf.String()
+}
+
 func randInsn(cfg *Config, r *rand.Rand) iset.Insn {
 	insnset := iset.Arches[cfg.Arch]
 	var insns []iset.Insn
@@ -4,6 +4,7 @@
 package ifuzz
 
 import (
+	"bytes"
 	"encoding/hex"
 	"math/rand"
 	"testing"
@@ -152,6 +153,92 @@ func testGenerate(t *testing.T, arch string) {
 	}
 }
 
+func TestRoundTrip(t *testing.T) {
+	for _, arch := range allArches {
+		t.Run(arch, func(t *testing.T) {
+			testRoundTrip(t, arch)
+		})
+	}
+}
+
+// testRoundTrip checks that every encoded instruction decodes back into an instruction
+// that covers the whole encoding and that itself can be re-encoded and decoded again.
+// Decoded instruction may be a different alias of the same encoding.
+func testRoundTrip(t *testing.T, arch string) {
+	insnset := iset.Arches[arch]
+	r := rand.New(testutil.RandSource(t))
+	for mode := iset.Mode(0); mode < iset.ModeLast; mode++ {
+		cfg := &iset.Config{
+			Arch: arch,
+			Mode: mode,
+			Priv: true,
+		}
+		for _, insn := range allInsns(arch, mode, true, false) {
+			name, _, _, _ := insn.Info()
+			text := insn.Encode(cfg, r)
+			decoded, size, err := insnset.DecodeInsn(mode, text)
+			if err != nil || size != len(text) {
+				t.Fatalf("decoding %v %v failed (mode=%v): decoded %v/%v: %v",
+					name, hex.EncodeToString(text), mode, size, len(text), err)
+			}
+			name1, _, _, _ := decoded.Info()
+			text1 := decoded.Encode(cfg, r)
+			if _, size, err := insnset.DecodeInsn(mode, text1); err != nil || size != len(text1) {
+				t.Fatalf("decoding %v (decoded from %v) %v failed (mode=%v): decoded %v/%v: %v",
+					name1, name, hex.EncodeToString(text1), mode, size, len(text1), err)
+			}
+		}
+		if len(insnset.GetInsns(mode, iset.TypeUser)) == 0 {
+			continue
+		}
+		cfg.Exec = true
+		cfg.Len = 10
+		text := Generate(cfg, r)
+		var text1 []byte
+		for _, insn := range Decode(cfg, text) {
+			text1 = append(text1, insn.Text...)
+		}
+		if !bytes.Equal(text, text1) {
+			t.Fatalf("decoded text does not match original (mode=%v):\n% x\n% x", mode, text, text1)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		arch   string
+		mode   iset.Mode
+		text   string
+		result string
+	}{
+		{
+			arch: ArchX86,
+			mode: ModeLong64,
+			text: "0f300f32f4ffff",
+			result: "0000: 0f 30                          WRMSR\n" +
+				"0002: 0f 32                          RDMSR\n" +
+				"0004: f4                             HLT\n" +
+				"0005: ff ff                          ???\n",
+		},
+		{
+			arch:   ArchArm64,
+			mode:   ModeLong64,
+			text:   "c0035fd6",
+			result: "0000: c0 03 5f d6                    RET\n",
+		},
+	}
+	for _, test := range tests {
+		text, err := hex.DecodeString(test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := &iset.Config{Arch: test.arch, Mode: test.mode}
+		if got := Disassemble(cfg, text); got != test.result {
+			t.Errorf("%v %v: got:\n%v\nwant:\n%v", test.arch, test.text, got, test.result)
+		}
+	}
+}
+
 func allInsns(arch string, mode iset.Mode, priv, exec bool) []iset.Insn {
 	insnset := iset.Arches[arch]
 	insns := insnset.GetInsns(mode, iset.TypeUser)
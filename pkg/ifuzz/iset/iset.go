@@ -29,7 +29,8 @@ type Insn interface {
 type InsnSet interface {
 	GetInsns(mode Mode, typ Type) []Insn
 	Decode(mode Mode, text []byte) (int, error)
-	DecodeExt(mode Mode, text []byte) (int, error) // XED, to keep ifuzz_test happy
+	DecodeInsn(mode Mode, text []byte) (Insn, int, error) // like Decode, but also returns the instruction
+	DecodeExt(mode Mode, text []byte) (int, error)        // XED, to keep ifuzz_test happy
 }
 
 type Config struct {
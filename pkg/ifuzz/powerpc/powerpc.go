@@ -104,6 +104,39 @@ func (insnset *InsnSet) Decode(mode iset.Mode, text []byte) (int, error) {
 	return 0, fmt.Errorf("unrecognised instruction %08x", insn32)
 }
 
+// DecodeInsn is like Decode, but also returns the matched instruction description.
+// Unlike Decode it does not accept words that match only pseudo instructions.
+func (insnset *InsnSet) DecodeInsn(mode iset.Mode, text []byte) (iset.Insn, int, error) {
+	size, err := insnset.Decode(mode, text)
+	if err != nil {
+		return nil, 0, err
+	}
+	insn32 := binary.LittleEndian.Uint32(text)
+	var suffix uint32
+	if size == 8 {
+		suffix = binary.LittleEndian.Uint32(text[4:])
+	}
+	var prefixed *Insn
+	for _, ins := range insnset.Insns {
+		if ins.Pseudo || ins.Mask&insn32 != ins.Opcode {
+			continue
+		}
+		if size == 4 {
+			return ins, size, nil
+		}
+		if ins.MaskSuffix&suffix == ins.OpcodeSuffix {
+			return ins, size, nil
+		}
+		if prefixed == nil {
+			prefixed = ins
+		}
+	}
+	if prefixed != nil {
+		return prefixed, size, nil
+	}
+	return nil, 0, fmt.Errorf("unrecognised instruction %08x", insn32)
+}
+
 func (insnset *InsnSet) DecodeExt(mode iset.Mode, text []byte) (int, error) {
 	return 0, fmt.Errorf("no external decoder")
 }
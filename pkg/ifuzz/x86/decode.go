@@ -12,10 +12,28 @@ import (
 // Decode decodes instruction length for the given mode.
 // It can have falsely decode incorrect instructions,
 // but should not fail to decode correct instructions.
-// nolint: gocyclo, nestif, gocognit, funlen
 func (insnset *InsnSet) Decode(mode iset.Mode, text []byte) (int, error) {
+	_, size, err := insnset.decode(mode, text)
+	return size, err
+}
+
+// DecodeInsn is like Decode, but also returns the matched instruction description.
+func (insnset *InsnSet) DecodeInsn(mode iset.Mode, text []byte) (iset.Insn, int, error) {
+	insn, size, err := insnset.decode(mode, text)
+	if err != nil {
+		return nil, 0, err
+	}
+	if insn.Pseudo {
+		// Pseudo instructions don't have opcodes and match only stray prefixes.
+		return nil, 0, fmt.Errorf("unknown instruction")
+	}
+	return insn, size, nil
+}
+
+// nolint: gocyclo, nestif, gocognit, funlen
+func (insnset *InsnSet) decode(mode iset.Mode, text []byte) (*Insn, int, error) {
 	if len(text) == 0 {
-		return 0, fmt.Errorf("zero-length instruction")
+		return nil, 0, fmt.Errorf("zero-length instruction")
 	}
 	prefixes := prefixes32
 	var operSize, immSize, dispSize, addrSize int
@@ -53,7 +71,7 @@ func (insnset *InsnSet) Decode(mode iset.Mode, text []byte) (int, error) {
 			vexMap = 1 // V0F
 		}
 		if len(text) < prefixLen {
-			return 0, fmt.Errorf("bad VEX/XOP prefix")
+			return nil, 0, fmt.Errorf("bad VEX/XOP prefix")
 		}
 		if prefixLen == 3 {
 			vexMap = text[1] & 0x1f
@@ -93,7 +111,7 @@ func (insnset *InsnSet) Decode(mode iset.Mode, text []byte) (int, error) {
 		operSize, immSize, dispSize, addrSize = operSize1, immSize1, dispSize1, addrSize1
 		decodedPrefixes = decodedPrefixes[:prefixLen]
 		if len(text) == 0 {
-			return 0, fmt.Errorf("no opcode, only prefixes")
+			return nil, 0, fmt.Errorf("no opcode, only prefixes")
 		}
 	}
 nextInsn:
@@ -207,9 +225,9 @@ nextInsn:
 			}
 			text1 = text1[1:]
 		}
-		return prefixLen + len(text) - len(text1), nil
+		return insn, prefixLen + len(text) - len(text1), nil
 	}
-	return 0, fmt.Errorf("unknown instruction")
+	return nil, 0, fmt.Errorf("unknown instruction")
 }
 
 var XedDecode func(mode iset.Mode, text []byte) (int, error)
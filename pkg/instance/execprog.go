@@ -24,6 +24,8 @@ type OptionalConfig struct {
 	OldFlagsCompatMode bool
 	BeforeContextLen   int
 	StraceBin          string
+	// Frames of known memory leaks that are not reported in the leak mode.
+	LeakFrames []string
 }
 
 type ExecProgInstance struct {
@@ -179,7 +181,7 @@ func (inst *ExecProgInstance) RunSyzProgFile(progFile string, duration time.Dura
 	}
 	command := ExecprogCmd(inst.execprogBin, inst.executorBin, target.OS, target.Arch, opts.Sandbox,
 		opts.SandboxArg, opts.Repeat, opts.Threaded, opts.Collide, opts.Procs, faultCall, opts.FaultNth,
-		!inst.OldFlagsCompatMode, inst.mgrCfg.Timeouts.Slowdown, vmProgFile)
+		opts.Leak, inst.LeakFrames, !inst.OldFlagsCompatMode, inst.mgrCfg.Timeouts.Slowdown, vmProgFile)
 	return inst.runCommand(command, duration, exitCondition)
 }
 
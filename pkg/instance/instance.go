@@ -50,6 +50,7 @@ type BuildKernelConfig struct {
 	SysctlFile   string
 	KernelConfig []byte
 	BuildCPUs    int
+	BuildCache   *build.Cache
 }
 
 func NewEnv(cfg *mgrconfig.Config, buildSem, testSem *Semaphore) (Env, error) {
@@ -155,6 +156,7 @@ func (env *env) BuildKernel(buildCfg *BuildKernelConfig) (
 		SysctlFile:   buildCfg.SysctlFile,
 		Config:       buildCfg.KernelConfig,
 		BuildCPUs:    buildCfg.BuildCPUs,
+		Cache:        buildCfg.BuildCache,
 	}
 	details, err := build.Image(params)
 	if err != nil {
@@ -442,7 +444,8 @@ func (inst *inst) csourceOptions() (csource.Options, error) {
 }
 
 func ExecprogCmd(execprog, executor, OS, arch, sandbox string, sandboxArg int, repeat, threaded, collide bool,
-	procs, faultCall, faultNth int, optionalFlags bool, slowdown int, progFile string) string {
+	procs, faultCall, faultNth int, leak bool, leakFrames []string, optionalFlags bool, slowdown int,
+	progFile string) string {
 	repeatCount := 1
 	if repeat {
 		repeatCount = 0
@@ -462,6 +465,8 @@ func ExecprogCmd(execprog, executor, OS, arch, sandbox string, sandboxArg int, r
 		optionalArg += " " + tool.OptionalFlags([]tool.Flag{
 			{Name: "slowdown", Value: fmt.Sprint(slowdown)},
 			{Name: "sandboxArg", Value: fmt.Sprint(sandboxArg)},
+			{Name: "leak", Value: fmt.Sprint(leak)},
+			{Name: "leak_frames", Value: strings.Join(leakFrames, ",")},
 		})
 	}
 
@@ -33,8 +33,10 @@ func TestExecprogCmd(t *testing.T) {
 	flagSignal := flags.Bool("cover", false, "collect feedback signals (coverage)")
 	flagSandbox := flags.String("sandbox", "none", "sandbox for fuzzing (none/setuid/namespace/android)")
 	flagSlowdown := flags.Int("slowdown", 1, "")
+	flagLeak := flags.Bool("leak", false, "")
+	flagLeakFrames := flags.String("leak_frames", "", "")
 	cmdLine := ExecprogCmd(os.Args[0], "/myexecutor", targets.FreeBSD, targets.I386,
-		"namespace", 3, true, false, true, 7, 2, 3, true, 10, "myprog")
+		"namespace", 3, true, false, true, 7, 2, 3, true, []string{"foo", "bar"}, true, 10, "myprog")
 	args := strings.Split(cmdLine, " ")[1:]
 	if err := tool.ParseFlags(flags, args); err != nil {
 		t.Fatal(err)
@@ -78,6 +80,12 @@ func TestExecprogCmd(t *testing.T) {
 	if *flagSlowdown != 10 {
 		t.Errorf("bad slowdown: %v, want: %v", *flagSlowdown, 10)
 	}
+	if !*flagLeak {
+		t.Errorf("bad leak: %v, want: %v", *flagLeak, true)
+	}
+	if *flagLeakFrames != "foo,bar" {
+		t.Errorf("bad leak frames: %q, want: %q", *flagLeakFrames, "foo,bar")
+	}
 }
 
 func TestRunnerCmd(t *testing.T) {
@@ -5,13 +5,69 @@ package kconfig
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // expr represents an arbitrary kconfig expression used in "depends on", "visible if", "if", etc.
-// Currently we can only extract dependent symbols from expressions.
+// We can extract dependent symbols from expressions and evaluate them against a .config.
 type expr interface {
 	String() string
 	collectDeps(map[string]bool)
+	eval(cf *ConfigFile) tristate
+}
+
+// tristate is the result of expression evaluation.
+type tristate int
+
+const (
+	triNo tristate = iota
+	triMod
+	triYes
+)
+
+func (val tristate) String() string {
+	switch val {
+	case triYes:
+		return "y"
+	case triMod:
+		return "m"
+	default:
+		return "n"
+	}
+}
+
+// symbolValue returns value of the symbol in the config: y/m/n for bool/tristate symbols,
+// unquoted string for string symbols and the number for int/hex symbols.
+func symbolValue(cf *ConfigFile, name string) string {
+	switch name {
+	case "y", "m", "n":
+		return name
+	}
+	if _, err := strconv.ParseInt(name, 0, 64); err == nil {
+		// Numbers are parsed as identifiers, but are constant symbols.
+		return name
+	}
+	val := cf.Value(name)
+	if val == No {
+		return "n"
+	}
+	if unquoted, err := strconv.Unquote(val); err == nil {
+		return unquoted
+	}
+	return val
+}
+
+// exprValue returns the value of a comparison operand.
+func exprValue(ex expr, cf *ConfigFile) string {
+	switch ex := ex.(type) {
+	case *exprIdent:
+		return symbolValue(cf, ex.name)
+	case *exprString:
+		return ex.val
+	default:
+		return ex.eval(cf).String()
+	}
 }
 
 type exprShell struct {
@@ -25,6 +81,12 @@ func (ex *exprShell) String() string {
 func (ex *exprShell) collectDeps(deps map[string]bool) {
 }
 
+// We don't execute shell commands, so assume that they succeed
+// (they are mostly used to check compiler features).
+func (ex *exprShell) eval(cf *ConfigFile) tristate {
+	return triYes
+}
+
 type exprNot struct {
 	ex expr
 }
@@ -36,6 +98,10 @@ func (ex *exprNot) String() string {
 func (ex *exprNot) collectDeps(deps map[string]bool) {
 }
 
+func (ex *exprNot) eval(cf *ConfigFile) tristate {
+	return triYes - ex.ex.eval(cf)
+}
+
 type exprIdent struct {
 	name string
 }
@@ -48,6 +114,17 @@ func (ex *exprIdent) collectDeps(deps map[string]bool) {
 	deps[ex.name] = true
 }
 
+func (ex *exprIdent) eval(cf *ConfigFile) tristate {
+	switch symbolValue(cf, ex.name) {
+	case "y":
+		return triYes
+	case "m":
+		return triMod
+	default:
+		return triNo
+	}
+}
+
 type exprString struct {
 	val string
 }
@@ -59,6 +136,10 @@ func (ex *exprString) String() string {
 func (ex *exprString) collectDeps(deps map[string]bool) {
 }
 
+func (ex *exprString) eval(cf *ConfigFile) tristate {
+	return triNo
+}
+
 type exprBin struct {
 	op  binOp
 	lex expr
@@ -111,6 +192,52 @@ func (ex *exprBin) collectDeps(deps map[string]bool) {
 	ex.rex.collectDeps(deps)
 }
 
+func (ex *exprBin) eval(cf *ConfigFile) tristate {
+	switch ex.op {
+	case opAnd:
+		return min(ex.lex.eval(cf), ex.rex.eval(cf))
+	case opOr:
+		return max(ex.lex.eval(cf), ex.rex.eval(cf))
+	}
+	cmp := compareValues(exprValue(ex.lex, cf), exprValue(ex.rex, cf))
+	res := false
+	switch ex.op {
+	case opEq:
+		res = cmp == 0
+	case opNe:
+		res = cmp != 0
+	case opLt:
+		res = cmp < 0
+	case opLe:
+		res = cmp <= 0
+	case opGt:
+		res = cmp > 0
+	case opGe:
+		res = cmp >= 0
+	}
+	if res {
+		return triYes
+	}
+	return triNo
+}
+
+// compareValues compares symbol values numerically if both are numbers, and as strings otherwise.
+func compareValues(a, b string) int {
+	an, aerr := strconv.ParseInt(a, 0, 64)
+	bn, berr := strconv.ParseInt(b, 0, 64)
+	if aerr != nil || berr != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case an < bn:
+		return -1
+	case an > bn:
+		return 1
+	default:
+		return 0
+	}
+}
+
 func exprAnd(lex, rex expr) expr {
 	if lex == nil {
 		return rex
@@ -131,7 +258,7 @@ func exprAnd(lex, rex expr) expr {
 // The doc claims that all operators have different precedence levels,
 // e.g. '<' has higher precedence than '>' rather than being left-associative with the same precedence.
 // This is somewhat strange semantics and here it is implemented as simply being left-associative.
-// For now it does not matter since we do not evaluate expressions.
+// In practice such expressions are not mixed without parenthesis, so this does not affect evaluation.
 func (p *parser) parseExpr() expr {
 	ex := p.parseExprAnd()
 	for p.TryConsume("||") {
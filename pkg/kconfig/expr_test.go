@@ -96,3 +96,46 @@ func TestFuzzParseExpr(t *testing.T) {
 		FuzzParseExpr([]byte(data)[:len(data):len(data)])
 	}
 }
+
+func TestEvalExpr(t *testing.T) {
+	cf, err := ParseConfigData([]byte(`
+CONFIG_A=y
+CONFIG_M=m
+CONFIG_I=42
+CONFIG_S="foo"
+# CONFIG_N is not set
+`), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		`A`:               "y",
+		`M`:               "m",
+		`N`:               "n",
+		`UNKNOWN`:         "n",
+		`!M`:              "m",
+		`A && M`:          "m",
+		`N || M`:          "m",
+		`!N && A`:         "y",
+		`I > 10`:          "y",
+		`I >= 0x100`:      "n",
+		`S = "foo"`:       "y",
+		`S != "foo"`:      "n",
+		`A = y`:           "y",
+		`M = y || N = n`:  "y",
+		`$(cc-option,-g)`: "y",
+	}
+	for in, want := range tests {
+		p := newParser([]byte(in+" Z"), "file")
+		if !p.nextLine() {
+			t.Fatal("nextLine failed")
+		}
+		ex := p.parseExpr()
+		if p.err != nil {
+			t.Fatalf("%v: failed: %v", in, p.err)
+		}
+		if got := ex.eval(cf).String(); got != want {
+			t.Errorf("%v: got %v, want %v", in, got, want)
+		}
+	}
+}
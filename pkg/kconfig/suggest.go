@@ -0,0 +1,75 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package kconfig
+
+import (
+	"sort"
+)
+
+// Suggestion is a config that is likely to enable some disabled functionality (e.g. syscalls).
+type Suggestion struct {
+	Config string   // config name without CONFIG_ prefix
+	Prompt string   // Kconfig prompt, empty if Kconfig is not known
+	Deps   []string // configs the config transitively depends on that are not enabled yet
+	Items  []string // disabled items that the config is likely to enable
+}
+
+// Suggest groups disabled items by the configs that may enable them.
+// candidates maps item names to candidate configs (guesses that don't need to be precise).
+// kconf and current are optional. If kconf is provided, candidates that don't exist
+// in Kconfig are dropped, and dependencies are filled in. If current is provided,
+// candidates that are already enabled in it are dropped.
+// Suggestions that are likely to enable more items go first.
+func Suggest(candidates map[string][]string, kconf *KConfig, current *ConfigFile) []*Suggestion {
+	configs := make(map[string]*Suggestion)
+	for item, names := range candidates {
+		for _, name := range names {
+			s, ok := configs[name]
+			if !ok {
+				s = newSuggestion(name, kconf, current)
+				configs[name] = s
+			}
+			if s != nil {
+				s.Items = append(s.Items, item)
+			}
+		}
+	}
+	var res []*Suggestion
+	for _, s := range configs {
+		if s == nil {
+			continue
+		}
+		sort.Strings(s.Items)
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if len(res[i].Items) != len(res[j].Items) {
+			return len(res[i].Items) > len(res[j].Items)
+		}
+		return res[i].Config < res[j].Config
+	})
+	return res
+}
+
+func newSuggestion(name string, kconf *KConfig, current *ConfigFile) *Suggestion {
+	if current != nil && current.Value(name) != No {
+		return nil
+	}
+	s := &Suggestion{Config: name}
+	if kconf == nil {
+		return s
+	}
+	menu := kconf.Configs[name]
+	if menu == nil {
+		return nil
+	}
+	s.Prompt = menu.Prompt()
+	for dep := range menu.DependsOn() {
+		if current == nil || current.Value(dep) == No {
+			s.Deps = append(s.Deps, dep)
+		}
+	}
+	sort.Strings(s.Deps)
+	return s
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package kconfig
+
+import (
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggest(t *testing.T) {
+	const (
+		kconfig = `
+mainmenu "test"
+config NET
+	bool "Networking support"
+
+menuconfig HAMRADIO
+	depends on NET
+	bool "Amateur Radio support"
+
+config AX25
+	tristate "Amateur Radio AX.25 Level 2 protocol"
+	depends on HAMRADIO
+
+config EXT4_FS
+	tristate "The Extended 4 (ext4) filesystem"
+`
+		config = `
+CONFIG_NET=y
+CONFIG_EXT4_FS=y
+`
+	)
+	target := targets.Get("linux", "amd64")
+	kconf, err := ParseData(target, []byte(kconfig), "Kconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := ParseConfigData([]byte(config), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates := map[string][]string{
+		"socket$ax25":      {"AX25"},
+		"mount$ext4":       {"EXT4_FS", "EXT4"},
+		"socket$hamradio":  {"AX25", "HAMRADIO"},
+		"mount$nonexisent": {"NONEXISTENT_FS"},
+	}
+	assert.Equal(t, []*Suggestion{
+		{
+			Config: "AX25",
+			Prompt: "Amateur Radio AX.25 Level 2 protocol",
+			Deps:   []string{"HAMRADIO"},
+			Items:  []string{"socket$ax25", "socket$hamradio"},
+		},
+		{
+			Config: "HAMRADIO",
+			Prompt: "Amateur Radio support",
+			Items:  []string{"socket$hamradio"},
+		},
+	}, Suggest(candidates, kconf, current))
+
+	// Without Kconfig and .config the candidates are not verified.
+	assert.Equal(t, []*Suggestion{
+		{Config: "AX25", Items: []string{"socket$ax25", "socket$hamradio"}},
+		{Config: "EXT4", Items: []string{"mount$ext4"}},
+		{Config: "EXT4_FS", Items: []string{"mount$ext4"}},
+		{Config: "HAMRADIO", Items: []string{"socket$hamradio"}},
+		{Config: "NONEXISTENT_FS", Items: []string{"mount$nonexisent"}},
+	}, Suggest(candidates, nil, nil))
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package kconfig
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// RandomVariant returns a copy of the base config where each of the toggle configs
+// is randomly enabled or disabled, and exactly one config from each of the choose groups
+// is enabled (e.g. a preemption model or a slab allocator).
+// Configs are enabled only if their dependencies are satisfied, configs that depend
+// on the disabled configs are disabled as well. The second result lists the changes
+// in the form of CONFIG=value in the order they were made.
+func (kconf *KConfig) RandomVariant(base *ConfigFile, toggle []string, choose [][]string,
+	rnd *rand.Rand) (*ConfigFile, []string) {
+	cf := base.Clone()
+	var changes []string
+	disabled := make(map[string]bool)
+	set := func(name string, enable bool) {
+		menu := kconf.Configs[name]
+		if menu == nil {
+			return
+		}
+		old := cf.Value(name)
+		if !enable {
+			if old != No {
+				cf.Unset(name)
+				disabled[name] = true
+				changes = append(changes, fmt.Sprintf("%v=n", name))
+			}
+			return
+		}
+		val := Yes
+		switch menu.evalDeps(cf) {
+		case triNo:
+			return
+		case triMod:
+			if menu.Type != TypeTristate {
+				return
+			}
+			val = Mod
+		}
+		if old != val {
+			cf.Set(name, val)
+			changes = append(changes, fmt.Sprintf("%v=%v", name, val))
+		}
+	}
+	for _, i := range rnd.Perm(len(toggle)) {
+		set(toggle[i], rnd.Intn(2) == 0)
+	}
+	for _, group := range choose {
+		var candidates []string
+		for _, name := range group {
+			if menu := kconf.Configs[name]; menu != nil && menu.evalDeps(cf) != triNo {
+				candidates = append(candidates, name)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		chosen := candidates[rnd.Intn(len(candidates))]
+		for _, name := range group {
+			if name != chosen {
+				set(name, false)
+			}
+		}
+		set(chosen, true)
+	}
+	changes = append(changes, kconf.disableDependents(cf, disabled)...)
+	return cf, changes
+}
+
+// disableDependents disables configs that depend on the disabled configs
+// and whose dependencies are not satisfied anymore.
+func (kconf *KConfig) disableDependents(cf *ConfigFile, disabled map[string]bool) []string {
+	var changes []string
+	for changed := true; changed; {
+		changed = false
+		for _, cfg := range cf.Configs {
+			menu := kconf.Configs[cfg.Name]
+			if cfg.Value == No || menu == nil || !dependsOnAny(menu, disabled) ||
+				menu.evalDeps(cf) != triNo {
+				continue
+			}
+			cf.Unset(cfg.Name)
+			disabled[cfg.Name] = true
+			changes = append(changes, fmt.Sprintf("%v=n", cfg.Name))
+			changed = true
+		}
+	}
+	return changes
+}
+
+func dependsOnAny(menu *Menu, configs map[string]bool) bool {
+	for dep := range menu.DependsOn() {
+		if configs[dep] {
+			return true
+		}
+	}
+	return false
+}
+
+// evalDeps evaluates the config dependencies against the config file.
+func (m *Menu) evalDeps(cf *ConfigFile) tristate {
+	res := triYes
+	if m.dependsOn != nil {
+		res = min(res, m.dependsOn.eval(cf))
+	}
+	if m.visibleIf != nil {
+		res = min(res, m.visibleIf.eval(cf))
+	}
+	return res
+}
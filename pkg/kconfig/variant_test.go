@@ -0,0 +1,87 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package kconfig
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestRandomVariant(t *testing.T) {
+	const (
+		kconfig = `
+mainmenu "test"
+config DEBUG
+	bool "debug"
+config DEBUG_EXTRA
+	bool "extra debug"
+	depends on DEBUG
+config DEBUG_MOD
+	tristate "debug module"
+	depends on DEBUG && MODULES
+config MODULES
+	bool "modules"
+choice
+	prompt "Preemption model"
+config PREEMPT_NONE
+	bool "none"
+config PREEMPT
+	bool "full"
+config PREEMPT_RT
+	bool "rt"
+	depends on !DEBUG
+endchoice
+`
+		baseConfig = `
+CONFIG_DEBUG=y
+CONFIG_DEBUG_EXTRA=y
+CONFIG_PREEMPT_NONE=y
+`
+	)
+	target := targets.Get("linux", "amd64")
+	kconf, err := ParseData(target, []byte(kconfig), "Kconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := ParseConfigData([]byte(baseConfig), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	toggle := []string{"DEBUG", "DEBUG_MOD", "MODULES", "UNKNOWN"}
+	choose := [][]string{{"PREEMPT_NONE", "PREEMPT", "PREEMPT_RT"}}
+	seen := make(map[string]bool)
+	for seed := int64(0); seed < 100; seed++ {
+		cf, changes := kconf.RandomVariant(base, toggle, choose, rand.New(rand.NewSource(seed)))
+		t.Logf("seed %v: %v", seed, changes)
+		for _, cfg := range cf.Configs {
+			if cfg.Value == No {
+				continue
+			}
+			seen[cfg.Name+"="+cfg.Value] = true
+			if deps := kconf.Configs[cfg.Name].evalDeps(cf); deps == triNo {
+				t.Errorf("seed %v: %v is enabled, but dependencies are not satisfied", seed, cfg.Name)
+			}
+		}
+		preempt := 0
+		for _, name := range choose[0] {
+			if cf.Value(name) != No {
+				preempt++
+			}
+		}
+		if preempt != 1 {
+			t.Errorf("seed %v: enabled %v preemption models", seed, preempt)
+		}
+		if base.Value("DEBUG_EXTRA") != Yes {
+			t.Fatalf("base config was modified")
+		}
+	}
+	for _, cfg := range []string{"DEBUG=y", "DEBUG_EXTRA=y", "DEBUG_MOD=y", "PREEMPT_NONE=y",
+		"PREEMPT=y", "PREEMPT_RT=y"} {
+		if !seen[cfg] {
+			t.Errorf("%v was never generated", cfg)
+		}
+	}
+}
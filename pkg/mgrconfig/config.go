@@ -57,6 +57,11 @@ type Config struct {
 	//		{ "name": "mydriver": "path": ["mydriver_path"]}
 	//	]
 	KernelSubsystem []Subsystem `json:"kernel_subsystem,omitempty"`
+	// Share of fuzzing executions (in percent) to spend on the given kernel subsystems (optional).
+	// Subsystems are looked up in kernel_subsystem first and then in the built-in subsystem list
+	// for the target OS. The rest of executions is distributed as usual. Requires coverage.
+	//	"subsystem_weights": {"io_uring": 30, "net": 20}
+	SubsystemWeights map[string]int `json:"subsystem_weights,omitempty"`
 	// Arbitrary optional tag that is saved along with crash reports (e.g. branch/commit).
 	Tag string `json:"tag,omitempty"`
 	// Location of the disk image file.
@@ -175,6 +180,8 @@ type Config struct {
 
 	// List of syscalls to test (optional). For example:
 	//	"enable_syscalls": [ "mmap", "openat$ashmem", "ioctl$ASHMEM*" ]
+	// Required for the "namespace" VM type, which runs programs directly on the host;
+	// syscalls that can harm the host (reboot, init_module, etc) are rejected for it.
 	EnabledSyscalls []string `json:"enable_syscalls,omitempty"`
 	// List of system calls that should be treated as disabled (optional).
 	DisabledSyscalls []string `json:"disable_syscalls,omitempty"`
@@ -183,6 +190,9 @@ type Config struct {
 	// List of regexps for known bugs.
 	// Don't save reports matching these regexps, but reboot VM after them,
 	// matched against whole report output.
+	// Data races are also matched against the report title which lists the racing functions
+	// in sorted order (e.g. "KCSAN: data-race in bar / foo"), so a pair can be suppressed
+	// regardless of the order in which the kernel printed it.
 	Suppressions []string `json:"suppressions,omitempty"`
 	// Completely ignore reports matching these regexps (don't save nor reboot),
 	// must match the first line of crash message.
@@ -235,6 +245,14 @@ type Experimental struct {
 
 	// Use automatically (auto) generated or manually (manual) written descriptions or any (any) (default: manual)
 	DescriptionsMode string `json:"descriptions_mode"`
+
+	// Record console output and executor RPC traffic of fuzzing VMs. Recordings of crashed VMs
+	// are saved as crashes/*/record* and can be replayed with tools/syz-replay.
+	RecordVMs bool `json:"record_vms"`
+
+	// Store crashes and reproducers in the workdir even if they are reported to the dashboard.
+	// Used by syz-ci to classify regressions.
+	KeepLocalCrashes bool `json:"keep_local_crashes"`
 }
 
 type Subsystem struct {
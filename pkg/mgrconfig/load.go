@@ -13,6 +13,8 @@ import (
 
 	"github.com/google/syzkaller/pkg/config"
 	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/subsystem"
+	_ "github.com/google/syzkaller/pkg/subsystem/lists"
 	"github.com/google/syzkaller/pkg/vminfo"
 	"github.com/google/syzkaller/prog"
 	_ "github.com/google/syzkaller/sys" // most mgrconfig users want targets too
@@ -186,6 +188,9 @@ func Complete(cfg *Config) error {
 	if cfg.FuzzingVMs < 0 {
 		return fmt.Errorf("fuzzing_vms cannot be less than 0")
 	}
+	if err := cfg.checkSubsystemWeights(); err != nil {
+		return err
+	}
 
 	var err error
 	cfg.Syscalls, err = ParseEnabledSyscalls(cfg.Target, cfg.EnabledSyscalls, cfg.DisabledSyscalls,
@@ -193,6 +198,9 @@ func Complete(cfg *Config) error {
 	if err != nil {
 		return err
 	}
+	if err := cfg.checkHostSyscalls(); err != nil {
+		return err
+	}
 	cfg.NoMutateCalls, err = ParseNoMutateSyscalls(cfg.Target, cfg.NoMutateSyscalls)
 	if err != nil {
 		return err
@@ -202,6 +210,162 @@ func Complete(cfg *Config) error {
 	return nil
 }
 
+func (cfg *Config) checkSubsystemWeights() error {
+	if len(cfg.SubsystemWeights) == 0 {
+		return nil
+	}
+	if !cfg.Cover {
+		return fmt.Errorf("subsystem_weights require coverage")
+	}
+	known := make(map[string]bool)
+	for _, item := range cfg.KernelSubsystem {
+		known[item.Name] = true
+	}
+	for _, item := range subsystem.GetList(cfg.TargetOS) {
+		known[item.Name] = true
+	}
+	total := 0
+	for name, weight := range cfg.SubsystemWeights {
+		if !known[name] {
+			return fmt.Errorf("unknown subsystem %v in subsystem_weights: it's neither in kernel_subsystem"+
+				" nor in the %v list", name, cfg.TargetOS)
+		}
+		if weight <= 0 || weight > 100 {
+			return fmt.Errorf("bad subsystem_weights value for %v: %v, want [1, 100]", name, weight)
+		}
+		total += weight
+	}
+	if total > 100 {
+		return fmt.Errorf("subsystem_weights sum up to %v%%, want at most 100%%", total)
+	}
+	return nil
+}
+
+// hostSafeSyscalls is the list of syscalls that can be enabled for the namespace VM type.
+// They only affect the calling process, objects it owns, or resources that are isolated
+// by the user, mount, pid, net, ipc and uts namespaces. Anything else is rejected,
+// because it may affect the host even when executed by a process that is root in the namespace only.
+// The map is keyed by call names, variants of the calls are allowed only if they don't refer
+// to fixed files outside of the private /dev (e.g. openat$kvm is fine, but openat$sysctl is not).
+var hostSafeSyscalls = map[string]bool{
+	// Files and file descriptors (the file system is read-only except for the instance dir,
+	// and the private /dev contains only harmless device nodes, see vm/namespace).
+	"open": true, "openat": true, "openat2": true, "creat": true, "close": true, "close_range": true,
+	"read": true, "write": true, "readv": true, "writev": true, "pread64": true, "pwrite64": true,
+	"preadv": true, "pwritev": true, "preadv2": true, "pwritev2": true, "lseek": true,
+	"dup": true, "dup2": true, "dup3": true, "fcntl": true, "flock": true,
+	"pipe": true, "pipe2": true, "splice": true, "tee": true, "vmsplice": true,
+	"sendfile": true, "copy_file_range": true,
+	"stat": true, "lstat": true, "fstat": true, "newfstatat": true, "statx": true,
+	"access": true, "faccessat": true, "faccessat2": true, "getdents": true, "getdents64": true,
+	"mkdir": true, "mkdirat": true, "rmdir": true, "unlink": true, "unlinkat": true,
+	"rename": true, "renameat": true, "renameat2": true, "link": true, "linkat": true,
+	"symlink": true, "symlinkat": true, "readlink": true, "readlinkat": true,
+	"truncate": true, "ftruncate": true, "fallocate": true, "fsync": true, "fdatasync": true,
+	"chmod": true, "fchmod": true, "fchmodat": true, "chdir": true, "fchdir": true, "getcwd": true,
+	"poll": true, "ppoll": true, "select": true, "pselect6": true,
+	"epoll_create": true, "epoll_create1": true, "epoll_ctl": true, "epoll_wait": true, "epoll_pwait": true,
+	"eventfd": true, "eventfd2": true, "timerfd_create": true, "timerfd_settime": true,
+	"timerfd_gettime": true, "signalfd": true, "signalfd4": true, "memfd_create": true,
+	"inotify_init": true, "inotify_init1": true, "inotify_add_watch": true, "inotify_rm_watch": true,
+	// Memory of the process.
+	"mmap": true, "munmap": true, "mremap": true, "mprotect": true, "madvise": true,
+	"msync": true, "mincore": true, "brk": true,
+	// Sockets (the network namespace is not connected to the host).
+	"socket": true, "socketpair": true, "bind": true, "connect": true, "listen": true,
+	"accept": true, "accept4": true, "sendto": true, "recvfrom": true, "sendmsg": true,
+	"recvmsg": true, "sendmmsg": true, "recvmmsg": true, "setsockopt": true, "getsockopt": true,
+	"getsockname": true, "getpeername": true, "shutdown": true,
+	// Processes, signals and synchronization within the pid namespace.
+	"getpid": true, "gettid": true, "getppid": true, "getuid": true, "getgid": true,
+	"geteuid": true, "getegid": true, "kill": true, "tkill": true, "tgkill": true,
+	"rt_sigaction": true, "rt_sigprocmask": true, "rt_sigreturn": true, "rt_sigpending": true,
+	"sigaltstack": true, "futex": true, "set_robust_list": true, "get_robust_list": true,
+	"sched_yield": true, "nanosleep": true, "clock_gettime": true, "clock_nanosleep": true,
+	"uname": true, "sethostname": true, "setdomainname": true,
+	// System V IPC is isolated by the ipc namespace.
+	"msgget": true, "msgsnd": true, "msgrcv": true, "msgctl": true,
+	"semget": true, "semop": true, "semtimedop": true, "semctl": true,
+	"shmget": true, "shmat": true, "shmdt": true, "shmctl": true,
+}
+
+// checkHostSyscalls checks that the namespace VM type, which runs programs directly on the host,
+// is used with an explicit allowlist of syscalls that can't harm the host.
+// Variants of safe calls that refer to fixed global files are dropped if they are enabled
+// by a wildcard or by the call name, and rejected if they are enabled explicitly.
+func (cfg *Config) checkHostSyscalls() error {
+	if cfg.Type != "namespace" {
+		return nil
+	}
+	if len(cfg.EnabledSyscalls) == 0 {
+		return fmt.Errorf("vm type namespace requires an explicit enable_syscalls allowlist")
+	}
+	explicit := make(map[string]bool)
+	for _, name := range cfg.EnabledSyscalls {
+		explicit[name] = true
+	}
+	var syscalls []int
+	for _, id := range cfg.Syscalls {
+		call := cfg.Target.Syscalls[id]
+		if !hostSafeSyscalls[call.CallName] {
+			return fmt.Errorf("syscall %v can't be enabled for vm type namespace", call.CallName)
+		}
+		safe := hostSafeFiles(cfg.Target, call)
+		if !safe && explicit[call.Name] {
+			return fmt.Errorf("syscall %v can't be enabled for vm type namespace", call.Name)
+		}
+		if safe {
+			syscalls = append(syscalls, id)
+		}
+	}
+	if len(syscalls) == 0 {
+		return fmt.Errorf("all enabled syscalls refer to host files, which is not allowed for vm type namespace")
+	}
+	cfg.Syscalls = syscalls
+	return nil
+}
+
+// hostSafeFiles checks that the syscall doesn't open fixed global files (e.g. openat$sysctl).
+// The namespace hides only the host /dev, other absolute paths refer to the host files.
+// File names are the string arguments of the variant that are filenames in the base call.
+func hostSafeFiles(target *prog.Target, call *prog.Syscall) bool {
+	base := target.SyscallMap[call.CallName]
+	if base == nil || base == call {
+		return true
+	}
+	for i, arg := range call.Args {
+		if i >= len(base.Args) || !isFilenameArg(base.Args[i].Type) {
+			continue
+		}
+		safe := true
+		prog.ForeachArgType(arg.Type, func(typ prog.Type, ctx *prog.TypeCtx) {
+			buf, ok := typ.(*prog.BufferType)
+			if !ok || buf.Kind != prog.BufferString {
+				return
+			}
+			for _, val := range buf.Values {
+				file := filepath.Clean(strings.TrimRight(val, "\x00"))
+				if strings.HasPrefix(file, "/") && !strings.HasPrefix(file, "/dev/") {
+					safe = false
+				}
+			}
+		})
+		if !safe {
+			return false
+		}
+	}
+	return true
+}
+
+func isFilenameArg(typ prog.Type) bool {
+	ptr, ok := typ.(*prog.PtrType)
+	if !ok {
+		return false
+	}
+	buf, ok := ptr.Elem.(*prog.BufferType)
+	return ok && buf.Kind == prog.BufferFilename
+}
+
 func (cfg *Config) completeServices() error {
 	if cfg.HubClient != "" {
 		if err := checkNonEmpty(
@@ -4,12 +4,14 @@
 package mgrconfig_test
 
 import (
+	"fmt"
 	"path/filepath"
 	"testing"
 
 	"github.com/google/syzkaller/pkg/config"
 	. "github.com/google/syzkaller/pkg/mgrconfig"
 	"github.com/google/syzkaller/vm/gce"
+	"github.com/google/syzkaller/vm/namespace"
 	"github.com/google/syzkaller/vm/proxyapp"
 	"github.com/google/syzkaller/vm/qemu"
 )
@@ -33,6 +35,8 @@ func TestCanned(t *testing.T) {
 				vmCfg = new(gce.Config)
 			case "proxyapp":
 				vmCfg = new(proxyapp.Config)
+			case "namespace":
+				vmCfg = new(namespace.Config)
 			default:
 				t.Fatalf("unknown VM type: %v", cfg.Type)
 			}
@@ -43,6 +47,91 @@ func TestCanned(t *testing.T) {
 	}
 }
 
+func TestNamespaceSyscalls(t *testing.T) {
+	const base = `{
+		"target": "linux/amd64",
+		"http": "myhost.com:56741",
+		"workdir": "/syzkaller/workdir",
+		"syzkaller": "./testdata/syzkaller",
+		"type": "namespace",
+		"procs": 1%v
+	}`
+	tests := []struct {
+		syscalls string
+		err      string
+	}{
+		{``, "vm type namespace requires an explicit enable_syscalls allowlist"},
+		{`, "enable_syscalls": ["read", "kexec_load"]`, "syscall kexec_load can't be enabled for vm type namespace"},
+		{`, "enable_syscalls": ["read", "syslog"]`, "syscall syslog can't be enabled for vm type namespace"},
+		{`, "enable_syscalls": ["read", "mount"]`, "syscall mount can't be enabled for vm type namespace"},
+		{`, "enable_syscalls": ["read", "openat$sysctl"]`, "syscall openat$sysctl can't be enabled for vm type namespace"},
+		{`, "enable_syscalls": ["read", "openat$yama_ptrace_scope"]`,
+			"syscall openat$yama_ptrace_scope can't be enabled for vm type namespace"},
+		{`, "enable_syscalls": ["read", "write"]`, ""},
+		{`, "enable_syscalls": ["openat", "openat$tun", "write$tun"]`, ""},
+		{`, "enable_syscalls": ["openat$yama*"]`,
+			"all enabled syscalls refer to host files, which is not allowed for vm type namespace"},
+	}
+	for i, test := range tests {
+		_, err := LoadData([]byte(fmt.Sprintf(base, test.syscalls)))
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != test.err {
+			t.Errorf("#%v: want error %q, got %q", i, test.err, errStr)
+		}
+	}
+	// Variants that open host files are dropped when they are enabled by the call name.
+	cfg, err := LoadData([]byte(fmt.Sprintf(base, `, "enable_syscalls": ["openat"]`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled := make(map[string]bool)
+	for _, id := range cfg.Syscalls {
+		enabled[cfg.Target.Syscalls[id].Name] = true
+	}
+	for name, want := range map[string]bool{"openat": true, "openat$tun": true, "openat$sysctl": false} {
+		if enabled[name] != want {
+			t.Errorf("syscall %v enabled: %v, want %v", name, enabled[name], want)
+		}
+	}
+}
+
+func TestSubsystemWeights(t *testing.T) {
+	const base = `{
+		"target": "linux/amd64",
+		"http": "myhost.com:56741",
+		"workdir": "/syzkaller/workdir",
+		"syzkaller": "./testdata/syzkaller",
+		"type": "qemu",
+		"procs": 1,
+		"kernel_subsystem": [{"name": "mydrv", "path": ["drivers/mydrv/"]}]%v
+	}`
+	tests := []struct {
+		weights string
+		err     string
+	}{
+		{`, "subsystem_weights": {"ext4": 30, "mydrv": 20}`, ""},
+		{`, "subsystem_weights": {"ext4": 30}, "cover": false`, "subsystem_weights require coverage"},
+		{`, "subsystem_weights": {"ext4": 0}`, "bad subsystem_weights value for ext4: 0, want [1, 100]"},
+		{`, "subsystem_weights": {"ext4": 60, "mydrv": 50}`, "subsystem_weights sum up to 110%, want at most 100%"},
+		{`, "subsystem_weights": {"nosuchsubsystem": 10}`,
+			"unknown subsystem nosuchsubsystem in subsystem_weights: it's neither in kernel_subsystem" +
+				" nor in the linux list"},
+	}
+	for i, test := range tests {
+		_, err := LoadData([]byte(fmt.Sprintf(base, test.weights)))
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != test.err {
+			t.Errorf("#%v: want error %q, got %q", i, test.err, errStr)
+		}
+	}
+}
+
 func TestMatchSyscall(t *testing.T) {
 	tests := []struct {
 		pattern string
@@ -0,0 +1,266 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package record implements recording of time-stamped VM traces: console output,
+// messages exchanged with the executor, executed programs, etc.
+// The traces can later be replayed without the kernel (see vm.Replay and tools/syz-replay)
+// to understand why a particular VM run was classified as it was.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type Kind string
+
+const (
+	// Parameters of the VM run (recorded by the vm package in its own format).
+	KindRun Kind = "run"
+	// Console output chunk as received from the VM.
+	KindConsole Kind = "console"
+	// Notification that the VM has executed a program (vm.InjectExecuting).
+	KindExecuting Kind = "executing"
+	// Result of the command running in the VM, Data contains the error message (empty if none).
+	KindExit Kind = "exit"
+	// Output of the VM diagnosis.
+	KindDiagnose Kind = "diagnose"
+	// Title of the crash detected by the monitor (empty if none).
+	KindReport Kind = "report"
+	// Serialized flatrpc messages sent to/received from the executor.
+	KindRPCSend Kind = "rpc-send"
+	KindRPCRecv Kind = "rpc-recv"
+	// Exec request sent to the executor, Data is ExecEvent in JSON.
+	KindExec Kind = "exec"
+	// Events before this one were dropped to bound the recording size (except for KindRun).
+	KindTruncated Kind = "truncated"
+)
+
+// Similar to the console output kept by the vm package, only the tail of the recording is kept:
+// the recording is written in segments of maxSegmentSize and only the last 2 segments are kept.
+const maxSegmentSize = 64 << 20
+
+type Event struct {
+	// Time since the start of the recording.
+	Time time.Duration
+	Kind Kind
+	Data []byte `json:",omitempty"`
+}
+
+type ExecEvent struct {
+	ID   int64
+	Prog string `json:",omitempty"`
+}
+
+// Recorder writes events to a file as JSON lines.
+// All methods can be called concurrently and on a nil Recorder (they do nothing then),
+// so that callers don't need to check if recording is enabled.
+type Recorder struct {
+	file    string
+	start   time.Time
+	maxSize int
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	closed  bool
+	err     error
+	// Size of the current segment.
+	size int
+	// Number of finished segments, the last of them is stored in file.old.
+	segments int
+	// Start times of the current and the previous segments.
+	segStart  time.Duration
+	prevStart time.Duration
+	// Events that are kept even if their segment is dropped.
+	pinned [][]byte
+}
+
+func New(file string) (*Recorder, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		file:    file,
+		start:   time.Now(),
+		maxSize: maxSegmentSize,
+		f:       f,
+		w:       bufio.NewWriter(f),
+	}, nil
+}
+
+// File returns the file the recorder writes to.
+func (rec *Recorder) File() string {
+	if rec == nil {
+		return ""
+	}
+	return rec.file
+}
+
+func (rec *Recorder) Record(kind Kind, data []byte) {
+	if rec == nil {
+		return
+	}
+	ev := &Event{
+		Time: time.Since(rec.start),
+		Kind: kind,
+		Data: data,
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		panic(err)
+	}
+	line = append(line, '\n')
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.err != nil || rec.closed {
+		return
+	}
+	if rec.size != 0 && rec.size+len(line) > rec.maxSize {
+		if rec.err = rec.rotate(ev.Time); rec.err != nil {
+			return
+		}
+	}
+	if kind == KindRun {
+		rec.pinned = append(rec.pinned, line)
+	}
+	rec.size += len(line)
+	_, rec.err = rec.w.Write(line)
+}
+
+// rotate moves the current segment to file.old (dropping the previous one) and starts a new segment.
+func (rec *Recorder) rotate(now time.Duration) error {
+	if err := rec.closeFile(); err != nil {
+		return err
+	}
+	if err := os.Rename(rec.file, rec.file+".old"); err != nil {
+		return err
+	}
+	f, err := os.Create(rec.file)
+	if err != nil {
+		return err
+	}
+	rec.f, rec.w = f, bufio.NewWriter(f)
+	rec.segments++
+	rec.size = 0
+	rec.prevStart, rec.segStart = rec.segStart, now
+	return nil
+}
+
+func (rec *Recorder) closeFile() error {
+	err := rec.w.Flush()
+	if err1 := rec.f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// merge joins the kept segments into file.
+func (rec *Recorder) merge() error {
+	old := rec.file + ".old"
+	tmp := rec.file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	w := bufio.NewWriter(f)
+	if rec.segments > 1 {
+		// The first segment with the pinned events was dropped.
+		for _, line := range rec.pinned {
+			w.Write(line)
+		}
+		line, err := json.Marshal(&Event{Time: rec.prevStart, Kind: KindTruncated})
+		if err != nil {
+			panic(err)
+		}
+		w.Write(append(line, '\n'))
+	}
+	for _, file := range []string{old, rec.file} {
+		if err := appendFile(w, file); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, rec.file); err != nil {
+		return err
+	}
+	return os.Remove(old)
+}
+
+func (rec *Recorder) RecordJSON(kind Kind, v any) {
+	if rec == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	rec.Record(kind, data)
+}
+
+// Close flushes and closes the file, and returns the first error encountered during recording.
+// Events recorded after Close are silently dropped.
+func (rec *Recorder) Close() error {
+	if rec == nil {
+		return nil
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.closed {
+		return rec.err
+	}
+	rec.closed = true
+	if err := rec.closeFile(); rec.err == nil {
+		rec.err = err
+	}
+	if rec.err == nil && rec.segments != 0 {
+		rec.err = rec.merge()
+	}
+	return rec.err
+}
+
+func appendFile(w io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Load reads all events from the file.
+// A truncated last event (e.g. if the manager was killed) is ignored.
+func Load(file string) ([]*Event, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var events []*Event
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		ev := new(Event)
+		if err := dec.Decode(ev); err != nil {
+			if len(events) != 0 {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse %v: %w", file, err)
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package record
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	for _, events := range []int{5, 100} {
+		t.Run(fmt.Sprint(events), func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "record")
+			rec, err := New(file)
+			require.NoError(t, err)
+			rec.maxSize = 2000
+			rec.RecordJSON(KindRun, "params")
+			for i := 0; i < events; i++ {
+				rec.Record(KindConsole, []byte(fmt.Sprintf("%04d%v", i, strings.Repeat("x", 96))))
+			}
+			require.NoError(t, rec.Close())
+			rec.Record(KindConsole, []byte("dropped"))
+			require.NoError(t, rec.Close())
+			assert.NoFileExists(t, file+".old")
+
+			loaded, err := Load(file)
+			require.NoError(t, err)
+			// Run parameters are always kept.
+			assert.Equal(t, KindRun, loaded[0].Kind)
+			loaded = loaded[1:]
+			first := 0
+			if loaded[0].Kind == KindTruncated {
+				loaded = loaded[1:]
+				fmt.Sscanf(string(loaded[0].Data), "%04d", &first)
+			}
+			// The tail of the recording is kept.
+			for i, ev := range loaded {
+				assert.Equal(t, KindConsole, ev.Kind)
+				assert.Equal(t, fmt.Sprintf("%04d", first+i), string(ev.Data[:4]))
+			}
+			assert.Equal(t, events, first+len(loaded))
+			if events > 20 {
+				assert.NotZero(t, first)
+				assert.GreaterOrEqual(t, len(loaded), 10)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package crash
+
+import (
+	"sort"
+	"strings"
+)
+
+// KCSAN data-race reports are titled after top frames of both racing accesses
+// ("KCSAN: data-race in A / B"), but the order depends on which of the accesses
+// set up the watchpoint. So a race is identified by the unordered pair of functions.
+
+const raceTitlePrefix = "KCSAN: data-race in "
+
+// RacePair returns the racing functions of a data-race title in the canonical (sorted) order.
+// Races at unknown origin have a single function. Returns nil if the title is not a data-race title.
+func RacePair(title string) []string {
+	rest, ok := strings.CutPrefix(title, raceTitlePrefix)
+	if !ok {
+		return nil
+	}
+	pair := strings.Split(rest, " / ")
+	if len(pair) > 2 {
+		return nil
+	}
+	for _, fn := range pair {
+		if fn == "" || strings.Contains(fn, " ") {
+			return nil
+		}
+	}
+	sort.Strings(pair)
+	return pair
+}
+
+// RaceTitles returns the canonical data-race title (with the racing functions in the sorted order)
+// and the title with the reverse order. For titles that don't have two different racing functions,
+// the title is returned as is and the reverse title is empty.
+func RaceTitles(title string) (string, string) {
+	pair := RacePair(title)
+	if len(pair) != 2 || pair[0] == pair[1] {
+		return title, ""
+	}
+	return raceTitlePrefix + pair[0] + " / " + pair[1], raceTitlePrefix + pair[1] + " / " + pair[0]
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package crash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRaceTitles(t *testing.T) {
+	tests := []struct {
+		title   string
+		pair    []string
+		reverse string
+		result  string
+	}{
+		{
+			title:   "KCSAN: data-race in foo / bar",
+			pair:    []string{"bar", "foo"},
+			result:  "KCSAN: data-race in bar / foo",
+			reverse: "KCSAN: data-race in foo / bar",
+		},
+		{
+			title:   "KCSAN: data-race in bar / foo",
+			pair:    []string{"bar", "foo"},
+			result:  "KCSAN: data-race in bar / foo",
+			reverse: "KCSAN: data-race in foo / bar",
+		},
+		{
+			title:  "KCSAN: data-race in foo / foo",
+			pair:   []string{"foo", "foo"},
+			result: "KCSAN: data-race in foo / foo",
+		},
+		{
+			title:  "KCSAN: data-race in foo",
+			pair:   []string{"foo"},
+			result: "KCSAN: data-race in foo",
+		},
+		{
+			title:  "KASAN: use-after-free Read in foo",
+			result: "KASAN: use-after-free Read in foo",
+		},
+		{
+			title:  "KCSAN: data-race in foo / bar / baz",
+			result: "KCSAN: data-race in foo / bar / baz",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.pair, RacePair(test.title))
+			result, reverse := RaceTitles(test.title)
+			assert.Equal(t, test.result, result)
+			assert.Equal(t, test.reverse, reverse)
+		})
+	}
+}
@@ -19,6 +19,7 @@ const (
 	AtomicSleep      = Type("ATOMIC_SLEEP")
 	KMSAN            = Type("KMSAN")
 	SyzFailure       = Type("SYZ_FAILURE")
+	RustPanic        = Type("RUST_PANIC")
 )
 
 func (t Type) String() string {
@@ -110,6 +110,7 @@ func ctorLinux(cfg *config) (reporterImpl, []string, error) {
 		regexp.MustCompile(`^fs/proc/generic.c`),
 		regexp.MustCompile(`^trusty/`),                // Trusty sources are not in linux kernel tree.
 		regexp.MustCompile(`^drivers/usb/core/urb.c`), // WARNING in urb.c usually means a bug in a driver
+		regexp.MustCompile(`^rust/.*`),                // Rust kernel abstractions and core library
 	}
 	ctx.guiltyLineIgnore = regexp.MustCompile(`(hardirqs|softirqs)\s+last\s+(enabled|disabled)|^Register r\d+ information`)
 	// These pattern do _not_ start a new report, i.e. can be in a middle of another report.
@@ -123,6 +124,8 @@ func ctorLinux(cfg *config) (reporterImpl, []string, error) {
 		// But of course it can come from another CPU as well.
 		compile(`PANIC: double fault`),
 		compile(`Internal error:`),
+		// Rust panic handler invokes BUG after printing the panic message.
+		compile(`kernel BUG at rust/helpers`),
 	}
 	// These pattern math kernel reports which are not bugs in itself but contain stack traces.
 	// If we see them in the middle of another report, we know that the report is potentially corrupted.
@@ -193,6 +196,26 @@ func (ctx *linux) Parse(output []byte) *Report {
 		if !rep.Corrupted {
 			rep.Corrupted, rep.CorruptedReason = ctx.isCorrupted(title, report, format)
 		}
+		if !rep.Corrupted && strings.HasPrefix(rep.Title, linuxTaskHungTitle) {
+			if culprit := findHangCulprit(report); culprit != nil && rep.Title != linuxTaskHungTitle+culprit.frame {
+				// Keep the waiter-based title among alt titles, so that the report
+				// is still matched to the existing bugs that were reported under it.
+				rep.AltTitles = append(rep.AltTitles, rep.Title, "hang in "+culprit.frame)
+				rep.Title = linuxTaskHungTitle + culprit.frame
+			}
+		}
+		if rep.CorruptedReason == corruptedNoFrames && strings.HasPrefix(rep.Title, linuxRCUStallTitle) {
+			// The stalled CPU backtrace is unusable, but we may still know the task
+			// that blocks the grace period.
+			if culprit := findRCUStallCulprit(report); culprit != nil {
+				rep.Title = linuxRCUStallTitle + culprit.frame
+				rep.AltTitles = []string{"stall in " + culprit.frame}
+				rep.Corrupted, rep.CorruptedReason = ctx.isCorrupted(rep.Title, report, format)
+			}
+		}
+		if rep.Type == crash.DataRace {
+			canonicalizeRace(rep, report)
+		}
 		if rep.CorruptedReason == corruptedNoFrames && context != contextConsole && !questionable {
 			// We used to look at questionable frame with the following incentive:
 			// """
@@ -730,7 +753,12 @@ func (ctx *linux) extractGuiltyFile(rep *Report) string {
 }
 
 func (ctx *linux) extractGuiltyFileRaw(title string, report []byte) string {
-	if strings.HasPrefix(title, "INFO: rcu detected stall") {
+	if strings.HasPrefix(title, linuxRCUStallTitle) {
+		if culprit := findRCUStallCulprit(report); culprit != nil && title == linuxRCUStallTitle+culprit.frame {
+			if file := ctx.extractCulpritFile(report, culprit); file != "" {
+				return file
+			}
+		}
 		// Special case for rcu stalls.
 		// There are too many frames that we want to skip before actual guilty frames,
 		// we would need to ignore too many files and that would be fragile.
@@ -745,9 +773,63 @@ func (ctx *linux) extractGuiltyFileRaw(title string, report []byte) string {
 			}
 		}
 	}
+	if strings.HasPrefix(title, linuxTaskHungTitle) {
+		// If the hung task is blocked by another task, blame the other task.
+		if culprit := findHangCulprit(report); culprit != nil {
+			if file := ctx.extractCulpritFile(report, culprit); file != "" {
+				return file
+			}
+		}
+	}
 	return ctx.extractGuiltyFileImpl(report)
 }
 
+// extractCulpritFile extracts the guilty file starting from the culprit frame in the culprit task stack.
+func (ctx *linux) extractCulpritFile(report []byte, culprit *hangCulprit) string {
+	for pos := culprit.stackPos; pos < len(report); {
+		end := bytes.IndexByte(report[pos:], '\n')
+		if end == -1 {
+			break
+		}
+		line := report[pos : pos+end]
+		if match := linuxHangFrameRe.FindSubmatch(line); match != nil && string(match[1]) == culprit.frame {
+			return ctx.extractGuiltyFileImpl(report[pos:])
+		}
+		pos += end + 1
+	}
+	return ""
+}
+
+const (
+	linuxTaskHungTitle = "INFO: task hung in "
+	linuxRCUStallTitle = "INFO: rcu detected stall in "
+)
+
+// linuxSymbolizedFrameRe matches symbolized frames like:
+// "  sock_poll+0x12/0x34 net/socket.c:1234" or "RIP: 0010:__dump_stack lib/dump_stack.c:88 [inline]".
+var linuxSymbolizedFrameRe = regexp.MustCompile(`^\s*(?:RIP: [0-9a-f]+:)?([a-zA-Z0-9_]+)[a-zA-Z0-9_.]*` +
+	`(?:\+0x[0-9a-f]+/0x[0-9a-f]+)?\s+([a-zA-Z0-9_\-\./]*[a-zA-Z0-9_\-]+\.(?:c|h|rs)):[0-9]+`)
+
+func (ctx *linux) extractFrames(report []byte) []StackFrame {
+	var frames []StackFrame
+	for _, line := range lines(report) {
+		match := linuxSymbolizedFrameRe.FindSubmatch(line)
+		if match == nil {
+			continue
+		}
+		frame := StackFrame{
+			Function: string(match[1]),
+			File:     filepath.Clean(string(match[2])),
+		}
+		frame.Ignored = matchesAny([]byte(frame.File), ctx.guiltyFileIgnores)
+		if len(frames) != 0 && frames[len(frames)-1] == frame {
+			continue
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
 func (ctx *linux) extractGuiltyFileImpl(report []byte) string {
 	// Extract the first possible guilty file.
 	guilty := ""
@@ -1094,6 +1176,9 @@ var linuxStackParams = &stackParams{
 		// Extract both current and next frames. This is needed for the top
 		// frame which is present only in LR register which we don't parse.
 		compile(`^ *{{PC}} \(([a-zA-Z0-9_.]+)\) from {{PC}} \({{FUNC}}`),
+		// Demangled Rust inline frames added during symbolization.
+		compile(`^ *(?:{{PC}} ){0,2}((?:<.+>|[a-zA-Z0-9_]+)(?:::[a-zA-Z0-9_<>]+)*::[a-zA-Z0-9_]+)` +
+			` [^ ]+\.rs:[0-9]+ \[inline\]`),
 	},
 	skipPatterns: []string{
 		"__sanitizer",
@@ -1323,6 +1408,10 @@ var linuxStackParams = &stackParams{
 		"__fortify_report",
 		"cleanup_srcu_struct",
 		"rhashtable_lookup",
+		// Rust panic machinery and library code.
+		"^rust_begin_unwind$",
+		"^rust_helper_",
+		"^<?(?:core|alloc)::",
 	},
 	corruptedLines: []*regexp.Regexp{
 		// Fault injection stacks are frequently intermixed with crash reports.
@@ -1347,6 +1436,14 @@ var linuxStackParams = &stackParams{
 	},
 }
 
+var linuxRustPanicStackFmt = &stackFmt{
+	parts: []*regexp.Regexp{
+		linuxRipFrame,
+		linuxCallTrace,
+		parseStackTrace,
+	},
+}
+
 func warningStackFmt(skip ...string) *stackFmt {
 	return &stackFmt{
 		// In newer kernels WARNING traps and actual stack starts after invalid_op frame,
@@ -2220,6 +2317,44 @@ var linuxOopses = append([]*oops{
 		[]*regexp.Regexp{},
 		crash.UnknownType,
 	},
+	{
+		// Rust panics print "panicked at file:line:col:" followed by the message on the next line
+		// (older Rust versions print "panicked at 'message', file:line:col"), and then invoke BUG.
+		[]byte("panicked at "),
+		[]oopsFormat{
+			{
+				title: compile("panicked at [^ ]+\\.rs:[0-9]+:[0-9]+:\n(attempt to [a-z ]+)"),
+				fmt:   "Rust panic: %[1]v in %[2]v",
+				stack: linuxRustPanicStackFmt,
+			},
+			{
+				title: compile("panicked at [^ ]+\\.rs:[0-9]+:[0-9]+:\n(index out of bounds|range (?:start|end) index)"),
+				fmt:   "Rust panic: %[1]v in %[2]v",
+				stack: linuxRustPanicStackFmt,
+			},
+			{
+				title: compile("panicked at [^ ]+\\.rs:[0-9]+:[0-9]+:\n" +
+					"called `(?:Option|Result)::unwrap\\(\\)` on an? `(None|Err)` value"),
+				fmt:   "Rust panic: unwrap on %[1]v in %[2]v",
+				stack: linuxRustPanicStackFmt,
+			},
+			{
+				title: compile("panicked at [^ ]+\\.rs:[0-9]+:[0-9]+:"),
+				fmt:   "Rust panic in %[1]v",
+				stack: linuxRustPanicStackFmt,
+			},
+			{
+				title: compile("panicked at '.*', [^ ]+\\.rs:[0-9]+:[0-9]+"),
+				fmt:   "Rust panic in %[1]v",
+				stack: linuxRustPanicStackFmt,
+			},
+		},
+		[]*regexp.Regexp{
+			// User-space Rust programs.
+			compile("thread '.*' panicked at"),
+		},
+		crash.RustPanic,
+	},
 	{
 		[]byte("kernel BUG"),
 		[]oopsFormat{
@@ -0,0 +1,289 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package report
+
+import (
+	"bytes"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Task hung reports point to the task that waits for too long, but frequently the root cause
+// is in another task that holds the lock the hung task is waiting for.
+// Along with the hung task the kernel dumps all locks held in the system (with lockdep),
+// backtraces of all CPUs, and sometimes states of other tasks. From this info we build
+// a wait-for graph and try to find the task that blocks the hung task.
+//
+// Similarly, RCU stall reports list tasks that block the current grace period
+// (tasks preempted inside RCU read-side critical sections) and dump their stacks.
+// If the stalled CPU backtrace gives us nothing, the blocked task is the best culprit.
+
+type hangTask struct {
+	pid      int
+	locks    []string // names of the held locks, the last one may be the lock the task is waiting for
+	numLocks int      // number of held locks as reported by lockdep, can be larger than len(locks)
+	hung     bool     // the task is reported by the hung task detector
+	running  bool     // the task is running on a CPU (we have its NMI backtrace)
+	frames   []string
+	stackPos int // position of the task stack trace in the report
+}
+
+type hangCulprit struct {
+	frame    string
+	stackPos int
+}
+
+var (
+	linuxHungTaskRe      = regexp.MustCompile(`^INFO: task .*:([0-9]+) blocked for more than`)
+	linuxTaskStateRe     = regexp.MustCompile(`^task:.* state:([A-Z]) .* pid: *([0-9]+)`)
+	linuxNMICPURe        = regexp.MustCompile(`^NMI backtrace for cpu [0-9]+`)
+	linuxCPUTaskRe       = regexp.MustCompile(`^CPU: [0-9]+ (?:UID: [0-9]+ )?PID: ([0-9]+) Comm:`)
+	linuxLocksHeldRe     = regexp.MustCompile(`^([0-9]+) locks? held by .*/([0-9]+):`)
+	linuxHeldLockRe      = regexp.MustCompile(`^ *#[0-9]+: [0-9a-f]+ \((.*)\)\{`)
+	linuxHangFrameRe     = compile(`^ *(?:{{PC}} ){0,2}{{FUNC}}`)
+	linuxRCUBlockedRe    = regexp.MustCompile(`Tasks blocked on level-[0-9]+ rcu_node \(CPUs [0-9]+-[0-9]+\):(.*)`)
+	linuxRCUBlockedPidRe = regexp.MustCompile(` P([0-9]+)`)
+	// Running tasks are looping somewhere, these are not interesting in their stacks.
+	linuxHangRunningSkipRe = regexp.MustCompile(strings.Join(append([]string{"apic_timer_interrupt", "rcu"},
+		linuxStackParams.skipPatterns...), "|"))
+	linuxHangBlockedSkipRe = regexp.MustCompile(strings.Join(linuxStackParams.skipPatterns, "|"))
+	linuxSchedulerFrameRe  = regexp.MustCompile(`schedule|preempt|context_switch|__switch_to`)
+	linuxLockWaitFrameRe   = regexp.MustCompile(`mutex_lock|down_read|down_write|rwsem|__down|ldsem_down|lock_sock`)
+	// In addition to linuxStallAnchorFrames, the frame preceding these is a good representative
+	// of a running task (e.g. the file system-specific fill_super function).
+	linuxHangRunningAnchorRe = regexp.MustCompile(`^(?:mount_bdev|get_tree_bdev|mount_nodev|get_tree_nodev)$`)
+)
+
+// findHangCulprit returns the key frame of the task that blocks the hung task, or nil if it's unknown.
+func findHangCulprit(report []byte) *hangCulprit {
+	tasks := parseHangTasks(report)
+	var hung *hangTask
+	for _, task := range tasks {
+		if task.hung {
+			hung = task
+			break
+		}
+	}
+	if hung == nil {
+		return nil
+	}
+	visited := map[*hangTask]bool{hung: true}
+	culprit := hung
+	for !culprit.running {
+		holder := findLockHolder(tasks, culprit)
+		if holder == nil || visited[holder] {
+			// Either we don't know who holds the lock, or it's a deadlock
+			// (then the last task we found is as good as any other in the cycle).
+			break
+		}
+		visited[holder] = true
+		culprit = holder
+	}
+	if culprit == hung || len(culprit.frames) == 0 {
+		return nil
+	}
+	var frame string
+	if culprit.running {
+		frame = linuxHangRunningFrameExtractor(skipHangFrames(culprit.frames, linuxHangRunningSkipRe))
+	} else {
+		frame = linuxHangTaskFrameExtractor(skipHangFrames(culprit.frames, linuxHangBlockedSkipRe))
+	}
+	if frame == "" {
+		return nil
+	}
+	return &hangCulprit{
+		frame:    frame,
+		stackPos: culprit.stackPos,
+	}
+}
+
+// findRCUStallCulprit returns the key frame of the first task that blocks the RCU grace period,
+// or nil if it's unknown.
+func findRCUStallCulprit(report []byte) *hangCulprit {
+	match := linuxRCUBlockedRe.FindSubmatch(report)
+	if match == nil {
+		return nil
+	}
+	tasks := parseHangTasks(report)
+	for _, pidMatch := range linuxRCUBlockedPidRe.FindAllSubmatch(match[1], -1) {
+		pid, _ := strconv.Atoi(string(pidMatch[1]))
+		idx := slices.IndexFunc(tasks, func(task *hangTask) bool { return task.pid == pid })
+		if idx == -1 {
+			continue
+		}
+		task := tasks[idx]
+		// The task was preempted, so its stack starts in the scheduler.
+		frames := task.frames
+		for len(frames) != 0 && linuxSchedulerFrameRe.MatchString(frames[0]) {
+			frames = frames[1:]
+		}
+		frames = skipHangFrames(frames, linuxHangRunningSkipRe)
+		if len(frames) == 0 {
+			continue
+		}
+		if frame := linuxHangRunningFrameExtractor(frames); frame != "" {
+			return &hangCulprit{
+				frame:    frame,
+				stackPos: task.stackPos,
+			}
+		}
+	}
+	return nil
+}
+
+// findLockHolder returns the task that holds the lock the waiter is waiting for.
+// Lockdep accounts the lock as held before the task actually acquires it, so waiters
+// have the contended lock as the last held lock, while the holder either has taken
+// more locks after it, or is running, or is blocked on something else than a lock.
+// If lockdep does not print locks of a running task,
+// we assume that the only running task with non-printed locks is the holder.
+func findLockHolder(tasks []*hangTask, waiter *hangTask) *hangTask {
+	if len(waiter.locks) == 0 || !waitsForLock(waiter) {
+		return nil
+	}
+	lock := waiter.locks[len(waiter.locks)-1]
+	var holders, hidden []*hangTask
+	for _, task := range tasks {
+		if task == waiter {
+			continue
+		}
+		if idx := slices.Index(task.locks, lock); idx != -1 {
+			if idx != len(task.locks)-1 || task.running || len(task.frames) != 0 && !waitsForLock(task) {
+				holders = append(holders, task)
+			}
+		} else if task.running && task.numLocks > len(task.locks) {
+			hidden = append(hidden, task)
+		}
+	}
+	if len(holders) == 1 {
+		return holders[0]
+	}
+	if len(holders) == 0 && len(hidden) == 1 {
+		return hidden[0]
+	}
+	return nil
+}
+
+// waitsForLock checks if the task is blocked in a lock acquisition function
+// (rather than e.g. waits for a completion or for an RCU grace period).
+func waitsForLock(task *hangTask) bool {
+	for _, frame := range task.frames {
+		if !linuxSchedulerFrameRe.MatchString(frame) {
+			return linuxLockWaitFrameRe.MatchString(frame)
+		}
+	}
+	return false
+}
+
+func linuxHangRunningFrameExtractor(frames []string) string {
+	for i, frame := range frames {
+		if i != 0 && linuxHangRunningAnchorRe.MatchString(frame) {
+			return frames[i-1]
+		}
+	}
+	return linuxStallFrameExtractor(frames)
+}
+
+func parseHangTasks(report []byte) []*hangTask {
+	var tasks []*hangTask
+	taskByPid := make(map[int]*hangTask)
+	getTask := func(pid []byte) *hangTask {
+		id, _ := strconv.Atoi(string(pid))
+		task := taskByPid[id]
+		if task == nil {
+			task = &hangTask{pid: id}
+			taskByPid[id] = task
+			tasks = append(tasks, task)
+		}
+		return task
+	}
+	var stackTask, lockTask *hangTask
+	inStack, inNMI, nmiBacktrace := false, false, false
+	for pos := 0; pos < len(report); {
+		end := bytes.IndexByte(report[pos:], '\n')
+		if end == -1 {
+			end = len(report)
+		} else {
+			end += pos
+		}
+		line := report[pos:end]
+		linePos := pos
+		pos = end + 1
+		if lockTask != nil {
+			if match := linuxHeldLockRe.FindSubmatch(line); match != nil {
+				lockTask.locks = append(lockTask.locks, string(match[1]))
+				continue
+			}
+			lockTask = nil
+		}
+		if match := linuxHungTaskRe.FindSubmatch(line); match != nil {
+			stackTask, inStack = getTask(match[1]), false
+			stackTask.hung = true
+			continue
+		}
+		if match := linuxTaskStateRe.FindSubmatch(line); match != nil {
+			stackTask, inStack = getTask(match[2]), false
+			stackTask.running = stackTask.running || string(match[1]) == "R"
+			continue
+		}
+		if linuxNMICPURe.Match(line) {
+			stackTask, inStack, nmiBacktrace = nil, false, true
+			continue
+		}
+		if match := linuxCPUTaskRe.FindSubmatch(line); match != nil {
+			if nmiBacktrace {
+				stackTask, inStack = getTask(match[1]), false
+				stackTask.running = true
+			}
+			nmiBacktrace = false
+			continue
+		}
+		if match := linuxLocksHeldRe.FindSubmatch(line); match != nil {
+			stackTask, inStack = nil, false
+			lockTask = getTask(match[2])
+			lockTask.numLocks, _ = strconv.Atoi(string(match[1]))
+			continue
+		}
+		if linuxCallTrace.Match(line) {
+			if stackTask != nil && len(stackTask.frames) == 0 {
+				inStack, inNMI = true, false
+				stackTask.stackPos = linePos
+			}
+			continue
+		}
+		if !inStack {
+			continue
+		}
+		trimmed := bytes.TrimSpace(line)
+		if bytes.Equal(trimmed, []byte("<NMI>")) || bytes.Equal(trimmed, []byte("</NMI>")) {
+			inNMI = trimmed[1] != '/'
+			continue
+		}
+		if len(trimmed) != 0 && (trimmed[0] == '<' || trimmed[0] == '?') || bytes.Contains(line, []byte("[inline]")) {
+			continue
+		}
+		if match := linuxHangFrameRe.FindSubmatch(line); match != nil {
+			if !inNMI {
+				stackTask.frames = append(stackTask.frames, string(match[1]))
+			}
+			continue
+		}
+		if len(stackTask.frames) != 0 {
+			stackTask, inStack = nil, false
+		}
+	}
+	return tasks
+}
+
+func skipHangFrames(frames []string, skip *regexp.Regexp) []string {
+	var res []string
+	for _, frame := range frames {
+		if !skip.MatchString(frame) {
+			res = append(res, frame)
+		}
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package report
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/report/crash"
+)
+
+// KCSAN data-race reports contain stack traces of both racing accesses and are titled
+// after top frames of both accesses. We order the pair canonically (see crash.RaceTitles)
+// so that such reports are deduplicated into the same bug, and keep the reverse order
+// as an alternative title to match bugs that were reported with the other order before.
+
+var (
+	// E.g. "write to 0xffff8880b588efb2 of 2 bytes by task 19788 on cpu 0:",
+	// "read-write (marked) to 0xffffffff85a7f140 of 8 bytes by interrupt on cpu 4:",
+	// "race at unknown origin, with read to 0xffff933db8a2ae6c of 1 bytes by interrupt on cpu 0:".
+	linuxRaceAccessRe = regexp.MustCompile(`^(?:race at unknown origin, with )?(?:read|write|read-write)` +
+		`(?: \([a-z ]+\))? to 0x[0-9a-f]+ of [0-9]+ bytes by (?:task [0-9]+|interrupt) on cpu [0-9]+:`)
+	linuxRaceFrameRe = compile(`^ *(?:{{PC}} ){0,2}{{FUNC}}`)
+	linuxRaceSkipRe  = regexp.MustCompile(strings.Join(linuxStackParams.skipPatterns, "|"))
+)
+
+// parseRaceStacks returns stacks of the racing accesses in the order they are present in the report.
+// Races at unknown origin have only one stack.
+func parseRaceStacks(report []byte) [][]string {
+	var stacks [][]string
+	inStack := false
+	for _, line := range bytes.Split(report, []byte{'\n'}) {
+		if linuxRaceAccessRe.Match(bytes.TrimSpace(line)) {
+			stacks = append(stacks, nil)
+			inStack = true
+			continue
+		}
+		if !inStack {
+			continue
+		}
+		if bytes.Contains(line, []byte("[inline]")) {
+			continue
+		}
+		match := linuxRaceFrameRe.FindSubmatch(line)
+		if match == nil {
+			inStack = false
+			continue
+		}
+		stacks[len(stacks)-1] = append(stacks[len(stacks)-1], string(match[1]))
+	}
+	return stacks
+}
+
+// canonicalizeRace orders the racing accesses in the data-race report title and stacks.
+func canonicalizeRace(rep *Report, report []byte) {
+	rep.RaceStacks = parseRaceStacks(report)
+	title, reverse := crash.RaceTitles(rep.Title)
+	if reverse == "" {
+		return
+	}
+	rep.Title = title
+	rep.AltTitles = append(rep.AltTitles, reverse)
+	first := crash.RacePair(title)[0]
+	// The kernel does not necessarily print the stacks in the title order.
+	if stacks := rep.RaceStacks; len(stacks) == 2 && raceTopFrame(stacks[1]) == first &&
+		raceTopFrame(stacks[0]) != first {
+		stacks[0], stacks[1] = stacks[1], stacks[0]
+	}
+}
+
+func raceTopFrame(frames []string) string {
+	for _, frame := range frames {
+		if !linuxRaceSkipRe.MatchString(frame) {
+			return frame
+		}
+	}
+	return ""
+}
@@ -505,3 +505,114 @@ func testDisassembly(t *testing.T, reporter *Reporter, linuxReporter *linux, tes
 		t.Fatalf("expected:\n%s\ngot:\n%s", output, result)
 	}
 }
+
+func TestLinuxRaceStacks(t *testing.T) {
+	reporter, err := NewReporter(&mgrconfig.Config{
+		Derived: mgrconfig.Derived{
+			TargetOS:   targets.Linux,
+			TargetArch: targets.AMD64,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file   string
+		stacks [][]string // first frames of each stack
+	}{
+		{"427", [][]string{{"find_next_bit"}, {"rcu_report_exp_cpu_mult"}}},
+		{"428", [][]string{{"e1000_clean_rx_irq"}}},
+		{"728", [][]string{{"__ext4_update_other_inode_time"}, {"ext4_do_update_inode"}}},
+		{"729", [][]string{{"tick_nohz_idle_stop_tick"}}},
+		{"730", [][]string{{"__filemap_add_folio"}, {"shmem_file_splice_read"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "linux", "report", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rep := reporter.Parse(data)
+			if rep == nil {
+				t.Fatal("failed to parse the report")
+			}
+			var got [][]string
+			for _, stack := range rep.RaceStacks {
+				if len(stack) == 0 {
+					t.Fatalf("empty stack in %v", rep.RaceStacks)
+				}
+				got = append(got, stack[:1])
+			}
+			if !reflect.DeepEqual(got, test.stacks) {
+				t.Fatalf("want %v, got %v", test.stacks, got)
+			}
+		})
+	}
+}
+
+func TestLinuxRaceSuppression(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "linux", "report", "728"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		suppression string
+		suppressed  bool
+	}{
+		{"data-race in __ext4_update_other_inode_time / ext4_do_update_inode", true},
+		{"data-race in ext4_do_update_inode / __ext4_update_other_inode_time", true},
+		{"data-race in ext4_do_update_inode / foo", false},
+	} {
+		reporter, err := NewReporter(&mgrconfig.Config{
+			Derived: mgrconfig.Derived{
+				TargetOS:   targets.Linux,
+				TargetArch: targets.AMD64,
+			},
+			Suppressions: []string{test.suppression},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rep := reporter.Parse(data); rep.Suppressed != test.suppressed {
+			t.Errorf("%q: suppressed=%v, want %v", test.suppression, rep.Suppressed, test.suppressed)
+		}
+	}
+}
+
+func TestLinuxReportToFrames(t *testing.T) {
+	reporter, err := NewReporter(&mgrconfig.Config{
+		Derived: mgrconfig.Derived{
+			TargetOS:   targets.Linux,
+			TargetArch: targets.AMD64,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := []byte(`WARNING: CPU: 1 PID: 3214 at kernel/workqueue.c:2911 __flush_work+0x740/0x880 kernel/workqueue.c:2911
+Call Trace:
+ __dump_stack lib/dump_stack.c:77 [inline]
+ dump_stack+0x172/0x1f0 lib/dump_stack.c:113
+ __warn.cold+0x20/0x54 kernel/panic.c:540
+ fixup_bug arch/x86/kernel/traps.c:178 [inline]
+ fixup_bug arch/x86/kernel/traps.c:173 [inline]
+RIP: 0010:__flush_work+0x740/0x880 kernel/workqueue.c:2911
+RSP: 0018:ffff88809bc3f990 EFLAGS: 00010293
+ blk_sync_queue+0x33/0x1c0 block/blk-core.c:408
+ md_free+0xcb/0x1b0 drivers/md/md.c:5223
+ kref_put include/linux/kref.h:70 [inline]
+`)
+	want := []StackFrame{
+		{"__dump_stack", "lib/dump_stack.c", true},
+		{"dump_stack", "lib/dump_stack.c", true},
+		{"__warn", "kernel/panic.c", true},
+		{"fixup_bug", "arch/x86/kernel/traps.c", true},
+		{"__flush_work", "kernel/workqueue.c", true},
+		{"blk_sync_queue", "block/blk-core.c", false},
+		{"md_free", "drivers/md/md.c", false},
+		{"kref_put", "include/linux/kref.h", true},
+	}
+	if got := reporter.ReportToFrames(report); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
@@ -6,6 +6,7 @@
 package report
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"regexp"
@@ -14,6 +15,7 @@ import (
 	"github.com/google/syzkaller/pkg/cover/backend"
 	"github.com/google/syzkaller/pkg/mgrconfig"
 	"github.com/google/syzkaller/pkg/report/crash"
+	"github.com/google/syzkaller/pkg/symbolizer"
 	"github.com/google/syzkaller/pkg/vcs"
 	"github.com/google/syzkaller/pkg/vminfo"
 	"github.com/google/syzkaller/sys/targets"
@@ -71,6 +73,9 @@ type Report struct {
 	MachineInfo []byte
 	// If the crash happened in the context of the syz-executor process, Executor will hold more info.
 	Executor *ExecutorInfo
+	// For data races, RaceStacks holds stack traces of the racing accesses in the title order.
+	// Races at unknown origin have only one stack.
+	RaceStacks [][]string
 	// reportPrefixLen is length of additional prefix lines that we added before actual crash report.
 	reportPrefixLen int
 	// symbolized is set if the report is symbolized.
@@ -209,6 +214,11 @@ func (reporter *Reporter) ParseFrom(output []byte, minReportPos int) *Report {
 		rep.AltTitles[i] = sanitizeTitle(replaceTable(dynamicTitleReplacement, title))
 	}
 	rep.Suppressed = matchesAny(rep.Output, reporter.suppressions)
+	if rep.Type == crash.DataRace && matchesAnyString(rep.Title, reporter.suppressions) {
+		// Races are printed in arbitrary order, but the title has the canonical order
+		// and allows to suppress a pair of racing functions with a single regexp.
+		rep.Suppressed = true
+	}
 	if bytes.Contains(rep.Output, gceConsoleHangup) {
 		rep.Corrupted = true
 	}
@@ -290,6 +300,27 @@ func (reporter *Reporter) ReportToGuiltyFile(title string, report []byte) string
 	return ii.extractGuiltyFileRaw(title, report)
 }
 
+// StackFrame is a frame of a symbolized stack trace.
+type StackFrame struct {
+	Function string
+	File     string
+	// Ignored is set for frames in files that are never blamed for crashes
+	// (common helpers, debugging tools, etc), see GuiltyFile.
+	Ignored bool
+}
+
+// ReportToFrames extracts stack frames from an already symbolized report in the order of appearance.
+// Consecutive duplicate frames (e.g. inlined functions reported several times) are merged.
+func (reporter *Reporter) ReportToFrames(report []byte) []StackFrame {
+	ii, ok := reporter.impl.(interface {
+		extractFrames(report []byte) []StackFrame
+	})
+	if !ok {
+		return nil
+	}
+	return ii.extractFrames(report)
+}
+
 func IsSuppressed(reporter *Reporter, output []byte) bool {
 	return matchesAny(output, reporter.suppressions) ||
 		bytes.Contains(output, gceConsoleHangup)
@@ -308,6 +339,32 @@ func ParseAll(reporter *Reporter, output []byte) (reports []*Report) {
 	}
 }
 
+// LeakBacktrace returns functions of the allocation backtrace of the first object
+// in a kmemleak report. Returns nil if the report has no backtrace.
+func LeakBacktrace(report []byte) []string {
+	var frames []string
+	inBacktrace := false
+	for s := bufio.NewScanner(bytes.NewReader(report)); s.Scan(); {
+		line := s.Bytes()
+		if !inBacktrace {
+			inBacktrace = leakBacktraceRe.Match(line)
+			continue
+		}
+		match := leakFrameRe.FindSubmatch(line)
+		if match == nil {
+			break
+		}
+		frames = append(frames, string(match[1]))
+	}
+	return frames
+}
+
+var (
+	leakBacktraceRe = regexp.MustCompile(`^\s*backtrace(?: \(crc [0-9a-f]+\))?:`)
+	leakFrameRe     = regexp.MustCompile(`^\s*(?:\[<[0-9a-f]+>\]\s*)?([a-zA-Z0-9_.]+)` +
+		`(?:\+0x[0-9a-f]+/0x[0-9a-f]+|\s+\S+:\d+(?:\s+\[inline\])?)`)
+)
+
 // GCE console connection sometimes fails with this message.
 // The message frequently happens right after a kernel panic.
 // So if we see it in output where we recognized a crash, we mark the report as corrupted
@@ -685,13 +742,17 @@ func appendStackFrame(frames []string, match [][]byte, params *stackParams, skip
 		return frames
 	}
 	for _, frame := range match[1:] {
-		if frame != nil && (skipRe == nil || !skipRe.Match(frame)) {
-			frameName := string(frame)
-			for _, prefix := range params.stripFramePrefixes {
-				frameName = strings.TrimPrefix(frameName, prefix)
-			}
-			frames = append(frames, frameName)
+		if frame == nil {
+			continue
+		}
+		frameName := symbolizer.DemangleRust(string(frame))
+		if skipRe != nil && skipRe.MatchString(frameName) {
+			continue
+		}
+		for _, prefix := range params.stripFramePrefixes {
+			frameName = strings.TrimPrefix(frameName, prefix)
 		}
+		frames = append(frames, frameName)
 	}
 	return frames
 }
@@ -790,10 +851,11 @@ func Truncate(log []byte, begin, end int) []byte {
 }
 
 var (
-	filenameRe    = regexp.MustCompile(`([a-zA-Z0-9_\-\./]*[a-zA-Z0-9_\-]+\.(c|h)):[0-9]+`)
-	reportFrameRe = regexp.MustCompile(`.* in ([a-zA-Z0-9_]+)`)
-	// Matches a slash followed by at least one directory nesting before .c/.h file.
-	deeperPathRe = regexp.MustCompile(`^/[a-zA-Z0-9_\-\./]+/[a-zA-Z0-9_\-]+\.(c|h)$`)
+	filenameRe = regexp.MustCompile(`([a-zA-Z0-9_\-\./]*[a-zA-Z0-9_\-]+\.(c|h|rs)):[0-9]+`)
+	// Frames are C function names or demangled Rust paths (e.g. "<kernel::sync::Arc<T>>::drop").
+	reportFrameRe = regexp.MustCompile(`.* in ((?:<.+>|[a-zA-Z0-9_]+)(?:::[a-zA-Z0-9_]+)*)`)
+	// Matches a slash followed by at least one directory nesting before .c/.h/.rs file.
+	deeperPathRe = regexp.MustCompile(`^/[a-zA-Z0-9_\-\./]+/[a-zA-Z0-9_\-]+\.(c|h|rs)$`)
 )
 
 // These are produced by syzkaller itself.
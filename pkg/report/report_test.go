@@ -474,3 +474,35 @@ BCDEF`), Truncate([]byte(`0123456789ABCDEF`), 0, 5))
 
 DEF`), Truncate([]byte(`0123456789ABCDEF`), 4, 3))
 }
+
+func TestLeakBacktrace(t *testing.T) {
+	report := []byte(`BUG: memory leak
+unreferenced object 0xffff88810b2a5c00 (size 512):
+  comm "syz-executor.0", pid 8383, jiffies 4294942826 (age 13.850s)
+  hex dump (first 32 bytes):
+    00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00  ................
+  backtrace:
+    [<ffffffff8152ae43>] kmemleak_alloc_recursive include/linux/kmemleak.h:43 [inline]
+    [<ffffffff8152ae43>] slab_post_alloc_hook mm/slab.h:586 [inline]
+    [<00000000a5d5fbd1>] kmem_cache_alloc_trace+0x145/0x2c0
+    [<000000006bde4f45>] kmalloc include/linux/slab.h:552 [inline]
+    [<0000000055ed1c1c>] sock_alloc_inode+0x1d/0xe0
+    [<00000000ae3c5d2e>] __do_sys_socket+0x48/0xb0
+    [<00000000a1f1e3d7>] do_syscall_64+0x76/0x1a0
+
+BUG: memory leak
+unreferenced object 0xffff88810b2a5e00 (size 512):
+  backtrace:
+    [<000000006bde4f45>] other_function+0x1d/0xe0
+`)
+	assert.Equal(t, []string{
+		"kmemleak_alloc_recursive",
+		"slab_post_alloc_hook",
+		"kmem_cache_alloc_trace",
+		"kmalloc",
+		"sock_alloc_inode",
+		"__do_sys_socket",
+		"do_syscall_64",
+	}, LeakBacktrace(report))
+	assert.Nil(t, LeakBacktrace([]byte("BUG: memory leak\nunreferenced object 0xffff88810b2a5c00 (size 512):\n")))
+}
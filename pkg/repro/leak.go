@@ -0,0 +1,122 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package repro
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/prog"
+)
+
+// Memory leaks are reported asynchronously by periodic kmemleak scans (see the Leak option
+// and the -leak flag of syz-execprog), so the program that was running
+// at the time of the report is usually not the one that leaked the object. Instead we use
+// the allocation backtrace: syscall entry frames in it tell us which programs could have done
+// the allocation, and the backtrace itself lets us tell the original leak from other leaks
+// that the candidate programs may trigger.
+type leakInfo struct {
+	title    string
+	frame    string
+	frames   []string
+	syscalls map[string]bool
+}
+
+// maxLeakCandidates limits the number of programs tested separately based on the leak backtrace.
+// Leak tests are slow, so we can't test all programs from the log.
+const maxLeakCandidates = 10
+
+func newLeakInfo(rep *report.Report) *leakInfo {
+	frames := report.LeakBacktrace(rep.Report)
+	if len(frames) == 0 {
+		return nil
+	}
+	info := &leakInfo{
+		title:    rep.Title,
+		frame:    rep.Frame,
+		frames:   frames,
+		syscalls: make(map[string]bool),
+	}
+	for _, frame := range frames {
+		if match := leakSyscallRe.FindStringSubmatch(frame); match != nil {
+			info.syscalls[match[1]] = true
+		}
+	}
+	return info
+}
+
+var leakSyscallRe = regexp.MustCompile(`^(?:__x64_sys|__ia32_sys|__arm64_sys|__riscv_sys|__s390x_sys|` +
+	`__se_sys|__do_sys|__se_compat_sys|__do_compat_sys|__sys|ksys)_([a-z0-9_]+)$`)
+
+// matches checks that rep is the same leak we are reproducing.
+func (leak *leakInfo) matches(rep *report.Report) bool {
+	if rep.Title == leak.title {
+		return true
+	}
+	frames := report.LeakBacktrace(rep.Report)
+	if len(frames) == 0 {
+		// Without the backtrace we can compare only titles, and they are different.
+		return false
+	}
+	for _, frame := range frames {
+		if frame == leak.frame {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredFrames returns the known leak frames that can be ignored while reproducing this leak.
+// The executor ignores leaks whose reports contain any of the frames,
+// so the frames that are part of the backtrace of this leak are not ignored.
+func (leak *leakInfo) ignoredFrames(known []string) []string {
+	backtrace := strings.Join(leak.frames, "\n")
+	var ret []string
+	for _, frame := range known {
+		if frame != "" && !strings.Contains(backtrace, frame) && !strings.Contains(leak.title, frame) {
+			ret = append(ret, frame)
+		}
+	}
+	return ret
+}
+
+// leakCandidates returns programs that invoke syscalls from the leak backtrace (most recent first),
+// followed by the programs from toTest.
+func (ctx *reproContext) leakCandidates(entries, toTest []*prog.LogEntry) []*prog.LogEntry {
+	var ret []*prog.LogEntry
+	added := make(map[*prog.LogEntry]bool)
+	for i := len(entries) - 1; i >= 0 && len(ret) < maxLeakCandidates; i-- {
+		ent := entries[i]
+		if ctx.leak.uses(ent.P) {
+			ret = append(ret, ent)
+			added[ent] = true
+		}
+	}
+	if len(ret) != 0 {
+		var names []string
+		for name := range ctx.leak.syscalls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		ctx.reproLogf(3, "leak: %v programs invoke %v from the allocation backtrace",
+			len(ret), strings.Join(names, ", "))
+	}
+	for _, ent := range toTest {
+		if !added[ent] {
+			ret = append(ret, ent)
+		}
+	}
+	return ret
+}
+
+func (leak *leakInfo) uses(p *prog.Prog) bool {
+	for _, c := range p.Calls {
+		if leak.syscalls[c.Meta.CallName] {
+			return true
+		}
+	}
+	return false
+}
@@ -52,6 +52,7 @@ type reproContext struct {
 	crashType      crash.Type
 	crashStart     int
 	crashExecutor  *report.ExecutorInfo
+	leak           *leakInfo
 	entries        []*prog.LogEntry
 	testTimeouts   []time.Duration
 	startOpts      csource.Options
@@ -68,8 +69,10 @@ type execInterface interface {
 		*instance.RunResult, error)
 }
 
+// Run tries to find a reproducer for the crash in crashLog.
+// leakFrames are frames of known memory leaks, they are ignored when reproducing a different memory leak.
 func Run(crashLog []byte, cfg *mgrconfig.Config, features flatrpc.Feature, reporter *report.Reporter,
-	pool *dispatcher.Pool[*vm.Instance]) (*Result, *Stats, error) {
+	pool *dispatcher.Pool[*vm.Instance], leakFrames []string) (*Result, *Stats, error) {
 	exec := &poolWrapper{
 		cfg:      cfg,
 		reporter: reporter,
@@ -80,6 +83,9 @@ func Run(crashLog []byte, cfg *mgrconfig.Config, features flatrpc.Feature, repor
 		return nil, nil, err
 	}
 	exec.logf = ctx.reproLogf
+	if ctx.leak != nil {
+		exec.leakFrames = ctx.leak.ignoredFrames(leakFrames)
+	}
 	return ctx.run()
 }
 
@@ -94,11 +100,15 @@ func prepareCtx(crashLog []byte, cfg *mgrconfig.Config, features flatrpc.Feature
 	crashStart := len(crashLog)
 	crashTitle, crashType := "", crash.UnknownType
 	var crashExecutor *report.ExecutorInfo
+	var leak *leakInfo
 	if rep := reporter.Parse(crashLog); rep != nil {
 		crashStart = rep.StartPos
 		crashTitle = rep.Title
 		crashType = rep.Type
 		crashExecutor = rep.Executor
+		if crashType == crash.MemoryLeak {
+			leak = newLeakInfo(rep)
+		}
 	}
 	testTimeouts := []time.Duration{
 		max(30*time.Second, 3*cfg.Timeouts.Program), // to catch simpler crashes (i.e. no races and no hangs)
@@ -126,6 +136,7 @@ func prepareCtx(crashLog []byte, cfg *mgrconfig.Config, features flatrpc.Feature
 		crashType:     crashType,
 		crashStart:    crashStart,
 		crashExecutor: crashExecutor,
+		leak:          leak,
 
 		entries:        entries,
 		testTimeouts:   testTimeouts,
@@ -274,6 +285,9 @@ func (ctx *reproContext) extractProg(entries []*prog.LogEntry) (*Result, error)
 		ctx.reproLogf(3, "testing a last program of every proc")
 		toTest = lastEntries(entries)
 	}
+	if ctx.leak != nil {
+		toTest = ctx.leakCandidates(entries, toTest)
+	}
 
 	for _, timeout := range ctx.testTimeouts {
 		// Execute each program separately to detect simple crashes caused by a single program.
@@ -616,6 +630,10 @@ func (ctx *reproContext) getVerdict(callback func() (rep *instance.RunResult, er
 		ctx.reproLogf(2, "not a leak crash: %v", rep.Title)
 		return verdict{false, result.Duration}, nil
 	}
+	if ctx.leak != nil && !ctx.leak.matches(rep) {
+		ctx.reproLogf(2, "a different leak: %v", rep.Title)
+		return verdict{false, result.Duration}, nil
+	}
 	if strict && len(ctx.observedTitles) > 0 {
 		if !ctx.observedTitles[rep.Title] {
 			ctx.reproLogf(2, "a never seen crash title: %v, ignore", rep.Title)
@@ -715,10 +733,11 @@ func (ctx *reproContext) bisectProgs(progs []*prog.LogEntry, pred func([]*prog.L
 }
 
 type poolWrapper struct {
-	cfg      *mgrconfig.Config
-	reporter *report.Reporter
-	pool     *dispatcher.Pool[*vm.Instance]
-	logf     func(level int, format string, args ...interface{})
+	cfg        *mgrconfig.Config
+	reporter   *report.Reporter
+	pool       *dispatcher.Pool[*vm.Instance]
+	logf       func(level int, format string, args ...interface{})
+	leakFrames []string
 }
 
 func (pw *poolWrapper) RunCProg(p *prog.Prog, duration time.Duration,
@@ -731,7 +750,7 @@ func (pw *poolWrapper) RunCProg(p *prog.Prog, duration time.Duration,
 		})
 		var ret *instance.ExecProgInstance
 		ret, err = instance.SetupExecProg(inst, pw.cfg, pw.reporter,
-			&instance.OptionalConfig{Logf: pw.logf})
+			&instance.OptionalConfig{Logf: pw.logf, LeakFrames: pw.leakFrames})
 		if err != nil {
 			return
 		}
@@ -750,7 +769,7 @@ func (pw *poolWrapper) RunSyzProg(syzProg []byte, duration time.Duration,
 		})
 		var ret *instance.ExecProgInstance
 		ret, err = instance.SetupExecProg(inst, pw.cfg, pw.reporter,
-			&instance.OptionalConfig{Logf: pw.logf})
+			&instance.OptionalConfig{Logf: pw.logf, LeakFrames: pw.leakFrames})
 		if err != nil {
 			return
 		}
@@ -4,9 +4,11 @@
 package repro
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 	"github.com/google/syzkaller/pkg/instance"
 	"github.com/google/syzkaller/pkg/mgrconfig"
 	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/pkg/report/crash"
 	"github.com/google/syzkaller/pkg/testutil"
 	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
@@ -267,3 +270,106 @@ alarm(0xa)
 		t.Fatal(diff)
 	}
 }
+
+const testLeakReport = `BUG: memory leak
+unreferenced object 0xffff88810b2a5c00 (size 512):
+  comm "syz-executor.0", pid 8383, jiffies 4294942826 (age 13.850s)
+  hex dump (first 32 bytes):
+    00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00  ................
+  backtrace:
+    [<00000000a5d5fbd1>] kmem_cache_alloc_trace+0x145/0x2c0
+    [<0000000055ed1c1c>] %v+0x1d/0xe0
+    [<00000000ae3c5d2e>] %v+0x48/0xb0
+    [<00000000a1f1e3d7>] do_syscall_64+0x76/0x1a0
+
+`
+
+// The leak is reported while a different program is running,
+// and the last programs trigger a different leak.
+func TestLeakRepro(t *testing.T) {
+	execLog := `
+2015/12/21 12:18:05 executing program 1:
+getuid()
+2015/12/21 12:18:10 executing program 2:
+alarm(0x5)
+2015/12/21 12:18:15 executing program 1:
+getpid()
+2015/12/21 12:18:20 executing program 2:
+getpid()
+` + fmt.Sprintf(testLeakReport, "uid_leaker", "__do_sys_getuid")
+	ctx := prepareTestCtx(t, execLog, &testExecInterface{
+		run: func(log []byte) (*instance.RunResult, error) {
+			ret := &instance.RunResult{}
+			var output string
+			switch {
+			case bytes.Contains(log, []byte("getuid()")):
+				output = fmt.Sprintf(testLeakReport, "uid_leaker", "__do_sys_getuid")
+			case bytes.Contains(log, []byte("getpid()")):
+				output = fmt.Sprintf(testLeakReport, "pid_leaker", "__do_sys_getpid")
+			default:
+				return ret, nil
+			}
+			ret.Report = &report.Report{
+				Title:  "memory leak in " + strings.Split(strings.Split(output, "] ")[2], "+")[0],
+				Type:   crash.MemoryLeak,
+				Report: []byte(output),
+			}
+			return ret, nil
+		},
+	})
+	if ctx.leak == nil || !ctx.leak.syscalls["getuid"] {
+		t.Fatalf("failed to parse the leak: %+v", ctx.leak)
+	}
+	result, _, err := ctx.run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("getuid()\n", string(result.Prog.Serialize())); diff != "" {
+		t.Fatal(diff)
+	}
+	if result.Report.Title != "memory leak in uid_leaker" {
+		t.Fatalf("unexpected report title: %v", result.Report.Title)
+	}
+}
+
+func TestLeakMatches(t *testing.T) {
+	leakReport := func(title, frame string) *report.Report {
+		return &report.Report{
+			Title:  "memory leak in " + title,
+			Type:   crash.MemoryLeak,
+			Report: []byte(fmt.Sprintf(testLeakReport, title, frame)),
+		}
+	}
+	leak := newLeakInfo(leakReport("uid_leaker", "__do_sys_getuid"))
+	leak.frame = "uid_leaker"
+	tests := []struct {
+		rep     *report.Report
+		matches bool
+	}{
+		{leakReport("uid_leaker", "__do_sys_getuid"), true},
+		// A different title, but the backtrace still contains the frame.
+		{leakReport("uid_leaker_caller", "uid_leaker"), true},
+		{leakReport("pid_leaker", "__do_sys_getpid"), false},
+		// Without a backtrace only the title is compared.
+		{&report.Report{Title: "memory leak in uid_leaker"}, true},
+		{&report.Report{Title: "memory leak in pid_leaker"}, false},
+	}
+	for i, test := range tests {
+		if got := leak.matches(test.rep); got != test.matches {
+			t.Errorf("test #%v: matches=%v, want %v", i, got, test.matches)
+		}
+	}
+}
+
+func TestLeakIgnoredFrames(t *testing.T) {
+	leak := newLeakInfo(&report.Report{
+		Title:  "memory leak in uid_leaker",
+		Type:   crash.MemoryLeak,
+		Report: []byte(fmt.Sprintf(testLeakReport, "uid_leaker", "__do_sys_getuid")),
+	})
+	// Frames of the leak being reproduced must not be ignored by the executor.
+	got := leak.ignoredFrames([]string{"pid_leaker", "uid_leaker", "__do_sys_getuid", ""})
+	if diff := cmp.Diff([]string{"pid_leaker"}, got); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -58,7 +58,7 @@ func RunLocal(cfg *LocalConfig) error {
 	close(ctx.setupDone)
 
 	id := 0
-	connErr := serv.CreateInstance(id, nil, nil)
+	connErr := serv.CreateInstance(id, nil, nil, nil)
 	defer serv.ShutdownInstance(id, true)
 
 	bin := cfg.Executor
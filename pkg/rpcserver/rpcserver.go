@@ -20,6 +20,7 @@ import (
 	"github.com/google/syzkaller/pkg/fuzzer/queue"
 	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/record"
 	"github.com/google/syzkaller/pkg/signal"
 	"github.com/google/syzkaller/pkg/stat"
 	"github.com/google/syzkaller/pkg/vminfo"
@@ -71,6 +72,8 @@ type Server struct {
 	checkFailures    int
 	baseSource       *queue.DynamicSourceCtl
 	setupFeatures    flatrpc.Feature
+	disabledCalls    map[*prog.Syscall]string
+	features         vminfo.Features
 	canonicalModules *cover.Canonicalizer
 	coverFilter      []uint64
 
@@ -180,7 +183,7 @@ func (serv *Server) handleConn(conn *flatrpc.Conn) {
 
 	if serv.cfg.VMLess {
 		// There is no VM loop, so minic what it would do.
-		serv.CreateInstance(id, nil, nil)
+		serv.CreateInstance(id, nil, nil, nil)
 		defer func() {
 			serv.StopFuzzing(id)
 			serv.ShutdownInstance(id, true)
@@ -328,6 +331,8 @@ func (serv *Server) runCheck(checkFilesInfo []*flatrpc.FileInfo, checkFeatureInf
 	}
 	enabledFeatures := features.Enabled()
 	serv.setupFeatures = features.NeedSetup()
+	serv.disabledCalls = disabledCalls
+	serv.features = features
 	newSource := serv.mgr.MachineChecked(enabledFeatures, enabledCalls)
 	serv.baseSource.Store(newSource)
 	serv.checkDone.Store(true)
@@ -384,7 +389,16 @@ func (serv *Server) printMachineCheck(checkFilesInfo []*flatrpc.FileInfo, enable
 	log.Logf(0, "machine check:\n%s", buf.Bytes())
 }
 
-func (serv *Server) CreateInstance(id int, injectExec chan<- bool, updInfo dispatcher.UpdateInfo) chan error {
+// MachineCheckResult returns syscalls disabled by the machine check along with the reasons,
+// and results of the feature checks. It can be called only from Manager.MachineChecked or later.
+func (serv *Server) MachineCheckResult() (map[*prog.Syscall]string, vminfo.Features) {
+	return serv.disabledCalls, serv.features
+}
+
+// CreateInstance creates a new runner for the VM with the given id.
+// If rec is not nil, all messages exchanged with the executor and exec requests are recorded to it.
+func (serv *Server) CreateInstance(id int, injectExec chan<- bool, updInfo dispatcher.UpdateInfo,
+	rec *record.Recorder) chan error {
 	runner := &Runner{
 		id:            id,
 		source:        serv.execSource,
@@ -403,6 +417,7 @@ func (serv *Server) CreateInstance(id int, injectExec chan<- bool, updInfo dispa
 		procs:         serv.cfg.Procs,
 		updInfo:       updInfo,
 		resultCh:      make(chan error, 1),
+		rec:           rec,
 	}
 	serv.mu.Lock()
 	defer serv.mu.Unlock()
@@ -17,6 +17,7 @@ import (
 	"github.com/google/syzkaller/pkg/fuzzer/queue"
 	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/record"
 	"github.com/google/syzkaller/pkg/stat"
 	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
@@ -44,6 +45,7 @@ type Runner struct {
 	lastExec      *LastExecuting
 	updInfo       dispatcher.UpdateInfo
 	resultCh      chan error
+	rec           *record.Recorder
 
 	// The mutex protects all the fields below.
 	mu          sync.Mutex
@@ -88,6 +90,16 @@ func (runner *Runner) Handshake(conn *flatrpc.Conn, cfg *handshakeConfig) error
 		})
 	}
 
+	if runner.rec != nil {
+		// Note: ConnectRequest was already received to identify the runner, so it's not recorded.
+		conn.SetTrace(func(sent bool, data []byte) {
+			kind := record.KindRPCRecv
+			if sent {
+				kind = record.KindRPCSend
+			}
+			runner.rec.Record(kind, data)
+		})
+	}
 	connectReply := &flatrpc.ConnectReply{
 		Debug:            runner.debug,
 		Cover:            runner.cover,
@@ -336,6 +348,15 @@ func (runner *Runner) sendRequest(req *queue.Request) error {
 		},
 	}
 	runner.requests[id] = req
+	if runner.rec != nil {
+		ev := &record.ExecEvent{ID: id}
+		if req.BinaryFile == "" {
+			ev.Prog = string(req.Prog.Serialize())
+		} else {
+			ev.Prog = req.BinaryFile
+		}
+		runner.rec.RecordJSON(record.KindExec, ev)
+	}
 	return flatrpc.Send(runner.conn, msg)
 }
 
@@ -376,33 +397,16 @@ func (runner *Runner) handleExecResult(msg *flatrpc.ExecResult) error {
 	delete(runner.requests, msg.Id)
 	delete(runner.executing, msg.Id)
 	if msg.Info != nil {
-		for len(msg.Info.Calls) < len(req.Prog.Calls) {
-			msg.Info.Calls = append(msg.Info.Calls, &flatrpc.CallInfo{
-				Error: 999,
-			})
-		}
-		msg.Info.Calls = msg.Info.Calls[:len(req.Prog.Calls)]
 		if msg.Info.Freshness == 0 {
 			runner.stats.statExecutorRestarts.Add(1)
 		}
-		if !runner.cover && req.ExecOpts.ExecFlags&flatrpc.ExecFlagCollectSignal != 0 {
-			// Coverage collection is disabled, but signal was requested => use a substitute signal.
-			addFallbackSignal(req.Prog, msg.Info)
-		}
-		for _, call := range msg.Info.Calls {
-			runner.convertCallInfo(call)
-		}
-		if len(msg.Info.ExtraRaw) != 0 {
-			msg.Info.Extra = msg.Info.ExtraRaw[0]
-			for _, info := range msg.Info.ExtraRaw[1:] {
-				// All processing in the fuzzer later will convert signal/cover to maps and dedup,
-				// so there is little point in deduping here.
-				msg.Info.Extra.Cover = append(msg.Info.Extra.Cover, info.Cover...)
-				msg.Info.Extra.Signal = append(msg.Info.Extra.Signal, info.Signal...)
-			}
-			msg.Info.ExtraRaw = nil
-			runner.convertCallInfo(msg.Info.Extra)
+		conv := InfoConverter{
+			Target:        runner.sysTarget,
+			Cover:         runner.cover,
+			FilterSignal:  runner.filterSignal,
+			Canonicalizer: runner.canonicalizer,
 		}
+		conv.Convert(req, msg.Info)
 	}
 	status := queue.Success
 	var resErr error
@@ -423,26 +427,66 @@ func (runner *Runner) handleExecResult(msg *flatrpc.ExecResult) error {
 	return nil
 }
 
-func (runner *Runner) convertCallInfo(call *flatrpc.CallInfo) {
-	call.Cover = runner.canonicalizer.Canonicalize(call.Cover)
-	call.Signal = runner.canonicalizer.Canonicalize(call.Signal)
+// InfoConverter post-processes program execution info received from executor:
+// adjusts the number of per-call infos to the program, canonicalizes PCs,
+// and filters out bogus signal and uninteresting comparisons.
+type InfoConverter struct {
+	Target       *targets.Target
+	Cover        bool
+	FilterSignal bool
+	// Canonicalizer is optional, if it's nil PCs are left as is.
+	Canonicalizer *cover.CanonicalizerInstance
+}
 
-	call.Comps = slices.DeleteFunc(call.Comps, func(cmp *flatrpc.Comparison) bool {
-		converted := runner.canonicalizer.Canonicalize([]uint64{cmp.Pc})
-		if len(converted) == 0 {
-			return true
+func (conv *InfoConverter) Convert(req *queue.Request, info *flatrpc.ProgInfo) {
+	for len(info.Calls) < len(req.Prog.Calls) {
+		info.Calls = append(info.Calls, &flatrpc.CallInfo{
+			Error: 999,
+		})
+	}
+	info.Calls = info.Calls[:len(req.Prog.Calls)]
+	if !conv.Cover && req.ExecOpts.ExecFlags&flatrpc.ExecFlagCollectSignal != 0 {
+		// Coverage collection is disabled, but signal was requested => use a substitute signal.
+		addFallbackSignal(req.Prog, info)
+	}
+	for _, call := range info.Calls {
+		conv.convertCallInfo(call)
+	}
+	if len(info.ExtraRaw) != 0 {
+		info.Extra = info.ExtraRaw[0]
+		for _, extra := range info.ExtraRaw[1:] {
+			// All processing in the fuzzer later will convert signal/cover to maps and dedup,
+			// so there is little point in deduping here.
+			info.Extra.Cover = append(info.Extra.Cover, extra.Cover...)
+			info.Extra.Signal = append(info.Extra.Signal, extra.Signal...)
 		}
-		cmp.Pc = converted[0]
-		return false
-	})
+		info.ExtraRaw = nil
+		conv.convertCallInfo(info.Extra)
+	}
+}
+
+func (conv *InfoConverter) convertCallInfo(call *flatrpc.CallInfo) {
+	if conv.Canonicalizer != nil {
+		call.Cover = conv.Canonicalizer.Canonicalize(call.Cover)
+		call.Signal = conv.Canonicalizer.Canonicalize(call.Signal)
+
+		call.Comps = slices.DeleteFunc(call.Comps, func(cmp *flatrpc.Comparison) bool {
+			converted := conv.Canonicalizer.Canonicalize([]uint64{cmp.Pc})
+			if len(converted) == 0 {
+				return true
+			}
+			cmp.Pc = converted[0]
+			return false
+		})
+	}
 
 	// Check signal belongs to kernel addresses.
 	// Mismatching addresses can mean either corrupted VM memory, or that the fuzzer somehow
 	// managed to inject output signal. If we see any bogus signal, drop whole signal
 	// (we don't want programs that can inject bogus coverage to end up in the corpus).
 	var kernelAddresses targets.KernelAddresses
-	if runner.filterSignal {
-		kernelAddresses = runner.sysTarget.KernelAddresses
+	if conv.FilterSignal {
+		kernelAddresses = conv.Target.KernelAddresses
 	}
 	textStart, textEnd := kernelAddresses.TextStart, kernelAddresses.TextEnd
 	if textStart != 0 {
@@ -459,7 +503,7 @@ func (runner *Runner) convertCallInfo(call *flatrpc.CallInfo) {
 	// These are internal kernel comparisons and should not be interesting.
 	dataStart, dataEnd := kernelAddresses.DataStart, kernelAddresses.DataEnd
 	if len(call.Comps) != 0 && (textStart != 0 || dataStart != 0) {
-		if runner.sysTarget.PtrSize == 4 {
+		if conv.Target.PtrSize == 4 {
 			// These will appear sign-extended in comparison operands.
 			textStart = uint64(int64(int32(textStart)))
 			textEnd = uint64(int64(int32(textEnd)))
@@ -292,6 +292,18 @@ nextSandbox:
 					return err
 				}
 				ctx.produceTest(req, name, properties, requires, results)
+				if threaded && times == 1 {
+					// Threaded programs are also generated in the Pretty mode
+					// to check that it produces equivalent programs.
+					req, err := ctx.createCTest(p, sandbox, threaded, times)
+					if err != nil {
+						return err
+					}
+					req.sourceOpts.Pretty = true
+					if req.sourceOpts.Check(p.Target.OS) == nil {
+						ctx.produceTest(req, name+" pretty", properties, requires, results)
+					}
+				}
 			}
 		}
 	}
@@ -0,0 +1,48 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package sample
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Interval is a confidence interval.
+type Interval struct {
+	Lo float64
+	Hi float64
+}
+
+// Contains returns whether the value lies within the interval.
+func (i Interval) Contains(v float64) bool {
+	return i.Lo <= v && v <= i.Hi
+}
+
+const bootstrapIterations = 2000
+
+// BootstrapMedianDiff estimates the confidence interval of the difference between
+// the medians of the new and the old samples (new - old) with the percentile bootstrap method.
+// Level is the confidence level, e.g. 0.95. The result is deterministic for the same inputs.
+func BootstrapMedianDiff(old, new *Sample, level float64) (Interval, error) {
+	if len(old.Xs) < 2 || len(new.Xs) < 2 {
+		return Interval{}, fmt.Errorf("too few data points")
+	}
+	rnd := rand.New(rand.NewSource(0))
+	diffs := &Sample{}
+	for i := 0; i < bootstrapIterations; i++ {
+		diffs.Xs = append(diffs.Xs, resample(new, rnd).Median()-resample(old, rnd).Median())
+	}
+	return Interval{
+		Lo: diffs.Percentile((1 - level) / 2),
+		Hi: diffs.Percentile((1 + level) / 2),
+	}, nil
+}
+
+func resample(s *Sample, rnd *rand.Rand) *Sample {
+	ret := &Sample{Xs: make([]float64, len(s.Xs))}
+	for i := range ret.Xs {
+		ret.Xs[i] = s.Xs[rnd.Intn(len(s.Xs))]
+	}
+	return ret
+}
@@ -64,3 +64,55 @@ func TestRemoveOutliers(t *testing.T) {
 		}
 	}
 }
+
+func TestSeries(t *testing.T) {
+	s := &Series{
+		Xs: []float64{0, 10, 20, 40},
+		Ys: []float64{0, 100, 100, 200},
+	}
+	for x, want := range map[float64]float64{-1: 0, 5: 50, 15: 100, 30: 150, 50: 200} {
+		if got := s.ValueAt(x); got != want {
+			t.Errorf("ValueAt(%v): got %v, want %v", x, got, want)
+		}
+	}
+	for maxX, want := range map[float64]float64{0: 0, 10: 500, 20: 1500, 30: 2750, 100: 4500} {
+		if got := s.AreaUnderCurve(maxX); got != want {
+			t.Errorf("AreaUnderCurve(%v): got %v, want %v", maxX, got, want)
+		}
+	}
+	for y, want := range map[float64]float64{0: 0, 50: 5, 100: 10, 150: 30, 200: 40} {
+		if got, ok := s.FirstReach(y); !ok || got != want {
+			t.Errorf("FirstReach(%v): got %v/%v, want %v", y, got, ok, want)
+		}
+	}
+	if _, ok := s.FirstReach(201); ok {
+		t.Errorf("FirstReach(201) succeeded")
+	}
+}
+
+func TestBootstrapMedianDiff(t *testing.T) {
+	old := &Sample{Xs: []float64{10, 11, 9, 10, 12, 8, 10}}
+	same := &Sample{Xs: []float64{9, 10, 11, 10, 8, 12, 10}}
+	better := &Sample{Xs: []float64{20, 21, 19, 20, 22, 18, 20}}
+	ci, err := BootstrapMedianDiff(old, same, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ci.Contains(0) {
+		t.Errorf("interval for equal samples does not contain 0: %+v", ci)
+	}
+	ci, err = BootstrapMedianDiff(old, better, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Contains(0) || !ci.Contains(10) {
+		t.Errorf("unexpected interval: %+v", ci)
+	}
+	ci2, _ := BootstrapMedianDiff(old, better, 0.95)
+	if ci != ci2 {
+		t.Errorf("the result is not deterministic: %+v vs %+v", ci, ci2)
+	}
+	if _, err := BootstrapMedianDiff(&Sample{Xs: []float64{1}}, better, 0.95); err == nil {
+		t.Errorf("expected an error for a single data point")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package sample
+
+// Series is a sequence of data points ordered by X, e.g. coverage over fuzzing time.
+// Values between the data points are linearly interpolated.
+type Series struct {
+	Xs []float64
+	Ys []float64
+}
+
+func (s *Series) Len() int {
+	return len(s.Xs)
+}
+
+// MaxX returns the last X value of the series.
+func (s *Series) MaxX() float64 {
+	if len(s.Xs) == 0 {
+		return 0
+	}
+	return s.Xs[len(s.Xs)-1]
+}
+
+// ValueAt returns the interpolated value at x.
+// Outside of the series range, the closest known value is returned.
+func (s *Series) ValueAt(x float64) float64 {
+	if len(s.Xs) == 0 {
+		return 0
+	}
+	if x <= s.Xs[0] {
+		return s.Ys[0]
+	}
+	for i := 1; i < len(s.Xs); i++ {
+		if x <= s.Xs[i] {
+			return interpolate(s.Xs[i-1], s.Ys[i-1], s.Xs[i], s.Ys[i], x)
+		}
+	}
+	return s.Ys[len(s.Ys)-1]
+}
+
+// AreaUnderCurve returns the area under the series curve from the first point up to maxX.
+// Unlike the last value, it also reflects how fast the value was growing.
+func (s *Series) AreaUnderCurve(maxX float64) float64 {
+	area := 0.0
+	for i := 1; i < len(s.Xs) && s.Xs[i-1] < maxX; i++ {
+		x0, y0, x1, y1 := s.Xs[i-1], s.Ys[i-1], s.Xs[i], s.Ys[i]
+		if x1 > maxX {
+			y1 = interpolate(x0, y0, x1, y1, maxX)
+			x1 = maxX
+		}
+		area += (x1 - x0) * (y0 + y1) / 2
+	}
+	return area
+}
+
+// FirstReach returns the interpolated X at which the series first reached the value y.
+// The second result is false if the value was never reached.
+func (s *Series) FirstReach(y float64) (float64, bool) {
+	for i := range s.Xs {
+		if s.Ys[i] < y {
+			continue
+		}
+		if i == 0 {
+			return s.Xs[0], true
+		}
+		return interpolate(s.Ys[i-1], s.Xs[i-1], s.Ys[i], s.Xs[i], y), true
+	}
+	return 0, false
+}
+
+func interpolate(x0, y0, x1, y1, x float64) float64 {
+	if x1 == x0 {
+		return y1
+	}
+	return y0 + (y1-y0)*(x-x0)/(x1-x0)
+}
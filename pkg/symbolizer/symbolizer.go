@@ -10,11 +10,13 @@ import (
 	"fmt"
 	"io"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/sys/targets"
+	"github.com/ianlancetaylor/demangle"
 )
 
 type Symbolizer struct {
@@ -184,7 +186,7 @@ func parse(interner *Interner, s *bufio.Scanner) ([]Frame, error) {
 		}
 		frames = append(frames, Frame{
 			PC:     pc,
-			Func:   interner.Do(fn),
+			Func:   interner.Do(DemangleRust(fn)),
 			File:   interner.Do(file),
 			Line:   line,
 			Inline: true,
@@ -198,3 +200,17 @@ func parse(interner *Interner, s *bufio.Scanner) ([]Frame, error) {
 	}
 	return frames, nil
 }
+
+// Legacy Rust mangling scheme is C++-like, but has a hash suffix.
+var rustLegacySymbolRe = regexp.MustCompile(`^_ZN.*17h[0-9a-f]{16}E(?:\.|$)`)
+
+// DemangleRust returns demangled name of a Rust symbol (either v0 or legacy mangling scheme),
+// e.g. "_RNvNtCs1234_4core9panicking9panic_fmt" becomes "core::panicking::panic_fmt".
+// Names of non-Rust symbols are returned as is (we don't use -C flag for addr2line
+// since the kernel does not have C++ code, and report parsing expects plain C names).
+func DemangleRust(name string) string {
+	if !strings.HasPrefix(name, "_R") && !rustLegacySymbolRe.MatchString(name) {
+		return name
+	}
+	return demangle.Filter(name)
+}
@@ -101,6 +101,30 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			0xffffffff84a1c2d5,
+			"0xffffffff84a1c2d5\n" +
+				"_RNvMs_NtCs4fqI2P2rA04_6kernel5allocINtB4_3BoxNtNtB6_4sync5MutexE3new\n" +
+				"rust/kernel/alloc.rs:72\n" +
+				"_RNvMNtCs4fqI2P2rA04_5rnull6deviceNtB2_6Device3new\n" +
+				"drivers/block/rnull/device.rs:105\n",
+			[]Frame{
+				{
+					PC:     0xffffffff84a1c2d5,
+					Func:   "<kernel::alloc::Box<kernel::sync::Mutex>>::new",
+					File:   "rust/kernel/alloc.rs",
+					Line:   72,
+					Inline: true,
+				},
+				{
+					PC:     0xffffffff84a1c2d5,
+					Func:   "<rnull::device::Device>::new",
+					File:   "drivers/block/rnull/device.rs",
+					Line:   105,
+					Inline: false,
+				},
+			},
+		},
 	}
 
 	// Stub addr2line.
@@ -194,3 +218,21 @@ func TestParse(t *testing.T) {
 		t.Fatalf("want %v frames, got %v", want, len(frames))
 	}
 }
+
+func TestDemangleRust(t *testing.T) {
+	tests := map[string]string{
+		"_RNvNtCsdfZWD8DztAw_4core9panicking9panic_fmt":           "core::panicking::panic_fmt",
+		"_RNvMNtCs4fqI2P2rA04_5rnull6deviceNtB2_6Device3new":      "<rnull::device::Device>::new",
+		"_ZN4core9panicking9panic_fmt17h1234567890abcdefE":        "core::panicking::panic_fmt",
+		"_ZN4core9panicking9panic_fmt17h1234567890abcdefE.llvm.1": "core::panicking::panic_fmt",
+		"_ZN3foo3barE":          "_ZN3foo3barE",
+		"rust_begin_unwind":     "rust_begin_unwind",
+		"__asan_report_load2":   "__asan_report_load2",
+		"_RET_IP_not_a_symbol_": "_RET_IP_not_a_symbol_",
+	}
+	for mangled, want := range tests {
+		if got := DemangleRust(mangled); got != want {
+			t.Errorf("DemangleRust(%q) = %q, want %q", mangled, got, want)
+		}
+	}
+}
@@ -447,6 +447,76 @@ func (git *git) fetchCommits(since, base, user, domain string, greps []string, f
 	return commits, s.Err()
 }
 
+var (
+	diffFileRe = regexp.MustCompile(`^diff --git a/.* b/(.*)$`)
+	// Git prints the enclosing function declaration after the hunk header, e.g.
+	// "@@ -10,7 +10,8 @@ static int foo(struct bar *b)".
+	diffHunkFuncRe = regexp.MustCompile(`^@@ [^@]* @@.*?([a-zA-Z_][a-zA-Z0-9_]*)\s*\(`)
+)
+
+func (git *git) ListChanges(commitRange string, paths []string) ([]*CommitChanges, error) {
+	const (
+		commitStart = "---===syzkaller-commit-start===---"
+		commitEnd   = "---===syzkaller-commit-end===---"
+	)
+	args := []string{"log", "--no-merges", "--patch", "--unified=0", "--no-renames",
+		"--format=" + commitStart + "%n%H%n%s%n%ae%n%an%n%ad%n%P%n%cd%n%b%n" + commitEnd,
+		commitRange, "--"}
+	args = append(args, paths...)
+	output, err := git.git(args...)
+	if err != nil {
+		return nil, err
+	}
+	var changes []*CommitChanges
+	var cur *CommitChanges
+	var header []byte
+	inHeader := false
+	s := bufio.NewScanner(bytes.NewReader(output))
+	s.Buffer(nil, 64<<20)
+	for s.Scan() {
+		ln := s.Text()
+		switch {
+		case ln == commitStart:
+			inHeader = true
+			header = header[:0]
+		case ln == commitEnd:
+			inHeader = false
+			com, err := gitParseCommit(header, nil, nil, git.ignoreCC)
+			if err != nil {
+				return nil, err
+			}
+			lines := strings.Split(string(header), "\n")
+			cur = &CommitChanges{
+				Commit:      com,
+				Description: strings.TrimSpace(strings.Join(lines[7:], "\n")),
+			}
+			changes = append(changes, cur)
+		case inHeader:
+			header = append(header, ln...)
+			header = append(header, '\n')
+		case cur == nil:
+		case strings.HasPrefix(ln, "diff --git "):
+			if match := diffFileRe.FindStringSubmatch(ln); match != nil {
+				cur.Files = appendUnique(cur.Files, match[1])
+			}
+		case strings.HasPrefix(ln, "@@ "):
+			if match := diffHunkFuncRe.FindStringSubmatch(ln); match != nil {
+				cur.Functions = appendUnique(cur.Functions, match[1])
+			}
+		}
+	}
+	return changes, s.Err()
+}
+
+func appendUnique(list []string, str string) []string {
+	for _, s := range list {
+		if s == str {
+			return list
+		}
+	}
+	return append(list, str)
+}
+
 func (git *git) git(args ...string) ([]byte, error) {
 	cmd := osutil.Command("git", args...)
 	cmd.Dir = git.dir
@@ -12,6 +12,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/syzkaller/pkg/debugtracer"
+	"github.com/google/syzkaller/pkg/osutil"
 )
 
 func init() {
@@ -401,3 +402,58 @@ func TestBisect(t *testing.T) {
 		}
 	}
 }
+
+func TestListChanges(t *testing.T) {
+	t.Parallel()
+	repo := MakeTestRepo(t, t.TempDir())
+	writeFile := func(name, data string) {
+		file := filepath.Join(repo.Dir, name)
+		if err := osutil.MkdirAll(filepath.Dir(file)); err != nil {
+			t.Fatal(err)
+		}
+		if err := osutil.WriteFile(file, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		repo.Git("add", name)
+	}
+	writeFile("net/foo.c", "static int foo(int x)\n{\n\treturn x;\n}\n\nint bar(void)\n{\n\treturn 0;\n}\n")
+	writeFile("fs/baz.c", "int baz;\n")
+	base := repo.CommitChange("initial commit")
+	writeFile("net/foo.c", "static int foo(int x)\n{\n\treturn x + 1;\n}\n\nint bar(void)\n{\n\treturn 1;\n}\n")
+	writeFile("fs/baz.c", "int baz = 1;\n")
+	fix := repo.CommitChange("net: fix foo\n\nReported-by: syzbot+123@example.com\nFixes: 0123456789ab (\"net: add foo\")")
+	writeFile("fs/baz.c", "int baz = 2;\n")
+	repo.CommitChange("fs: update baz")
+
+	changes, err := repo.repo.ListChanges(base.Hash+"..HEAD", []string{"net"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("want 1 commit, got %v", len(changes))
+	}
+	got := changes[0]
+	if got.Hash != fix.Hash || got.Title != "net: fix foo" {
+		t.Fatalf("unexpected commit %v %q", got.Hash, got.Title)
+	}
+	if want := "Reported-by: syzbot+123@example.com\nFixes: 0123456789ab (\"net: add foo\")"; got.Description != want {
+		t.Fatalf("want description %q, got %q", want, got.Description)
+	}
+	if diff := cmp.Diff([]string{"net/foo.c"}, got.Files); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]string{"foo", "bar"}, got.Functions); diff != "" {
+		t.Fatal(diff)
+	}
+
+	changes, err = repo.repo.ListChanges(base.Hash+"..HEAD", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("want 2 commits, got %v", len(changes))
+	}
+	if diff := cmp.Diff([]string{"fs/baz.c", "net/foo.c"}, changes[1].Files); diff != "" {
+		t.Fatal(diff)
+	}
+}
@@ -126,8 +126,8 @@ func setLinuxSanitizerConfigs(cf *kconfig.ConfigFile, types []crash.Type, dt deb
 	}
 	need := map[crash.Type]bool{}
 	for _, typ := range types {
-		if typ == crash.Warning {
-			// These are disabled together.
+		if typ == crash.Warning || typ == crash.RustPanic {
+			// These are disabled together (Rust panic handler invokes BUG).
 			typ = crash.Bug
 		}
 		need[typ] = true
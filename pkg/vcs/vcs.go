@@ -98,6 +98,23 @@ type Bisecter interface {
 		kernelConfig []byte, backports []BackportCommit) (*BisectEnv, error)
 }
 
+// ChangeLister may be optionally implemented by Repo.
+type ChangeLister interface {
+	// ListChanges returns non-merge commits in the range (e.g. "v6.1..v6.2") that touch
+	// any of the paths (all commits if paths are empty) together with the changes they make.
+	// Only changes to the specified paths are returned.
+	ListChanges(commitRange string, paths []string) ([]*CommitChanges, error)
+}
+
+type CommitChanges struct {
+	*Commit
+	// Description is the full commit description (including tags like Fixes: and Reported-by:).
+	Description string
+	Files       []string
+	// Functions contains names of functions with modified code (as reported in diff hunk headers).
+	Functions []string
+}
+
 type ConfigMinimizer interface {
 	Minimize(target *targets.Target, original, baseline []byte, types []crash.Type,
 		dt debugtracer.DebugTracer, pred func(test []byte) (BisectResult, error)) ([]byte, error)
@@ -0,0 +1,123 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package vminfo
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/pkg/flatrpc"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// ConfigCandidates guesses kernel configs that may enable the disabled syscalls and features.
+// The result maps syscall and feature names to config names without CONFIG_ prefix
+// and is meant to be passed to kconfig.Suggest, which drops the guesses that are wrong.
+// descriptions are the parsed syscall descriptions for the target OS (optional),
+// configs mentioned in comments in a description file are attributed to all syscalls in the file.
+func ConfigCandidates(target *prog.Target, disabled map[*prog.Syscall]string, features Features,
+	descriptions *ast.Description) map[string][]string {
+	if target.OS != targets.Linux {
+		return nil
+	}
+	fileConfigs := descriptionConfigs(descriptions)
+	res := make(map[string][]string)
+	add := func(item string, configs ...string) {
+		for _, cfg := range configs {
+			if !slices.Contains(res[item], cfg) {
+				res[item] = append(res[item], cfg)
+			}
+		}
+	}
+	for call, reason := range disabled {
+		add(call.Name, linuxReasonConfigs(reason)...)
+		add(call.Name, fileConfigs[call.Name]...)
+	}
+	for feat, info := range features {
+		if info.Enabled {
+			continue
+		}
+		add(flatrpc.EnumNamesFeature[feat], linuxFeatureConfigs[feat]...)
+	}
+	for item, configs := range res {
+		if len(configs) == 0 {
+			delete(res, item)
+		}
+	}
+	return res
+}
+
+var linuxFeatureConfigs = map[flatrpc.Feature][]string{
+	flatrpc.FeatureCoverage:         {"KCOV"},
+	flatrpc.FeatureComparisons:      {"KCOV_ENABLE_COMPARISONS"},
+	flatrpc.FeatureExtraCoverage:    {"KCOV"},
+	flatrpc.FeatureSandboxNamespace: {"NAMESPACES", "USER_NS"},
+	flatrpc.FeatureFault:            {"FAULT_INJECTION", "FAULT_INJECTION_DEBUG_FS", "FAILSLAB", "FAIL_PAGE_ALLOC"},
+	flatrpc.FeatureLeak:             {"DEBUG_KMEMLEAK"},
+	flatrpc.FeatureNetInjection:     {"TUN"},
+	flatrpc.FeatureKCSAN:            {"KCSAN"},
+	flatrpc.FeatureUSBEmulation:     {"USB_RAW_GADGET", "USB_DUMMY_HCD"},
+	flatrpc.FeatureVhciInjection:    {"BT_HCIVHCI"},
+	flatrpc.FeatureWifiEmulation:    {"MAC80211_HWSIM"},
+	flatrpc.FeatureLRWPANEmulation:  {"IEEE802154_HWSIM"},
+	flatrpc.FeatureBinFmtMisc:       {"BINFMT_MISC"},
+	flatrpc.FeatureSwap:             {"SWAP"},
+}
+
+var (
+	reasonFilesystemRe = regexp.MustCompile(`^/proc/filesystems does not contain ([a-z0-9_.]+)$`)
+	reasonLSMRe        = regexp.MustCompile(`^([a-z]+) is not enabled$`)
+	commentConfigRe    = regexp.MustCompile(`CONFIG_([A-Z0-9_]+)`)
+)
+
+// linuxReasonConfigs extracts config candidates from the reasons produced by linuxSyscallChecks.
+func linuxReasonConfigs(reason string) []string {
+	if match := reasonFilesystemRe.FindStringSubmatch(reason); match != nil {
+		name := strings.ToUpper(strings.ReplaceAll(match[1], ".", "_"))
+		return []string{name + "_FS", name}
+	}
+	if match := reasonLSMRe.FindStringSubmatch(reason); match != nil {
+		return []string{"SECURITY_" + strings.ToUpper(match[1])}
+	}
+	return nil
+}
+
+// descriptionConfigs returns configs mentioned in description files for each syscall described in them.
+// Mentions in the form of "CONFIG_FOO is not set" are ignored.
+func descriptionConfigs(desc *ast.Description) map[string][]string {
+	if desc == nil {
+		return nil
+	}
+	files := make(map[string][]string)
+	var calls []*ast.Call
+	for _, node := range desc.Nodes {
+		switch n := node.(type) {
+		case *ast.Comment:
+			if strings.Contains(n.Text, "not set") {
+				continue
+			}
+			for _, match := range commentConfigRe.FindAllStringSubmatch(n.Text, -1) {
+				if !slices.Contains(files[n.Pos.File], match[1]) {
+					files[n.Pos.File] = append(files[n.Pos.File], match[1])
+				}
+			}
+		case *ast.Call:
+			calls = append(calls, n)
+		}
+	}
+	res := make(map[string][]string)
+	for _, call := range calls {
+		if configs := files[call.Pos.File]; len(configs) != 0 {
+			res[call.Name.Name] = configs
+		}
+	}
+	for _, configs := range files {
+		sort.Strings(configs)
+	}
+	return res
+}
@@ -14,7 +14,9 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/syzkaller/pkg/ast"
 	"github.com/google/syzkaller/pkg/flatrpc"
+	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
 	"github.com/stretchr/testify/assert"
 )
@@ -90,6 +92,31 @@ func TestLinuxSyscalls(t *testing.T) {
 	}
 }
 
+func TestLinuxConfigCandidates(t *testing.T) {
+	cfg := testConfig(t, targets.Linux, targets.AMD64)
+	target := cfg.Target
+	descriptions := ast.Parse([]byte(`
+# In the kernel CONFIG_CDROM should be enabled.
+# Description assumes CONFIG_CDROM_PKTCDVD is not set.
+openat$cdrom(fd const[AT_FDCWD], file ptr[in, string["/dev/cdrom"]]) fd
+`), "dev_cdrom.txt", nil)
+	disabled := map[*prog.Syscall]string{
+		target.SyscallMap["openat$cdrom"]:          "open(/dev/cdrom) failed: no such file or directory",
+		target.SyscallMap["syz_mount_image$btrfs"]: "/proc/filesystems does not contain btrfs",
+		target.SyscallMap["openat$selinux_load"]:   "selinux is not enabled",
+	}
+	features := Features{
+		flatrpc.FeatureFault: {Reason: "CONFIG_FAULT_INJECTION is not enabled"},
+		flatrpc.FeatureLeak:  {Enabled: true, Reason: "enabled"},
+	}
+	assert.Equal(t, map[string][]string{
+		"openat$cdrom":          {"CDROM"},
+		"syz_mount_image$btrfs": {"BTRFS_FS", "BTRFS"},
+		"openat$selinux_load":   {"SECURITY_SELINUX"},
+		"Fault":                 {"FAULT_INJECTION", "FAULT_INJECTION_DEBUG_FS", "FAILSLAB", "FAIL_PAGE_ALLOC"},
+	}, ConfigCandidates(target, disabled, features, descriptions))
+}
+
 func TestReadKVMInfo(t *testing.T) {
 	if runtime.GOOS != "linux" {
 		t.Skip("not linux")
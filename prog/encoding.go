@@ -48,6 +48,24 @@ func (p *Prog) serialize(verbose bool) []byte {
 	return ctx.buf.Bytes()
 }
 
+// SerializeCalls serializes each call of the program separately.
+// Result variables are named consistently with Serialize.
+func (p *Prog) SerializeCalls() []string {
+	p.debugValidate()
+	ctx := &serializer{
+		target: p.Target,
+		buf:    new(bytes.Buffer),
+		vars:   make(map[*ResultArg]int),
+	}
+	var calls []string
+	for _, c := range p.Calls {
+		ctx.buf.Reset()
+		ctx.call(c)
+		calls = append(calls, strings.TrimSuffix(ctx.buf.String(), "\n"))
+	}
+	return calls
+}
+
 type serializer struct {
 	target  *Target
 	buf     *bytes.Buffer
@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -388,6 +389,18 @@ func TestSerializeDeserialize(t *testing.T) {
 	})
 }
 
+func TestSerializeCalls(t *testing.T) {
+	testEachTargetRandom(t, func(t *testing.T, target *Target, rs rand.Source, iters int) {
+		ct := target.DefaultChoiceTable()
+		for i := 0; i < iters; i++ {
+			p := target.Generate(rs, 10, ct)
+			calls := p.SerializeCalls()
+			assert.Len(t, calls, len(p.Calls))
+			assert.Equal(t, string(p.Serialize()), strings.Join(calls, "\n")+"\n")
+		}
+	})
+}
+
 func TestDeserializeDataMmapProg(t *testing.T) {
 	testEachTarget(t, func(t *testing.T, target *Target) {
 		p := target.DataMmapProg()
@@ -289,3 +289,26 @@ func (ct *ChoiceTable) choose(r *rand.Rand, bias int) int {
 	}
 	return res
 }
+
+// Biased returns a copy of the choice table where the priorities of the given calls
+// are multiplied by factor, so that they are chosen more frequently.
+func (ct *ChoiceTable) Biased(calls map[*Syscall]bool, factor int32) *ChoiceTable {
+	runs := make([][]int32, len(ct.runs))
+	for i, run := range ct.runs {
+		if run == nil {
+			continue
+		}
+		runs[i] = make([]int32, len(run))
+		var prev, sum int32
+		for j, val := range run {
+			prio := val - prev
+			prev = val
+			if calls[ct.target.Syscalls[j]] {
+				prio *= factor
+			}
+			sum += prio
+			runs[i][j] = sum
+		}
+	}
+	return &ChoiceTable{ct.target, runs, ct.calls}
+}
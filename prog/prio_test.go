@@ -94,3 +94,23 @@ func TestPrioDeterminism(t *testing.T) {
 		}
 	}
 }
+
+func TestChoiceTableBiased(t *testing.T) {
+	target, rs, iters := initTest(t)
+	ct := target.DefaultChoiceTable()
+	focus := ct.calls[len(ct.calls)/2]
+	biased := ct.Biased(map[*Syscall]bool{focus: true}, 100)
+	count := func(ct *ChoiceTable) int {
+		r := rand.New(rand.NewSource(rs.Int63()))
+		res := 0
+		for i := 0; i < iters; i++ {
+			if ct.choose(r, -1) == focus.ID {
+				res++
+			}
+		}
+		return res
+	}
+	if plain, focused := count(ct), count(biased); focused <= plain {
+		t.Fatalf("biased table chose %v %v times, plain table %v times", focus.Name, focused, plain)
+	}
+}
@@ -491,6 +491,21 @@ func (r *randGen) mutateText(kind TextKind, text []byte) []byte {
 	}
 }
 
+// DisassembleText returns a listing of machine code of the given kind with one instruction per line.
+// Returns an empty string if the kind can't be decoded.
+func (target *Target) DisassembleText(kind TextKind, text []byte) string {
+	var cfg *ifuzz.Config
+	if kind == TextTarget {
+		cfg = createTargetIfuzzConfig(target)
+	} else {
+		cfg = createIfuzzConfig(kind)
+	}
+	if cfg == nil || len(text) == 0 {
+		return ""
+	}
+	return ifuzz.Disassemble(cfg, text)
+}
+
 func createTargetIfuzzConfig(target *Target) *ifuzz.Config {
 	cfg := &ifuzz.Config{
 		Len:  10,
@@ -32,3 +32,34 @@ func TestBaselineCannotInference(t *testing.T) {
 	osutil.WriteFile(kernelConfig, nil)
 	assert.Equal(t, "", inferBaselineConfig(kernelConfig))
 }
+
+func TestValidateJobsDashboard(t *testing.T) {
+	local := &Config{LocalJobsDir: "jobs", BisectBinDir: "bin"}
+	dash := &Config{DashboardAddr: "localhost:1234", DashboardClient: "ci", BisectBinDir: "bin"}
+	tests := []struct {
+		jobs   ManagerJobs
+		cfg    *Config
+		client string
+		err    string
+	}{
+		{ManagerJobs{TestPatches: true, BisectCause: true, BisectFix: true}, local, "", ""},
+		{ManagerJobs{PollCommits: true}, local, "", "poll_commits is set but no dashboard info"},
+		{ManagerJobs{TestPatches: true, PollCommits: true}, local, "", "poll_commits is set but no dashboard info"},
+		{ManagerJobs{TestPatches: true}, &Config{}, "", "has jobs but no dashboard info or local_jobs_dir"},
+		{ManagerJobs{PollCommits: true}, dash, "", "poll_commits is set but no dashboard info"},
+		{ManagerJobs{TestPatches: true, PollCommits: true}, dash, "ci-manager", ""},
+	}
+	for i, test := range tests {
+		mgr := &ManagerConfig{
+			Name:            "test-manager",
+			DashboardClient: test.client,
+			Jobs:            test.jobs,
+		}
+		err := mgr.validate(test.cfg)
+		if test.err == "" {
+			assert.NoError(t, err, "test #%v", i)
+		} else {
+			assert.ErrorContains(t, err, test.err, "test #%v", i)
+		}
+	}
+}
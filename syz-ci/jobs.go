@@ -27,9 +27,19 @@ import (
 	"github.com/google/syzkaller/vm"
 )
 
+// JobSource provides jobs for processing and accepts their results.
+// It's implemented by the dashboard and by localJobSource.
+type JobSource interface {
+	JobPoll(req *dashapi.JobPollReq) (*dashapi.JobPollResp, error)
+	JobDone(req *dashapi.JobDoneReq) error
+	JobReset(req *dashapi.JobResetReq) error
+}
+
 type JobManager struct {
 	cfg               *Config
 	dash              *dashapi.Dashboard
+	sources           []JobSource
+	local             *localJobSource
 	managers          []*Manager
 	parallelJobFilter *ManagerJobs
 	shutdownPending   <-chan struct{}
@@ -47,18 +57,29 @@ type JobProcessor struct {
 }
 
 func newJobManager(cfg *Config, managers []*Manager, shutdownPending chan struct{}) (*JobManager, error) {
-	dash, err := dashapi.New(cfg.DashboardClient, cfg.DashboardAddr, cfg.DashboardKey)
-	if err != nil {
-		return nil, err
-	}
-	return &JobManager{
+	jm := &JobManager{
 		cfg:             cfg,
-		dash:            dash,
 		managers:        managers,
 		shutdownPending: shutdownPending,
 		// For now let's only parallelize patch testing requests.
 		parallelJobFilter: &ManagerJobs{TestPatches: true},
-	}, nil
+	}
+	if cfg.DashboardAddr != "" {
+		dash, err := dashapi.New(cfg.DashboardClient, cfg.DashboardAddr, cfg.DashboardKey)
+		if err != nil {
+			return nil, err
+		}
+		jm.dash = dash
+		jm.sources = append(jm.sources, dash)
+	}
+	if cfg.LocalJobsDir != "" {
+		var err error
+		if jm.local, err = newLocalJobSource(cfg.LocalJobsDir, cfg.LocalJobsKey, managers); err != nil {
+			return nil, err
+		}
+		jm.sources = append(jm.sources, jm.local)
+	}
+	return jm, nil
 }
 
 // startLoop starts a job loop in parallel and returns a blocking function
@@ -137,8 +158,13 @@ func (jm *JobManager) resetJobs() error {
 			managerNames = append(managerNames, mgr.name)
 		}
 	}
-	if len(managerNames) > 0 {
-		return jm.dash.JobReset(&dashapi.JobResetReq{Managers: managerNames})
+	if len(managerNames) == 0 {
+		return nil
+	}
+	for _, src := range jm.sources {
+		if err := src.JobReset(&dashapi.JobResetReq{Managers: managerNames}); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -175,7 +201,7 @@ loop:
 
 func (jp *JobProcessor) pollCommits() {
 	for _, mgr := range jp.managers {
-		if !mgr.mgrcfg.Jobs.PollCommits {
+		if !mgr.mgrcfg.Jobs.PollCommits || mgr.dash == nil {
 			continue
 		}
 		if err := jp.pollManagerCommits(mgr); err != nil {
@@ -304,12 +330,20 @@ func (jp *JobProcessor) pollJobs() {
 	if len(poll.Managers) == 0 {
 		return
 	}
-	req, err := jp.dash.JobPoll(poll)
-	if err != nil {
-		jp.Errorf("failed to poll jobs: %v", err)
-		return
+	var req *dashapi.JobPollResp
+	var source JobSource
+	for _, src := range jp.sources {
+		resp, err := src.JobPoll(poll)
+		if err != nil {
+			jp.Errorf("failed to poll jobs: %v", err)
+			continue
+		}
+		if resp.ID != "" {
+			req, source = resp, src
+			break
+		}
 	}
-	if req.ID == "" {
+	if req == nil {
 		return
 	}
 	var mgr *Manager
@@ -324,8 +358,9 @@ func (jp *JobProcessor) pollJobs() {
 		return
 	}
 	job := &Job{
-		req: req,
-		mgr: mgr,
+		req:    req,
+		mgr:    mgr,
+		source: source,
 	}
 	jp.processJob(job)
 }
@@ -346,16 +381,17 @@ func (jp *JobProcessor) processJob(job *Job) {
 		}
 	default:
 	}
-	if err := jp.dash.JobDone(resp); err != nil {
+	if err := job.source.JobDone(resp); err != nil {
 		jp.Errorf("failed to mark job as done: %v", err)
 		return
 	}
 }
 
 type Job struct {
-	req  *dashapi.JobPollResp
-	resp *dashapi.JobDoneReq
-	mgr  *Manager
+	req    *dashapi.JobPollResp
+	resp   *dashapi.JobDoneReq
+	mgr    *Manager
+	source JobSource
 }
 
 func (jp *JobProcessor) process(job *Job) *dashapi.JobDoneReq {
@@ -494,6 +530,7 @@ func (jp *JobProcessor) bisect(job *Job, mgrcfg *mgrconfig.Config) error {
 		Linker:          mgr.mgrcfg.Linker,
 		Ccache:          jp.cfg.Ccache,
 		BuildCPUs:       jp.cfg.BuildCPUs,
+		BuildCache:      jp.cfg.buildCache,
 		Kernel: bisect.KernelConfig{
 			Repo:           req.KernelRepo,
 			Branch:         req.KernelBranch,
@@ -651,6 +688,7 @@ func (jp *JobProcessor) testPatch(job *Job, mgrcfg *mgrconfig.Config) error {
 		CmdlineFile:  mgr.mgrcfg.KernelCmdline,
 		SysctlFile:   mgr.mgrcfg.KernelSysctl,
 		KernelConfig: req.KernelConfig,
+		BuildCache:   jp.cfg.buildCache,
 	})
 	resp.Build.CompilerID = details.CompilerID
 	if err != nil {
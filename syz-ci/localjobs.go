@@ -0,0 +1,266 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/config"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/prog"
+)
+
+// localJobSource provides jobs from a local directory, which allows to use patch testing
+// and bisection without the dashboard.
+// A job is a JSON-serialized dashapi.JobPollResp stored in <id>.job file.
+// Empty Manager, KernelConfig and SyzkallerCommit default to the first manager that
+// has the job type enabled, its kernel config and the current syzkaller commit.
+// Once the job is done, the result (JSON-serialized dashapi.JobDoneReq) is written
+// to <id>.result and the job file is removed.
+// Jobs can also be submitted with HTTP POST to /local_jobs?key=<key>, the response contains
+// the job ID. The result can be then queried with GET /local_jobs?key=<key>&id=<id>.
+// The HTTP endpoint is served only if local_jobs_key is set in the config.
+type localJobSource struct {
+	dir      string
+	key      string
+	managers map[string]*Manager
+	mu       sync.Mutex
+	running  map[string]bool
+}
+
+const (
+	localJobSuffix    = ".job"
+	localResultSuffix = ".result"
+)
+
+var localJobIDRe = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+func newLocalJobSource(dir, key string, managers []*Manager) (*localJobSource, error) {
+	if err := osutil.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	src := &localJobSource{
+		dir:      dir,
+		key:      key,
+		managers: make(map[string]*Manager),
+		running:  make(map[string]bool),
+	}
+	for _, mgr := range managers {
+		src.managers[mgr.name] = mgr
+	}
+	return src, nil
+}
+
+func (src *localJobSource) JobPoll(req *dashapi.JobPollReq) (*dashapi.JobPollResp, error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	ids, err := src.pendingJobs()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if src.running[id] {
+			continue
+		}
+		job := new(dashapi.JobPollResp)
+		if err := config.LoadFile(filepath.Join(src.dir, id+localJobSuffix), job); err != nil {
+			if err := src.saveResult(&dashapi.JobDoneReq{
+				ID:    id,
+				Error: []byte(fmt.Sprintf("failed to load the job: %v", err)),
+			}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		job.ID = id
+		if !src.assignManager(job, req) {
+			// Some other job processor may take it.
+			continue
+		}
+		mgr := src.managers[job.Manager]
+		if len(job.KernelConfig) == 0 && mgr != nil {
+			job.KernelConfig = mgr.configData
+		}
+		if job.SyzkallerCommit == "" {
+			job.SyzkallerCommit = prog.GitRevisionBase
+		}
+		src.running[id] = true
+		return job, nil
+	}
+	return &dashapi.JobPollResp{}, nil
+}
+
+func (src *localJobSource) assignManager(job *dashapi.JobPollResp, req *dashapi.JobPollReq) bool {
+	enabled := func(jobs dashapi.ManagerJobs) bool {
+		switch job.Type {
+		case dashapi.JobTestPatch:
+			return jobs.TestPatches
+		case dashapi.JobBisectCause:
+			return jobs.BisectCause
+		case dashapi.JobBisectFix:
+			return jobs.BisectFix
+		}
+		return false
+	}
+	if job.Manager != "" {
+		return enabled(req.Managers[job.Manager])
+	}
+	var names []string
+	for name := range req.Managers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if enabled(req.Managers[name]) {
+			job.Manager = name
+			return true
+		}
+	}
+	return false
+}
+
+// pendingJobs returns IDs of jobs that don't have results yet, in the order of submission.
+func (src *localJobSource) pendingJobs() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(src.dir, "*"+localJobSuffix))
+	if err != nil {
+		return nil, err
+	}
+	type pending struct {
+		id   string
+		time int64
+	}
+	var jobs []pending
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		id := strings.TrimSuffix(filepath.Base(file), localJobSuffix)
+		if osutil.IsExist(filepath.Join(src.dir, id+localResultSuffix)) {
+			continue
+		}
+		jobs = append(jobs, pending{id, info.ModTime().UnixNano()})
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].time != jobs[j].time {
+			return jobs[i].time < jobs[j].time
+		}
+		return jobs[i].id < jobs[j].id
+	})
+	var ids []string
+	for _, job := range jobs {
+		ids = append(ids, job.id)
+	}
+	return ids, nil
+}
+
+func (src *localJobSource) JobDone(req *dashapi.JobDoneReq) error {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if !src.running[req.ID] {
+		return fmt.Errorf("unknown job %v", req.ID)
+	}
+	delete(src.running, req.ID)
+	return src.saveResult(req)
+}
+
+func (src *localJobSource) saveResult(req *dashapi.JobDoneReq) error {
+	if err := config.SaveFile(filepath.Join(src.dir, req.ID+localResultSuffix), req); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(src.dir, req.ID+localJobSuffix))
+}
+
+func (src *localJobSource) JobReset(req *dashapi.JobResetReq) error {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	// Jobs are marked as running only in memory, so after a restart they are given out again.
+	src.running = make(map[string]bool)
+	return nil
+}
+
+func (src *localJobSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.FormValue("key")
+	if src.key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(src.key)) != 1 {
+		http.Error(w, "bad key", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		src.submitJob(w, r)
+	case http.MethodGet:
+		src.jobResult(w, r)
+	default:
+		http.Error(w, "only GET and POST are supported", http.StatusMethodNotAllowed)
+	}
+}
+
+func (src *localJobSource) submitJob(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	job := new(dashapi.JobPollResp)
+	if err := json.Unmarshal(data, job); err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse the job: %v", err), http.StatusBadRequest)
+		return
+	}
+	if job.ID == "" {
+		job.ID = hash.String(data)
+	}
+	if !localJobIDRe.MatchString(job.ID) {
+		http.Error(w, fmt.Sprintf("bad job ID %q", job.ID), http.StatusBadRequest)
+		return
+	}
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if !osutil.IsExist(filepath.Join(src.dir, job.ID+localResultSuffix)) {
+		if err := config.SaveFile(filepath.Join(src.dir, job.ID+localJobSuffix), job); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Logf(0, "local job %v submitted", job.ID)
+	}
+	w.Write([]byte(job.ID))
+}
+
+func (src *localJobSource) jobResult(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if !localJobIDRe.MatchString(id) {
+		http.Error(w, fmt.Sprintf("bad job ID %q", id), http.StatusBadRequest)
+		return
+	}
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	data, err := os.ReadFile(filepath.Join(src.dir, id+localResultSuffix))
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+		return
+	}
+	if !osutil.IsExist(filepath.Join(src.dir, id+localJobSuffix)) {
+		http.Error(w, fmt.Sprintf("unknown job %v", id), http.StatusNotFound)
+		return
+	}
+	status := "pending"
+	if src.running[id] {
+		status = "running"
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(status))
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/prog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalJobSource(t *testing.T) {
+	managers := []*Manager{
+		{name: "ci-bisect", configData: []byte("CONFIG_BISECT=y")},
+		{name: "ci-patch", configData: []byte("CONFIG_PATCH=y")},
+	}
+	src, err := newLocalJobSource(t.TempDir(), "secret", managers)
+	require.NoError(t, err)
+	server := httptest.NewServer(src)
+	defer server.Close()
+
+	submit := func(job string) string {
+		resp, err := http.Post(server.URL+"?key=secret", "application/json", strings.NewReader(job))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		return string(body)
+	}
+	result := func(id string) (int, string) {
+		resp, err := http.Get(server.URL + "?key=secret&id=" + id)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+	poll := &dashapi.JobPollReq{
+		Managers: map[string]dashapi.ManagerJobs{
+			"ci-bisect": {BisectCause: true, BisectFix: true},
+			"ci-patch":  {TestPatches: true},
+		},
+	}
+
+	resp, err := src.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Empty(t, resp.ID)
+
+	id := submit(`{"ID": "test1", "Type": 0, "KernelRepo": "repo", "KernelBranch": "master",
+		"ReproSyz": "Z2V0cGlkKCk="}`)
+	assert.Equal(t, "test1", id)
+	code, status := result(id)
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, "pending", status)
+
+	// The job can be done only by ci-patch.
+	resp, err = src.JobPoll(&dashapi.JobPollReq{
+		Managers: map[string]dashapi.ManagerJobs{
+			"ci-bisect": poll.Managers["ci-bisect"],
+		},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, resp.ID)
+
+	resp, err = src.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Equal(t, &dashapi.JobPollResp{
+		ID:              "test1",
+		Type:            dashapi.JobTestPatch,
+		Manager:         "ci-patch",
+		KernelRepo:      "repo",
+		KernelBranch:    "master",
+		KernelConfig:    []byte("CONFIG_PATCH=y"),
+		SyzkallerCommit: prog.GitRevisionBase,
+		ReproSyz:        []byte("getpid()"),
+	}, resp)
+	_, status = result(id)
+	assert.Equal(t, "running", status)
+
+	// Running jobs are not given out again.
+	resp, err = src.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Empty(t, resp.ID)
+
+	// Jobs without explicit ID are identified by their contents.
+	id2 := submit(`{"Type": 1, "KernelRepo": "repo"}`)
+	assert.NotEmpty(t, id2)
+	assert.Equal(t, id2, submit(`{"Type": 1, "KernelRepo": "repo"}`))
+	resp, err = src.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Equal(t, id2, resp.ID)
+	assert.Equal(t, "ci-bisect", resp.Manager)
+
+	require.NoError(t, src.JobDone(&dashapi.JobDoneReq{
+		ID:    "test1",
+		Error: []byte("failed"),
+	}))
+	code, status = result(id)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, status, `"ID": "test1"`)
+	assert.Error(t, src.JobDone(&dashapi.JobDoneReq{ID: "test1"}))
+
+	// After a reset unfinished jobs are given out again.
+	require.NoError(t, src.JobReset(&dashapi.JobResetReq{}))
+	resp, err = src.JobPoll(poll)
+	require.NoError(t, err)
+	assert.Equal(t, id2, resp.ID)
+
+	code, _ = result("unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = result("../test1")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Requests without the right key are rejected.
+	for _, url := range []string{"?id=test1", "?key=wrong&id=test1"} {
+		resp, err := http.Get(server.URL + url)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	}
+	resp2, err := http.Post(server.URL+"?key=wrong", "application/json", strings.NewReader(`{"ID": "test3"}`))
+	require.NoError(t, err)
+	resp2.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp2.StatusCode)
+	assert.NoFileExists(t, filepath.Join(src.dir, "test3"+localJobSuffix))
+}
@@ -18,6 +18,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/syzkaller/dashboard/dashapi"
@@ -28,6 +30,7 @@ import (
 	"github.com/google/syzkaller/pkg/gcs"
 	"github.com/google/syzkaller/pkg/hash"
 	"github.com/google/syzkaller/pkg/instance"
+	"github.com/google/syzkaller/pkg/kconfig"
 	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/mgrconfig"
 	"github.com/google/syzkaller/pkg/osutil"
@@ -78,6 +81,8 @@ type Manager struct {
 	kernelSrcDir   string
 	currentDir     string
 	latestDir      string
+	previousDir    string // the build that was latest before the current latest, see regression.go
+	crashHistory   string
 	configTag      string
 	configData     []byte
 	cfg            *Config
@@ -93,6 +98,13 @@ type Manager struct {
 	lastBuild      *dashapi.Build
 	buildFailed    bool
 	lastRestarted  time.Time
+	configVariant  int // config variant used for the last build, see ConfigVariants
+
+	// Crash classification runs in the background, see regression.go.
+	crashHistoryMu sync.Mutex // protects crashHistory file
+	previousMu     sync.Mutex // protects previousDir from rotation while it's being snapshotted
+	classifying    atomic.Bool
+	classifyWG     sync.WaitGroup
 }
 
 type ManagerDashapi interface {
@@ -148,6 +160,8 @@ func createManager(cfg *Config, mgrcfg *ManagerConfig, stop chan struct{},
 		kernelBuildDir: kernelDir,
 		currentDir:     filepath.Join(dir, "current"),
 		latestDir:      filepath.Join(dir, "latest"),
+		previousDir:    filepath.Join(dir, "previous"),
+		crashHistory:   filepath.Join(dir, crashHistoryFile),
 		configTag:      hash.String(configData),
 		configData:     configData,
 		cfg:            cfg,
@@ -228,6 +242,10 @@ loop:
 			}
 		}
 
+		if mgr.cmd != nil && mgr.mgrcfg.ClassifyRegressions {
+			mgr.startCrashClassification()
+		}
+
 		select {
 		case <-mgr.stop:
 			break loop
@@ -249,6 +267,7 @@ loop:
 		}
 	}
 
+	mgr.classifyWG.Wait()
 	if mgr.cmd != nil {
 		mgr.cmd.Close()
 		mgr.cmd = nil
@@ -304,6 +323,8 @@ type BuildInfo struct {
 	KernelCommitTitle string
 	KernelCommitDate  time.Time
 	KernelConfigTag   string // SHA1 hash of .config contents
+	// Randomized kernel config variant (1-based, see ConfigVariants), 0 if not used.
+	KernelConfigVariant int
 }
 
 func loadBuildInfo(dir string) (*BuildInfo, error) {
@@ -330,16 +351,20 @@ func (mgr *Manager) createBuildInfo(kernelCommit *vcs.Commit, compilerID string)
 	tagData = append(tagData, kernelCommit.Hash...)
 	tagData = append(tagData, compilerID...)
 	tagData = append(tagData, mgr.configTag...)
+	if mgr.configVariant != 0 {
+		tagData = append(tagData, fmt.Sprintf("variant%v", mgr.configVariant)...)
+	}
 	return &BuildInfo{
-		Time:              time.Now(),
-		Tag:               hash.String(tagData),
-		CompilerID:        compilerID,
-		KernelRepo:        mgr.mgrcfg.Repo,
-		KernelBranch:      mgr.mgrcfg.Branch,
-		KernelCommit:      kernelCommit.Hash,
-		KernelCommitTitle: kernelCommit.Title,
-		KernelCommitDate:  kernelCommit.CommitDate,
-		KernelConfigTag:   mgr.configTag,
+		Time:                time.Now(),
+		Tag:                 hash.String(tagData),
+		CompilerID:          compilerID,
+		KernelRepo:          mgr.mgrcfg.Repo,
+		KernelBranch:        mgr.mgrcfg.Branch,
+		KernelCommit:        kernelCommit.Hash,
+		KernelCommitTitle:   kernelCommit.Title,
+		KernelCommitDate:    kernelCommit.CommitDate,
+		KernelConfigTag:     mgr.configTag,
+		KernelConfigVariant: mgr.configVariant,
 	}
 }
 
@@ -352,6 +377,13 @@ func (mgr *Manager) build(kernelCommit *vcs.Commit) error {
 	if err := osutil.MkdirAll(tmpDir); err != nil {
 		return fmt.Errorf("failed to create tmp dir: %w", err)
 	}
+	configData := mgr.configData
+	if mgr.mgrcfg.KernelConfigVariants != nil {
+		var err error
+		if configData, err = mgr.nextConfigVariant(); err != nil {
+			return fmt.Errorf("failed to create config variant: %w", err)
+		}
+	}
 	params := build.Params{
 		TargetOS:     mgr.managercfg.TargetOS,
 		TargetArch:   mgr.managercfg.TargetVMArch,
@@ -364,9 +396,10 @@ func (mgr *Manager) build(kernelCommit *vcs.Commit) error {
 		UserspaceDir: mgr.mgrcfg.Userspace,
 		CmdlineFile:  mgr.mgrcfg.KernelCmdline,
 		SysctlFile:   mgr.mgrcfg.KernelSysctl,
-		Config:       mgr.configData,
+		Config:       configData,
 		Build:        mgr.mgrcfg.Build,
 		BuildCPUs:    mgr.cfg.BuildCPUs,
+		Cache:        mgr.cfg.buildCache,
 	}
 	details, err := build.Image(params)
 	info := mgr.createBuildInfo(kernelCommit, details.CompilerID)
@@ -401,13 +434,63 @@ func (mgr *Manager) build(kernelCommit *vcs.Commit) error {
 		return err
 	}
 
-	// Now try to replace latest with our tmp dir as atomically as we can get on Linux.
-	if err := os.RemoveAll(mgr.latestDir); err != nil {
-		return fmt.Errorf("failed to remove latest dir: %w", err)
+	if err := mgr.rotateLatest(); err != nil {
+		return err
 	}
+	// Now try to replace latest with our tmp dir as atomically as we can get on Linux.
 	return osutil.Rename(tmpDir, mgr.latestDir)
 }
 
+// rotateLatest removes the latest build, or keeps it as the previous build
+// to test reproducers of new crashes on it (see regression.go).
+func (mgr *Manager) rotateLatest() error {
+	if !mgr.mgrcfg.ClassifyRegressions {
+		if err := os.RemoveAll(mgr.latestDir); err != nil {
+			return fmt.Errorf("failed to remove latest dir: %w", err)
+		}
+		return nil
+	}
+	mgr.previousMu.Lock()
+	defer mgr.previousMu.Unlock()
+	if err := os.RemoveAll(mgr.previousDir); err != nil {
+		return fmt.Errorf("failed to remove previous dir: %w", err)
+	}
+	if osutil.IsExist(mgr.latestDir) {
+		if err := osutil.Rename(mgr.latestDir, mgr.previousDir); err != nil {
+			return fmt.Errorf("failed to rename latest dir: %w", err)
+		}
+	}
+	return nil
+}
+
+// nextConfigVariant switches to the next randomized config variant and returns its contents.
+// Variants are generated deterministically from their number, but the result also
+// depends on the Kconfig of the current kernel checkout.
+func (mgr *Manager) nextConfigVariant() ([]byte, error) {
+	variants := mgr.mgrcfg.KernelConfigVariants
+	if mgr.configVariant == 0 {
+		// Continue the rotation after restart.
+		if info := mgr.checkLatest(); info != nil {
+			mgr.configVariant = info.KernelConfigVariant
+		}
+	}
+	mgr.configVariant = mgr.configVariant%variants.Count + 1
+	target := targets.Get(mgr.managercfg.TargetOS, mgr.managercfg.TargetVMArch)
+	kconf, err := kconfig.Parse(target, filepath.Join(mgr.kernelBuildDir, "Kconfig"))
+	if err != nil {
+		return nil, err
+	}
+	base, err := kconfig.ParseConfigData(mgr.configData, "config")
+	if err != nil {
+		return nil, err
+	}
+	rnd := rand.New(rand.NewSource(int64(mgr.configVariant)))
+	cf, changes := kconf.RandomVariant(base, variants.Toggle, variants.Choose, rnd)
+	log.Logf(0, "%v: using config variant %v/%v: %v", mgr.name, mgr.configVariant, variants.Count,
+		strings.Join(changes, " "))
+	return cf.Serialize(), nil
+}
+
 const benchFileName = "bench.json"
 
 func (mgr *Manager) restartManager() {
@@ -433,6 +516,9 @@ func (mgr *Manager) restartManager() {
 		mgr.Errorf("failed to upload build: %v", err)
 		return
 	}
+	if mgr.mgrcfg.ClassifyRegressions {
+		mgr.recordBuild(buildTag, info)
+	}
 	daysSinceCommit := time.Since(info.KernelCommitDate).Hours() / 24
 	if mgr.buildFailed && daysSinceCommit > float64(mgr.mgrcfg.MaxKernelLagDays) {
 		log.Logf(0, "%s: the kernel is now too old (%.1f days since last commit), fuzzing is stopped",
@@ -459,7 +545,7 @@ func (mgr *Manager) restartManager() {
 
 func (mgr *Manager) testImage(imageDir string, info *BuildInfo) error {
 	log.Logf(0, "%v: testing image...", mgr.name)
-	mgrcfg, err := mgr.createTestConfig(imageDir, info)
+	mgrcfg, err := mgr.createTestConfig(imageDir, info, false)
 	if err != nil {
 		return fmt.Errorf("failed to create manager config: %w", err)
 	}
@@ -562,15 +648,22 @@ func (mgr *Manager) reportBuildError(rep *report.Report, info *BuildInfo, imageD
 	return nil
 }
 
-func (mgr *Manager) createTestConfig(imageDir string, info *BuildInfo) (*mgrconfig.Config, error) {
+// createTestConfig creates a config for testing of the image in imageDir.
+// Regression tests run concurrently with image tests, so they use a different name and ports.
+func (mgr *Manager) createTestConfig(imageDir string, info *BuildInfo, regression bool) (*mgrconfig.Config, error) {
 	mgrcfg := new(mgrconfig.Config)
 	*mgrcfg = *mgr.managercfg
 	mgrcfg.Name += "-test"
 	mgrcfg.Tag = info.KernelCommit
+	httpPort, rpcPort := mgr.mgrcfg.testHTTPPort, mgr.mgrcfg.testRPCPort
+	if regression {
+		mgrcfg.Name = mgr.managercfg.Name + "-regression"
+		httpPort, rpcPort = mgr.mgrcfg.regressionHTTPPort, mgr.mgrcfg.regressionRPCPort
+	}
 	// Use designated ports not to collide with the ports of other managers.
-	mgrcfg.HTTP = fmt.Sprintf("localhost:%v", mgr.mgrcfg.testHTTPPort)
+	mgrcfg.HTTP = fmt.Sprintf("localhost:%v", httpPort)
 	// For GCE VMs, we need to bind to a real networking interface, so no localhost.
-	mgrcfg.RPC = fmt.Sprintf(":%v", mgr.mgrcfg.testRPCPort)
+	mgrcfg.RPC = fmt.Sprintf(":%v", rpcPort)
 	mgrcfg.Workdir = filepath.Join(imageDir, "workdir")
 	if err := instance.SetConfigImage(mgrcfg, imageDir, true); err != nil {
 		return nil, err
@@ -602,6 +695,9 @@ func (mgr *Manager) writeConfig(buildTag string) (string, error) {
 	}
 	mgrcfg.Tag = buildTag
 	mgrcfg.Workdir = mgr.workDir
+	// Regression classification reads crashes from the workdir,
+	// syz-manager does not store them there when it reports them to the dashboard.
+	mgrcfg.Experimental.KeepLocalCrashes = mgr.mgrcfg.ClassifyRegressions
 	// There's not much point in keeping disabled progs in the syz-ci corpuses.
 	// If the syscalls on some instance are enabled again, syz-hub will provide
 	// it with the missing progs over time.
@@ -633,6 +729,10 @@ func (mgr *Manager) uploadBuild(info *BuildInfo, imageDir string) (string, error
 		// This combined tag is meaningless without dashboard,
 		// so we use kenrel tag (commit tag) because it communicates
 		// at least some useful information.
+		// The config variant is mixed in so that crashes can be attributed to it.
+		if info.KernelConfigVariant != 0 {
+			return fmt.Sprintf("%v-variant%v", info.KernelCommit, info.KernelConfigVariant), nil
+		}
 		return info.KernelCommit, nil
 	}
 
@@ -674,6 +774,11 @@ func (mgr *Manager) createDashboardBuild(info *BuildInfo, imageDir, typ string)
 			return nil, fmt.Errorf("failed to read kernel.config: %w", err)
 		}
 	}
+	if info.KernelConfigVariant != 0 {
+		// Let the dashboard (and the bug reports) show which config variant was used.
+		kernelConfig = append([]byte(fmt.Sprintf("# syz-ci kernel_config_variants: variant %v\n",
+			info.KernelConfigVariant)), kernelConfig...)
+	}
 	// Resulting build depends on both kernel build tag and syzkaller commmit.
 	// Also mix in build type, so that image error builds are not merged into normal builds.
 	var tagData []byte
@@ -1063,14 +1168,24 @@ func (mgr *ManagerConfig) validate(cfg *Config) error {
 	if !managerNameRe.MatchString(mgr.Name) {
 		return fmt.Errorf("param 'managers.name' has bad value: %q", mgr.Name)
 	}
-	if mgr.Jobs.AnyEnabled() && (cfg.DashboardAddr == "" || cfg.DashboardClient == "") {
-		return fmt.Errorf("manager %v: has jobs but no dashboard info", mgr.Name)
+	// Commits can only be polled from the dashboard, local_jobs_dir serves only patch testing and bisection.
+	hasDashboard := cfg.DashboardAddr != "" && cfg.DashboardClient != ""
+	if mgr.Jobs.PollCommits && (!hasDashboard || mgr.DashboardClient == "") {
+		return fmt.Errorf("manager %v: poll_commits is set but no dashboard info", mgr.Name)
 	}
-	if mgr.Jobs.PollCommits && (cfg.DashboardAddr == "" || mgr.DashboardClient == "") {
-		return fmt.Errorf("manager %v: commit_poll is set but no dashboard info", mgr.Name)
+	if mgr.Jobs.AnyEnabled() && !hasDashboard && cfg.LocalJobsDir == "" {
+		return fmt.Errorf("manager %v: has jobs but no dashboard info or local_jobs_dir", mgr.Name)
 	}
 	if (mgr.Jobs.BisectCause || mgr.Jobs.BisectFix) && cfg.BisectBinDir == "" {
 		return fmt.Errorf("manager %v: enabled bisection but no bisect_bin_dir", mgr.Name)
 	}
+	if variants := mgr.KernelConfigVariants; variants != nil {
+		if mgr.KernelConfig == "" || mgr.managercfg.TargetOS != targets.Linux {
+			return fmt.Errorf("manager %v: kernel_config_variants require linux kernel_config", mgr.Name)
+		}
+		if variants.Count <= 0 || len(variants.Toggle) == 0 && len(variants.Choose) == 0 {
+			return fmt.Errorf("manager %v: kernel_config_variants need count and toggle/choose", mgr.Name)
+		}
+	}
 	return nil
 }
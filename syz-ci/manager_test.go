@@ -5,13 +5,17 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/pkg/vcs"
 	"github.com/google/syzkaller/sys/targets"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type dashapiMock struct {
@@ -106,3 +110,21 @@ Reported-by: foo+abcd000@bar.com`,
 	assert.Equal(t, commit.Title, "title with fix")
 	assert.ElementsMatch(t, commit.BugIDs, []string{"abcd000"})
 }
+
+func TestDashboardBuildConfigVariant(t *testing.T) {
+	imageDir := t.TempDir()
+	require.NoError(t, osutil.WriteFile(filepath.Join(imageDir, "kernel.config"), []byte("CONFIG_FOO=y\n")))
+	mgr := &Manager{
+		name:       "test-manager",
+		managercfg: &mgrconfig.Config{},
+	}
+	build, err := mgr.createDashboardBuild(&BuildInfo{Tag: "tag"}, imageDir, "normal")
+	require.NoError(t, err)
+	assert.Equal(t, "CONFIG_FOO=y\n", string(build.KernelConfig))
+
+	variant, err := mgr.createDashboardBuild(&BuildInfo{Tag: "tag-variant2", KernelConfigVariant: 2},
+		imageDir, "normal")
+	require.NoError(t, err)
+	assert.Equal(t, "# syz-ci kernel_config_variants: variant 2\nCONFIG_FOO=y\n", string(variant.KernelConfig))
+	assert.NotEqual(t, build.ID, variant.ID)
+}
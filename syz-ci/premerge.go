@@ -0,0 +1,493 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/pkg/build"
+	"github.com/google/syzkaller/pkg/config"
+	"github.com/google/syzkaller/pkg/email"
+	"github.com/google/syzkaller/pkg/instance"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/vcs"
+	"github.com/google/syzkaller/vm"
+)
+
+// Pre-merge fuzzing takes patch series that are not yet merged (as mbox files),
+// applies them on top of the tree of one of the managers and fuzzes the result
+// for a limited time with the coverage filter set to the files touched by the series.
+// The same session is run on the base tree, and crashes that happen only on the patched
+// tree are reported in a <name>.result file next to the <name>.mbox file.
+// The presence of the result file also marks the series as processed.
+type PreMergeConfig struct {
+	// Directory that is polled for *.mbox files, each file contains a single patch series.
+	MboxDir string `json:"mbox_dir"`
+	// Name of the syz-manager instance which repo, branch, kernel config and VM config
+	// are used for the base tree.
+	Manager string `json:"manager"`
+	// Duration of each fuzzing session (on the base and on the patched tree), in minutes.
+	// Defaults to 60.
+	FuzzingMinutes int `json:"fuzzing_minutes"`
+	// Number of VMs used for each fuzzing session (optional).
+	// The VMs are created in addition to the VMs of the managers.
+	VMs int `json:"vms"`
+
+	// Auto-assigned ports used by the fuzzing sessions.
+	httpPort int
+	rpcPort  int
+}
+
+type PreMergeResult struct {
+	Series     string
+	Patches    []string
+	Files      []string
+	BaseCommit string
+	Error      string `json:",omitempty"`
+	// Titles of crashes observed on the base tree.
+	BaseCrashes []string
+	// Crashes observed on the patched tree, but not on the base tree.
+	NewCrashes []*PreMergeCrash
+}
+
+type PreMergeCrash struct {
+	Title  string
+	Report string
+}
+
+const preMergeResultSuffix = ".result"
+
+func validatePreMergeConfig(cfg *Config) error {
+	pm := cfg.PreMerge
+	if pm.MboxDir == "" {
+		return fmt.Errorf("pre_merge: mbox_dir is empty")
+	}
+	pm.MboxDir = osutil.Abs(pm.MboxDir)
+	if pm.FuzzingMinutes == 0 {
+		pm.FuzzingMinutes = 60
+	}
+	var mgr *ManagerConfig
+	for _, mgrcfg := range cfg.Managers {
+		if mgrcfg.managercfg.Name == pm.Manager {
+			mgr = mgrcfg
+		}
+	}
+	if mgr == nil {
+		return fmt.Errorf("pre_merge: unknown manager %q", pm.Manager)
+	}
+	if typ := mgr.managercfg.Type; !vm.AllowsOvercommit(typ) {
+		return fmt.Errorf("pre_merge: fuzzing is not supported for %v machine type", typ)
+	}
+	pm.httpPort = cfg.ManagerPort
+	cfg.ManagerPort++
+	pm.rpcPort = cfg.RPCPort
+	cfg.RPCPort++
+	return nil
+}
+
+type PreMerge struct {
+	cfg  *Config
+	pm   *PreMergeConfig
+	mgr  *Manager
+	dir  string
+	stop chan struct{}
+	// Crashes of the last base tree session, they are reused while the base tree
+	// and the set of touched files stay the same.
+	baseTag     string
+	baseCrashes map[string]*PreMergeCrash
+}
+
+var errPreMergeStopped = errors.New("pre-merge fuzzing stopped")
+
+func runPreMerge(cfg *Config, managers []*Manager, stop chan struct{}) {
+	pm := &PreMerge{
+		cfg:  cfg,
+		pm:   cfg.PreMerge,
+		dir:  osutil.Abs("premerge"),
+		stop: stop,
+	}
+	for _, mgr := range managers {
+		if mgr.name == pm.pm.Manager {
+			pm.mgr = mgr
+		}
+	}
+	if pm.mgr == nil {
+		log.Errorf("pre-merge: manager %v is not running", pm.pm.Manager)
+		return
+	}
+	ticker := time.NewTicker(time.Duration(cfg.JobPollPeriod) * time.Second)
+	defer ticker.Stop()
+	for {
+		if err := pm.poll(); err != nil {
+			if err == errPreMergeStopped {
+				return
+			}
+			pm.Errorf("%v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (pm *PreMerge) poll() error {
+	files, err := filepath.Glob(filepath.Join(pm.pm.MboxDir, "*.mbox"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		resultFile := strings.TrimSuffix(file, ".mbox") + preMergeResultSuffix
+		if osutil.IsExist(resultFile) {
+			continue
+		}
+		log.Logf(0, "pre-merge: processing %v", file)
+		res, err := pm.process(file)
+		if err == errPreMergeStopped {
+			return err
+		}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		log.Logf(0, "pre-merge: done %v: %v new crashes, error: %v", file, len(res.NewCrashes), res.Error)
+		if err := config.SaveFile(resultFile, res); err != nil {
+			return fmt.Errorf("failed to save pre-merge result: %w", err)
+		}
+	}
+	return nil
+}
+
+func (pm *PreMerge) process(file string) (*PreMergeResult, error) {
+	res := new(PreMergeResult)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return res, err
+	}
+	series, err := parsePatchSeries(data)
+	if err != nil {
+		return res, err
+	}
+	res.Series = series.Subject
+	for _, patch := range series.Patches {
+		res.Patches = append(res.Patches, patch.Subject)
+	}
+	res.Files = series.touchedFiles()
+	mgr := pm.mgr
+	kernelDir := filepath.Join(pm.dir, "kernel")
+	repo, err := vcs.NewRepo(mgr.managercfg.TargetOS, mgr.managercfg.Type, kernelDir)
+	if err != nil {
+		return res, fmt.Errorf("failed to create kernel repo: %w", err)
+	}
+	commit, err := repo.CheckoutBranch(mgr.mgrcfg.Repo, mgr.mgrcfg.Branch)
+	if err != nil {
+		return res, fmt.Errorf("failed to checkout kernel repo %v/%v: %w",
+			mgr.mgrcfg.Repo, mgr.mgrcfg.Branch, err)
+	}
+	res.BaseCommit = commit.Hash
+
+	baseTag := commit.Hash + "|" + strings.Join(res.Files, "|")
+	if pm.baseTag != baseTag {
+		pm.baseTag = ""
+		crashes, err := pm.buildAndFuzz("base", kernelDir, res.Files)
+		if err != nil {
+			return res, fmt.Errorf("base tree: %w", err)
+		}
+		pm.baseTag, pm.baseCrashes = baseTag, crashes
+	}
+	for title := range pm.baseCrashes {
+		res.BaseCrashes = append(res.BaseCrashes, title)
+	}
+	sort.Strings(res.BaseCrashes)
+
+	for _, patch := range series.Patches {
+		if err := vcs.Patch(kernelDir, []byte(patch.Diff)); err != nil {
+			return res, fmt.Errorf("patch %q: %w", patch.Subject, err)
+		}
+	}
+	crashes, err := pm.buildAndFuzz("patched", kernelDir, res.Files)
+	if err != nil {
+		return res, fmt.Errorf("patched tree: %w", err)
+	}
+	res.NewCrashes = newPreMergeCrashes(pm.baseCrashes, crashes)
+	return res, nil
+}
+
+func newPreMergeCrashes(base, patched map[string]*PreMergeCrash) []*PreMergeCrash {
+	var ret []*PreMergeCrash
+	for title, crash := range patched {
+		if base[title] == nil {
+			ret = append(ret, crash)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Title < ret[j].Title
+	})
+	return ret
+}
+
+// buildAndFuzz builds the kernel in kernelDir and fuzzes it for the configured time.
+// It returns crashes found during the session keyed by title.
+func (pm *PreMerge) buildAndFuzz(name, kernelDir string, files []string) (map[string]*PreMergeCrash, error) {
+	mgr := pm.mgr
+	imageDir := filepath.Join(pm.dir, name)
+	if err := os.RemoveAll(imageDir); err != nil {
+		return nil, fmt.Errorf("failed to remove image dir: %w", err)
+	}
+	if err := osutil.MkdirAll(imageDir); err != nil {
+		return nil, fmt.Errorf("failed to create image dir: %w", err)
+	}
+	select {
+	case <-buildSem.WaitC():
+	case <-pm.stop:
+		return nil, errPreMergeStopped
+	}
+	log.Logf(0, "pre-merge: building %v kernel...", name)
+	_, err := build.Image(build.Params{
+		TargetOS:     mgr.managercfg.TargetOS,
+		TargetArch:   mgr.managercfg.TargetVMArch,
+		VMType:       mgr.managercfg.Type,
+		KernelDir:    kernelDir,
+		OutputDir:    imageDir,
+		Compiler:     mgr.mgrcfg.Compiler,
+		Linker:       mgr.mgrcfg.Linker,
+		Ccache:       mgr.mgrcfg.Ccache,
+		UserspaceDir: mgr.mgrcfg.Userspace,
+		CmdlineFile:  mgr.mgrcfg.KernelCmdline,
+		SysctlFile:   mgr.mgrcfg.KernelSysctl,
+		Config:       mgr.configData,
+		Build:        mgr.mgrcfg.Build,
+		BuildCPUs:    pm.cfg.BuildCPUs,
+		Cache:        pm.cfg.buildCache,
+	})
+	buildSem.Signal()
+	if err != nil {
+		var kernelError *build.KernelError
+		if errors.As(err, &kernelError) {
+			return nil, fmt.Errorf("kernel build failed: %s\n\n%s", kernelError.Report, kernelError.Output)
+		}
+		return nil, fmt.Errorf("kernel build failed: %w", err)
+	}
+	cfgFile, mgrcfg, err := pm.writeConfig(name, imageDir, kernelDir, files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create manager config: %w", err)
+	}
+	log.Logf(0, "pre-merge: fuzzing %v kernel for %v minutes...", name, pm.pm.FuzzingMinutes)
+	bin := filepath.FromSlash("syzkaller/current/bin/syz-manager")
+	logFile := filepath.Join(imageDir, "manager.log")
+	cmd := NewManagerCmd(mgrcfg.Name, logFile, pm.Errorf, bin, "-config", cfgFile, "-vv", "1")
+	stopped := false
+	select {
+	case <-time.After(time.Duration(pm.pm.FuzzingMinutes) * time.Minute):
+	case <-pm.stop:
+		stopped = true
+	}
+	cmd.Close()
+	if stopped {
+		return nil, errPreMergeStopped
+	}
+	return readPreMergeCrashes(mgrcfg.Workdir)
+}
+
+func (pm *PreMerge) writeConfig(name, imageDir, kernelDir string, files []string) (
+	string, *mgrconfig.Config, error) {
+	mgr := pm.mgr
+	mgrcfg := new(mgrconfig.Config)
+	*mgrcfg = *mgr.managercfg
+	mgrcfg.Name += "-premerge-" + name
+	mgrcfg.HTTP = fmt.Sprintf("localhost:%v", pm.pm.httpPort)
+	mgrcfg.RPC = fmt.Sprintf(":%v", pm.pm.rpcPort)
+	mgrcfg.Workdir = filepath.Join(imageDir, "workdir")
+	mgrcfg.KernelSrc = filepath.Join(kernelDir, mgr.mgrcfg.KernelSrcSuffix)
+	for _, file := range files {
+		mgrcfg.CovFilter.Files = append(mgrcfg.CovFilter.Files, "^"+regexp.QuoteMeta(file)+"$")
+	}
+	if err := instance.SetConfigImage(mgrcfg, imageDir, false); err != nil {
+		return "", nil, err
+	}
+	if pm.pm.VMs != 0 {
+		if err := instance.OverrideVMCount(mgrcfg, pm.pm.VMs); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := mgrconfig.Complete(mgrcfg); err != nil {
+		return "", nil, fmt.Errorf("bad manager config: %w", err)
+	}
+	cfgFile := filepath.Join(imageDir, "manager.cfg")
+	if err := config.SaveFile(cfgFile, mgrcfg); err != nil {
+		return "", nil, err
+	}
+	return cfgFile, mgrcfg, nil
+}
+
+// readPreMergeCrashes collects crashes from the syz-manager workdir.
+func readPreMergeCrashes(workdir string) (map[string]*PreMergeCrash, error) {
+	crashDir := filepath.Join(workdir, "crashes")
+	dirs, err := osutil.ListDir(crashDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	crashes := make(map[string]*PreMergeCrash)
+	for _, dir := range dirs {
+		desc, err := os.ReadFile(filepath.Join(crashDir, dir, "description"))
+		if err != nil {
+			continue
+		}
+		title := strings.TrimSpace(string(desc))
+		if title == "" {
+			continue
+		}
+		report, _ := os.ReadFile(filepath.Join(crashDir, dir, "report0"))
+		crashes[title] = &PreMergeCrash{
+			Title:  title,
+			Report: string(report),
+		}
+	}
+	return crashes, nil
+}
+
+func (pm *PreMerge) Errorf(msg string, args ...interface{}) {
+	log.Errorf("pre-merge: "+msg, args...)
+	if pm.mgr.dash != nil {
+		pm.mgr.dash.LogError(pm.mgr.name+"-premerge", msg, args...)
+	}
+}
+
+// patchSeries is a series of patches extracted from a single mbox file.
+type patchSeries struct {
+	// Subject of the cover letter, or of the first patch if there is no cover letter.
+	Subject string
+	// Patches in the order they need to be applied.
+	Patches []*seriesPatch
+}
+
+type seriesPatch struct {
+	Subject string
+	Index   int
+	Diff    string
+}
+
+// Matches [PATCH 2/5], [PATCH v3 02/10], [RFC PATCH net-next 1/2] and alike.
+var seriesIndexRe = regexp.MustCompile(`\[[^\]]*PATCH[^\]]*?\s0*(\d+)/(\d+)\s*\]`)
+
+func parsePatchSeries(data []byte) (*patchSeries, error) {
+	series := new(patchSeries)
+	total := 0
+	seen := make(map[int]bool)
+	for _, msg := range splitMbox(data) {
+		parsed, err := email.Parse(bytes.NewReader(msg), nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		// Unfold long subjects.
+		subject := strings.Join(strings.Fields(parsed.Subject), " ")
+		if strings.HasPrefix(strings.ToLower(subject), "re:") {
+			continue
+		}
+		index, count := 1, 1
+		if match := seriesIndexRe.FindStringSubmatch(subject); match != nil {
+			index, _ = strconv.Atoi(match[1])
+			count, _ = strconv.Atoi(match[2])
+		}
+		if index == 0 {
+			series.Subject = subject
+			continue
+		}
+		if parsed.Patch == "" {
+			// Probably a reply to one of the patches.
+			continue
+		}
+		if total != 0 && total != count {
+			return nil, fmt.Errorf("patch %q does not belong to a series of %v patches", subject, total)
+		}
+		total = count
+		if seen[index] {
+			return nil, fmt.Errorf("duplicate patch %v/%v", index, count)
+		}
+		seen[index] = true
+		series.Patches = append(series.Patches, &seriesPatch{
+			Subject: subject,
+			Index:   index,
+			Diff:    parsed.Patch,
+		})
+	}
+	if len(series.Patches) == 0 {
+		return nil, fmt.Errorf("no patches found")
+	}
+	if len(series.Patches) != total {
+		return nil, fmt.Errorf("incomplete series: got %v out of %v patches", len(series.Patches), total)
+	}
+	sort.Slice(series.Patches, func(i, j int) bool {
+		return series.Patches[i].Index < series.Patches[j].Index
+	})
+	if series.Subject == "" {
+		series.Subject = series.Patches[0].Subject
+	}
+	return series, nil
+}
+
+// splitMbox splits an mbox file into individual messages.
+func splitMbox(data []byte) [][]byte {
+	var msgs [][]byte
+	var cur []byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("From ")) {
+			if len(bytes.TrimSpace(cur)) != 0 {
+				msgs = append(msgs, cur)
+			}
+			cur = nil
+			continue
+		}
+		// Undo the >From quoting.
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			line = line[1:]
+		}
+		cur = append(cur, line...)
+	}
+	if len(bytes.TrimSpace(cur)) != 0 {
+		msgs = append(msgs, cur)
+	}
+	return msgs
+}
+
+// touchedFiles returns the sorted list of files modified by the series
+// (excluding deleted files, since they are not present in the patched tree).
+func (series *patchSeries) touchedFiles() []string {
+	files := make(map[string]bool)
+	for _, patch := range series.Patches {
+		for _, line := range strings.Split(patch.Diff, "\n") {
+			file, ok := strings.CutPrefix(line, "+++ ")
+			if !ok {
+				continue
+			}
+			// Strip the timestamp that diff -u may add.
+			file, _, _ = strings.Cut(file, "\t")
+			if file == "/dev/null" {
+				continue
+			}
+			// Strip b/ or any other prefix as patch -p1 does.
+			if _, rest, ok := strings.Cut(file, "/"); ok {
+				files[rest] = true
+			}
+		}
+	}
+	var ret []string
+	for file := range files {
+		ret = append(ret, file)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePatchSeries(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "series.mbox"))
+	require.NoError(t, err)
+	series, err := parsePatchSeries(data)
+	require.NoError(t, err)
+	assert.Equal(t, "[PATCH v2 0/2] foo: fix the bar handling", series.Subject)
+	require.Len(t, series.Patches, 2)
+	assert.Equal(t, "[PATCH v2 1/2] foo: add a bar helper", series.Patches[0].Subject)
+	assert.Equal(t, "[PATCH v2 2/2] foo: use the new bar helper", series.Patches[1].Subject)
+	assert.Contains(t, series.Patches[0].Diff, "+++ b/drivers/foo/bar.c\n")
+	assert.Contains(t, series.Patches[1].Diff, "+\treturn bar_helper(0);\n")
+	assert.Equal(t, []string{"drivers/foo/bar.c", "drivers/foo/main.c"}, series.touchedFiles())
+}
+
+func TestParsePatchSeriesErrors(t *testing.T) {
+	patch := func(subject string) string {
+		return "From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001\n" +
+			"From: dev@example.com\nSubject: " + subject + "\n\n" +
+			"--- a/file.c\n+++ b/file.c\n@@ -1 +1 @@\n-a\n+b\n\n"
+	}
+	tests := []struct {
+		mbox string
+		err  string
+		len  int
+	}{
+		{
+			mbox: patch("foo: single patch"),
+			len:  1,
+		},
+		{
+			mbox: patch("[PATCH 1/3] foo") + patch("[PATCH 3/3] bar"),
+			err:  "incomplete series: got 2 out of 3 patches",
+		},
+		{
+			mbox: patch("[PATCH 1/2] foo") + patch("[PATCH 1/2] foo"),
+			err:  "duplicate patch 1/2",
+		},
+		{
+			mbox: patch("[PATCH 1/2] foo") + patch("[PATCH 2/3] bar"),
+			err:  `patch "[PATCH 2/3] bar" does not belong to a series of 2 patches`,
+		},
+		{
+			mbox: patch("[RFC PATCH net-next 02/10] foo") + patch("[RFC PATCH net-next 10/10] bar"),
+			err:  "incomplete series: got 2 out of 10 patches",
+		},
+		{
+			mbox: "From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001\n" +
+				"From: dev@example.com\nSubject: hello\n\nno patches here\n",
+			err: "no patches found",
+		},
+	}
+	for i, test := range tests {
+		series, err := parsePatchSeries([]byte(test.mbox))
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, "#%v", i)
+			continue
+		}
+		require.NoError(t, err, "#%v", i)
+		assert.Len(t, series.Patches, test.len, "#%v", i)
+	}
+}
+
+func TestReadPreMergeCrashes(t *testing.T) {
+	workdir := t.TempDir()
+	crashes, err := readPreMergeCrashes(workdir)
+	require.NoError(t, err)
+	assert.Empty(t, crashes)
+
+	addCrash := func(dir, title, report string) {
+		require.NoError(t, osutil.MkdirAll(filepath.Join(workdir, "crashes", dir)))
+		require.NoError(t, osutil.WriteFile(filepath.Join(workdir, "crashes", dir, "description"),
+			[]byte(title+"\n")))
+		if report != "" {
+			require.NoError(t, osutil.WriteFile(filepath.Join(workdir, "crashes", dir, "report0"),
+				[]byte(report)))
+		}
+	}
+	addCrash("1", "WARNING in foo", "")
+	addCrash("2", "KASAN: use-after-free Read in bar", "report")
+	addCrash("3", "", "")
+	base, err := readPreMergeCrashes(workdir)
+	require.NoError(t, err)
+	assert.Len(t, base, 2)
+
+	addCrash("4", "BUG: unable to handle kernel NULL pointer dereference in baz", "baz report")
+	patched, err := readPreMergeCrashes(workdir)
+	require.NoError(t, err)
+	delete(patched, "WARNING in foo")
+	assert.Equal(t, []*PreMergeCrash{{
+		Title:  "BUG: unable to handle kernel NULL pointer dereference in baz",
+		Report: "baz report",
+	}}, newPreMergeCrashes(base, patched))
+}
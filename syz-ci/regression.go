@@ -0,0 +1,385 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/config"
+	"github.com/google/syzkaller/pkg/instance"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// Regression classification of new crashes.
+// For each manager we keep the history of crash titles observed on each kernel build.
+// When a crash title first appears on a build and it has a reproducer, the reproducer
+// is run on the previous build. If it does not crash the previous build, the crash
+// is a regression introduced by the new build. The classification is written to
+// the crash dir in the manager workdir and is shown in the syz-manager UI.
+// The classification is enabled with classify_regressions in the manager config.
+// Crashes are read from the workdir, so syz-manager is asked to keep them there
+// even if they are reported to the dashboard (see Manager.writeConfig).
+
+const (
+	crashHistoryFile = "crash_history.json"
+	// Name of the file in the crash dir with the classification (read by syz-manager).
+	crashClassFile = "regression"
+
+	crashClassRegression  = "regression"
+	crashClassPreExisting = "pre-existing"
+	// The crash was first observed on the first build in the history.
+	crashClassFirstBuild = "unknown (no previous build)"
+	// The previous build was already replaced by a newer build.
+	crashClassNoPreviousBuild = "unknown (previous build is not available)"
+
+	maxCrashHistoryBuilds = 100
+	maxRegressionAttempts = 3
+	regressionTestVMs     = 3
+)
+
+type CrashHistory struct {
+	Builds  []*CrashHistoryBuild
+	Crashes map[string]*CrashHistoryEntry
+}
+
+type CrashHistoryBuild struct {
+	// Tag used in syz-manager crash logs for this build (see Manager.uploadBuild).
+	Tag    string
+	Info   *BuildInfo
+	Titles []string
+}
+
+type CrashHistoryEntry struct {
+	// Tag of the build where the crash was first observed.
+	// Empty if the crash was observed before the history was recorded.
+	FirstBuild string
+	// Classification of the crash, empty if it's not classified yet.
+	Class    string
+	Attempts int
+}
+
+// observedCrash is a crash found in the syz-manager workdir.
+type observedCrash struct {
+	dir   string
+	title string
+	tags  []string
+}
+
+func loadCrashHistory(file string) (*CrashHistory, error) {
+	hist := &CrashHistory{
+		Crashes: make(map[string]*CrashHistoryEntry),
+	}
+	if !osutil.IsExist(file) {
+		return hist, nil
+	}
+	if err := config.LoadFile(file, hist); err != nil {
+		return nil, err
+	}
+	return hist, nil
+}
+
+func (hist *CrashHistory) addBuild(tag string, info *BuildInfo) {
+	if len(hist.Builds) != 0 && hist.Builds[len(hist.Builds)-1].Tag == tag {
+		return
+	}
+	hist.Builds = append(hist.Builds, &CrashHistoryBuild{
+		Tag:  tag,
+		Info: info,
+	})
+	if len(hist.Builds) > maxCrashHistoryBuilds {
+		hist.Builds = hist.Builds[len(hist.Builds)-maxCrashHistoryBuilds:]
+	}
+}
+
+func (hist *CrashHistory) build(tag string) (*CrashHistoryBuild, *CrashHistoryBuild) {
+	for i, build := range hist.Builds {
+		if build.Tag == tag {
+			if i == 0 {
+				return build, nil
+			}
+			return build, hist.Builds[i-1]
+		}
+	}
+	return nil, nil
+}
+
+// record attributes crashes to builds and creates entries for crashes that are seen for the first time.
+func (hist *CrashHistory) record(crashes []*observedCrash) {
+	for _, crash := range crashes {
+		firstBuild := ""
+		for _, build := range hist.Builds {
+			if !stringInList(crash.tags, build.Tag) {
+				continue
+			}
+			if firstBuild == "" {
+				firstBuild = build.Tag
+			}
+			if !stringInList(build.Titles, crash.title) {
+				build.Titles = append(build.Titles, crash.title)
+			}
+		}
+		if hist.Crashes[crash.title] != nil {
+			continue
+		}
+		entry := &CrashHistoryEntry{FirstBuild: firstBuild}
+		hist.Crashes[crash.title] = entry
+		if firstBuild == "" {
+			continue
+		}
+		if _, prev := hist.build(firstBuild); prev == nil {
+			entry.Class = crashClassFirstBuild
+		}
+	}
+}
+
+func stringInList(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// scanCrashes reads crashes saved by syz-manager in the workdir.
+func scanCrashes(workdir string) ([]*observedCrash, error) {
+	crashDir := filepath.Join(workdir, "crashes")
+	dirs, err := osutil.ListDir(crashDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	var crashes []*observedCrash
+	for _, dir := range dirs {
+		dir = filepath.Join(crashDir, dir)
+		desc, err := os.ReadFile(filepath.Join(dir, "description"))
+		if err != nil {
+			continue
+		}
+		crash := &observedCrash{
+			dir:   dir,
+			title: strings.TrimSpace(string(desc)),
+		}
+		if crash.title == "" {
+			continue
+		}
+		files, err := osutil.ListDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if !strings.HasPrefix(file, "tag") && file != "repro.tag" {
+				continue
+			}
+			tag, err := os.ReadFile(filepath.Join(dir, file))
+			if err == nil && len(tag) != 0 && !stringInList(crash.tags, string(tag)) {
+				crash.tags = append(crash.tags, string(tag))
+			}
+		}
+		crashes = append(crashes, crash)
+	}
+	return crashes, nil
+}
+
+// updateCrashHistory loads the crash history, modifies it with fn and saves it back.
+// Crash classification runs concurrently with the manager loop, so all updates go through this function.
+func (mgr *Manager) updateCrashHistory(fn func(hist *CrashHistory)) error {
+	mgr.crashHistoryMu.Lock()
+	defer mgr.crashHistoryMu.Unlock()
+	hist, err := loadCrashHistory(mgr.crashHistory)
+	if err != nil {
+		return fmt.Errorf("failed to load crash history: %w", err)
+	}
+	fn(hist)
+	if err := config.SaveFile(mgr.crashHistory, hist); err != nil {
+		return fmt.Errorf("failed to save crash history: %w", err)
+	}
+	return nil
+}
+
+func (mgr *Manager) recordBuild(tag string, info *BuildInfo) {
+	err := mgr.updateCrashHistory(func(hist *CrashHistory) {
+		hist.addBuild(tag, info)
+	})
+	if err != nil {
+		mgr.Errorf("%v", err)
+	}
+}
+
+// startCrashClassification classifies new crashes in the background
+// (reproducers are tested on VMs, which takes a while), unless it's already running.
+func (mgr *Manager) startCrashClassification() {
+	if !mgr.classifying.CompareAndSwap(false, true) {
+		return
+	}
+	mgr.classifyWG.Add(1)
+	go func() {
+		defer mgr.classifyWG.Done()
+		defer mgr.classifying.Store(false)
+		mgr.classifyCrashes()
+	}()
+}
+
+// classifyCrashes records crashes observed on the current build and classifies new ones.
+func (mgr *Manager) classifyCrashes() {
+	crashes, err := scanCrashes(mgr.workDir)
+	if err != nil {
+		mgr.Errorf("failed to read crashes: %v", err)
+		return
+	}
+	type pendingCrash struct {
+		crash *observedCrash
+		prev  *CrashHistoryBuild
+	}
+	var pending []pendingCrash
+	err = mgr.updateCrashHistory(func(hist *CrashHistory) {
+		hist.record(crashes)
+		for _, crash := range crashes {
+			entry := hist.Crashes[crash.title]
+			if entry.Class == "" && entry.FirstBuild != "" &&
+				osutil.IsExist(filepath.Join(crash.dir, "repro.prog")) {
+				_, prev := hist.build(entry.FirstBuild)
+				pending = append(pending, pendingCrash{crash, prev})
+			}
+			mgr.writeCrashClass(crash, entry.Class)
+		}
+	})
+	if err != nil {
+		mgr.Errorf("%v", err)
+		return
+	}
+	for _, p := range pending {
+		class, stopped, err := mgr.classifyCrashOrStop(p.crash, p.prev)
+		if stopped {
+			return
+		}
+		err = mgr.updateCrashHistory(func(hist *CrashHistory) {
+			entry := hist.Crashes[p.crash.title]
+			if entry == nil || entry.Class != "" {
+				return
+			}
+			if err != nil {
+				entry.Attempts++
+				log.Logf(0, "%v: failed to classify %q: %v", mgr.name, p.crash.title, err)
+				if entry.Attempts >= maxRegressionAttempts {
+					entry.Class = fmt.Sprintf("unknown (%v)", err)
+				}
+			} else {
+				entry.Class = class
+				log.Logf(0, "%v: %q is %v", mgr.name, p.crash.title, entry.Class)
+			}
+			mgr.writeCrashClass(p.crash, entry.Class)
+		})
+		if err != nil {
+			mgr.Errorf("%v", err)
+			return
+		}
+	}
+}
+
+// classifyCrashOrStop runs classifyCrash, but returns early if the manager is stopped.
+// Testing on the previous build can take a long time, so it must not delay the shutdown.
+func (mgr *Manager) classifyCrashOrStop(crash *observedCrash, prev *CrashHistoryBuild) (string, bool, error) {
+	type result struct {
+		class string
+		err   error
+	}
+	res := make(chan result, 1)
+	go func() {
+		class, err := mgr.classifyCrash(crash, prev)
+		res <- result{class, err}
+	}()
+	select {
+	case r := <-res:
+		return r.class, false, r.err
+	case <-mgr.stop:
+		return "", true, nil
+	}
+}
+
+// writeCrashClass writes the classification to the crash dir for syz-manager.
+func (mgr *Manager) writeCrashClass(crash *observedCrash, class string) {
+	classFile := filepath.Join(crash.dir, crashClassFile)
+	if class == "" || osutil.IsExist(classFile) {
+		return
+	}
+	if err := osutil.WriteFile(classFile, []byte(class)); err != nil {
+		mgr.Errorf("failed to write crash classification: %v", err)
+	}
+}
+
+// snapshotPreviousBuild hard links the previous build files into dir,
+// so that the build can be tested while it is replaced by a new build.
+// Returns nil if the previous build is not the expected build.
+func (mgr *Manager) snapshotPreviousBuild(dir string, prev *CrashHistoryBuild) (*BuildInfo, error) {
+	mgr.previousMu.Lock()
+	defer mgr.previousMu.Unlock()
+	info, err := loadBuildInfo(mgr.previousDir)
+	if err != nil || info.Tag != prev.Info.Tag || !osutil.FilesExist(mgr.previousDir, imageFiles) {
+		return nil, nil
+	}
+	if err := osutil.LinkFiles(mgr.previousDir, dir, imageFiles); err != nil {
+		return nil, fmt.Errorf("failed to link the previous build: %w", err)
+	}
+	return info, nil
+}
+
+// classifyCrash runs the crash reproducer on the previous build.
+// Only the build preceding the current one is kept, so if it was already replaced
+// by a newer build, the crash can't be classified anymore.
+func (mgr *Manager) classifyCrash(crash *observedCrash, prev *CrashHistoryBuild) (string, error) {
+	if prev == nil {
+		return crashClassFirstBuild, nil
+	}
+	imageDir := filepath.Join(filepath.Dir(mgr.previousDir), "regression")
+	defer os.RemoveAll(imageDir)
+	info, err := mgr.snapshotPreviousBuild(imageDir, prev)
+	if err != nil {
+		return "", err
+	}
+	if info == nil {
+		return crashClassNoPreviousBuild, nil
+	}
+	reproSyz, err := os.ReadFile(filepath.Join(crash.dir, "repro.prog"))
+	if err != nil {
+		return "", err
+	}
+	// syz-manager prepends the repro options as a comment.
+	var reproOpts []byte
+	if bytes.HasPrefix(reproSyz, []byte("# ")) {
+		reproOpts, reproSyz, _ = bytes.Cut(reproSyz[2:], []byte("\n"))
+	}
+	reproC, _ := os.ReadFile(filepath.Join(crash.dir, "repro.cprog"))
+	log.Logf(0, "%v: testing %q on the previous build %v", mgr.name, crash.title, info.KernelCommit)
+	mgrcfg, err := mgr.createTestConfig(imageDir, info, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to create manager config: %w", err)
+	}
+	env, err := instance.NewEnv(mgrcfg, buildSem, testSem)
+	if err != nil {
+		return "", err
+	}
+	results, err := env.Test(regressionTestVMs, reproSyz, reproOpts, reproC)
+	if err != nil {
+		return "", err
+	}
+	ret, err := aggregateTestResults(results)
+	if err != nil {
+		return "", err
+	}
+	if ret.report == nil {
+		return crashClassRegression, nil
+	}
+	if ret.report.Title == crash.title || stringInList(ret.report.AltTitles, crash.title) {
+		return crashClassPreExisting, nil
+	}
+	return fmt.Sprintf("unknown (previous build crashed with %q)", ret.report.Title), nil
+}
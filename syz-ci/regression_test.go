@@ -0,0 +1,122 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/syzkaller/pkg/config"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrashHistory(t *testing.T) {
+	workdir := t.TempDir()
+	addCrash := func(title string, tags ...string) {
+		dir := filepath.Join(workdir, "crashes", title)
+		require.NoError(t, osutil.MkdirAll(dir))
+		require.NoError(t, osutil.WriteFile(filepath.Join(dir, "description"), []byte(title+"\n")))
+		for i, tag := range tags {
+			require.NoError(t, osutil.WriteFile(filepath.Join(dir, fmt.Sprintf("tag%v", i)), []byte(tag)))
+		}
+	}
+	histFile := filepath.Join(t.TempDir(), crashHistoryFile)
+	update := func() *CrashHistory {
+		hist, err := loadCrashHistory(histFile)
+		require.NoError(t, err)
+		crashes, err := scanCrashes(workdir)
+		require.NoError(t, err)
+		hist.record(crashes)
+		require.NoError(t, config.SaveFile(histFile, hist))
+		return hist
+	}
+	addBuild := func(tag string) {
+		hist, err := loadCrashHistory(histFile)
+		require.NoError(t, err)
+		hist.addBuild(tag, &BuildInfo{Tag: "info-" + tag})
+		hist.addBuild(tag, &BuildInfo{Tag: "info-" + tag})
+		require.NoError(t, config.SaveFile(histFile, hist))
+	}
+
+	// Crashes from before the history was recorded are never classified.
+	addCrash("old crash", "build0")
+	hist := update()
+	assert.Equal(t, &CrashHistoryEntry{}, hist.Crashes["old crash"])
+
+	// There is nothing to compare with for crashes on the first build.
+	addBuild("build1")
+	addCrash("crash1", "build1")
+	addCrash("old crash", "build0", "build1")
+	hist = update()
+	assert.Equal(t, &CrashHistoryEntry{
+		FirstBuild: "build1",
+		Class:      crashClassFirstBuild,
+	}, hist.Crashes["crash1"])
+	assert.Equal(t, &CrashHistoryEntry{}, hist.Crashes["old crash"])
+
+	addBuild("build2")
+	addCrash("crash1", "build1", "build2")
+	addCrash("crash2", "build2")
+	hist = update()
+	require.Len(t, hist.Builds, 2)
+	assert.Equal(t, []string{"crash1", "old crash"}, hist.Builds[0].Titles)
+	assert.Equal(t, []string{"crash1", "crash2"}, hist.Builds[1].Titles)
+	assert.Equal(t, "build1", hist.Crashes["crash1"].FirstBuild)
+	assert.Equal(t, &CrashHistoryEntry{FirstBuild: "build2"}, hist.Crashes["crash2"])
+	build, prev := hist.build("build2")
+	assert.Equal(t, "build2", build.Tag)
+	assert.Equal(t, "info-build1", prev.Info.Tag)
+}
+
+func TestClassifyCrashNoPreviousBuild(t *testing.T) {
+	dir := t.TempDir()
+	mgr := &Manager{
+		name:        "test-manager",
+		previousDir: filepath.Join(dir, "previous"),
+	}
+	crash := &observedCrash{dir: dir, title: "crash"}
+	prev := &CrashHistoryBuild{Tag: "build1", Info: &BuildInfo{Tag: "info-build1"}}
+
+	class, stopped, err := mgr.classifyCrashOrStop(crash, nil)
+	require.NoError(t, err)
+	assert.False(t, stopped)
+	assert.Equal(t, crashClassFirstBuild, class)
+
+	// The previous build is missing or replaced by a newer build,
+	// the crash can't be classified anymore.
+	class, err = mgr.classifyCrash(crash, prev)
+	require.NoError(t, err)
+	assert.Equal(t, crashClassNoPreviousBuild, class)
+	require.NoError(t, osutil.MkdirAll(mgr.previousDir))
+	require.NoError(t, config.SaveFile(filepath.Join(mgr.previousDir, "tag"), &BuildInfo{Tag: "info-build2"}))
+	require.NoError(t, osutil.WriteFile(filepath.Join(mgr.previousDir, "image"), []byte("image")))
+	class, err = mgr.classifyCrash(crash, prev)
+	require.NoError(t, err)
+	assert.Equal(t, crashClassNoPreviousBuild, class)
+
+	// The snapshot survives replacement of the previous build.
+	snapshot := filepath.Join(dir, "snapshot")
+	prev.Info.Tag = "info-build2"
+	info, err := mgr.snapshotPreviousBuild(snapshot, prev)
+	require.NoError(t, err)
+	assert.Equal(t, "info-build2", info.Tag)
+	require.NoError(t, os.RemoveAll(mgr.previousDir))
+	data, err := os.ReadFile(filepath.Join(snapshot, "image"))
+	require.NoError(t, err)
+	assert.Equal(t, "image", string(data))
+}
+
+func TestClassifyRegressionsConfig(t *testing.T) {
+	mgrcfg := &ManagerConfig{
+		Name:                "test-manager",
+		ClassifyRegressions: true,
+	}
+	assert.NoError(t, mgrcfg.validate(&Config{}))
+	mgrcfg.DashboardClient = "test-client"
+	assert.NoError(t, mgrcfg.validate(&Config{DashboardAddr: "localhost:1234"}))
+}
@@ -45,6 +45,10 @@ package main
 //		kernel/		: kernel checkout
 //		image/		: currently used image
 //		workdir/	: some temp files
+// premerge/			: pre-merge fuzzing of patch series (see PreMergeConfig)
+//	kernel/			: kernel checkout
+//	base/			: base kernel image and manager workdir
+//	patched/		: patched kernel image and manager workdir
 //
 // Current executable, syzkaller and kernel builds are marked with tag files.
 // Tag files uniquely identify the build (git hash, compiler identity, kernel config, etc).
@@ -66,6 +70,7 @@ import (
 
 	"github.com/google/syzkaller/dashboard/dashapi"
 	"github.com/google/syzkaller/pkg/asset"
+	"github.com/google/syzkaller/pkg/build"
 	"github.com/google/syzkaller/pkg/config"
 	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/mgrconfig"
@@ -130,8 +135,14 @@ type Config struct {
 	BisectBackports []vcs.BackportCommit `json:"bisect_backports"`
 	Ccache          string               `json:"ccache"`
 	// BuildCPUs defines the maximum number of parallel kernel build threads.
-	BuildCPUs int              `json:"build_cpus"`
-	Managers  []*ManagerConfig `json:"managers"`
+	BuildCPUs int `json:"build_cpus"`
+	// Directory for the kernel build cache (optional).
+	// The cache is shared by all managers, patch testing and bisection jobs,
+	// so e.g. re-testing of already built commits during bisection does not require a rebuild.
+	BuildCacheDir string `json:"build_cache_dir"`
+	// Maximum size of the build cache in GB (defaults to 100).
+	BuildCacheSize int              `json:"build_cache_size_gb"`
+	Managers       []*ManagerConfig `json:"managers"`
 	// Poll period for jobs in seconds (optional, defaults to 10 seconds)
 	JobPollPeriod int `json:"job_poll_period"`
 	// Set up a second (parallel) job processor to speed up processing.
@@ -144,6 +155,16 @@ type Config struct {
 	// Per-vm type JSON diffs that will be applied to every instace of the
 	// corresponding VM type.
 	PatchVMConfigs map[string]json.RawMessage `json:"patch_vm_configs"`
+	// Directory with local jobs (optional), see localJobSource.
+	// Allows to use patch testing and bisection without the dashboard.
+	LocalJobsDir string `json:"local_jobs_dir"`
+	// Shared secret for submission of local jobs over HTTP (optional).
+	// If not set, the /local_jobs HTTP endpoint is disabled and jobs can only be added to local_jobs_dir.
+	LocalJobsKey string `json:"local_jobs_key"`
+	// Pre-merge fuzzing of patch series (optional), see PreMergeConfig.
+	PreMerge *PreMergeConfig `json:"pre_merge"`
+
+	buildCache *build.Cache
 }
 
 type ManagerConfig struct {
@@ -191,6 +212,8 @@ type ManagerConfig struct {
 	Ccache       string `json:"ccache"`
 	Userspace    string `json:"userspace"`
 	KernelConfig string `json:"kernel_config"`
+	// Randomized variants of kernel_config to rotate across kernel builds (optional).
+	KernelConfigVariants *ConfigVariants `json:"kernel_config_variants"`
 	// KernelSrcSuffix adds a suffix to the kernel_src manager config. This is needed for cases where
 	// the kernel source root as reported in the coverage UI is a subdirectory of the VCS root.
 	KernelSrcSuffix string `json:"kernel_src_suffix"`
@@ -214,11 +237,30 @@ type ManagerConfig struct {
 	// fuzzing won't be started on this instance.
 	// By default it's 30 days.
 	MaxKernelLagDays int `json:"max_kernel_lag_days"`
-	managercfg       *mgrconfig.Config
+	// Test reproducers of new crashes on the previous kernel build to classify them
+	// as regressions (see regression.go).
+	ClassifyRegressions bool `json:"classify_regressions"`
+	managercfg          *mgrconfig.Config
 
 	// Auto-assigned ports used by test instances.
 	testHTTPPort int
 	testRPCPort  int
+	// Ports used by regression test instances (see regression.go).
+	regressionHTTPPort int
+	regressionRPCPort  int
+}
+
+// ConfigVariants describes how to generate randomized variants of the kernel config
+// to find config-dependent bugs. Each kernel build uses the next variant.
+// Config names don't include the CONFIG_ prefix.
+type ConfigVariants struct {
+	// Number of variants to rotate.
+	Count int `json:"count"`
+	// Configs that are randomly enabled/disabled in each variant (e.g. debug options).
+	Toggle []string `json:"toggle"`
+	// Groups of mutually exclusive configs, in each variant one config from each group
+	// is enabled and the rest are disabled (e.g. preemption models or slab allocators).
+	Choose [][]string `json:"choose"`
 }
 
 type ManagerJobs struct {
@@ -249,6 +291,12 @@ func main() {
 		log.Fatalf("failed to load config: %v", err)
 	}
 	log.SetName(cfg.Name)
+	if cfg.BuildCacheDir != "" {
+		cfg.buildCache, err = build.NewCache(cfg.BuildCacheDir, int64(cfg.BuildCacheSize)<<30)
+		if err != nil {
+			log.Fatalf("failed to create build cache: %v", err)
+		}
+	}
 
 	shutdownPending := make(chan struct{})
 	osutil.HandleInterrupts(shutdownPending)
@@ -300,6 +348,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to create dashapi connection %v", err)
 	}
+	if jp.local != nil && cfg.LocalJobsKey != "" {
+		http.Handle("/local_jobs", jp.local)
+	}
 	stopJobs := jp.startLoop(&wg)
 
 	// For testing. Racy. Use with care.
@@ -316,6 +367,14 @@ func main() {
 	wg.Add(1)
 	go deprecateAssets(cfg, stop, &wg)
 
+	if cfg.PreMerge != nil && *flagManagers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runPreMerge(cfg, managers, stop)
+		}()
+	}
+
 	select {
 	case <-shutdownPending:
 	case <-updatePending:
@@ -364,6 +423,9 @@ loop:
 }
 
 func serveHTTP(cfg *Config) {
+	if prefix, handler := cfg.AssetStorage.LocalHandler(); handler != nil {
+		http.Handle(prefix, handler)
+	}
 	ln, err := net.Listen("tcp4", cfg.HTTP)
 	if err != nil {
 		log.Fatalf("failed to listen on %v: %v", cfg.HTTP, err)
@@ -398,6 +460,11 @@ func loadConfig(filename string) (*Config, error) {
 	cfg.SyzkallerDescriptions = osutil.Abs(cfg.SyzkallerDescriptions)
 	cfg.BisectBinDir = osutil.Abs(cfg.BisectBinDir)
 	cfg.Ccache = osutil.Abs(cfg.Ccache)
+	cfg.LocalJobsDir = osutil.Abs(cfg.LocalJobsDir)
+	cfg.BuildCacheDir = osutil.Abs(cfg.BuildCacheDir)
+	if cfg.BuildCacheSize == 0 {
+		cfg.BuildCacheSize = 100
+	}
 	var managers []*ManagerConfig
 	for _, mgr := range cfg.Managers {
 		if mgr.Disabled == "" {
@@ -416,6 +483,11 @@ func loadConfig(filename string) (*Config, error) {
 			return nil, fmt.Errorf("asset storage config error: %w", err)
 		}
 	}
+	if cfg.PreMerge != nil {
+		if err := validatePreMergeConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
 	return cfg, nil
 }
 
@@ -455,6 +527,12 @@ func loadManagerConfig(cfg *Config, mgr *ManagerConfig) error {
 	cfg.ManagerPort++
 	mgr.testRPCPort = cfg.RPCPort
 	cfg.RPCPort++
+	if mgr.ClassifyRegressions {
+		mgr.regressionHTTPPort = cfg.ManagerPort
+		cfg.ManagerPort++
+		mgr.regressionRPCPort = cfg.RPCPort
+		cfg.RPCPort++
+	}
 	// Note: we don't change Compiler/Ccache because it may be just "gcc" referring
 	// to the system binary, or pkg/build/netbsd.go uses "g++" and "clang++" as special marks.
 	mgr.Userspace = osutil.Abs(mgr.Userspace)
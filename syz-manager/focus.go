@@ -0,0 +1,95 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/corpus"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/subsystem"
+	_ "github.com/google/syzkaller/pkg/subsystem/lists"
+	"github.com/google/syzkaller/pkg/vminfo"
+	"github.com/google/syzkaller/prog"
+)
+
+// createFocusAreas resolves subsystem_weights into corpus focus areas:
+// coverage PCs are taken from the source files that belong to the subsystem.
+func createFocusAreas(cfg *mgrconfig.Config, modules []*vminfo.KernelModule,
+	enabledSyscalls map[*prog.Syscall]bool) ([]corpus.FocusArea, error) {
+	if len(cfg.SubsystemWeights) == 0 {
+		return nil, nil
+	}
+	rg, err := getReportGenerator(cfg, modules)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range cfg.SubsystemWeights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	builtin := make(map[string]*subsystem.Subsystem)
+	for _, item := range subsystem.GetList(cfg.TargetOS) {
+		builtin[item.Name] = item
+	}
+	var areas []corpus.FocusArea
+	for _, name := range names {
+		area := corpus.FocusArea{
+			Name:     name,
+			CoverPCs: make(map[uint64]struct{}),
+			Calls:    make(map[*prog.Syscall]bool),
+			Weight:   float64(cfg.SubsystemWeights[name]) / 100,
+		}
+		match := focusFileMatcher(cfg, builtin, name)
+		for _, unit := range rg.Units {
+			if !match(unit.Name) {
+				continue
+			}
+			for _, pc := range unit.PCs {
+				area.CoverPCs[pc] = struct{}{}
+			}
+		}
+		if item := builtin[name]; item != nil {
+			for _, call := range item.Syscalls {
+				if meta := cfg.Target.SyscallMap[call]; meta != nil && enabledSyscalls[meta] {
+					area.Calls[meta] = true
+				}
+			}
+		}
+		log.Logf(0, "focus area %v: %v PCs, %v syscalls, %v%% of executions",
+			name, len(area.CoverPCs), len(area.Calls), cfg.SubsystemWeights[name])
+		if len(area.CoverPCs) == 0 && len(area.Calls) == 0 {
+			return nil, fmt.Errorf("subsystem %v does not match any kernel code", name)
+		}
+		areas = append(areas, area)
+	}
+	return areas, nil
+}
+
+// focusFileMatcher returns a source file matcher for the subsystem.
+// The name is already checked to be either in kernel_subsystem or in the builtin list by mgrconfig.
+func focusFileMatcher(cfg *mgrconfig.Config, builtin map[string]*subsystem.Subsystem,
+	name string) func(string) bool {
+	for _, item := range cfg.KernelSubsystem {
+		if item.Name != name {
+			continue
+		}
+		return func(file string) bool {
+			for _, path := range item.Paths {
+				if strings.HasPrefix(file, path) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	matcher := subsystem.MakePathMatcher([]*subsystem.Subsystem{builtin[name]})
+	return func(file string) bool {
+		return len(matcher.Match(file)) != 0
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/google/syzkaller/pkg/cover"
 	"github.com/google/syzkaller/pkg/fuzzer"
 	"github.com/google/syzkaller/pkg/html/pages"
+	"github.com/google/syzkaller/pkg/kconfig"
 	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/pkg/stat"
@@ -64,6 +65,9 @@ func (mgr *Manager) initHTTP() {
 	handle("/debuginput", mgr.httpDebugInput)
 	handle("/modules", mgr.modulesInfo)
 	handle("/jobs", mgr.httpJobs)
+	if prefix, handler := mgr.cfg.AssetStorage.LocalHandler(); handler != nil {
+		http.Handle(prefix, handler)
+	}
 	// Browsers like to request this, without special handler this goes to / handler.
 	handle("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {})
 
@@ -155,6 +159,18 @@ func (mgr *Manager) httpSyscalls(w http.ResponseWriter, r *http.Request) {
 	sort.Slice(data.Calls, func(i, j int) bool {
 		return data.Calls[i].Name < data.Calls[j].Name
 	})
+	mgr.mu.Lock()
+	for call, reason := range mgr.disabledSyscalls {
+		data.Disabled = append(data.Disabled, UIDisabledCall{
+			Name:   call.Name,
+			Reason: reason,
+		})
+	}
+	data.Configs = mgr.configSuggestions
+	mgr.mu.Unlock()
+	sort.Slice(data.Disabled, func(i, j int) bool {
+		return data.Disabled[i].Name < data.Disabled[j].Name
+	})
 	executeTemplate(w, syscallsTemplate, data)
 }
 
@@ -519,6 +535,31 @@ func (mgr *Manager) httpInput(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.Write(inp.Prog.Serialize())
+	writeTextDisassembly(w, inp.Prog)
+}
+
+// writeTextDisassembly appends disassembly of machine code arguments of p as program comments.
+func writeTextDisassembly(w io.Writer, p *prog.Prog) {
+	for i, c := range p.Calls {
+		prog.ForeachArg(c, func(arg prog.Arg, _ *prog.ArgCtx) {
+			data, ok := arg.(*prog.DataArg)
+			if !ok {
+				return
+			}
+			typ, ok := data.Type().(*prog.BufferType)
+			if !ok || typ.Kind != prog.BufferText {
+				return
+			}
+			listing := p.Target.DisassembleText(typ.Text, data.Data())
+			if listing == "" {
+				return
+			}
+			fmt.Fprintf(w, "\n# call #%v %v: %v\n", i, c.Meta.Name, typ.Name())
+			for _, line := range strings.Split(strings.TrimSpace(listing), "\n") {
+				fmt.Fprintf(w, "#   %v\n", line)
+			}
+		})
+	}
 }
 
 func (mgr *Manager) httpDebugInput(w http.ResponseWriter, r *http.Request) {
@@ -770,6 +811,8 @@ func readCrash(workdir, dir string, repros map[string]bool, start int64, full bo
 	}
 
 	triaged := reproStatus(hasRepro, hasCRepro, repros[desc], reproAttempts >= maxReproAttempts)
+	// Written by syz-ci when it classifies new crashes, see syz-ci/regression.go.
+	regression, _ := os.ReadFile(filepath.Join(crashdir, dir, "regression"))
 	return &UICrashType{
 		Description: desc,
 		LastTime:    modTime,
@@ -777,6 +820,7 @@ func readCrash(workdir, dir string, repros map[string]bool, start int64, full bo
 		ID:          dir,
 		Count:       len(crashes),
 		Triaged:     triaged,
+		Regression:  string(regression),
 		Strace:      strace,
 		Crashes:     crashes,
 	}
@@ -838,8 +882,15 @@ type UIVMInfo struct {
 }
 
 type UISyscallsData struct {
-	Name  string
-	Calls []UICallType
+	Name     string
+	Calls    []UICallType
+	Disabled []UIDisabledCall
+	Configs  []*kconfig.Suggestion
+}
+
+type UIDisabledCall struct {
+	Name   string
+	Reason string
 }
 
 type UICrashType struct {
@@ -849,6 +900,7 @@ type UICrashType struct {
 	ID          string
 	Count       int
 	Triaged     string
+	Regression  string
 	Strace      string
 	Crashes     []*UICrash
 }
@@ -925,6 +977,7 @@ var summaryTemplate = pages.Create(`
 		<th><a onclick="return sortTable(this, 'Count', numSort)" href="#">Count</a></th>
 		<th><a onclick="return sortTable(this, 'Last Time', textSort, true)" href="#">Last Time</a></th>
 		<th><a onclick="return sortTable(this, 'Report', textSort)" href="#">Report</a></th>
+		<th><a onclick="return sortTable(this, 'Regression', textSort)" href="#">Regression</a></th>
 	</tr>
 	{{range $c := $.Crashes}}
 	<tr>
@@ -939,6 +992,7 @@ var summaryTemplate = pages.Create(`
 				<a href="/file?name={{$c.Strace}}">Strace</a>
 			{{end}}
 		</td>
+		<td>{{$c.Regression}}</td>
 	</tr>
 	{{end}}
 </table>
@@ -1012,6 +1066,42 @@ var syscallsTemplate = pages.Create(`
 	</tr>
 	{{end}}
 </table>
+
+{{if $.Configs}}
+<table class="list_table">
+	<caption>Kernel configs that may enable disabled syscalls/features:</caption>
+	<tr>
+		<th>Config</th>
+		<th>Prompt</th>
+		<th>Missing dependencies</th>
+		<th>Syscalls/features</th>
+	</tr>
+	{{range $c := $.Configs}}
+	<tr>
+		<td>CONFIG_{{$c.Config}}</td>
+		<td>{{$c.Prompt}}</td>
+		<td>{{range $dep := $c.Deps}}CONFIG_{{$dep}} {{end}}</td>
+		<td>{{len $c.Items}}: {{range $item := $c.Items}}{{$item}} {{end}}</td>
+	</tr>
+	{{end}}
+</table>
+{{end}}
+
+{{if $.Disabled}}
+<table class="list_table">
+	<caption>Disabled syscalls:</caption>
+	<tr>
+		<th><a onclick="return sortTable(this, 'Syscall', textSort)" href="#">Syscall</a></th>
+		<th><a onclick="return sortTable(this, 'Reason', textSort)" href="#">Reason</a></th>
+	</tr>
+	{{range $c := $.Disabled}}
+	<tr>
+		<td>{{$c.Name}}</td>
+		<td>{{$c.Reason}}</td>
+	</tr>
+	{{end}}
+</table>
+{{end}}
 </body></html>
 `)
 
@@ -1028,6 +1118,9 @@ var crashTemplate = pages.Create(`
 {{if .Triaged}}
 Report: <a href="/report?id={{.ID}}">{{.Triaged}}</a>
 {{end}}
+{{if .Regression}}
+<br>Regression: {{.Regression}}
+{{end}}
 
 <table class="list_table">
 	<tr>
@@ -0,0 +1,44 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/pkg/kconfig"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/vminfo"
+	"github.com/google/syzkaller/prog"
+)
+
+// suggestConfigs cross-references syscalls and features disabled by the machine check
+// with syscall descriptions and kernel Kconfig to suggest configs that may enable them.
+// Kconfig and .config are used only if kernel_src/kernel_obj are present.
+func suggestConfigs(cfg *mgrconfig.Config, disabled map[*prog.Syscall]string,
+	features vminfo.Features) []*kconfig.Suggestion {
+	descriptions := ast.ParseGlob(filepath.Join(cfg.Syzkaller, "sys", cfg.TargetOS, "*.txt"),
+		func(pos ast.Pos, msg string) {})
+	candidates := vminfo.ConfigCandidates(cfg.Target, disabled, features, descriptions)
+	if len(candidates) == 0 {
+		return nil
+	}
+	var kconf *kconfig.KConfig
+	if file := filepath.Join(cfg.KernelSrc, "Kconfig"); cfg.KernelSrc != "" && osutil.IsExist(file) {
+		var err error
+		if kconf, err = kconfig.Parse(cfg.SysTarget, file); err != nil {
+			log.Logf(0, "failed to parse Kconfig, config suggestions are not verified: %v", err)
+		}
+	}
+	var current *kconfig.ConfigFile
+	if file := filepath.Join(cfg.KernelObj, ".config"); cfg.KernelObj != "" && osutil.IsExist(file) {
+		var err error
+		if current, err = kconfig.ParseConfig(file); err != nil {
+			log.Logf(0, "failed to parse kernel .config: %v", err)
+		}
+	}
+	return kconfig.Suggest(candidates, kconf, current)
+}
@@ -17,6 +17,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -33,9 +34,11 @@ import (
 	"github.com/google/syzkaller/pkg/fuzzer/queue"
 	"github.com/google/syzkaller/pkg/gce"
 	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/kconfig"
 	"github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/mgrconfig"
 	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/record"
 	"github.com/google/syzkaller/pkg/report"
 	crash_pkg "github.com/google/syzkaller/pkg/report/crash"
 	"github.com/google/syzkaller/pkg/repro"
@@ -104,12 +107,14 @@ type Manager struct {
 	snapshotSource        *queue.Distributor
 	phase                 int
 	targetEnabledSyscalls map[*prog.Syscall]bool
+	disabledSyscalls      map[*prog.Syscall]string
+	configSuggestions     []*kconfig.Suggestion
 
 	disabledHashes   map[string]struct{}
 	newRepros        [][]byte
 	lastMinCorpus    int
 	memoryLeakFrames map[string]bool
-	dataRaceFrames   map[string]bool
+	dataRaces        map[string][]string
 	saturatedCalls   map[string]bool
 
 	externalReproQueue chan *Crash
@@ -158,6 +163,7 @@ type Crash struct {
 	fromHub       bool // this crash was created based on a repro from syz-hub
 	fromDashboard bool // .. or from dashboard
 	manual        bool
+	recordFile    string // recording of the VM run, if experimental record_vms is enabled
 	*report.Report
 }
 
@@ -247,7 +253,7 @@ func RunManager(mode Mode, cfg *mgrconfig.Config) {
 		crashTypes:         make(map[string]bool),
 		disabledHashes:     make(map[string]struct{}),
 		memoryLeakFrames:   make(map[string]bool),
-		dataRaceFrames:     make(map[string]bool),
+		dataRaces:          make(map[string][]string),
 		fresh:              true,
 		externalReproQueue: make(chan *Crash, 10),
 		crashes:            make(chan *Crash, 10),
@@ -454,7 +460,8 @@ func reportReproError(err error) {
 }
 
 func (mgr *Manager) runRepro(crash *Crash) *ReproResult {
-	res, stats, err := repro.Run(crash.Output, mgr.cfg, mgr.enabledFeatures, mgr.reporter, mgr.pool)
+	leakFrames, _ := mgr.BugFrames()
+	res, stats, err := repro.Run(crash.Output, mgr.cfg, mgr.enabledFeatures, mgr.reporter, mgr.pool, leakFrames)
 	ret := &ReproResult{
 		crash: crash,
 		repro: res,
@@ -749,15 +756,19 @@ func (mgr *Manager) fuzzerInstance(ctx context.Context, inst *vm.Instance, updIn
 		return
 	}
 	injectExec := make(chan bool, 10)
-	serv.CreateInstance(inst.Index(), injectExec, updInfo)
+	rec := mgr.createRecorder(inst.Index())
+	serv.CreateInstance(inst.Index(), injectExec, updInfo, rec)
 
-	rep, vmInfo, err := mgr.runInstanceInner(ctx, inst, injectExec, vm.EarlyFinishCb(func() {
+	rep, vmInfo, err := mgr.runInstanceInner(ctx, inst, injectExec, rec, vm.EarlyFinishCb(func() {
 		// Depending on the crash type and kernel config, fuzzing may continue
 		// running for several seconds even after kernel has printed a crash report.
 		// This litters the log and we want to prevent it.
 		serv.StopFuzzing(inst.Index())
 	}))
 	lastExec, machineInfo := serv.ShutdownInstance(inst.Index(), rep != nil)
+	if err := rec.Close(); err != nil {
+		log.Logf(0, "VM %v: failed to write the recording: %v", inst.Index(), err)
+	}
 	if rep != nil {
 		rpcserver.PrependExecuting(rep, lastExec)
 		if len(vmInfo) != 0 {
@@ -768,16 +779,37 @@ func (mgr *Manager) fuzzerInstance(ctx context.Context, inst *vm.Instance, updIn
 	if err == nil && rep != nil {
 		mgr.crashes <- &Crash{
 			instanceIndex: inst.Index(),
+			recordFile:    rec.File(),
 			Report:        rep,
 		}
+	} else if rec != nil {
+		os.Remove(rec.File())
 	}
 	if err != nil {
 		log.Logf(1, "VM %v: failed with error: %v", inst.Index(), err)
 	}
 }
 
+// createRecorder returns a recorder for a VM run, or nil if recording is not enabled.
+func (mgr *Manager) createRecorder(index int) *record.Recorder {
+	if !mgr.cfg.Experimental.RecordVMs {
+		return nil
+	}
+	dir := filepath.Join(mgr.cfg.Workdir, "records")
+	if err := osutil.MkdirAll(dir); err != nil {
+		log.Logf(0, "failed to create records dir: %v", err)
+		return nil
+	}
+	rec, err := record.New(filepath.Join(dir, fmt.Sprintf("vm%v-%v", index, time.Now().UnixNano())))
+	if err != nil {
+		log.Logf(0, "VM %v: failed to create recording: %v", index, err)
+		return nil
+	}
+	return rec
+}
+
 func (mgr *Manager) runInstanceInner(ctx context.Context, inst *vm.Instance, injectExec <-chan bool,
-	finishCb vm.EarlyFinishCb) (*report.Report, []byte, error) {
+	rec *record.Recorder, finishCb vm.EarlyFinishCb) (*report.Report, []byte, error) {
 	fwdAddr, err := inst.Forward(mgr.serv.Port)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to setup port forwarding: %w", err)
@@ -803,7 +835,7 @@ func (mgr *Manager) runInstanceInner(ctx context.Context, inst *vm.Instance, inj
 	cmd := fmt.Sprintf("%v runner %v %v %v", executorBin, inst.Index(), host, port)
 	_, rep, err := inst.Run(mgr.cfg.Timeouts.VMRunningTime, mgr.reporter, cmd,
 		vm.ExitTimeout, vm.StopContext(ctx), vm.InjectExecuting(injectExec),
-		finishCb,
+		finishCb, rec,
 	)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to run fuzzer: %w", err)
@@ -836,6 +868,10 @@ func (mgr *Manager) emailCrash(crash *Crash) {
 }
 
 func (mgr *Manager) saveCrash(crash *Crash) bool {
+	if crash.recordFile != "" {
+		// If the recording is saved below, it's moved to the crash dir and this is a no-op.
+		defer os.Remove(crash.recordFile)
+	}
 	if err := mgr.reporter.Symbolize(crash.Report); err != nil {
 		log.Errorf("failed to symbolize report: %v", err)
 	}
@@ -845,9 +881,15 @@ func (mgr *Manager) saveCrash(crash *Crash) bool {
 		mgr.mu.Unlock()
 	}
 	if crash.Type == crash_pkg.DataRace {
-		mgr.mu.Lock()
-		mgr.dataRaceFrames[crash.Frame] = true
-		mgr.mu.Unlock()
+		pair := crash_pkg.RacePair(crash.Title)
+		if pair == nil && crash.Frame != "" {
+			pair = []string{crash.Frame}
+		}
+		if pair != nil {
+			mgr.mu.Lock()
+			mgr.dataRaces[crash.Title] = pair
+			mgr.mu.Unlock()
+		}
 	}
 	flags := ""
 	if crash.Corrupted {
@@ -905,11 +947,19 @@ func (mgr *Manager) saveCrash(crash *Crash) bool {
 			log.Logf(0, "failed to report crash to dashboard: %v", err)
 		} else {
 			// Don't store the crash locally, if we've successfully
-			// uploaded it to the dashboard. These will just eat disk space.
+			// uploaded it to the dashboard (unless explicitly requested).
+			// These will just eat disk space.
+			if mgr.cfg.Experimental.KeepLocalCrashes {
+				mgr.saveLocalCrash(crash)
+			}
 			return mgr.cfg.Reproduce && resp.NeedRepro
 		}
 	}
+	mgr.saveLocalCrash(crash)
+	return mgr.needRepro(crash)
+}
 
+func (mgr *Manager) saveLocalCrash(crash *Crash) {
 	sig := hash.Hash([]byte(crash.Title))
 	id := sig.String()
 	dir := filepath.Join(mgr.crashdir, id)
@@ -949,7 +999,13 @@ func (mgr *Manager) saveCrash(crash *Crash) bool {
 	writeOrRemove("tag", []byte(mgr.cfg.Tag))
 	writeOrRemove("report", crash.Report.Report)
 	writeOrRemove("machineInfo", crash.MachineInfo)
-	return mgr.needRepro(crash)
+	recordFile := filepath.Join(dir, fmt.Sprintf("record%v", oldestI))
+	os.Remove(recordFile)
+	if crash.recordFile != "" {
+		if err := os.Rename(crash.recordFile, recordFile); err != nil {
+			log.Logf(0, "failed to save VM recording: %v", err)
+		}
+	}
 }
 
 const maxReproAttempts = 3
@@ -1109,7 +1165,7 @@ func (mgr *Manager) saveRepro(res *ReproResult) {
 		setGuiltyFiles(dc, report)
 		if _, err := mgr.dash.ReportCrash(dc); err != nil {
 			log.Logf(0, "failed to report repro to dashboard: %v", err)
-		} else {
+		} else if !mgr.cfg.Experimental.KeepLocalCrashes {
 			// Don't store the crash locally, if we've successfully
 			// uploaded it to the dashboard. These will just eat disk space.
 			return
@@ -1328,12 +1384,47 @@ func (mgr *Manager) BugFrames() (leaks, races []string) {
 	for frame := range mgr.memoryLeakFrames {
 		leaks = append(leaks, frame)
 	}
-	for frame := range mgr.dataRaceFrames {
-		races = append(races, frame)
+	var pairs [][]string
+	for _, pair := range mgr.dataRaces {
+		pairs = append(pairs, pair)
 	}
+	races = raceFilterFrames(pairs)
 	return
 }
 
+// raceFilterFrames returns functions for the KCSAN report filter that suppress all of the given races.
+// KCSAN skips a report if the top frame of either of the racing accesses is filtered, so it's enough
+// to filter one function of each pair. But filtering a function suppresses all other races
+// in that function as well, so we greedily choose functions that cover the most pairs.
+func raceFilterFrames(pairs [][]string) []string {
+	var frames []string
+	for len(pairs) != 0 {
+		count := make(map[string]int)
+		for _, pair := range pairs {
+			for i, frame := range pair {
+				if i == 0 || frame != pair[0] {
+					count[frame]++
+				}
+			}
+		}
+		best := ""
+		for frame, n := range count {
+			if best == "" || n > count[best] || n == count[best] && frame < best {
+				best = frame
+			}
+		}
+		frames = append(frames, best)
+		var rest [][]string
+		for _, pair := range pairs {
+			if !slices.Contains(pair, best) {
+				rest = append(rest, pair)
+			}
+		}
+		pairs = rest
+	}
+	return frames
+}
+
 func (mgr *Manager) MachineChecked(features flatrpc.Feature, enabledSyscalls map[*prog.Syscall]bool) queue.Source {
 	if len(enabledSyscalls) == 0 {
 		log.Fatalf("all system calls are disabled")
@@ -1352,6 +1443,14 @@ func (mgr *Manager) MachineChecked(features flatrpc.Feature, enabledSyscalls map
 	}
 	mgr.enabledFeatures = features
 	mgr.targetEnabledSyscalls = enabledSyscalls
+	disabledSyscalls, checkedFeatures := mgr.serv.MachineCheckResult()
+	mgr.disabledSyscalls = disabledSyscalls
+	go func() {
+		suggestions := suggestConfigs(mgr.cfg, disabledSyscalls, checkedFeatures)
+		mgr.mu.Lock()
+		defer mgr.mu.Unlock()
+		mgr.configSuggestions = suggestions
+	}()
 	mgr.firstConnect.Store(time.Now().Unix())
 	statSyscalls := stat.New("syscalls", "Number of enabled syscalls",
 		stat.Simple, stat.NoGraph, stat.Link("/syscalls"))
@@ -1361,6 +1460,11 @@ func (mgr *Manager) MachineChecked(features flatrpc.Feature, enabledSyscalls map
 	opts := mgr.defaultExecOpts()
 
 	if mgr.mode == ModeFuzzing {
+		areas, err := createFocusAreas(mgr.cfg, mgr.modules, enabledSyscalls)
+		if err != nil {
+			log.Fatalf("failed to init subsystem weights: %v", err)
+		}
+		mgr.corpus.SetFocusAreas(areas)
 		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
 		fuzzerObj := fuzzer.NewFuzzer(context.Background(), &fuzzer.Config{
 			Corpus:         mgr.corpus,
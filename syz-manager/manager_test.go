@@ -0,0 +1,27 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRaceFilterFrames(t *testing.T) {
+	assert.Empty(t, raceFilterFrames(nil))
+	// A single function covers all races it participates in.
+	assert.Equal(t, []string{"foo"}, raceFilterFrames([][]string{
+		{"bar", "foo"},
+		{"baz", "foo"},
+		{"foo", "qux"},
+	}))
+	assert.Equal(t, []string{"foo", "bar"}, raceFilterFrames([][]string{
+		{"bar", "baz"},
+		{"baz", "foo"},
+		{"foo", "qux"},
+		{"foo", "foo"},
+		{"bar"},
+	}))
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/google/syzkaller/pkg/flatrpc"
 	"github.com/google/syzkaller/pkg/fuzzer/queue"
 	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/rpcserver"
 	"github.com/google/syzkaller/vm"
 	"github.com/google/syzkaller/vm/dispatcher"
 )
@@ -45,23 +46,30 @@ func (mgr *Manager) snapshotLoop(ctx context.Context, inst *vm.Instance) error {
 	}
 
 	builder := flatbuffers.NewBuilder(0)
-	var envFlags flatrpc.ExecEnv
-	for first := true; ctx.Err() == nil; first = false {
+	// Each distinct set of env flags requires own executor handshake, so we keep a snapshot per env flags.
+	profiles := make(map[flatrpc.ExecEnv]int)
+	for ctx.Err() == nil {
 		queue.StatExecs.Add(1)
 		req := mgr.snapshotSource.Next(inst.Index())
-		if first {
-			envFlags = req.ExecOpts.EnvFlags
-			if err := mgr.snapshotSetup(inst, builder, envFlags); err != nil {
+		profile, ok := profiles[req.ExecOpts.EnvFlags]
+		if !ok {
+			if len(profiles) >= maxSnapshotProfiles {
+				req.Done(&queue.Result{
+					Status: queue.ExecFailure,
+					Err: fmt.Errorf("too many env flag profiles in snapshot mode, can't execute with env flags 0x%x",
+						req.ExecOpts.EnvFlags),
+				})
+				continue
+			}
+			profile = len(profiles)
+			if err := mgr.snapshotSetup(inst, builder, profile, req.ExecOpts.EnvFlags); err != nil {
 				req.Done(&queue.Result{Status: queue.Crashed})
 				return err
 			}
-		}
-		if envFlags != req.ExecOpts.EnvFlags {
-			panic(fmt.Sprintf("request env flags has changed: 0x%x -> 0x%x",
-				envFlags, req.ExecOpts.EnvFlags))
+			profiles[req.ExecOpts.EnvFlags] = profile
 		}
 
-		res, output, err := mgr.snapshotRun(inst, builder, req)
+		res, output, err := mgr.snapshotRun(inst, builder, profile, req)
 		if err != nil {
 			req.Done(&queue.Result{Status: queue.Crashed})
 			return err
@@ -82,7 +90,11 @@ func (mgr *Manager) snapshotLoop(ctx context.Context, inst *vm.Instance) error {
 	return nil
 }
 
-func (mgr *Manager) snapshotSetup(inst *vm.Instance, builder *flatbuffers.Builder, env flatrpc.ExecEnv) error {
+// Each snapshot profile consumes VM memory, so we limit the number of distinct env flag sets.
+const maxSnapshotProfiles = 4
+
+func (mgr *Manager) snapshotSetup(inst *vm.Instance, builder *flatbuffers.Builder, profile int,
+	env flatrpc.ExecEnv) error {
 	msg := flatrpc.SnapshotHandshakeT{
 		CoverEdges:       mgr.cfg.Experimental.CoverEdges,
 		Kernel64Bit:      mgr.cfg.SysTarget.PtrSize == 8,
@@ -95,10 +107,10 @@ func (mgr *Manager) snapshotSetup(inst *vm.Instance, builder *flatbuffers.Builde
 	}
 	builder.Reset()
 	builder.Finish(msg.Pack(builder))
-	return inst.SetupSnapshot(builder.FinishedBytes())
+	return inst.SetupSnapshot(profile, builder.FinishedBytes())
 }
 
-func (mgr *Manager) snapshotRun(inst *vm.Instance, builder *flatbuffers.Builder, req *queue.Request) (
+func (mgr *Manager) snapshotRun(inst *vm.Instance, builder *flatbuffers.Builder, profile int, req *queue.Request) (
 	*queue.Result, []byte, error) {
 	progData, err := req.Prog.SerializeForExec()
 	if err != nil {
@@ -121,7 +133,7 @@ func (mgr *Manager) snapshotRun(inst *vm.Instance, builder *flatbuffers.Builder,
 	builder.Finish(msg.Pack(builder))
 
 	start := time.Now()
-	resData, output, err := inst.RunSnapshot(builder.FinishedBytes())
+	resData, output, err := inst.RunSnapshot(profile, builder.FinishedBytes())
 	if err != nil {
 		return nil, nil, err
 	}
@@ -130,20 +142,12 @@ func (mgr *Manager) snapshotRun(inst *vm.Instance, builder *flatbuffers.Builder,
 	res := parseExecResult(resData)
 	if res.Info != nil {
 		res.Info.Elapsed = uint64(elapsed)
-		for len(res.Info.Calls) < len(req.Prog.Calls) {
-			res.Info.Calls = append(res.Info.Calls, &flatrpc.CallInfo{
-				Error: 999,
-			})
-		}
-		res.Info.Calls = res.Info.Calls[:len(req.Prog.Calls)]
-		if len(res.Info.ExtraRaw) != 0 {
-			res.Info.Extra = res.Info.ExtraRaw[0]
-			for _, info := range res.Info.ExtraRaw[1:] {
-				res.Info.Extra.Cover = append(res.Info.Extra.Cover, info.Cover...)
-				res.Info.Extra.Signal = append(res.Info.Extra.Signal, info.Signal...)
-			}
-			res.Info.ExtraRaw = nil
+		conv := rpcserver.InfoConverter{
+			Target:       mgr.cfg.SysTarget,
+			Cover:        mgr.cfg.Cover,
+			FilterSignal: true,
 		}
+		conv.Convert(req, res.Info)
 	}
 
 	ret := &queue.Result{
@@ -0,0 +1,46 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-dashboard-lite is a self-hosted dashboard that can be used by syz-ci and syz-manager
+// instead of the App Engine dashboard (set dashboard_addr to http://host:port).
+// The config is a JSON file with the dashboard/lite.Config contents, e.g.:
+//
+//	{
+//		"name": "local",
+//		"url": "http://dashboard.local:8080",
+//		"clients": [{"name": "ci", "key": "secret"}]
+//	}
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/google/syzkaller/dashboard/lite"
+	"github.com/google/syzkaller/pkg/config"
+	"github.com/google/syzkaller/pkg/log"
+)
+
+var (
+	flagConfig = flag.String("config", "", "config file")
+	flagHTTP   = flag.String("http", ":8080", "address to serve http on")
+	flagDB     = flag.String("db", "dashboard.db", "database file")
+)
+
+func main() {
+	flag.Parse()
+	cfg := new(lite.Config)
+	if err := config.LoadFile(*flagConfig, cfg); err != nil {
+		log.Fatal(err)
+	}
+	st, err := lite.LoadState(*flagDB)
+	if err != nil {
+		log.Fatalf("failed to load state: %v", err)
+	}
+	srv, err := lite.NewServer(cfg, st)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Logf(0, "serving http on http://%v", *flagHTTP)
+	log.Fatal(http.ListenAndServe(*flagHTTP, srv))
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-email parses .eml files the same way the dashboard parses incoming emails
+// and prints the #syz commands found in them, the actions they would cause and
+// problems with malformed commands.
+// Usage:
+//
+//	syz-email -emails "syzbot@syzkaller.appspotmail.com" reply.eml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/email"
+	"github.com/google/syzkaller/pkg/email/lore"
+	"github.com/google/syzkaller/pkg/tool"
+)
+
+var (
+	flagEmails  = flag.String("emails", "", "comma-separated list of own emails")
+	flagLists   = flag.String("lists", "", "comma-separated list of known mailing lists")
+	flagDomains = flag.String("domains", "", "comma-separated list of own domains")
+)
+
+func main() {
+	defer tool.Init()()
+	if flag.NArg() == 0 {
+		tool.Failf("specify at least one .eml file")
+	}
+	failed := false
+	for _, file := range flag.Args() {
+		ok, err := processFile(file)
+		if err != nil {
+			tool.Fail(err)
+		}
+		failed = failed || !ok
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// processFile prints the parsed email and returns false if it contains malformed commands.
+func processFile(file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	msg, err := email.Parse(f, splitList(*flagEmails), splitList(*flagLists), splitList(*flagDomains))
+	if err != nil {
+		return false, fmt.Errorf("%v: %w", file, err)
+	}
+	fmt.Printf("%v:\n", file)
+	fmt.Printf("  message-id:   %v\n", msg.MessageID)
+	fmt.Printf("  subject:      %v\n", msg.Subject)
+	fmt.Printf("  author:       %v\n", msg.Author)
+	fmt.Printf("  mailing list: %v\n", msg.MailingList)
+	fmt.Printf("  bug IDs:      %v\n", strings.Join(msg.BugIDs, ", "))
+	fmt.Printf("  patch:        %v\n", msg.Patch != "")
+	fmt.Printf("  discussion:   %v (%v)\n", email.NewMessageAction(msg, lore.DiscussionType(msg), nil),
+		lore.DiscussionType(msg))
+	if msg.OwnEmail {
+		fmt.Printf("  commands are not parsed in own emails\n")
+		return true, nil
+	}
+	if len(msg.Commands) == 0 {
+		fmt.Printf("  no commands\n")
+	}
+	ok := true
+	for _, cmd := range msg.Commands {
+		line := fmt.Sprintf("line %v", cmd.Line)
+		if cmd.Line == 0 {
+			line = "subject"
+		}
+		fmt.Printf("  %v: %v\n", line, strings.TrimSpace("#syz "+cmd.Str+" "+cmd.Args))
+		if cmd.Error != nil {
+			ok = false
+			fmt.Printf("    error: %v\n", cmd.Error)
+			continue
+		}
+		fmt.Printf("    action: %v\n", describeCommand(msg, cmd))
+	}
+	return ok, nil
+}
+
+func describeCommand(msg *email.Email, cmd *email.SingleCommand) string {
+	switch cmd.Command {
+	case email.CmdUpstream:
+		return "send the bug to the next reporting stage"
+	case email.CmdFix:
+		return fmt.Sprintf("mark the bug as fixed by commit %q", cmd.Args)
+	case email.CmdUnFix:
+		return "reset the fixing commits"
+	case email.CmdDup:
+		return fmt.Sprintf("mark the bug as a duplicate of %q", cmd.Args)
+	case email.CmdUnDup:
+		return "mark the bug as not a duplicate"
+	case email.CmdTest:
+		what := "the attached patch"
+		if msg.Patch == "" {
+			what = "the reproducer without a patch"
+		}
+		where := "the kernel tree where the bug was found"
+		if cmd.Args != "" {
+			where = cmd.Args
+		}
+		return fmt.Sprintf("test %v on %v", what, where)
+	case email.CmdInvalid:
+		return "mark the bug as invalid"
+	case email.CmdUnCC:
+		return fmt.Sprintf("remove %v from Cc", msg.Author)
+	case email.CmdSet:
+		return fmt.Sprintf("set label %q", cmd.Args)
+	case email.CmdUnset:
+		return fmt.Sprintf("unset label %q", cmd.Args)
+	case email.CmdRegenerate:
+		return "regenerate the bug list"
+	}
+	return fmt.Sprintf("unknown command %v", cmd.Command)
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
@@ -51,6 +51,8 @@ var (
 	flagSandboxArg = flag.Int("sandbox_arg", 0, "argument for sandbox runner to adjust it via config")
 	flagDebug      = flag.Bool("debug", false, "debug output from executor")
 	flagSlowdown   = flag.Int("slowdown", 1, "execution slowdown caused by emulation/instrumentation")
+	flagLeak       = flag.Bool("leak", false, "periodically check for memory leaks (requires kmemleak)")
+	flagLeakFrames = flag.String("leak_frames", "", "comma-separated list of frames of known memory leaks to ignore")
 
 	// The in the stress mode resembles simple unguided fuzzer.
 	// This mode can be used as an intermediate step when porting syzkaller to a new OS,
@@ -140,18 +142,25 @@ func main() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	var leakFrames []string
+	if *flagLeakFrames != "" {
+		leakFrames = strings.Split(*flagLeakFrames, ",")
+	}
 	rpcCtx, done := context.WithCancel(context.Background())
 	ctx := &Context{
-		target:    target,
-		done:      done,
-		progs:     progs,
-		rs:        rand.NewSource(time.Now().UnixNano()),
-		coverFile: *flagCoverFile,
-		output:    *flagOutput,
-		signal:    *flagSignal,
-		hints:     *flagHints,
-		stress:    *flagStress,
-		repeat:    *flagRepeat,
+		target:     target,
+		done:       done,
+		progs:      progs,
+		rs:         rand.NewSource(time.Now().UnixNano()),
+		coverFile:  *flagCoverFile,
+		output:     *flagOutput,
+		signal:     *flagSignal,
+		hints:      *flagHints,
+		stress:     *flagStress,
+		repeat:     *flagRepeat,
+		leak:       *flagLeak,
+		leakFrames: leakFrames,
+		leakNext:   time.Now().Add(leakCheckPeriod),
 		defaultOpts: flatrpc.ExecOpts{
 			EnvFlags:   env,
 			ExecFlags:  exec,
@@ -199,6 +208,13 @@ type Context struct {
 	hints       bool
 	stress      bool
 	repeat      int
+	leak        bool
+	leakFrames  []string
+	leakMu      sync.Mutex
+	leakNext    time.Time // time of the next leak scan
+	leakPause   bool      // new programs are not started until the leak scan is done
+	leakScan    bool      // the leak scan is in progress
+	running     int       // number of programs being executed in the leak mode
 	pos         int
 	completed   atomic.Uint64
 	resultIndex atomic.Int64
@@ -210,16 +226,79 @@ func (ctx *Context) machineChecked(features flatrpc.Feature, syscalls map[*prog.
 		ctx.choiceTable = ctx.target.BuildChoiceTable(ctx.progs, syscalls)
 	}
 	ctx.defaultOpts.EnvFlags |= csource.FeaturesToFlags(features, nil)
+	if ctx.leak {
+		if features&flatrpc.FeatureLeak == 0 {
+			log.Fatalf("leak checking is not supported on the machine")
+		}
+	}
 	return queue.DefaultOpts(ctx, ctx.defaultOpts)
 }
 
+// leakCheckPeriod is the period of kmemleak scans in the leak mode.
+// Each scan takes more than 5 seconds because of false positive mitigations in the executor.
+const leakCheckPeriod = 30 * time.Second
+
+// leakStart is called before a new program is started in the leak mode.
+// It returns false if the program must not be started because of a pending leak scan.
+// The scans are done only when no programs are running, so that objects
+// of half-finished programs are not reported as leaks.
+func (ctx *Context) leakStart() bool {
+	ctx.leakMu.Lock()
+	defer ctx.leakMu.Unlock()
+	if !ctx.leakPause && time.Now().After(ctx.leakNext) {
+		ctx.leakPause = true
+	}
+	if ctx.leakPause {
+		ctx.maybeCheckLeaksLocked()
+		return false
+	}
+	ctx.running++
+	return true
+}
+
+func (ctx *Context) leakDone() {
+	ctx.leakMu.Lock()
+	defer ctx.leakMu.Unlock()
+	ctx.running--
+	ctx.maybeCheckLeaksLocked()
+}
+
+func (ctx *Context) maybeCheckLeaksLocked() {
+	if ctx.leakPause && !ctx.leakScan && ctx.running == 0 {
+		ctx.leakScan = true
+		go ctx.checkLeaks()
+	}
+}
+
+// checkLeaks runs a kmemleak scan and resumes execution of programs.
+// Found leaks are printed as "BUG: memory leak" reports to the console, so that they are detected by the caller.
+func (ctx *Context) checkLeaks() {
+	cmd := osutil.Command(*flagExecutor, append([]string{"leak"}, ctx.leakFrames...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Logf(0, "leak check failed: %v", err)
+	}
+	ctx.leakMu.Lock()
+	defer ctx.leakMu.Unlock()
+	ctx.leakPause = false
+	ctx.leakScan = false
+	ctx.leakNext = time.Now().Add(leakCheckPeriod)
+}
+
 func (ctx *Context) Next() *queue.Request {
+	if ctx.leak && !ctx.leakStart() {
+		return nil
+	}
 	var p *prog.Prog
 	if ctx.stress {
 		p = ctx.createStressProg()
 	} else {
 		idx := ctx.getProgramIndex()
 		if idx < 0 {
+			if ctx.leak {
+				ctx.leakDone()
+			}
 			return nil
 		}
 		p = ctx.progs[idx]
@@ -244,6 +323,9 @@ func (ctx *Context) Next() *queue.Request {
 }
 
 func (ctx *Context) Done(req *queue.Request, res *queue.Result) bool {
+	if ctx.leak {
+		ctx.leakDone()
+	}
 	if res.Info != nil {
 		ctx.printCallResults(res.Info)
 		if ctx.hints {
@@ -0,0 +1,116 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-find-fix ranks candidate fixing commits for a bug that stopped reproducing
+// when fix bisection is not possible. Commits in the given range are scored by
+// the functions and files from the crash stack they modify, Fixes: tags and
+// Reported-by tags. The output is a ranked list of commits with justifications.
+// Usage:
+//
+//	syz-find-fix -kernel linux -range v6.5..v6.6 -report report.txt -bug 0123456789abcdef
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/pkg/tool"
+	"github.com/google/syzkaller/pkg/vcs"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+var (
+	flagOS     = flag.String("os", targets.Linux, "target OS")
+	flagArch   = flag.String("arch", targets.AMD64, "target arch")
+	flagKernel = flag.String("kernel", "", "kernel git checkout")
+	flagRange  = flag.String("range", "", "commit range to search (e.g. v6.5..v6.6 or <last crashing commit>..HEAD)")
+	flagReport = flag.String("report", "", "file with the symbolized crash report")
+	flagBug    = flag.String("bug", "", "syzbot bug ID used in Reported-by tags (optional)")
+	flagEmail  = flag.String("email", "syzbot@syzkaller.appspotmail.com", "email used in Reported-by tags")
+	flagTop    = flag.Int("top", 20, "number of candidates to print")
+	flagJSON   = flag.Bool("json", false, "print the candidates in JSON format")
+)
+
+func main() {
+	defer tool.Init()()
+	if *flagKernel == "" || *flagRange == "" || *flagReport == "" {
+		tool.Failf("-kernel, -range and -report are required")
+	}
+	data, err := os.ReadFile(*flagReport)
+	if err != nil {
+		tool.Fail(err)
+	}
+	reporter, err := report.NewReporter(&mgrconfig.Config{
+		Derived: mgrconfig.Derived{
+			TargetOS:   *flagOS,
+			TargetArch: *flagArch,
+			SysTarget:  targets.Get(*flagOS, *flagArch),
+		},
+	})
+	if err != nil {
+		tool.Fail(err)
+	}
+	frames := reporter.ReportToFrames(data)
+	if len(frames) == 0 {
+		tool.Failf("no symbolized frames found in the report")
+	}
+	repo, err := vcs.NewRepo(*flagOS, "", *flagKernel, vcs.OptPrecious, vcs.OptDontSandbox)
+	if err != nil {
+		tool.Fail(err)
+	}
+	lister, ok := repo.(vcs.ChangeLister)
+	if !ok {
+		tool.Failf("listing changes is not supported for %v", *flagOS)
+	}
+	commits, err := lister.ListChanges(*flagRange, frameFiles(guiltyFrames(frames)))
+	if err != nil {
+		tool.Failf("failed to list commits: %v", err)
+	}
+	if *flagBug != "" {
+		// Commits with the bug tag may not touch any of the frame files.
+		tagged, err := repo.ExtractFixTagsFromCommits(*flagRange, *flagEmail)
+		if err != nil {
+			tool.Failf("failed to extract fix tags: %v", err)
+		}
+		commits = mergeTagged(commits, tagged, *flagBug)
+	}
+	candidates := rankCommits(frames, commits, *flagBug)
+	if len(candidates) > *flagTop {
+		candidates = candidates[:*flagTop]
+	}
+	if *flagJSON {
+		out, err := json.MarshalIndent(candidates, "", "\t")
+		if err != nil {
+			tool.Fail(err)
+		}
+		os.Stdout.Write(append(out, '\n'))
+		return
+	}
+	if len(candidates) == 0 {
+		fmt.Printf("no candidates found among %v commits\n", len(commits))
+		return
+	}
+	for i, cand := range candidates {
+		fmt.Printf("%v. %v %v (score %v)\n", i+1, cand.Hash[:12], cand.Title, cand.Score)
+		fmt.Printf("   %v\n", strings.Join(cand.Reasons, "\n   "))
+	}
+}
+
+// mergeTagged adds commits tagged with the bug ID that are not in the list yet.
+func mergeTagged(commits []*vcs.CommitChanges, tagged []*vcs.Commit, bugID string) []*vcs.CommitChanges {
+	known := make(map[string]bool)
+	for _, com := range commits {
+		known[com.Hash] = true
+	}
+	for _, com := range tagged {
+		if !known[com.Hash] && stringInList(com.Tags, bugID) {
+			commits = append(commits, &vcs.CommitChanges{Commit: com})
+		}
+	}
+	return commits
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/pkg/vcs"
+)
+
+type Candidate struct {
+	Hash    string
+	Title   string
+	Date    time.Time
+	Score   int
+	Reasons []string
+}
+
+// Score weights. Frames closer to the top of the stack are more likely to be fixed.
+const (
+	scoreBugTag        = 100
+	scoreSyzbotTag     = 5
+	scoreFixesTag      = 10
+	scoreTitleFunction = 10
+	scoreFunction      = 30
+	scoreFile          = 10
+	// The score for a frame is reduced by this amount for every frame above it.
+	scoreFunctionStep = 5
+	scoreFileStep     = 2
+	maxTitleFrames    = 3
+)
+
+var (
+	fixesTagRe     = regexp.MustCompile(`(?im)^\s*Fixes:\s*[0-9a-f]{8,}`)
+	syzbotReportRe = regexp.MustCompile(`(?im)^\s*Reported-by:.*\bsyz(?:bot|kaller)\b`)
+)
+
+// guiltyFrames returns the frames that may be blamed for the crash.
+// If all frames are ignored (e.g. a crash in a common helper), all of them are returned.
+func guiltyFrames(frames []report.StackFrame) []report.StackFrame {
+	var ret []report.StackFrame
+	for _, frame := range frames {
+		if !frame.Ignored {
+			ret = append(ret, frame)
+		}
+	}
+	if len(ret) == 0 {
+		return frames
+	}
+	return ret
+}
+
+// frameFiles returns unique files of the frames in the order of appearance.
+func frameFiles(frames []report.StackFrame) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, frame := range frames {
+		if !seen[frame.File] {
+			seen[frame.File] = true
+			files = append(files, frame.File)
+		}
+	}
+	return files
+}
+
+// rankCommits scores the commits and returns the candidates with non-zero score, best first.
+// bugID is the syzbot bug ID that may be present in Reported-by tags (optional).
+func rankCommits(frames []report.StackFrame, commits []*vcs.CommitChanges, bugID string) []*Candidate {
+	frames = guiltyFrames(frames)
+	files := frameFiles(frames)
+	var candidates []*Candidate
+	for _, com := range commits {
+		cand := &Candidate{
+			Hash:  com.Hash,
+			Title: com.Title,
+			Date:  com.CommitDate,
+		}
+		add := func(score int, reason string, args ...interface{}) {
+			cand.Score += score
+			cand.Reasons = append(cand.Reasons, fmt.Sprintf("%v (+%v)", fmt.Sprintf(reason, args...), score))
+		}
+		if bugID != "" && (stringInList(com.Tags, bugID) || strings.Contains(com.Description, "+"+bugID+"@")) {
+			add(scoreBugTag, "Reported-by tag for the bug")
+		} else if syzbotReportRe.MatchString(com.Description) {
+			add(scoreSyzbotTag, "reported by syzbot")
+		}
+		if fixesTagRe.MatchString(com.Description) {
+			add(scoreFixesTag, "has a Fixes: tag")
+		}
+		seen := make(map[string]bool)
+		for i, frame := range frames {
+			if seen[frame.Function] || !stringInList(com.Functions, frame.Function) {
+				continue
+			}
+			seen[frame.Function] = true
+			add(max(scoreFunction-i*scoreFunctionStep, 1), "modifies %v() (frame #%v)",
+				frame.Function, i+1)
+		}
+		for i, file := range files {
+			if stringInList(com.Files, file) {
+				add(max(scoreFile-i*scoreFileStep, 1), "modifies %v", file)
+			}
+		}
+		for i := 0; i < len(frames) && i < maxTitleFrames; i++ {
+			if titleMentions(com.Title, frames[i].Function) {
+				add(scoreTitleFunction, "title mentions %v", frames[i].Function)
+				break
+			}
+		}
+		if cand.Score != 0 {
+			candidates = append(candidates, cand)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ci.Score != cj.Score {
+			return ci.Score > cj.Score
+		}
+		// Prefer older commits: the first fix is more likely to be the one.
+		return ci.Date.Before(cj.Date)
+	})
+	return candidates
+}
+
+func titleMentions(title, function string) bool {
+	re := regexp.MustCompile(`\b` + regexp.QuoteMeta(function) + `\b`)
+	return re.MatchString(title)
+}
+
+func stringInList(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
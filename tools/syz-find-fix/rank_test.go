@@ -0,0 +1,82 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/pkg/vcs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankCommits(t *testing.T) {
+	frames := []report.StackFrame{
+		{Function: "dump_stack", File: "lib/dump_stack.c", Ignored: true},
+		{Function: "foo_parse", File: "net/foo/parse.c"},
+		{Function: "foo_rcv", File: "net/foo/core.c"},
+		{Function: "sock_recvmsg", File: "net/socket.c"},
+	}
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	change := func(hash, title, desc string, files, funcs []string) *vcs.CommitChanges {
+		date = date.Add(time.Hour)
+		return &vcs.CommitChanges{
+			Commit: &vcs.Commit{
+				Hash:       hash,
+				Title:      title,
+				CommitDate: date,
+			},
+			Description: desc,
+			Files:       files,
+			Functions:   funcs,
+		}
+	}
+	commits := []*vcs.CommitChanges{
+		change("unrelated", "lib: update dump_stack", "",
+			[]string{"lib/dump_stack.c"}, []string{"dump_stack"}),
+		change("socket", "net: socket cleanup", "",
+			[]string{"net/socket.c"}, []string{"sock_recvmsg"}),
+		change("parse", "net/foo: fix out-of-bounds in foo_parse",
+			"Reported-by: syzbot+1234@syzkaller.appspotmail.com\nFixes: 0123456789ab (\"net/foo: add parser\")",
+			[]string{"net/foo/parse.c"}, []string{"foo_parse"}),
+		change("core", "net/foo: refactor", "",
+			[]string{"net/foo/core.c", "net/foo/parse.c"}, []string{"foo_rcv"}),
+		change("tagged", "net/foo: fix the bug", "Reported-by: syzbot+abcd@syzkaller.appspotmail.com",
+			nil, nil),
+	}
+	candidates := rankCommits(frames, commits, "abcd")
+	var got []string
+	for _, cand := range candidates {
+		got = append(got, cand.Hash)
+	}
+	assert.Equal(t, []string{"tagged", "parse", "core", "socket"}, got)
+	require.Len(t, candidates, 4)
+	assert.Equal(t, []string{
+		"reported by syzbot (+5)",
+		"has a Fixes: tag (+10)",
+		"modifies foo_parse() (frame #1) (+30)",
+		"modifies net/foo/parse.c (+10)",
+		"title mentions foo_parse (+10)",
+	}, candidates[1].Reasons)
+	assert.Equal(t, 65, candidates[1].Score)
+	assert.Equal(t, []string{
+		"modifies foo_rcv() (frame #2) (+25)",
+		"modifies net/foo/parse.c (+10)",
+		"modifies net/foo/core.c (+8)",
+	}, candidates[2].Reasons)
+}
+
+func TestMergeTagged(t *testing.T) {
+	commits := []*vcs.CommitChanges{{Commit: &vcs.Commit{Hash: "a"}}}
+	tagged := []*vcs.Commit{
+		{Hash: "a", Tags: []string{"bug"}},
+		{Hash: "b", Tags: []string{"bug"}},
+		{Hash: "c", Tags: []string{"other"}},
+	}
+	commits = mergeTagged(commits, tagged, "bug")
+	require.Len(t, commits, 2)
+	assert.Equal(t, "b", commits[1].Hash)
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-kconfsuggest suggests kernel configs that may enable syscalls and features
+// that were disabled by the syz-manager machine check.
+// The input is the "machine check" section of the syz-manager log
+// (disabled syscalls are printed there with -vv=1 or if enable_syscalls is set).
+// Example use:
+//
+//	$ syz-kconfsuggest -sourcedir /src/linux -config /src/linux/.config machine_check.log
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/pkg/flatrpc"
+	"github.com/google/syzkaller/pkg/kconfig"
+	"github.com/google/syzkaller/pkg/tool"
+	"github.com/google/syzkaller/pkg/vminfo"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func main() {
+	var (
+		flagOS           = flag.String("os", runtime.GOOS, "target OS")
+		flagArch         = flag.String("arch", runtime.GOARCH, "target arch")
+		flagDescriptions = flag.String("descriptions", "", "dir with syscall descriptions (default sys/$OS)")
+		flagSourceDir    = flag.String("sourcedir", "", "kernel sources dir (optional)")
+		flagConfig       = flag.String("config", "", "current kernel .config (optional)")
+	)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: syz-kconfsuggest [flags] machine_check.log\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	target, err := prog.GetTarget(*flagOS, *flagArch)
+	if err != nil {
+		tool.Fail(err)
+	}
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		tool.Fail(err)
+	}
+	disabled, features := parseMachineCheck(target, data)
+	if *flagDescriptions == "" {
+		*flagDescriptions = filepath.Join("sys", target.OS)
+	}
+	descriptions := ast.ParseGlob(filepath.Join(*flagDescriptions, "*.txt"), nil)
+	if descriptions == nil {
+		tool.Failf("failed to parse descriptions in %v", *flagDescriptions)
+	}
+	var kconf *kconfig.KConfig
+	if *flagSourceDir != "" {
+		kconf, err = kconfig.Parse(targets.Get(target.OS, target.Arch), filepath.Join(*flagSourceDir, "Kconfig"))
+		if err != nil {
+			tool.Fail(err)
+		}
+	}
+	var current *kconfig.ConfigFile
+	if *flagConfig != "" {
+		current, err = kconfig.ParseConfig(*flagConfig)
+		if err != nil {
+			tool.Fail(err)
+		}
+	}
+	candidates := vminfo.ConfigCandidates(target, disabled, features, descriptions)
+	for _, s := range kconfig.Suggest(candidates, kconf, current) {
+		fmt.Printf("CONFIG_%v", s.Config)
+		if s.Prompt != "" {
+			fmt.Printf(" (%v)", s.Prompt)
+		}
+		fmt.Printf(": %v\n", strings.Join(s.Items, " "))
+		if len(s.Deps) != 0 {
+			fmt.Printf("\tmissing dependencies: CONFIG_%v\n", strings.Join(s.Deps, " CONFIG_"))
+		}
+	}
+}
+
+// parseMachineCheck extracts disabled syscalls and feature check results
+// from "name: reason" lines printed by the manager.
+func parseMachineCheck(target *prog.Target, data []byte) (map[*prog.Syscall]string, vminfo.Features) {
+	disabled := make(map[*prog.Syscall]string)
+	features := make(vminfo.Features)
+	for s := bufio.NewScanner(bytes.NewReader(data)); s.Scan(); {
+		name, reason, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		name, reason = strings.TrimSpace(name), strings.TrimSpace(reason)
+		if call := target.SyscallMap[name]; call != nil {
+			disabled[call] = reason
+		} else if feat, ok := flatrpc.EnumValuesFeature[name]; ok {
+			features[feat] = vminfo.Feature{
+				Enabled: reason == "enabled",
+				Reason:  reason,
+			}
+		}
+	}
+	return disabled, features
+}
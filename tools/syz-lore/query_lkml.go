@@ -35,6 +35,7 @@ var (
 	flagAPIClient = flag.String("client", "", "the name of the API client")
 	flagAPIKey    = flag.String("key", "", "api key")
 	flagVerbose   = flag.Bool("v", false, "print more debug info")
+	flagIndex     = flag.String("index", "", "index file to update incrementally instead of re-reading archives")
 )
 
 func main() {
@@ -44,7 +45,12 @@ func main() {
 	}
 	emails := strings.Split(*flagEmails, ",")
 	domains := strings.Split(*flagDomains, ",")
-	threads := processArchives(*flagArchives, emails, domains)
+	var threads []*lore.Thread
+	if *flagIndex != "" {
+		threads = processIndex(*flagIndex, *flagArchives, emails, domains)
+	} else {
+		threads = processArchives(*flagArchives, emails, domains)
+	}
 	for i, thread := range threads {
 		messages := []dashapi.DiscussionMessage{}
 		for _, m := range thread.Messages {
@@ -100,6 +106,26 @@ func saveDiscussion(d *dashapi.Discussion) error {
 	return nil
 }
 
+func processIndex(file, dir string, emails, domains []string) []*lore.Thread {
+	idx, err := lore.LoadIndex(file)
+	if err != nil {
+		tool.Fail(err)
+	}
+	// Save what was indexed even if some messages failed to be read,
+	// the failed archives are not marked as indexed and are retried on the next run.
+	added, updateErr := idx.Update(dir, emails, domains)
+	log.Printf("indexed %d new messages (%d total)", added, len(idx.Messages))
+	if err := idx.Save(file); err != nil {
+		tool.Fail(err)
+	}
+	if updateErr != nil {
+		tool.Fail(updateErr)
+	}
+	threads := idx.Threads()
+	log.Printf("%d threads are related to syzbot", len(threads))
+	return threads
+}
+
 func processArchives(dir string, emails, domains []string) []*lore.Thread {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
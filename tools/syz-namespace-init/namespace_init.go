@@ -0,0 +1,17 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-namespace-init is started by the namespace vm type (see vm/namespace) in new namespaces.
+// It makes the host file system read-only except for the instance dir and runs the command.
+// It's not meant to be run manually.
+package main
+
+import (
+	"os"
+
+	"github.com/google/syzkaller/vm/namespace"
+)
+
+func main() {
+	os.Exit(namespace.InitMain(os.Args[1:]))
+}
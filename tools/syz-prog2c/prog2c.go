@@ -13,6 +13,7 @@ import (
 	"github.com/google/syzkaller/pkg/csource"
 	"github.com/google/syzkaller/prog"
 	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
 )
 
 var (
@@ -29,10 +30,13 @@ var (
 	flagHandleSegv = flag.Bool("segv", false, "catch and ignore SIGSEGV")
 	flagUseTmpDir  = flag.Bool("tmpdir", false, "create a temporary dir and execute inside it")
 	flagTrace      = flag.Bool("trace", false, "trace syscall results")
+	flagPretty     = flag.Bool("pretty", false, "use type information to make the program more readable")
 	flagStrict     = flag.Bool("strict", false, "parse input program in strict mode")
 	flagLeak       = flag.Bool("leak", false, "do leak checking")
 	flagEnable     = flag.String("enable", "none", "enable only listed additional features")
 	flagDisable    = flag.String("disable", "none", "enable all additional features except listed")
+	flagPortable   = flag.Bool("portable", false, "generate best-effort programs for all supported OSes "+
+		"and print portability diagnostics to stderr")
 )
 
 func main() {
@@ -95,6 +99,11 @@ func main() {
 		UseTmpDir:     *flagUseTmpDir,
 		HandleSegv:    *flagHandleSegv,
 		Trace:         *flagTrace,
+		Pretty:        *flagPretty,
+	}
+	if *flagPortable {
+		writePortable(p, opts)
+		return
 	}
 	src, err := csource.Write(p, opts)
 	if err != nil {
@@ -118,3 +127,44 @@ func main() {
 	os.Remove(bin)
 	fmt.Fprintf(os.Stderr, "binary build OK\n")
 }
+
+func writePortable(p *prog.Prog, opts csource.Options) {
+	failed := false
+	for _, OS := range csource.PortableOSes {
+		src, rep, err := csource.WriteBestEffort(p, opts, OS)
+		fmt.Fprintf(os.Stderr, "%v", rep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to generate C source for %v: %v\n", OS, err)
+			failed = true
+			continue
+		}
+		if formatted, err := csource.Format(src); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		} else {
+			src = formatted
+		}
+		fmt.Printf("// === %v/%v ===\n\n", rep.OS, rep.Arch)
+		os.Stdout.Write(src)
+		fmt.Printf("\n")
+		if !*flagBuild || targets.Get(rep.OS, rep.Arch).BuildOS != runtime.GOOS {
+			continue
+		}
+		target, err := prog.GetTarget(rep.OS, rep.Arch)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed = true
+			continue
+		}
+		bin, err := csource.Build(target, src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to build C source for %v: %v\n", OS, err)
+			failed = true
+			continue
+		}
+		os.Remove(bin)
+		fmt.Fprintf(os.Stderr, "%v/%v: binary build OK\n", rep.OS, rep.Arch)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-replay replays a VM run recorded by syz-manager (see experimental record_vms config option)
+// through the crash detection logic, and allows to understand why the run was classified as it was
+// without the kernel.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/record"
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/pkg/tool"
+	"github.com/google/syzkaller/vm"
+)
+
+var (
+	flagConfig = flag.String("config", "", "manager configuration file")
+	flagSpeed  = flag.Float64("speed", 10, "replay speed relative to the recorded timings")
+	flagDump   = flag.Bool("dump", false, "dump the recorded events instead of replaying them")
+	flagOutput = flag.Bool("output", false, "print the replayed console output and the report")
+)
+
+func main() {
+	flag.Parse()
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: syz-replay [flags] record_file\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	events, err := record.Load(flag.Args()[0])
+	if err != nil {
+		tool.Fail(err)
+	}
+	if *flagDump {
+		dump(events)
+		return
+	}
+	cfg, err := mgrconfig.LoadFile(*flagConfig)
+	if err != nil {
+		tool.Fail(err)
+	}
+	reporter, err := report.NewReporter(cfg)
+	if err != nil {
+		tool.Failf("failed to create reporter: %v", err)
+	}
+	recorded := "<missing>"
+	for _, ev := range events {
+		if ev.Kind == record.KindReport {
+			recorded = describe(string(ev.Data))
+		}
+	}
+	output, rep, err := vm.Replay(cfg, reporter, events, *flagSpeed)
+	if err != nil {
+		tool.Fail(err)
+	}
+	replayed := describe("")
+	if rep != nil {
+		replayed = describe(rep.Title)
+	}
+	fmt.Printf("recorded: %v\n", recorded)
+	fmt.Printf("replayed: %v\n", replayed)
+	if *flagOutput {
+		fmt.Printf("\nOUTPUT:\n")
+		os.Stdout.Write(output)
+		if rep != nil {
+			fmt.Printf("\nREPORT:\n")
+			os.Stdout.Write(rep.Report)
+		}
+	}
+}
+
+func describe(title string) string {
+	if title == "" {
+		return "no crash"
+	}
+	return title
+}
+
+func dump(events []*record.Event) {
+	for _, ev := range events {
+		fmt.Printf("%12.3f %-10v ", ev.Time.Seconds(), ev.Kind)
+		switch ev.Kind {
+		case record.KindRPCSend, record.KindRPCRecv:
+			fmt.Printf("%v bytes\n", len(ev.Data))
+		case record.KindExec:
+			exec := new(record.ExecEvent)
+			if err := json.Unmarshal(ev.Data, exec); err != nil {
+				fmt.Printf("bad event: %v\n", err)
+				continue
+			}
+			fmt.Printf("request %v\n%s\n", exec.ID, exec.Prog)
+		default:
+			fmt.Printf("%s\n", ev.Data)
+		}
+	}
+}
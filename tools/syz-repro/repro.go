@@ -65,7 +65,7 @@ func main() {
 	go pool.Loop(ctx)
 	defer done()
 
-	res, stats, err := repro.Run(data, cfg, flatrpc.AllFeatures, reporter, pool)
+	res, stats, err := repro.Run(data, cfg, flatrpc.AllFeatures, reporter, pool, nil)
 	if err != nil {
 		log.Logf(0, "reproduction failed: %v", err)
 	}
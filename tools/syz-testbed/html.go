@@ -133,10 +133,12 @@ type uiTable struct {
 	Extra     bool
 	HasFooter bool
 	AlignedBy string
+	Plots     []*uiPlot
 }
 
 const (
 	HTMLStatsTable         = "stats"
+	HTMLTimeSeriesTable    = "timeseries"
 	HTMLBugsTable          = "bugs"
 	HTMLBugCountsTable     = "bug_counts"
 	HTMLReprosTable        = "repros"
@@ -171,6 +173,7 @@ type uiMainPage struct {
 func (ctx *TestbedContext) getTableTypes() []uiTableType {
 	allTypeList := []uiTableType{
 		{HTMLStatsTable, "Statistics", ctx.httpMainStatsTable},
+		{HTMLTimeSeriesTable, "Time Series", ctx.httpTimeSeriesTable},
 		{HTMLBugsTable, "Bugs", ctx.genSimpleTableController((StatView).GenerateBugTable, true)},
 		{HTMLBugCountsTable, "Bug Counts", ctx.genSimpleTableController((StatView).GenerateBugCountsTable, false)},
 		{HTMLReprosTable, "Repros", ctx.genSimpleTableController((StatView).GenerateReproSuccessTable, true)},
@@ -240,6 +243,33 @@ func (ctx *TestbedContext) httpMainStatsTable(urlPrefix string, view StatView, r
 	}, nil
 }
 
+func (ctx *TestbedContext) httpTimeSeriesTable(urlPrefix string, view StatView, r *http.Request) (*uiTable, error) {
+	table, err := view.TimeSeriesTable()
+	if err != nil {
+		return nil, fmt.Errorf("time series table generation failed: %w", err)
+	}
+	baseColumn := r.FormValue("base_column")
+	if baseColumn != "" {
+		err := table.SetRelativeValues(baseColumn)
+		if err != nil {
+			log.Printf("failed to execute SetRelativeValues: %s", err)
+		}
+	}
+	return &uiTable{
+		Table: table,
+		Extra: baseColumn != "",
+		ColumnURL: func(column string) string {
+			if column == baseColumn {
+				return ""
+			}
+			v := url.Values{}
+			v.Set("base_column", column)
+			return urlPrefix + v.Encode()
+		},
+		Plots: view.TimeSeriesPlots(),
+	}, nil
+}
+
 func (ctx *TestbedContext) httpMain(w http.ResponseWriter, r *http.Request) {
 	activeView, err := ctx.getCurrentStatView(r)
 	if err != nil {
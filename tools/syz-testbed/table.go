@@ -27,7 +27,9 @@ type ValueCell struct {
 	Value         float64
 	Sample        *sample.Sample
 	PercentChange *float64
-	PValue        *float64
+	// Bootstrap confidence interval of PercentChange.
+	PercentCI *sample.Interval
+	PValue    *float64
 }
 
 type RatioCell struct {
@@ -157,6 +159,9 @@ func (t *Table) SaveAsCsv(fileName string) error {
 	return csv.NewWriter(f).WriteAll(t.ToStrings())
 }
 
+// Confidence level of the intervals calculated by SetRelativeValues.
+const relativeCILevel = 0.95
+
 func (t *Table) SetRelativeValues(baseColumn string) error {
 	for rowName, row := range t.Cells {
 		baseCell := t.Get(rowName, baseColumn)
@@ -176,13 +181,19 @@ func (t *Table) SetRelativeValues(baseColumn string) error {
 			if !ok {
 				continue
 			}
+			cellSample := valueCell.Sample.RemoveOutliers()
 			if baseValueCell.Value != 0 {
 				valueDiff := valueCell.Value - baseValueCell.Value
 				valueCell.PercentChange = new(float64)
 				*valueCell.PercentChange = valueDiff / baseValueCell.Value * 100
+				ci, err := sample.BootstrapMedianDiff(baseSample, cellSample, relativeCILevel)
+				if err == nil {
+					valueCell.PercentCI = &sample.Interval{
+						Lo: ci.Lo / baseValueCell.Value * 100,
+						Hi: ci.Hi / baseValueCell.Value * 100,
+					}
+				}
 			}
-
-			cellSample := valueCell.Sample.RemoveOutliers()
 			pval, err := sample.UTest(baseSample, cellSample)
 			if err == nil {
 				// Sometimes it fails because there are too few samples.
@@ -122,9 +122,10 @@ func (t *SyzManagerTarget) NewJob(slotName string, checkouts []*Checkout) (*Chec
 
 func (t *SyzManagerTarget) SupportsHTMLView(key string) bool {
 	supported := map[string]bool{
-		HTMLBugsTable:      true,
-		HTMLBugCountsTable: true,
-		HTMLStatsTable:     true,
+		HTMLBugsTable:       true,
+		HTMLBugCountsTable:  true,
+		HTMLStatsTable:      true,
+		HTMLTimeSeriesTable: true,
 	}
 	return supported[key]
 }
@@ -139,6 +140,7 @@ func (t *SyzManagerTarget) SaveStatView(view StatView, dir string) error {
 		"bugs.csv":           (StatView).GenerateBugTable,
 		"checkout_stats.csv": (StatView).StatsTable,
 		"instance_stats.csv": (StatView).InstanceStatsTable,
+		"timeseries.csv":     (StatView).TimeSeriesTable,
 	}
 	for fileName, genFunc := range tableStats {
 		table, err := genFunc(view)
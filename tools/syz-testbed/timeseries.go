@@ -0,0 +1,171 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/syzkaller/pkg/stat/sample"
+)
+
+// Time series metrics compare whole runs rather than just the final values.
+// All instances are cut at the same time (the shortest run in the view) to keep the comparison fair.
+const timeSeriesAxis = "uptime"
+
+// Stats for which we compare the areas under the curve.
+var timeSeriesFields = []string{"coverage", "corpus", "max signal", "crash types"}
+
+// For coverage we also compare the time needed to reach the given share of the final coverage.
+// The final coverage is the smallest median across all groups, so that all groups can reach it.
+const timeToField = "coverage"
+
+var timeToShares = []float64{0.5, 0.9}
+
+// The number of points on time series plots.
+const plotPoints = 100
+
+type uiPlot struct {
+	ID     string
+	Title  string
+	Lines  []string
+	Points []uiPlotPoint
+}
+
+type uiPlotPoint struct {
+	X float64
+	Y []float64
+}
+
+// groupSeries returns time series of the field for each instance of the group.
+func (group RunResultGroup) groupSeries(field string) []*sample.Series {
+	var ret []*sample.Series
+	for _, result := range group.SyzManagerResults() {
+		series := &sample.Series{}
+		for _, record := range result.StatRecords {
+			x, okX := record[timeSeriesAxis]
+			y, okY := record[field]
+			if !okX || !okY || float64(x) < series.MaxX() {
+				continue
+			}
+			series.Xs = append(series.Xs, float64(x))
+			series.Ys = append(series.Ys, float64(y))
+		}
+		if series.Len() >= 2 {
+			ret = append(ret, series)
+		}
+	}
+	return ret
+}
+
+// timeSeriesHorizon returns the duration covered by all instances of the view.
+func (view StatView) timeSeriesHorizon() float64 {
+	horizon := math.Inf(1)
+	for _, group := range view.Groups {
+		for _, series := range group.groupSeries(timeSeriesAxis) {
+			horizon = math.Min(horizon, series.MaxX())
+		}
+	}
+	if math.IsInf(horizon, 1) {
+		return 0
+	}
+	return horizon
+}
+
+// TimeSeriesTable compares the time series of the main fuzzing stats.
+// AUC values are normalized by the duration, i.e. they are the average values over time.
+// "Time to" values are in seconds; instances that never reached the value are counted with their full duration.
+func (view StatView) TimeSeriesTable() (*Table, error) {
+	table := NewTable("Metric")
+	for _, group := range view.Groups {
+		table.AddColumn(group.Name)
+	}
+	horizon := view.timeSeriesHorizon()
+	if horizon == 0 {
+		return table, nil
+	}
+	for _, field := range timeSeriesFields {
+		row := fmt.Sprintf("%v AUC", field)
+		for _, group := range view.Groups {
+			s := &sample.Sample{}
+			for _, series := range group.groupSeries(field) {
+				s.Xs = append(s.Xs, series.AreaUnderCurve(horizon)/horizon)
+			}
+			if len(s.Xs) != 0 {
+				table.Set(row, group.Name, NewValueCell(s))
+			}
+		}
+	}
+	target := math.Inf(1)
+	for _, group := range view.Groups {
+		final := &sample.Sample{}
+		for _, series := range group.groupSeries(timeToField) {
+			final.Xs = append(final.Xs, series.ValueAt(horizon))
+		}
+		if len(final.Xs) != 0 {
+			target = math.Min(target, final.Median())
+		}
+	}
+	if math.IsInf(target, 1) || target == 0 {
+		return table, nil
+	}
+	for _, share := range timeToShares {
+		row := fmt.Sprintf("time to %.0f%% %v (%.0f)", share*100, timeToField, target*share)
+		for _, group := range view.Groups {
+			s := &sample.Sample{}
+			for _, series := range group.groupSeries(timeToField) {
+				x, ok := series.FirstReach(target * share)
+				if !ok {
+					x = series.MaxX()
+				}
+				s.Xs = append(s.Xs, x)
+			}
+			if len(s.Xs) != 0 {
+				table.Set(row, group.Name, NewValueCell(s))
+			}
+		}
+	}
+	return table, nil
+}
+
+// TimeSeriesPlots returns plots of the median values of the time series fields for each group.
+func (view StatView) TimeSeriesPlots() []*uiPlot {
+	horizon := view.timeSeriesHorizon()
+	if horizon == 0 {
+		return nil
+	}
+	var plots []*uiPlot
+	for i, field := range timeSeriesFields {
+		plot := &uiPlot{
+			ID:    fmt.Sprintf("plot%v", i),
+			Title: fmt.Sprintf("%v (median) over %v", field, timeSeriesAxis),
+		}
+		var groupSeries [][]*sample.Series
+		for _, group := range view.Groups {
+			series := group.groupSeries(field)
+			if len(series) == 0 {
+				continue
+			}
+			plot.Lines = append(plot.Lines, group.Name)
+			groupSeries = append(groupSeries, series)
+		}
+		if len(groupSeries) == 0 {
+			continue
+		}
+		for p := 0; p <= plotPoints; p++ {
+			x := horizon * float64(p) / plotPoints
+			point := uiPlotPoint{X: math.Round(x)}
+			for _, list := range groupSeries {
+				s := &sample.Sample{}
+				for _, series := range list {
+					s.Xs = append(s.Xs, series.ValueAt(x))
+				}
+				point.Y = append(point.Y, s.Median())
+			}
+			plot.Points = append(plot.Points, point)
+		}
+		plots = append(plots, plot)
+	}
+	return plots
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeSeries(t *testing.T) {
+	// Coverage grows linearly with the given speed up to 1000.
+	result := func(speed uint64, minutes int) RunResult {
+		res := &SyzManagerResult{}
+		for i := 0; i <= minutes; i++ {
+			uptime := uint64(i * 60)
+			res.StatRecords = append(res.StatRecords, StatRecord{
+				"uptime":   uptime,
+				"coverage": min(uptime*speed, 1000),
+				"corpus":   uint64(i),
+			})
+		}
+		return res
+	}
+	view := StatView{
+		Groups: []RunResultGroup{
+			{
+				Name:    "slow",
+				Results: []RunResult{result(1, 20), result(1, 25), result(1, 30)},
+			},
+			{
+				Name:    "fast",
+				Results: []RunResult{result(2, 20), result(2, 20), result(2, 20)},
+			},
+		},
+	}
+	table, err := view.TimeSeriesTable()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"corpus AUC",
+		"coverage AUC",
+		"time to 50% coverage (500)",
+		"time to 90% coverage (900)",
+	}, table.SortedRows())
+	value := func(row, column string) float64 {
+		return table.Get(row, column).(*ValueCell).Value
+	}
+	// The runs are cut at 20 minutes.
+	assert.InDelta(t, 10, value("corpus AUC", "slow"), 0.01)
+	assert.InDelta(t, 10, value("corpus AUC", "fast"), 0.01)
+	// Slow: 1000s*1000/2 + 200s*1000, fast: 500s*1000/2 + 700s*1000 (minus the interpolation error
+	// because the records are taken once a minute).
+	assert.InDelta(t, 583, value("coverage AUC", "slow"), 0.01)
+	assert.InDelta(t, 791, value("coverage AUC", "fast"), 0.01)
+	assert.InDelta(t, 500, value("time to 50% coverage (500)", "slow"), 0.01)
+	assert.InDelta(t, 250, value("time to 50% coverage (500)", "fast"), 0.01)
+	assert.InDelta(t, 900, value("time to 90% coverage (900)", "slow"), 0.01)
+	assert.InDelta(t, 450, value("time to 90% coverage (900)", "fast"), 0.01)
+
+	require.NoError(t, table.SetRelativeValues("slow"))
+	cell := table.Get("coverage AUC", "fast").(*ValueCell)
+	require.NotNil(t, cell.PercentCI)
+	assert.InDelta(t, 35.68, *cell.PercentChange, 0.01)
+	assert.True(t, cell.PercentCI.Contains(*cell.PercentChange))
+
+	plots := view.TimeSeriesPlots()
+	require.Len(t, plots, 2)
+	assert.Equal(t, []string{"slow", "fast"}, plots[0].Lines)
+	assert.Len(t, plots[0].Points, plotPoints+1)
+	last := plots[0].Points[plotPoints]
+	assert.Equal(t, 1200.0, last.X)
+	assert.Equal(t, []float64{1000, 1000}, last.Y)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, mainTemplate.ExecuteTemplate(buf, "table.html", &uiTable{
+		Table: table,
+		Extra: true,
+		Plots: plots,
+	}))
+	assert.Contains(t, buf.String(), "div_plot0")
+}
@@ -0,0 +1,281 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package namespace runs the executor directly on the host inside of new Linux
+// user, mount, pid, network, ipc and uts namespaces, without any hypervisor.
+// It's meant for fuzzing of user-space-facing interfaces and for testing of the fuzzing
+// pipeline itself. The root user in the namespace is mapped to the user that runs the manager,
+// so the manager must be run as an unprivileged user. The manager config must also
+// contain an explicit enable_syscalls allowlist (see mgrconfig), so that the fuzzer
+// does not harm the host.
+//
+// The commands are started via the syz-namespace-init helper binary (see tools/syz-namespace-init),
+// which sets up the mounts in the new namespaces before running the command.
+package namespace
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/syzkaller/pkg/config"
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/sys/targets"
+	"github.com/google/syzkaller/vm/vmimpl"
+)
+
+func init() {
+	vmimpl.Register("namespace", vmimpl.Type{
+		Ctor:       ctor,
+		Overcommit: true,
+	})
+}
+
+type Config struct {
+	Count int `json:"count"` // number of VMs to use
+	// Path to the syz-namespace-init binary, by default it's looked up next to the current binary.
+	InitBin string `json:"init_bin"`
+}
+
+type Pool struct {
+	env *vmimpl.Env
+	cfg *Config
+}
+
+type instance struct {
+	dir    string
+	bin    string
+	port   int
+	merger *vmimpl.OutputMerger
+}
+
+func ctor(env *vmimpl.Env) (vmimpl.Pool, error) {
+	cfg := &Config{
+		Count: 1,
+	}
+	if err := config.LoadData(env.Config, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse vm config: %w", err)
+	}
+	if cfg.Count < 1 || cfg.Count > 128 {
+		return nil, fmt.Errorf("invalid config param count: %v, want [1, 128]", cfg.Count)
+	}
+	if env.OS != targets.Linux || runtime.GOOS != targets.Linux || env.Arch != runtime.GOARCH {
+		return nil, fmt.Errorf("namespace vm type supports only %v/%v targets on this host",
+			targets.Linux, runtime.GOARCH)
+	}
+	if _, err := sysProcAttr(); err != nil {
+		return nil, err
+	}
+	if env.Debug && cfg.Count > 1 {
+		log.Logf(0, "limiting number of VMs from %v to 1 in debug mode", cfg.Count)
+		cfg.Count = 1
+	}
+	if cfg.InitBin == "" {
+		self, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		cfg.InitBin = filepath.Join(filepath.Dir(self), initBinName)
+	}
+	bin, err := filepath.Abs(cfg.InitBin)
+	if err != nil {
+		return nil, err
+	}
+	if !osutil.IsExist(bin) {
+		return nil, fmt.Errorf("%v does not exist (build it with 'make namespace_init')", bin)
+	}
+	cfg.InitBin = bin
+	pool := &Pool{
+		cfg: cfg,
+		env: env,
+	}
+	return pool, nil
+}
+
+func (pool *Pool) Count() int {
+	return pool.cfg.Count
+}
+
+func (pool *Pool) Create(workdir string, index int) (vmimpl.Instance, error) {
+	dir := filepath.Join(workdir, "root")
+	if err := osutil.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	var tee io.Writer
+	if pool.env.Debug {
+		tee = os.Stdout
+	}
+	inst := &instance{
+		dir:    dir,
+		bin:    pool.cfg.InitBin,
+		merger: vmimpl.NewOutputMerger(tee),
+	}
+	return inst, nil
+}
+
+func (inst *instance) Info() ([]byte, error) {
+	return []byte(fmt.Sprintf("%v namespaces in %v\n", strings.Join(namespaceNames, ","), inst.dir)), nil
+}
+
+func (inst *instance) Close() error {
+	inst.merger.Wait()
+	return nil
+}
+
+func (inst *instance) Forward(port int) (string, error) {
+	if inst.port != 0 {
+		return "", fmt.Errorf("forward port is already setup")
+	}
+	inst.port = port
+	// The network namespace is not connected to the host,
+	// so the connection to the manager is passed in stdin.
+	return "stdin:0", nil
+}
+
+func (inst *instance) Copy(hostSrc string) (string, error) {
+	dst := filepath.Join(inst.dir, filepath.Base(hostSrc))
+	if err := osutil.CopyFile(hostSrc, dst); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(dst, 0777); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+func (inst *instance) Run(timeout time.Duration, stop <-chan bool, command string) (
+	<-chan []byte, <-chan error, error) {
+	args := append([]string{"-dir", inst.dir}, strings.Fields(command)...)
+	cmd := osutil.Command(inst.bin, args...)
+	cmd.Dir = inst.dir
+	attr, err := sysProcAttr()
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd.SysProcAttr = attr
+
+	rpipe, wpipe, err := osutil.LongPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer wpipe.Close()
+	inst.merger.Add("cmd", rpipe)
+	cmd.Stdout = wpipe
+	cmd.Stderr = wpipe
+
+	guestSock, err := inst.guestProxy()
+	if err != nil {
+		return nil, nil, err
+	}
+	if guestSock != nil {
+		defer guestSock.Close()
+		cmd.Stdin = guestSock
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	errc := make(chan error, 1)
+	signal := func(err error) {
+		select {
+		case errc <- err:
+		default:
+		}
+	}
+	go func() {
+		select {
+		case <-time.After(timeout):
+			signal(vmimpl.ErrTimeout)
+		case <-stop:
+			signal(vmimpl.ErrTimeout)
+		case err := <-inst.merger.Err:
+			cmd.Process.Kill()
+			if cmdErr := cmd.Wait(); cmdErr == nil {
+				// If the command exited successfully, we got EOF error from merger.
+				// But in this case no error has happened and the EOF is expected.
+				err = nil
+			}
+			signal(err)
+			return
+		}
+		// Killing init of the pid namespace kills all processes in the namespace.
+		cmd.Process.Kill()
+		err := cmd.Wait()
+		log.Logf(1, "namespace instance in %v exited with %v", inst.dir, err)
+	}()
+	return inst.merger.Output, errc, nil
+}
+
+func (inst *instance) guestProxy() (*os.File, error) {
+	if inst.port == 0 {
+		return nil, nil
+	}
+	socks, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	hostSock := os.NewFile(uintptr(socks[0]), "host unix proxy")
+	guestSock := os.NewFile(uintptr(socks[1]), "guest unix proxy")
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%v", inst.port))
+	if err != nil {
+		hostSock.Close()
+		guestSock.Close()
+		return nil, err
+	}
+	go func() {
+		io.Copy(hostSock, conn)
+		hostSock.Close()
+	}()
+	go func() {
+		io.Copy(conn, hostSock)
+		conn.Close()
+	}()
+	return guestSock, nil
+}
+
+func (inst *instance) Diagnose(rep *report.Report) ([]byte, bool) {
+	// There is no kernel of our own, and the host kernel log is not accessible from the namespaces.
+	return nil, false
+}
+
+const initBinName = "syz-namespace-init"
+
+// InitMain is the entry point of the syz-namespace-init binary.
+// It runs as pid 1 in the new namespaces: it sets up mounts and runs the command.
+// Usage: syz-namespace-init -dir instance_dir command [args...].
+func InitMain(args []string) int {
+	flags := flag.NewFlagSet(initBinName, flag.ContinueOnError)
+	dir := flags.String("dir", "", "instance dir that stays writable")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if *dir == "" || flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "SYZFAIL: usage: %v -dir instance_dir command [args...]\n", initBinName)
+		return 1
+	}
+	if err := setupMounts(*dir); err != nil {
+		fmt.Fprintf(os.Stderr, "SYZFAIL: failed to setup namespace mounts: %v\n", err)
+		return 1
+	}
+	cmd := exec.Command(flags.Arg(0), flags.Args()[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "SYZFAIL: failed to run %v: %v\n", flags.Arg(0), err)
+		return 1
+	}
+	return 0
+}
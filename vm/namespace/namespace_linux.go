@@ -0,0 +1,135 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package namespace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var namespaceNames = []string{"user", "mnt", "pid", "net", "ipc", "uts"}
+
+func sysProcAttr() (*syscall.SysProcAttr, error) {
+	// Root in the namespace has the host uid, which is enough to write to the global
+	// /proc and /sys files (e.g. sysctls) if the host uid is root.
+	if os.Getuid() == 0 || os.Getgid() == 0 {
+		return nil, fmt.Errorf("namespace vm type can't be used by root, run syz-manager as a regular user")
+	}
+	return &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		// Root in the namespace is the current user on the host.
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}, nil
+}
+
+// setupMounts makes the host file system (including all submounts like /home or /run) read-only
+// for the namespace except for the instance dir, and mounts fresh /proc, /tmp and /dev.
+// The parts of /proc that control the whole host are read-only as well.
+func setupMounts(dir string) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	// Bind-mount the instance dir onto itself first, so that it's a separate mount
+	// that can stay writable after all other mounts are made read-only.
+	if err := unix.Mount(dir, dir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to bind-mount %v: %w", dir, err)
+	}
+	// Unlike MS_REMOUNT|MS_RDONLY, mount_setattr with AT_RECURSIVE changes all submounts at once.
+	err := unix.MountSetattr(unix.AT_FDCWD, "/", unix.AT_RECURSIVE,
+		&unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY})
+	if err == unix.ENOSYS {
+		return fmt.Errorf("mount_setattr is not supported, namespace vm type requires Linux 5.12+")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to make mounts read-only: %w", err)
+	}
+	err = unix.MountSetattr(unix.AT_FDCWD, dir, 0, &unix.MountAttr{Attr_clr: unix.MOUNT_ATTR_RDONLY})
+	if err != nil {
+		return fmt.Errorf("failed to make %v writable: %w", dir, err)
+	}
+	if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("failed to mount /proc: %w", err)
+	}
+	if err := setupProc(); err != nil {
+		return err
+	}
+	// A fresh /tmp would hide the instance dir if it's inside of /tmp.
+	if !strings.HasPrefix(filepath.Clean(dir)+"/", "/tmp/") {
+		if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+			return fmt.Errorf("failed to mount /tmp: %w", err)
+		}
+	}
+	return setupDev()
+}
+
+// procReadOnly are /proc entries that control the host rather than the processes in the namespace.
+var procReadOnly = []string{"bus", "fs", "irq", "sys", "sysrq-trigger"}
+
+// setupProc bind-mounts the host-wide /proc entries onto themselves to make them read-only.
+func setupProc() error {
+	for _, name := range procReadOnly {
+		path := filepath.Join("/proc", name)
+		if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			if err == unix.ENOENT {
+				continue
+			}
+			return fmt.Errorf("failed to bind-mount %v: %w", path, err)
+		}
+		err := unix.MountSetattr(unix.AT_FDCWD, path, unix.AT_RECURSIVE,
+			&unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY})
+		if err != nil {
+			return fmt.Errorf("failed to make %v read-only: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// devNodes are host device nodes that are available in the namespace.
+var devNodes = []string{"null", "zero", "full", "random", "urandom"}
+
+// setupDev mounts a private /dev with only the harmless host device nodes.
+// Read-only mounts don't prevent writes to device nodes, so the host /dev must not be accessible.
+func setupDev() error {
+	hostDev, err := unix.Open("/dev", unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open /dev: %w", err)
+	}
+	defer unix.Close(hostDev)
+	if err := unix.Mount("tmpfs", "/dev", "tmpfs", unix.MS_NOSUID|unix.MS_NOEXEC, "mode=755"); err != nil {
+		return fmt.Errorf("failed to mount /dev: %w", err)
+	}
+	for _, name := range devNodes {
+		node := filepath.Join("/dev", name)
+		if err := os.WriteFile(node, nil, 0666); err != nil {
+			return err
+		}
+		// The new /dev hides the host nodes, so they are bind-mounted via the opened host /dev.
+		src := fmt.Sprintf("/proc/self/fd/%v/%v", hostDev, name)
+		if err := unix.Mount(src, node, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("failed to bind-mount %v: %w", node, err)
+		}
+	}
+	if err := os.Mkdir("/dev/shm", 0777|os.ModeSticky); err != nil {
+		return err
+	}
+	for name, target := range map[string]string{
+		"fd":     "/proc/self/fd",
+		"stdin":  "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1",
+		"stderr": "/proc/self/fd/2",
+	} {
+		if err := os.Symlink(target, filepath.Join("/dev", name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+//go:build !linux
+
+package namespace
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+var namespaceNames []string
+
+func sysProcAttr() (*syscall.SysProcAttr, error) {
+	return nil, fmt.Errorf("namespaces are not supported on %v", runtime.GOOS)
+}
+
+func setupMounts(dir string) error {
+	return fmt.Errorf("namespaces are not supported on %v", runtime.GOOS)
+}
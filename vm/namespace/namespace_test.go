@@ -0,0 +1,179 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+//go:build linux
+
+package namespace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/vm/vmimpl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// The test binary re-executes itself in the new namespaces.
+const testModeEnv = "SYZ_NAMESPACE_TEST"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(testModeEnv) {
+	case "init":
+		os.Exit(InitMain(os.Args[1:]))
+	case "mounts":
+		if err := checkMounts(os.Args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestMounts(t *testing.T) {
+	base := t.TempDir()
+	cmd := exec.Command(os.Args[0], base)
+	cmd.Env = append(os.Environ(), testModeEnv+"=mounts")
+	cmd.SysProcAttr = namespaceAttr(t)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "%s", out)
+	// The host must not see the changes.
+	assert.False(t, osutil.IsExist(filepath.Join(base, "sub", "file")))
+	assert.True(t, osutil.IsExist(filepath.Join(base, "root", "file")))
+}
+
+// checkMounts runs in the new namespaces, it creates a submount under base
+// and checks that setupMounts makes both the submount and the parent mount read-only.
+func checkMounts(base string) error {
+	sub := filepath.Join(base, "sub")
+	if err := osutil.MkdirAll(sub); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", sub, "tmpfs", 0, ""); err != nil {
+		return fmt.Errorf("failed to mount tmpfs: %w", err)
+	}
+	if err := osutil.WriteFile(filepath.Join(sub, "file"), nil); err != nil {
+		return err
+	}
+	// The dirs can be hidden by the new /tmp after setupMounts, so open them in advance.
+	var fds []int
+	for _, dir := range []string{base, sub} {
+		fd, err := unix.Open(dir, unix.O_DIRECTORY|unix.O_RDONLY, 0)
+		if err != nil {
+			return err
+		}
+		fds = append(fds, fd)
+	}
+	root := filepath.Join(base, "root")
+	if err := osutil.MkdirAll(root); err != nil {
+		return err
+	}
+	if err := setupMounts(root); err != nil {
+		return err
+	}
+	for _, fd := range fds {
+		file, err := unix.Openat(fd, "new", unix.O_CREAT|unix.O_WRONLY, 0600)
+		if err == nil {
+			unix.Close(file)
+		}
+		if err != unix.EROFS {
+			return fmt.Errorf("created file on a mount that must be read-only: %v", err)
+		}
+	}
+	// Only the harmless host device nodes are available.
+	entries, err := os.ReadDir("/dev")
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{"fd", "full", "null", "random", "shm", "stderr", "stdin", "stdout", "urandom", "zero"}
+	if !reflect.DeepEqual(names, want) {
+		return fmt.Errorf("unexpected /dev contents: %v, want %v", names, want)
+	}
+	if err := osutil.WriteFile("/dev/null", []byte("data")); err != nil {
+		return err
+	}
+	// The host-wide /proc files can't be written, even the ones that root in the namespace owns.
+	for _, file := range []string{"/proc/sysrq-trigger", "/proc/sys/kernel/hostname", "/proc/sys/net/ipv4/ip_forward"} {
+		fd, err := unix.Open(file, unix.O_WRONLY, 0)
+		if err == nil {
+			unix.Close(fd)
+			return fmt.Errorf("opened %v for writing", file)
+		}
+	}
+	return osutil.WriteFile(filepath.Join(root, "file"), nil)
+}
+
+func TestRun(t *testing.T) {
+	namespaceAttr(t)
+	t.Setenv(testModeEnv, "init")
+	pool, err := ctor(&vmimpl.Env{
+		Name:   "test",
+		OS:     runtime.GOOS,
+		Arch:   runtime.GOARCH,
+		Config: []byte(fmt.Sprintf(`{"init_bin": %q}`, os.Args[0])),
+	})
+	require.NoError(t, err)
+	inst, err := pool.Create(t.TempDir(), 0)
+	require.NoError(t, err)
+	defer inst.Close()
+	outc, errc, err := inst.Run(time.Minute, nil, "id -u")
+	require.NoError(t, err)
+	var output []byte
+	for done := false; !done; {
+		select {
+		case out := <-outc:
+			output = append(output, out...)
+		case err := <-errc:
+			require.NoError(t, err)
+			done = true
+		}
+	}
+	assert.Equal(t, "0", strings.TrimSpace(string(output)))
+}
+
+func TestInitBinMissing(t *testing.T) {
+	namespaceAttr(t)
+	_, err := ctor(&vmimpl.Env{
+		Name:   "test",
+		OS:     runtime.GOOS,
+		Arch:   runtime.GOARCH,
+		Config: []byte(`{"init_bin": "/non/existent/syz-namespace-init"}`),
+	})
+	assert.ErrorContains(t, err, "does not exist")
+}
+
+// namespaceAttr returns attributes for a new process in the namespaces,
+// or skips the test if the namespaces are not available on the host.
+func namespaceAttr(t *testing.T) *syscall.SysProcAttr {
+	if os.Getuid() == 0 || os.Getgid() == 0 {
+		t.Skip("namespace vm type can't be used by root")
+	}
+	attr, err := sysProcAttr()
+	require.NoError(t, err)
+	cmd := exec.Command("true")
+	cmd.SysProcAttr = attr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			t.Fatal(err)
+		}
+		t.Skipf("namespaces are not available: %v", err)
+	}
+	return attr
+}
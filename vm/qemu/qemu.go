@@ -6,6 +6,7 @@ package qemu
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -27,6 +28,7 @@ import (
 
 func init() {
 	var _ vmimpl.Infoer = (*instance)(nil)
+	var _ vmimpl.WarmBooter = (*instance)(nil)
 	vmimpl.Register("qemu", vmimpl.Type{
 		Ctor:       ctor,
 		Overcommit: true,
@@ -76,6 +78,12 @@ type Config struct {
 	Snapshot bool `json:"snapshot"`
 	// Magic key used to dongle macOS to the device.
 	AppleSmcOsk string `json:"apple_smc_osk"`
+	// Boot the VM once, save the post-boot VM state and restore subsequent instances
+	// from it instead of booting them from scratch (false by default).
+	// The saved state is kept in the workdir and is invalidated when the kernel, initrd, image,
+	// QEMU version or this config change. If restoring fails, instances are booted from scratch.
+	// Requires qemu-img, and is not compatible with 9p images and the snapshot fuzzing mode.
+	WarmStart bool `json:"warm_start"`
 }
 
 type Pool struct {
@@ -84,6 +92,7 @@ type Pool struct {
 	target     *targets.Target
 	archConfig *archConfig
 	version    string
+	warm       *warmStart
 }
 
 type instance struct {
@@ -111,6 +120,8 @@ type instance struct {
 	qemu        *exec.Cmd
 	merger      *vmimpl.OutputMerger
 	files       map[string]string
+	warm        bool   // the instance saves or restores the warm start state, image is a qcow2 overlay
+	incoming    string // file with the saved VM state to restore from
 	*snapshot
 }
 
@@ -321,6 +332,11 @@ func ctor(env *vmimpl.Env) (vmimpl.Pool, error) {
 		target:     targets.Get(env.OS, env.Arch),
 		archConfig: archConfig,
 	}
+	if cfg.WarmStart {
+		if pool.warm, err = newWarmStart(pool); err != nil {
+			return nil, err
+		}
+	}
 	return pool, nil
 }
 
@@ -361,6 +377,21 @@ func (pool *Pool) Create(workdir string, index int) (vmimpl.Instance, error) {
 }
 
 func (pool *Pool) ctor(workdir, sshkey, sshuser string, index int) (*instance, error) {
+	if pool.warm != nil {
+		inst, err := pool.warmCtor(workdir, sshkey, sshuser, index)
+		if err == nil || !errors.Is(err, errWarmStart) {
+			return inst, err
+		}
+		log.Logf(0, "VM-%v: %v, falling back to cold boot", index, err)
+	}
+	inst := pool.newInstance(workdir, sshkey, sshuser, index)
+	if err := inst.start(); err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+func (pool *Pool) newInstance(workdir, sshkey, sshuser string, index int) *instance {
 	inst := &instance{
 		index:      index,
 		cfg:        pool.cfg,
@@ -384,6 +415,11 @@ func (pool *Pool) ctor(workdir, sshkey, sshuser string, index int) (*instance, e
 		// assumes that an image is mandatory. So if the image is empty, we ignore it.
 		inst.image = ""
 	}
+	return inst
+}
+
+// start boots the instance, the instance is closed on failure.
+func (inst *instance) start() error {
 	closeInst := inst
 	defer func() {
 		if closeInst != nil {
@@ -394,15 +430,15 @@ func (pool *Pool) ctor(workdir, sshkey, sshuser string, index int) (*instance, e
 	var err error
 	inst.rpipe, inst.wpipe, err = osutil.LongPipe()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if err := inst.boot(); err != nil {
-		return nil, err
+		return err
 	}
 
 	closeInst = nil
-	return inst, nil
+	return nil
 }
 
 func (inst *instance) Close() error {
@@ -479,7 +515,17 @@ func (inst *instance) boot() error {
 		}
 	}
 
-	if err := vmimpl.WaitForSSH(inst.debug, 10*time.Minute*inst.timeouts.Scale, "localhost",
+	sshTimeout := 10 * time.Minute
+	if inst.incoming != "" {
+		if err := inst.resumeState(); err != nil {
+			bootOutputStop <- true
+			<-bootOutputStop
+			return vmimpl.MakeBootError(err, bootOutput)
+		}
+		// A restored VM is expected to respond right away.
+		sshTimeout = time.Minute
+	}
+	if err := vmimpl.WaitForSSH(inst.debug, sshTimeout*inst.timeouts.Scale, "localhost",
 		inst.sshkey, inst.sshuser, inst.os, inst.port, inst.merger.Err, false); err != nil {
 		bootOutputStop <- true
 		<-bootOutputStop
@@ -504,7 +550,13 @@ func (inst *instance) buildQemuArgs() ([]string, error) {
 		args = append(args, "-device", inst.archConfig.RngDev)
 	}
 	templateDir := filepath.Join(inst.workdir, "template")
-	args = append(args, splitArgs(inst.cfg.QemuArgs, templateDir, inst.index)...)
+	for _, arg := range splitArgs(inst.cfg.QemuArgs, templateDir, inst.index) {
+		if inst.warm {
+			// The VM state can't be saved with non-migratable CPUs.
+			arg = strings.ReplaceAll(arg, "migratable=off", "migratable=on")
+		}
+		args = append(args, arg)
+	}
 	args = append(args,
 		"-device", inst.cfg.NetDev+",netdev=net0",
 		"-netdev", fmt.Sprintf("user,id=net0,restrict=on,hostfwd=tcp:127.0.0.1:%v-:22", inst.port),
@@ -518,7 +570,7 @@ func (inst *instance) buildQemuArgs() ([]string, error) {
 		if inst.archConfig.UseNewQemuImageOptions {
 			args = append(args,
 				"-device", "virtio-blk-device,drive=hd0",
-				"-drive", fmt.Sprintf("file=%v,if=none,format=raw,id=hd0", inst.image),
+				"-drive", fmt.Sprintf("file=%v,if=none,format=%v,id=hd0", inst.image, inst.imageFormat()),
 			)
 		} else {
 			// inst.cfg.ImageDevice can contain spaces
@@ -531,7 +583,7 @@ func (inst *instance) buildQemuArgs() ([]string, error) {
 			}
 			args = append(args, imgline...)
 		}
-		if inst.cfg.Snapshot {
+		if inst.cfg.Snapshot && !inst.warm {
 			args = append(args, "-snapshot")
 		}
 	}
@@ -571,6 +623,9 @@ func (inst *instance) buildQemuArgs() ([]string, error) {
 			"-device", "isa-applesmc,osk="+inst.cfg.AppleSmcOsk,
 		)
 	}
+	if inst.incoming != "" {
+		args = append(args, "-incoming", fmt.Sprintf("exec:cat '%v'", inst.incoming))
+	}
 	if inst.snapshot != nil {
 		snapshotArgs, err := inst.snapshotEnable()
 		if err != nil {
@@ -581,6 +636,13 @@ func (inst *instance) buildQemuArgs() ([]string, error) {
 	return args, nil
 }
 
+func (inst *instance) imageFormat() string {
+	if inst.warm {
+		return "qcow2"
+	}
+	return "raw"
+}
+
 // "vfio-pci,host=BN:DN.{{FN%8}},addr=0x11".
 func handleVfioPciArg(arg string, index int) string {
 	if !strings.Contains(arg, "{{FN%8}}") {
@@ -18,14 +18,15 @@ import (
 )
 
 type snapshot struct {
-	ivsListener *net.UnixListener
-	ivsConn     *net.UnixConn
-	doorbellFD  int
-	eventFD     int
-	shmemFD     int
-	shmem       []byte
-	input       []byte
-	header      *flatrpc.SnapshotHeaderT
+	ivsListener  *net.UnixListener
+	ivsConn      *net.UnixConn
+	doorbellFD   int
+	eventFD      int
+	shmemFD      int
+	shmem        []byte
+	input        []byte
+	header       *flatrpc.SnapshotHeaderT
+	baseSnapshot bool
 }
 
 func (inst *instance) snapshotClose() {
@@ -159,17 +160,35 @@ func (inst *instance) snapshotHandshake() error {
 	return nil
 }
 
-func (inst *instance) SetupSnapshot(input []byte) error {
+func (inst *instance) SetupSnapshot(profile int, input []byte) error {
+	if !inst.baseSnapshot {
+		// Take a base snapshot while executor waits for the handshake, restoring it allows
+		// to set up more snapshots with different handshake requests later.
+		if !inst.waitSnapshotStateChange(flatrpc.SnapshotStateInitial, 10*time.Minute) ||
+			inst.header.LoadState() != flatrpc.SnapshotStateWaiting {
+			return fmt.Errorf("executor does not start\n%s", inst.readOutput())
+		}
+		if _, err := inst.hmp("migrate_set_capability x-ignore-shared on", 0); err != nil {
+			return err
+		}
+		if _, err := inst.hmp("savevm syz-base", 0); err != nil {
+			return err
+		}
+		inst.baseSnapshot = true
+	}
 	copy(inst.input, input)
 	// Tell executor that we are ready to snapshot and wait for an ack.
 	inst.header.UpdateState(flatrpc.SnapshotStateHandshake)
+	if profile != 0 {
+		// The executor has already handled a handshake, restart it from the base snapshot.
+		if _, err := inst.hmp("loadvm syz-base", 0); err != nil {
+			return fmt.Errorf("%w\n%s", err, inst.readOutput())
+		}
+	}
 	if !inst.waitSnapshotStateChange(flatrpc.SnapshotStateHandshake, 10*time.Minute) {
 		return fmt.Errorf("executor does not start snapshot handshake\n%s", inst.readOutput())
 	}
-	if _, err := inst.hmp("migrate_set_capability x-ignore-shared on", 0); err != nil {
-		return err
-	}
-	if _, err := inst.hmp("savevm syz", 0); err != nil {
+	if _, err := inst.hmp(fmt.Sprintf("savevm %v", snapshotTag(profile)), 0); err != nil {
 		return err
 	}
 	if inst.debug {
@@ -182,12 +201,13 @@ func (inst *instance) SetupSnapshot(input []byte) error {
 	return nil
 }
 
-func (inst *instance) RunSnapshot(timeout time.Duration, input []byte) (result, output []byte, err error) {
+func (inst *instance) RunSnapshot(timeout time.Duration, profile int, input []byte) (
+	result, output []byte, err error) {
 	copy(inst.input, input)
 	inst.header.OutputOffset = 0
 	inst.header.OutputSize = 0
 	inst.header.UpdateState(flatrpc.SnapshotStateExecute)
-	if _, err := inst.hmp("loadvm syz", 0); err != nil {
+	if _, err := inst.hmp(fmt.Sprintf("loadvm %v", snapshotTag(profile)), 0); err != nil {
 		return nil, nil, fmt.Errorf("%w\n%s", err, inst.readOutput())
 	}
 	inst.waitSnapshotStateChange(flatrpc.SnapshotStateExecute, timeout)
@@ -201,6 +221,10 @@ func (inst *instance) RunSnapshot(timeout time.Duration, input []byte) (result,
 	return res, output, nil
 }
 
+func snapshotTag(profile int) string {
+	return fmt.Sprintf("syz%v", profile)
+}
+
 func (inst *instance) waitSnapshotStateChange(state flatrpc.SnapshotState, timeout time.Duration) bool {
 	deadline := time.Now().Add(timeout)
 	timeoutMs := int(timeout / time.Millisecond)
@@ -7,6 +7,7 @@ package qemu
 
 import (
 	"fmt"
+	"time"
 )
 
 type snapshot struct{}
@@ -24,10 +25,11 @@ func (inst *instance) snapshotHandshake() error {
 	return errNotImplemented
 }
 
-func (inst *instance) SetupSnapshot(input []byte) error {
+func (inst *instance) SetupSnapshot(profile int, input []byte) error {
 	return errNotImplemented
 }
 
-func (inst *instance) RunSnapshot(input []byte) (result, output []byte, err error) {
+func (inst *instance) RunSnapshot(timeout time.Duration, profile int, input []byte) (
+	result, output []byte, err error) {
 	return nil, nil, errNotImplemented
 }
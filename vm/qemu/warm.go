@@ -0,0 +1,279 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package qemu
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// warmStart manages the saved post-boot VM state used to restore new instances
+// instead of booting them from scratch.
+//
+// The first instance boots from a qcow2 overlay of the image (dir/disk.qcow2), once it's up
+// the VM is stopped, its state is migrated to dir/state, and the VM is killed.
+// Then this and all subsequent instances are started with -incoming from the saved state
+// and resumed once it's loaded (the saved VM was stopped), each with own qcow2 overlay
+// on top of dir/disk.qcow2, so that disk contents match the saved state.
+type warmStart struct {
+	dir  string
+	hash string
+
+	mu       sync.Mutex
+	ready    bool
+	saving   bool
+	disabled bool
+	failures int
+}
+
+var errWarmStart = errors.New("warm start failed")
+
+const (
+	warmStateFile = "state"
+	warmDiskFile  = "disk.qcow2"
+	warmHashFile  = "hash"
+	// After this many consecutive restore failures we stop trying and always boot from scratch.
+	warmMaxFailures = 3
+)
+
+func newWarmStart(pool *Pool) (*warmStart, error) {
+	if pool.env.Image == "9p" {
+		return nil, fmt.Errorf("warm_start is not supported with 9p images")
+	}
+	if pool.env.Snapshot {
+		return nil, fmt.Errorf("warm_start is not supported in snapshot mode")
+	}
+	if strings.Contains(pool.cfg.QemuArgs, "{{") {
+		// All instances restore the same VM state, so they must have the same devices.
+		return nil, fmt.Errorf("warm_start is not supported with per-instance qemu_args templates")
+	}
+	if _, err := osutil.RunCmd(time.Minute, "", "qemu-img", "--version"); err != nil {
+		return nil, fmt.Errorf("warm_start requires qemu-img: %w", err)
+	}
+	hash, err := warmStartHash(pool)
+	if err != nil {
+		return nil, err
+	}
+	w := &warmStart{
+		dir:  filepath.Join(pool.env.Workdir, "qemu-warm"),
+		hash: hash,
+	}
+	oldHash, _ := os.ReadFile(filepath.Join(w.dir, warmHashFile))
+	if string(oldHash) == hash && osutil.IsExist(w.statePath()) {
+		w.ready = true
+		return w, nil
+	}
+	if len(oldHash) != 0 {
+		log.Logf(0, "kernel/image/config have changed, discarding saved VM state")
+	}
+	if err := os.RemoveAll(w.dir); err != nil {
+		return nil, err
+	}
+	if err := osutil.MkdirAll(w.dir); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// warmStartHash identifies everything that affects the saved VM state.
+func warmStartHash(pool *Pool) (string, error) {
+	h := sha1.New()
+	fmt.Fprintf(h, "%v\n%v/%v\n%s\n", pool.version, pool.env.OS, pool.env.Arch, pool.env.Config)
+	for _, file := range []string{pool.cfg.Kernel, pool.cfg.Initrd, pool.env.Image} {
+		if file == "" {
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %v: %w", file, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (w *warmStart) statePath() string {
+	return filepath.Join(w.dir, warmStateFile)
+}
+
+// prepare returns the saved VM state file, save is called to create it if it does not exist yet.
+// The state is saved by a single instance, other instances fail with errWarmStart meanwhile
+// (and boot from scratch) instead of waiting for the boot.
+func (w *warmStart) prepare(save func() error) (string, error) {
+	w.mu.Lock()
+	switch {
+	case w.disabled:
+		w.mu.Unlock()
+		return "", fmt.Errorf("%w: disabled after previous failures", errWarmStart)
+	case w.ready:
+		w.mu.Unlock()
+		return w.statePath(), nil
+	case w.saving:
+		w.mu.Unlock()
+		return "", fmt.Errorf("%w: VM state is being saved by another instance", errWarmStart)
+	}
+	w.saving = true
+	w.mu.Unlock()
+
+	err := save()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.saving = false
+	if err != nil {
+		if errors.Is(err, errWarmStart) {
+			w.disabled = true
+		}
+		return "", err
+	}
+	w.ready = true
+	return w.statePath(), nil
+}
+
+func (w *warmStart) restored(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err == nil {
+		w.failures = 0
+		return
+	}
+	w.failures++
+	if w.failures >= warmMaxFailures {
+		log.Logf(0, "failed to restore VMs %v times in a row, disabling warm start", w.failures)
+		w.disabled = true
+	}
+}
+
+func (pool *Pool) warmCtor(workdir, sshkey, sshuser string, index int) (*instance, error) {
+	state, err := pool.warm.prepare(func() error {
+		return pool.saveWarmState(workdir, sshkey, sshuser, index)
+	})
+	if err != nil {
+		return nil, err
+	}
+	inst := pool.newInstance(workdir, sshkey, sshuser, index)
+	inst.warm = true
+	inst.incoming = state
+	if inst.image != "" {
+		inst.image = filepath.Join(workdir, warmDiskFile)
+		if err := createOverlay(inst.image, filepath.Join(pool.warm.dir, warmDiskFile), "qcow2"); err != nil {
+			return nil, fmt.Errorf("%w: %w", errWarmStart, err)
+		}
+	}
+	err = inst.start()
+	pool.warm.restored(err)
+	if err != nil {
+		os.Remove(inst.image)
+		return nil, fmt.Errorf("%w: failed to restore VM: %w", errWarmStart, err)
+	}
+	return inst, nil
+}
+
+// saveWarmState boots a VM from scratch and saves its state.
+// Boot errors are returned as is, other errors are wrapped into errWarmStart.
+func (pool *Pool) saveWarmState(workdir, sshkey, sshuser string, index int) error {
+	log.Logf(0, "VM-%v: booting VM to save warm start state", index)
+	inst := pool.newInstance(workdir, sshkey, sshuser, index)
+	inst.warm = true
+	if inst.image != "" {
+		inst.image = filepath.Join(pool.warm.dir, warmDiskFile)
+		if err := createOverlay(inst.image, pool.env.Image, "raw"); err != nil {
+			return fmt.Errorf("%w: %w", errWarmStart, err)
+		}
+	}
+	if err := inst.start(); err != nil {
+		return err
+	}
+	defer inst.Close()
+	tmpState := pool.warm.statePath() + ".tmp"
+	if err := inst.saveState(tmpState); err != nil {
+		return fmt.Errorf("%w: %w", errWarmStart, err)
+	}
+	if err := os.Rename(tmpState, pool.warm.statePath()); err != nil {
+		return fmt.Errorf("%w: %w", errWarmStart, err)
+	}
+	if err := osutil.WriteFile(filepath.Join(pool.warm.dir, warmHashFile), []byte(pool.warm.hash)); err != nil {
+		return fmt.Errorf("%w: %w", errWarmStart, err)
+	}
+	return nil
+}
+
+func createOverlay(file, backing, backingFormat string) error {
+	os.Remove(file)
+	_, err := osutil.RunCmd(time.Minute, "", "qemu-img", "create", "-q", "-f", "qcow2",
+		"-F", backingFormat, "-b", backing, file)
+	return err
+}
+
+// saveState stops the VM and migrates its state into the file.
+func (inst *instance) saveState(file string) error {
+	if _, err := inst.qmp(&qmpCommand{Execute: "stop"}); err != nil {
+		return err
+	}
+	args := map[string]interface{}{
+		"uri": fmt.Sprintf("exec:cat > '%v'", file),
+	}
+	if _, err := inst.qmp(&qmpCommand{Execute: "migrate", Arguments: args}); err != nil {
+		return err
+	}
+	for start := time.Now(); time.Since(start) < 10*time.Minute*inst.timeouts.Scale; {
+		res, err := inst.qmp(&qmpCommand{Execute: "query-migrate"})
+		if err != nil {
+			return err
+		}
+		info, _ := res.(map[string]interface{})
+		switch status, _ := info["status"].(string); status {
+		case "completed":
+			return nil
+		case "failed", "cancelled":
+			return fmt.Errorf("VM state migration %v: %v", status, info["error-desc"])
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("VM state migration timed out")
+}
+
+// resumeState waits for the VM state to be restored and resumes the VM.
+// The state is saved from a stopped VM, so the restored VM is paused as well.
+func (inst *instance) resumeState() error {
+	for start := time.Now(); time.Since(start) < 10*time.Minute*inst.timeouts.Scale; {
+		res, err := inst.qmp(&qmpCommand{Execute: "query-status"})
+		if err != nil {
+			return err
+		}
+		info, _ := res.(map[string]interface{})
+		switch status, _ := info["status"].(string); status {
+		case "running":
+			return nil
+		case "inmigrate":
+		case "paused", "postmigrate":
+			if _, err := inst.qmp(&qmpCommand{Execute: "cont"}); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected VM status after restore: %v", status)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("VM state restore timed out")
+}
+
+func (inst *instance) WarmBooted() bool {
+	return inst.incoming != ""
+}
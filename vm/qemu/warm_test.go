@@ -0,0 +1,100 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package qemu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmStartPrepare(t *testing.T) {
+	w := &warmStart{dir: t.TempDir()}
+	saving := make(chan bool)
+	done := make(chan error)
+	go func() {
+		_, err := w.prepare(func() error {
+			saving <- true
+			<-saving
+			return nil
+		})
+		done <- err
+	}()
+	<-saving
+	// Other instances don't wait for the boot while the state is being saved.
+	_, err := w.prepare(func() error {
+		t.Fatal("the state is saved twice")
+		return nil
+	})
+	assert.True(t, errors.Is(err, errWarmStart), "%v", err)
+	saving <- true
+	require.NoError(t, <-done)
+	state, err := w.prepare(nil)
+	require.NoError(t, err)
+	assert.Equal(t, w.statePath(), state)
+
+	// Boot errors are returned as is and the state is saved again next time,
+	// but warm start is disabled after failures to save the state.
+	w = &warmStart{dir: t.TempDir()}
+	bootErr := errors.New("boot failed")
+	_, err = w.prepare(func() error { return bootErr })
+	assert.Equal(t, bootErr, err)
+	_, err = w.prepare(func() error { return fmt.Errorf("%w: save failed", errWarmStart) })
+	assert.True(t, errors.Is(err, errWarmStart), "%v", err)
+	_, err = w.prepare(nil)
+	assert.ErrorContains(t, err, "disabled after previous failures")
+}
+
+func TestWarmStartResume(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	// Fake QMP server of a VM that loads the saved state and stays paused until "cont".
+	var commands []string
+	done := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+		dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+		enc.Encode(map[string]interface{}{"QMP": map[string]interface{}{}})
+		status := "inmigrate"
+		for {
+			cmd := new(qmpCommand)
+			if err := dec.Decode(cmd); err != nil {
+				done <- nil
+				return
+			}
+			commands = append(commands, cmd.Execute)
+			var ret interface{} = map[string]interface{}{}
+			switch cmd.Execute {
+			case "query-status":
+				ret = map[string]interface{}{"status": status}
+				if status == "inmigrate" {
+					status = "paused"
+				}
+			case "cont":
+				status = "running"
+			}
+			enc.Encode(map[string]interface{}{"return": ret})
+		}
+	}()
+	inst := &instance{
+		monport:  ln.Addr().(*net.TCPAddr).Port,
+		timeouts: targets.Timeouts{Scale: 1},
+	}
+	require.NoError(t, inst.resumeState())
+	inst.mon.Close()
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"qmp_capabilities", "query-status", "query-status", "cont", "query-status"}, commands)
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package vm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/record"
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/pkg/stat"
+	"github.com/google/syzkaller/vm/vmimpl"
+)
+
+// replayParams are parameters of Instance.Run required to replay the recording.
+type replayParams struct {
+	Exit     ExitCondition
+	NoOutput time.Duration
+}
+
+// Replay feeds a recording of Instance.Run (console output, executed programs notifications,
+// command exit status and diagnosis output) through the same crash detection logic again.
+// Recorded timings are preserved, but are sped up speed times (including the no output timeout).
+// Returns the console output and the crash report (nil if there is no crash).
+func Replay(cfg *mgrconfig.Config, reporter *report.Reporter, events []*record.Event, speed float64) (
+	[]byte, *report.Report, error) {
+	if speed <= 0 {
+		return nil, nil, fmt.Errorf("bad replay speed %v", speed)
+	}
+	var params *replayParams
+	for _, ev := range events {
+		if ev.Kind == record.KindRun {
+			params = new(replayParams)
+			if err := json.Unmarshal(ev.Data, params); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse run parameters: %w", err)
+			}
+			break
+		}
+	}
+	if params == nil {
+		return nil, nil, fmt.Errorf("the recording does not contain VM run parameters")
+	}
+	timeouts := cfg.Timeouts
+	timeouts.Scale = 1
+	timeouts.NoOutput = time.Duration(float64(params.NoOutput) / speed)
+	pool := &Pool{
+		typ:      vmimpl.Types[vmType(cfg.Type)],
+		timeouts: timeouts,
+		statOutputReceived: stat.New("vm output", "Bytes of VM console output received",
+			stat.Graph("traffic"), stat.Rate{}, stat.FormatMB),
+	}
+	injected := make(chan bool)
+	impl := &replayInstance{
+		events:   events,
+		speed:    speed,
+		injected: injected,
+	}
+	inst := &Instance{
+		pool: pool,
+		impl: impl,
+	}
+	defer impl.Close()
+	return inst.Run(0, reporter, "", params.Exit, InjectExecuting(injected))
+}
+
+type replayInstance struct {
+	events   []*record.Event
+	speed    float64
+	injected chan<- bool
+	diagnose []*record.Event
+	stop     chan bool
+}
+
+func (inst *replayInstance) Copy(hostSrc string) (string, error) {
+	return "", fmt.Errorf("not supported for replay")
+}
+
+func (inst *replayInstance) Forward(port int) (string, error) {
+	return "", fmt.Errorf("not supported for replay")
+}
+
+func (inst *replayInstance) Run(timeout time.Duration, stop <-chan bool, command string) (
+	<-chan []byte, <-chan error, error) {
+	// The channels are unbuffered to preserve the recorded order of events.
+	outc := make(chan []byte)
+	errc := make(chan error)
+	inst.stop = make(chan bool)
+	for _, ev := range inst.events {
+		if ev.Kind == record.KindDiagnose {
+			inst.diagnose = append(inst.diagnose, ev)
+		}
+	}
+	go func() {
+		defer close(outc)
+		start := time.Now()
+		var base time.Duration
+		for _, ev := range inst.events {
+			if ev.Kind == record.KindTruncated {
+				// Don't wait for the dropped part of the recording.
+				base = ev.Time
+				continue
+			}
+			if ev.Kind != record.KindConsole && ev.Kind != record.KindExecuting && ev.Kind != record.KindExit {
+				continue
+			}
+			if delay := time.Duration(float64(ev.Time-base)/inst.speed) - time.Since(start); delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-inst.stop:
+					return
+				}
+			}
+			var ok bool
+			switch ev.Kind {
+			case record.KindConsole:
+				ok = send(outc, ev.Data, inst.stop)
+			case record.KindExecuting:
+				ok = send(inst.injected, true, inst.stop)
+			case record.KindExit:
+				ok = send(errc, replayError(string(ev.Data)), inst.stop)
+			}
+			if !ok {
+				return
+			}
+		}
+	}()
+	return outc, errc, nil
+}
+
+func send[T any](c chan<- T, v T, stop <-chan bool) bool {
+	select {
+	case c <- v:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+func replayError(msg string) error {
+	switch msg {
+	case "":
+		return nil
+	case ErrTimeout.Error():
+		return ErrTimeout
+	default:
+		return errors.New(msg)
+	}
+}
+
+func (inst *replayInstance) Diagnose(rep *report.Report) ([]byte, bool) {
+	if len(inst.diagnose) == 0 {
+		return nil, false
+	}
+	ev := inst.diagnose[0]
+	inst.diagnose = inst.diagnose[1:]
+	// If the original diagnosis went to the console, it's part of the recorded console output.
+	return ev.Data, len(ev.Data) == 0
+}
+
+func (inst *replayInstance) Close() error {
+	if inst.stop != nil {
+		close(inst.stop)
+	}
+	return nil
+}
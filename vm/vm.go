@@ -22,6 +22,7 @@ import (
 
 	"github.com/google/syzkaller/pkg/mgrconfig"
 	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/record"
 	"github.com/google/syzkaller/pkg/report"
 	"github.com/google/syzkaller/pkg/stat"
 	"github.com/google/syzkaller/sys/targets"
@@ -35,6 +36,7 @@ import (
 	_ "github.com/google/syzkaller/vm/gce"
 	_ "github.com/google/syzkaller/vm/gvisor"
 	_ "github.com/google/syzkaller/vm/isolated"
+	_ "github.com/google/syzkaller/vm/namespace"
 	_ "github.com/google/syzkaller/vm/proxyapp"
 	_ "github.com/google/syzkaller/vm/qemu"
 	_ "github.com/google/syzkaller/vm/starnix"
@@ -52,15 +54,17 @@ type Pool struct {
 	snapshot           bool
 	hostFuzzer         bool
 	statOutputReceived *stat.Val
+	statBootTime       *stat.Val
+	statWarmBoots      *stat.Val
 }
 
 type Instance struct {
-	pool          *Pool
-	impl          vmimpl.Instance
-	workdir       string
-	index         int
-	snapshotSetup bool
-	onClose       func()
+	pool             *Pool
+	impl             vmimpl.Instance
+	workdir          string
+	index            int
+	snapshotProfiles int
+	onClose          func()
 }
 
 var (
@@ -141,6 +145,10 @@ func Create(cfg *mgrconfig.Config, debug bool) (*Pool, error) {
 		hostFuzzer: cfg.SysTarget.HostFuzzer,
 		statOutputReceived: stat.New("vm output", "Bytes of VM console output received",
 			stat.Graph("traffic"), stat.Rate{}, stat.FormatMB),
+		statBootTime: stat.New("vm boot time", "Time to create and boot a VM (sec)",
+			stat.Distribution{}),
+		statWarmBoots: stat.New("vm warm boots", "Number of VMs restored from a saved post-boot state",
+			stat.Graph("vm boots")),
 	}, nil
 }
 
@@ -161,11 +169,16 @@ func (pool *Pool) Create(index int) (*Instance, error) {
 			return nil, err
 		}
 	}
+	start := time.Now()
 	impl, err := pool.impl.Create(workdir, index)
 	if err != nil {
 		os.RemoveAll(workdir)
 		return nil, err
 	}
+	pool.statBootTime.Add(int(time.Since(start) / time.Second))
+	if warm, ok := impl.(vmimpl.WarmBooter); ok && warm.WarmBooted() {
+		pool.statWarmBoots.Add(1)
+	}
 	atomic.AddInt32(&pool.activeCount, 1)
 	return &Instance{
 		pool:    pool,
@@ -189,40 +202,42 @@ func (pool *Pool) Close() error {
 	return nil
 }
 
-// SetupSnapshot must be called once before calling RunSnapshot.
+// SetupSnapshot must be called once for each profile before calling RunSnapshot with the profile.
 // Input is copied into the VM in an implementation defined way and is interpreted by executor.
-func (inst *Instance) SetupSnapshot(input []byte) error {
+// Profiles are small consecutive integers starting from 0, each profile has own snapshot
+// that allows to use different executor setup (e.g. env flags) in the same VM.
+func (inst *Instance) SetupSnapshot(profile int, input []byte) error {
 	impl, ok := inst.impl.(snapshotter)
 	if !ok {
 		return errors.New("this VM type does not support snapshot mode")
 	}
-	if inst.snapshotSetup {
-		return fmt.Errorf("SetupSnapshot called twice")
+	if profile != inst.snapshotProfiles {
+		return fmt.Errorf("SetupSnapshot called for profile %v, want %v", profile, inst.snapshotProfiles)
 	}
-	inst.snapshotSetup = true
-	return impl.SetupSnapshot(input)
+	inst.snapshotProfiles++
+	return impl.SetupSnapshot(profile, input)
 }
 
 // RunSnapshot runs one input in snapshotting mode.
 // Input is copied into the VM in an implementation defined way and is interpreted by executor.
 // Result is the result provided by the executor.
 // Output is the kernel console output during execution of the input.
-func (inst *Instance) RunSnapshot(input []byte) (result, output []byte, err error) {
+func (inst *Instance) RunSnapshot(profile int, input []byte) (result, output []byte, err error) {
 	impl, ok := inst.impl.(snapshotter)
 	if !ok {
 		return nil, nil, errors.New("this VM type does not support snapshot mode")
 	}
-	if !inst.snapshotSetup {
-		return nil, nil, fmt.Errorf("RunSnapshot without SetupSnapshot")
+	if profile < 0 || profile >= inst.snapshotProfiles {
+		return nil, nil, fmt.Errorf("RunSnapshot without SetupSnapshot for profile %v", profile)
 	}
 	// Executor has own timeout logic, so use a slightly larger timeout here.
 	timeout := inst.pool.timeouts.Program / 5 * 7
-	return impl.RunSnapshot(timeout, input)
+	return impl.RunSnapshot(timeout, profile, input)
 }
 
 type snapshotter interface {
-	SetupSnapshot([]byte) error
-	RunSnapshot(time.Duration, []byte) ([]byte, []byte, error)
+	SetupSnapshot(int, []byte) error
+	RunSnapshot(time.Duration, int, []byte) ([]byte, []byte, error)
 }
 
 func (inst *Instance) Copy(hostSrc string) (string, error) {
@@ -258,12 +273,14 @@ type EarlyFinishCb func()
 //   - StopContext: the context to be used to prematurely stop the command
 //   - ExitCondition: says which exit modes should be considered as errors/OK
 //   - OutputSize: how much output to keep/return
+//   - *record.Recorder: where to record console output and other events for later replay
 func (inst *Instance) Run(timeout time.Duration, reporter *report.Reporter, command string, opts ...any) (
 	[]byte, *report.Report, error) {
 	exit := ExitNormal
 	var stop <-chan bool
 	var injected <-chan bool
 	var finished func()
+	var rec *record.Recorder
 	outputSize := beforeContextDefault
 	for _, o := range opts {
 		switch opt := o.(type) {
@@ -282,6 +299,8 @@ func (inst *Instance) Run(timeout time.Duration, reporter *report.Reporter, comm
 			injected = (<-chan bool)(opt)
 		case EarlyFinishCb:
 			finished = opt
+		case *record.Recorder:
+			rec = opt
 		default:
 			panic(fmt.Sprintf("unknown option %#v", opt))
 		}
@@ -290,18 +309,25 @@ func (inst *Instance) Run(timeout time.Duration, reporter *report.Reporter, comm
 	if err != nil {
 		return nil, nil, err
 	}
+	rec.RecordJSON(record.KindRun, &replayParams{Exit: exit, NoOutput: inst.pool.timeouts.NoOutput})
 	mon := &monitor{
 		inst:            inst,
 		outc:            outc,
 		injected:        injected,
 		errc:            errc,
 		finished:        finished,
+		rec:             rec,
 		reporter:        reporter,
 		beforeContext:   outputSize,
 		exit:            exit,
 		lastExecuteTime: time.Now(),
 	}
 	rep := mon.monitorExecution()
+	title := ""
+	if rep != nil {
+		title = rep.Title
+	}
+	rec.Record(record.KindReport, []byte(title))
 	return mon.output, rep, nil
 }
 
@@ -346,6 +372,7 @@ type monitor struct {
 	injected        <-chan bool
 	finished        func()
 	errc            <-chan error
+	rec             *record.Recorder
 	reporter        *report.Reporter
 	exit            ExitCondition
 	output          []byte
@@ -366,6 +393,7 @@ func (mon *monitor) monitorExecution() *report.Report {
 	for {
 		select {
 		case err := <-mon.errc:
+			mon.recordExit(err)
 			switch err {
 			case nil:
 				// The program has exited without errors,
@@ -395,10 +423,12 @@ func (mon *monitor) monitorExecution() *report.Report {
 				continue
 			}
 			mon.inst.pool.statOutputReceived.Add(len(out))
+			mon.rec.Record(record.KindConsole, out)
 			if rep, done := mon.appendOutput(out); done {
 				return rep
 			}
 		case <-mon.injected:
+			mon.rec.Record(record.KindExecuting, nil)
 			mon.lastExecuteTime = time.Now()
 		case <-ticker.C:
 			// Detect both "no output whatsoever" and "kernel episodically prints
@@ -412,6 +442,20 @@ func (mon *monitor) monitorExecution() *report.Report {
 	}
 }
 
+func (mon *monitor) recordExit(err error) {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	mon.rec.Record(record.KindExit, []byte(msg))
+}
+
+func (mon *monitor) diagnose(defaultError string) ([]byte, bool) {
+	output, wait := mon.inst.diagnose(mon.createReport(defaultError))
+	mon.rec.Record(record.KindDiagnose, output)
+	return output, wait
+}
+
 func (mon *monitor) appendOutput(out []byte) (*report.Report, bool) {
 	lastPos := len(mon.output)
 	mon.output = append(mon.output, out...)
@@ -456,7 +500,7 @@ func (mon *monitor) extractError(defaultError string) *report.Report {
 	}
 	diagOutput, diagWait := []byte{}, false
 	if defaultError != "" {
-		diagOutput, diagWait = mon.inst.diagnose(mon.createReport(defaultError))
+		diagOutput, diagWait = mon.diagnose(defaultError)
 	}
 	// Give it some time to finish writing the error message.
 	// But don't wait for "no output", we already waited enough.
@@ -470,7 +514,7 @@ func (mon *monitor) extractError(defaultError string) *report.Report {
 	}
 	if defaultError == "" && mon.reporter.ContainsCrash(mon.output[mon.matchPos:]) {
 		// We did not call Diagnose above because we thought there is no error, so call it now.
-		diagOutput, diagWait = mon.inst.diagnose(mon.createReport(defaultError))
+		diagOutput, diagWait = mon.diagnose(defaultError)
 		if diagWait {
 			mon.waitForOutput()
 		}
@@ -521,6 +565,7 @@ func (mon *monitor) waitForOutput() {
 			if !ok {
 				return
 			}
+			mon.rec.Record(record.KindConsole, out)
 			mon.output = append(mon.output, out...)
 		case <-timer.C:
 			return
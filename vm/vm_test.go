@@ -6,10 +6,12 @@ package vm
 import (
 	"bytes"
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/google/syzkaller/pkg/mgrconfig"
+	"github.com/google/syzkaller/pkg/record"
 	"github.com/google/syzkaller/pkg/report"
 	"github.com/google/syzkaller/sys/targets"
 	"github.com/google/syzkaller/vm/vmimpl"
@@ -434,3 +436,87 @@ func TestVMType(t *testing.T) {
 		}
 	}
 }
+
+func TestReplay(t *testing.T) {
+	for _, test := range []*Test{
+		tests[1],
+		{
+			Name: "crash-after-exit",
+			Exit: ExitNormal,
+			Body: func(outc chan []byte, errc chan error) {
+				outc <- []byte("some output\n")
+				errc <- nil
+				outc <- []byte("BUG: bad\n")
+			},
+		},
+		{
+			Name: "no-output",
+			Body: func(outc chan []byte, errc chan error) {
+				outc <- []byte("some output\n")
+			},
+		},
+	} {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+			testReplay(t, test)
+		})
+	}
+}
+
+func testReplay(t *testing.T, test *Test) {
+	dir := t.TempDir()
+	cfg := &mgrconfig.Config{
+		Derived: mgrconfig.Derived{
+			TargetOS:     targets.Linux,
+			TargetArch:   targets.AMD64,
+			TargetVMArch: targets.AMD64,
+			Timeouts: targets.Timeouts{
+				Scale:    1,
+				Slowdown: 1,
+				NoOutput: 5 * time.Second,
+			},
+			SysTarget: targets.Get(targets.Linux, targets.AMD64),
+		},
+		Workdir: dir,
+		Type:    "test",
+	}
+	pool, err := Create(cfg, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	reporter, err := report.NewReporter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := pool.Create(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inst.Close()
+	rec, err := record.New(filepath.Join(dir, "record"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testInst := inst.impl.(*testInstance)
+	go test.Body(testInst.outc, testInst.errc)
+	_, rep, err := inst.Run(time.Second, reporter, "", test.Exit, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	events, err := record.Load(rec.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, replayed, err := Replay(cfg, reporter, events, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (rep == nil) != (replayed == nil) || rep != nil && rep.Title != replayed.Title {
+		t.Fatalf("recorded report %+v, replayed report %+v", rep, replayed)
+	}
+}
@@ -67,6 +67,13 @@ type Infoer interface {
 	Info() ([]byte, error)
 }
 
+// WarmBooter is an optional interface that can be implemented by Instance.
+type WarmBooter interface {
+	// WarmBooted says if the instance was restored from a saved post-boot state
+	// instead of being booted from scratch.
+	WarmBooted() bool
+}
+
 // Env contains global constant parameters for a pool of VMs.
 type Env struct {
 	// Unique name